@@ -0,0 +1,151 @@
+package attest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/baowuhe/go-dkci/checksum"
+)
+
+// AttestationSuffix is appended to an archive name to name its attestation document
+const AttestationSuffix = ".att.json"
+
+// Attestation records where and how an image archive was produced
+type Attestation struct {
+	Image         string            `json:"image"`
+	ImageID       string            `json:"imageId"`
+	RepoDigests   []string          `json:"repoDigests,omitempty"`
+	Archive       string            `json:"archive"`
+	ArchiveSHA256 string            `json:"archiveSha256"`
+	Builder       string            `json:"builder"`
+	ExportedAt    time.Time         `json:"exportedAt"`
+	Version       string            `json:"goDkciVersion"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+var (
+	// enabled makes exports write an attestation next to every archive
+	enabled bool
+	// toolVersion is the go-dkci version recorded in the attestations
+	toolVersion string
+	// annotations are the user-supplied key/value pairs recorded in the attestations
+	annotations map[string]string
+)
+
+// Enable makes exports write an attestation document next to every archive, recording the given go-dkci
+// version and annotations
+func Enable(version string, userAnnotations map[string]string) {
+	enabled = true
+	toolVersion = version
+	annotations = userAnnotations
+}
+
+// Enabled reports whether attestations are written for exported archives
+func Enabled() bool {
+	return enabled
+}
+
+// File returns the path of the attestation belonging to an archive
+func File(archivePath string) string {
+	return archivePath + AttestationSuffix
+}
+
+// ParseAnnotations parses key=value pairs into a map
+func ParseAnnotations(pairs []string) (map[string]string, error) {
+	result := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid annotation %q (expected key=value)", pair)
+		}
+		result[strings.TrimSpace(key)] = value
+	}
+	return result, nil
+}
+
+// Write records the provenance of an exported archive next to it and returns the attestation path
+func Write(archivePath, imageName, imageID string, repoDigests []string) (string, error) {
+	digest, err := fileSHA256(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %s: %v", archivePath, err)
+	}
+
+	builder, err := os.Hostname()
+	if err != nil {
+		builder = "unknown"
+	}
+
+	attestation := Attestation{
+		Image:         imageName,
+		ImageID:       imageID,
+		RepoDigests:   repoDigests,
+		Archive:       filepath.Base(archivePath),
+		ArchiveSHA256: digest,
+		Builder:       builder,
+		ExportedAt:    time.Now().UTC(),
+		Version:       toolVersion,
+		Annotations:   annotations,
+	}
+
+	data, err := json.MarshalIndent(attestation, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	attestationPath := File(archivePath)
+	if err := os.WriteFile(attestationPath, append(data, '\n'), 0644); err != nil {
+		return "", err
+	}
+	return attestationPath, nil
+}
+
+// Read loads an attestation document
+func Read(attestationPath string) (*Attestation, error) {
+	data, err := os.ReadFile(attestationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var attestation Attestation
+	if err := json.Unmarshal(data, &attestation); err != nil {
+		return nil, fmt.Errorf("invalid attestation %s: %v", attestationPath, err)
+	}
+	return &attestation, nil
+}
+
+// Print writes the attestation in a human-readable form
+func (a *Attestation) Print() {
+	fmt.Printf("Image:          %s\n", a.Image)
+	fmt.Printf("Image ID:       %s\n", a.ImageID)
+	for _, digest := range a.RepoDigests {
+		fmt.Printf("Repo digest:    %s\n", digest)
+	}
+	fmt.Printf("Archive:        %s\n", a.Archive)
+	fmt.Printf("Archive SHA256: %s\n", a.ArchiveSHA256)
+	fmt.Printf("Builder:        %s\n", a.Builder)
+	fmt.Printf("Exported at:    %s\n", a.ExportedAt.Local().Format("2006-01-02 15:04:05"))
+	fmt.Printf("go-dkci:        %s\n", a.Version)
+
+	keys := make([]string, 0, len(a.Annotations))
+	for key := range a.Annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("Annotation:     %s=%s\n", key, a.Annotations[key])
+	}
+}
+
+// fileSHA256 returns the hex SHA-256 digest of a file, without reading it again if it was hashed while written
+func fileSHA256(filePath string) (string, error) {
+	sums, err := checksum.File(filePath)
+	if err != nil {
+		return "", err
+	}
+	return sums.SHA256, nil
+}
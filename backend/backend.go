@@ -0,0 +1,236 @@
+package backend
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/docker"
+	"github.com/baowuhe/go-dkci/i18n"
+)
+
+// Endpoint is a place an image archive is read from or written to, given as "<scheme>:<location>"
+type Endpoint interface {
+	// Read opens the archive the endpoint refers to and returns it together with a file name for it
+	Read() (io.ReadCloser, string, error)
+	// Write stores an archive at the endpoint, under name if the endpoint is a folder, and returns where it went
+	Write(archive io.Reader, name string) (string, error)
+	// String returns the endpoint as given on the command line
+	String() string
+}
+
+// prefixes maps the endpoint prefixes to the constructors of their endpoints
+var prefixes = []struct {
+	prefix string
+	create func(location, ref string) Endpoint
+}{
+	{"docker:", func(location, ref string) Endpoint { return &dockerEndpoint{image: location, ref: ref} }},
+	{"file:", func(location, ref string) Endpoint { return &fileEndpoint{path: location, ref: ref} }},
+	{"bdfs:", func(location, ref string) Endpoint { return &bdfsEndpoint{path: location, ref: ref} }},
+	{"oci-dir:", func(location, ref string) Endpoint { return &ociDirEndpoint{dir: location, ref: ref} }},
+	{"s3://", func(location, ref string) Endpoint { return &s3Endpoint{url: ref} }},
+}
+
+// Parse returns the endpoint of a reference such as "docker:nginx:1.26", "file:/srv/images/",
+// "bdfs:/docker-images/a.tar", "oci-dir:/out/nginx" or "s3://bucket/images/". A location ending in '/'
+// names a folder the archive is written into.
+func Parse(ref string) (Endpoint, error) {
+	for _, p := range prefixes {
+		if location, ok := strings.CutPrefix(ref, p.prefix); ok {
+			return p.create(location, ref), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown endpoint %q (expected docker:, file:, bdfs:, oci-dir: or s3://)", ref)
+}
+
+// Prefixes returns the prefixes of the supported endpoints, such as "docker:" and "s3://"
+func Prefixes() []string {
+	names := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		names[i] = p.prefix
+	}
+	return names
+}
+
+// IsEndpoint reports whether ref starts with the prefix of an endpoint
+func IsEndpoint(ref string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(ref, p.prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Copy copies an image archive from one endpoint to another, streaming it where both ends allow
+func Copy(sourceRef, targetRef string) {
+	source, err := Parse(sourceRef)
+	if err != nil {
+		i18n.Printf("[x] Error: %v\n", err)
+		os.Exit(1)
+	}
+	target, err := Parse(targetRef)
+	if err != nil {
+		i18n.Printf("[x] Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Copying %s to %s...\n", source, target)
+	reader, name, err := source.Read()
+	if err != nil {
+		i18n.Printf("[x] Failed to read %s: %v\n", source, err)
+		os.Exit(1)
+	}
+
+	counter := docker.NewCountingReader(reader)
+	location, err := target.Write(counter, name)
+	if err == nil {
+		// Docker may stop reading at the end of the tar; drain the padding so command-backed sources exit cleanly
+		_, err = io.Copy(io.Discard, counter)
+	}
+	if closeErr := reader.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to read %s: %v", source, closeErr)
+	}
+	if err != nil {
+		i18n.Printf("[x] Failed to copy %s to %s: %v\n", source, target, err)
+		os.Exit(1)
+	}
+
+	i18n.Printf("[√] Successfully copied %s to %s (%s)\n", source, location, docker.FormatSize(counter.Count()))
+}
+
+// dockerEndpoint is an image of the local Docker daemon; as a target, the archive is loaded into the daemon
+type dockerEndpoint struct {
+	image string
+	ref   string
+}
+
+func (e *dockerEndpoint) String() string {
+	return e.ref
+}
+
+func (e *dockerEndpoint) Read() (io.ReadCloser, string, error) {
+	if e.image == "" {
+		return nil, "", fmt.Errorf("no image given (expected docker:<image>)")
+	}
+	if err := docker.CheckImageAllowed(e.image); err != nil {
+		return nil, "", err
+	}
+	cli, err := docker.NewClient("")
+	if err != nil {
+		return nil, "", err
+	}
+
+	ctx, cancel := docker.CallContext()
+	imageInspect, _, err := cli.ImageInspectWithRaw(ctx, e.image)
+	cancel()
+	if err != nil {
+		cli.Close()
+		return nil, "", err
+	}
+	name, err := docker.ArchiveName(e.image, imageInspect.Os, imageInspect.Architecture, imageInspect.ID)
+	if err != nil {
+		cli.Close()
+		return nil, "", err
+	}
+
+	saveCtx, watchdog := docker.StreamContext()
+	imageReader, err := cli.ImageSave(saveCtx, []string{e.image})
+	if err != nil {
+		watchdog.Stop()
+		cli.Close()
+		return nil, "", watchdog.Err(err)
+	}
+	watched := watchdog.ReadCloser(imageReader)
+	return &readCloser{Reader: watched, closers: []io.Closer{watched, cli}}, name, nil
+}
+
+func (e *dockerEndpoint) Write(archive io.Reader, name string) (string, error) {
+	cli, err := docker.NewClient("")
+	if err != nil {
+		return "", err
+	}
+	defer cli.Close()
+
+	refs, err := docker.LoadStream(cli, archive)
+	if err != nil {
+		return "", err
+	}
+	if len(refs) > 0 {
+		return "Docker as " + strings.Join(refs, ", "), nil
+	}
+	return "Docker", nil
+}
+
+// fileEndpoint is a local archive, or a local folder when writing
+type fileEndpoint struct {
+	path string
+	ref  string
+}
+
+func (e *fileEndpoint) String() string {
+	return e.ref
+}
+
+func (e *fileEndpoint) Read() (io.ReadCloser, string, error) {
+	if info, err := os.Stat(e.path); err == nil && info.IsDir() {
+		return nil, "", fmt.Errorf("%s is a directory, not an archive", e.path)
+	}
+	file, err := os.Open(e.path)
+	if err != nil {
+		return nil, "", err
+	}
+	return file, filepath.Base(e.path), nil
+}
+
+func (e *fileEndpoint) Write(archive io.Reader, name string) (string, error) {
+	filePath := e.path
+	if info, err := os.Stat(filePath); strings.HasSuffix(filePath, "/") || err == nil && info.IsDir() {
+		filePath = filepath.Join(filePath, name)
+	}
+	if err := writeFile(filePath, archive); err != nil {
+		return "", err
+	}
+	return filePath, nil
+}
+
+// writeFile writes a stream to filePath, creating its directory, and removes the partial file on failure
+func writeFile(filePath string, reader io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	outFile, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", filePath, err)
+	}
+	config.Track(filePath)
+
+	_, err = io.Copy(outFile, reader)
+	if closeErr := outFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(filePath)
+		return fmt.Errorf("failed to write %s: %v", filePath, err)
+	}
+	return nil
+}
+
+// readCloser reads from a stream and closes everything it depends on
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *readCloser) Close() error {
+	var firstErr error
+	for _, closer := range r.closers {
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
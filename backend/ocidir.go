@@ -0,0 +1,163 @@
+package backend
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/baowuhe/go-dkci/docker"
+)
+
+// ociLayoutFile marks the root of an OCI image layout
+const ociLayoutFile = "oci-layout"
+
+// ociDirEndpoint is an unpacked image archive. Docker 25 and later save OCI image layouts, so the folder can be
+// used with OCI tools such as skopeo or crane; it stays loadable by Docker because the archive's manifest.json
+// is kept as well.
+type ociDirEndpoint struct {
+	dir string
+	ref string
+}
+
+func (e *ociDirEndpoint) String() string {
+	return e.ref
+}
+
+// Read packs the folder into a tar stream
+func (e *ociDirEndpoint) Read() (io.ReadCloser, string, error) {
+	info, err := os.Stat(e.dir)
+	if err != nil {
+		return nil, "", err
+	}
+	if !info.IsDir() {
+		return nil, "", fmt.Errorf("%s is not a directory", e.dir)
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(packDir(e.dir, writer))
+	}()
+	return reader, filepath.Base(filepath.Clean(e.dir)) + ".tar", nil
+}
+
+// Write unpacks the archive into the folder
+func (e *ociDirEndpoint) Write(archive io.Reader, name string) (string, error) {
+	if err := os.MkdirAll(e.dir, 0755); err != nil {
+		return "", err
+	}
+
+	// Archives copied from files may be compressed
+	reader, err := docker.Decompress(archive)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	if err := unpackTar(reader, e.dir); err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filepath.Join(e.dir, ociLayoutFile)); err != nil {
+		fmt.Printf("Warning: %s has no %s file; the archive was saved by Docker before 25 and is not an OCI layout\n", e.dir, ociLayoutFile)
+	}
+	return e.dir, nil
+}
+
+// unpackTar extracts directories, regular files and symbolic links of a tar stream into dir. Entries and link
+// targets leaving dir are rejected.
+func unpackTar(reader io.Reader, dir string) error {
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %v", err)
+		}
+
+		name := filepath.FromSlash(strings.TrimSuffix(header.Name, "/"))
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("archive entry %q leaves the target directory", header.Name)
+		}
+		target := filepath.Join(dir, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(target, tarReader); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if !filepath.IsLocal(filepath.Join(filepath.Dir(name), filepath.FromSlash(header.Linkname))) {
+				return fmt.Errorf("archive link %q points outside the target directory", header.Name)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			os.Remove(target)
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		default:
+			fmt.Printf("Warning: Skipping archive entry %s of unsupported type\n", header.Name)
+		}
+	}
+}
+
+// packDir writes the contents of dir to w as a tar stream, with names relative to dir
+func packDir(dir string, w io.Writer) error {
+	tarWriter := tar.NewWriter(w)
+	err := filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || filePath == dir {
+			return err
+		}
+		relativePath, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		linkname := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if linkname, err = os.Readlink(filePath); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, linkname)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relativePath)
+		if entry.IsDir() {
+			header.Name += "/"
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tarWriter, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tarWriter.Close()
+}
@@ -0,0 +1,150 @@
+package backend
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/baowuhe/go-dkci/cloud"
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/docker"
+)
+
+// bdfsEndpoint is a file or folder of Baidu cloud. The Baidu API only transfers files, so archives are staged
+// in the temporary directory of go-dkci.
+type bdfsEndpoint struct {
+	path   string
+	ref    string
+	client cloud.Storage
+}
+
+func (e *bdfsEndpoint) String() string {
+	return e.ref
+}
+
+// login logs in to Baidu cloud the first time it is needed
+func (e *bdfsEndpoint) login() cloud.Storage {
+	if e.client == nil {
+		e.client = cloud.Login()
+	}
+	return e.client
+}
+
+func (e *bdfsEndpoint) Read() (io.ReadCloser, string, error) {
+	bdfsClient := e.login()
+
+	// Delta recipes and split manifests are rebuilt into the archive they describe
+	name := docker.TrimIndexSuffix(path.Base(e.path))
+	tempDir, err := stagingTempDir()
+	if err != nil {
+		return nil, "", err
+	}
+	localFilePath := filepath.Join(tempDir, name)
+
+	fmt.Printf("Downloading %s...\n", e.path)
+	if err := cloud.DownloadArchive(bdfsClient, e.path, localFilePath); err != nil {
+		os.RemoveAll(tempDir)
+		return nil, "", err
+	}
+
+	file, err := os.Open(localFilePath)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return nil, "", err
+	}
+	return &readCloser{Reader: file, closers: []io.Closer{file, removeAll(tempDir)}}, name, nil
+}
+
+func (e *bdfsEndpoint) Write(archive io.Reader, name string) (string, error) {
+	bdfsClient := e.login()
+
+	remoteFilePath := e.path
+	if strings.HasSuffix(remoteFilePath, "/") {
+		remoteFilePath = path.Join(remoteFilePath, name)
+	} else if info, err := bdfsClient.GetFileInfoByPath(remoteFilePath); err == nil && info.IsDir == 1 {
+		remoteFilePath = path.Join(remoteFilePath, name)
+	}
+
+	tempDir, err := stagingTempDir()
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tempDir)
+
+	localFilePath := filepath.Join(tempDir, path.Base(remoteFilePath))
+	if err := writeFile(localFilePath, archive); err != nil {
+		return "", err
+	}
+
+	fmt.Printf("Uploading to Baidu cloud path %s...\n", remoteFilePath)
+	if err := cloud.UploadVerified(bdfsClient, localFilePath, remoteFilePath); err != nil {
+		return "", err
+	}
+	return "bdfs:" + remoteFilePath, nil
+}
+
+// stagingTempDir creates a unique directory in the directory of this run for the local copy of an archive uploaded
+// to or downloaded from Baidu cloud
+func stagingTempDir() (string, error) {
+	runDir, err := config.RunDir()
+	if err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(runDir, "copy-")
+}
+
+// removeAll is a closer removing a directory tree
+type removeAll string
+
+func (dir removeAll) Close() error {
+	return os.RemoveAll(string(dir))
+}
+
+// s3Endpoint is an object or prefix of Amazon S3 or a compatible store. Objects are streamed through
+// `aws s3 cp`, which takes its credentials, region and endpoint from the usual AWS configuration.
+type s3Endpoint struct {
+	url string
+}
+
+func (e *s3Endpoint) String() string {
+	return e.url
+}
+
+func (e *s3Endpoint) Read() (io.ReadCloser, string, error) {
+	if strings.HasSuffix(e.url, "/") {
+		return nil, "", fmt.Errorf("%s is a prefix, not an object", e.url)
+	}
+	awsPath, err := exec.LookPath("aws")
+	if err != nil {
+		return nil, "", fmt.Errorf("aws executable not found in PATH: %v", err)
+	}
+
+	reader, err := docker.CommandOutput(exec.Command(awsPath, "s3", "cp", "--only-show-errors", e.url, "-"))
+	if err != nil {
+		return nil, "", err
+	}
+	return reader, path.Base(e.url), nil
+}
+
+func (e *s3Endpoint) Write(archive io.Reader, name string) (string, error) {
+	awsPath, err := exec.LookPath("aws")
+	if err != nil {
+		return "", fmt.Errorf("aws executable not found in PATH: %v", err)
+	}
+
+	objectURL := e.url
+	if strings.HasSuffix(objectURL, "/") {
+		objectURL += name
+	}
+
+	cmd := exec.Command(awsPath, "s3", "cp", "--only-show-errors", "-", objectURL)
+	cmd.Stdin = archive
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("aws s3 cp failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return objectURL, nil
+}
@@ -0,0 +1,431 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/baowuhe/go-dkci/cloud"
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/docker"
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// ManifestFile is the file at the root of a bundle listing its contents
+const ManifestFile = "backup.json"
+
+// composeConfigLabel is the label Docker Compose puts on containers, listing the compose files of the project
+const composeConfigLabel = "com.docker.compose.project.config_files"
+
+// Manifest describes a backup bundle. File paths are relative to the bundle root and use '/' on every platform.
+type Manifest struct {
+	Host         string    `json:"host"`
+	CreatedAt    time.Time `json:"createdAt"`
+	Version      string    `json:"goDkciVersion"`
+	Images       []Item    `json:"images"`
+	Volumes      []Item    `json:"volumes"`
+	ComposeFiles []Item    `json:"composeFiles"`
+}
+
+// Item is an image, volume or compose file of a bundle. Name is the image reference, the volume name or the
+// original path of the compose file.
+type Item struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+}
+
+// Selection lists what a backup captures
+type Selection struct {
+	Images       []string
+	Volumes      []string
+	ComposeFiles []string
+}
+
+// Discover returns the images, named volumes and compose files used by the Docker Compose projects of the host
+func Discover(cli *client.Client) (Selection, error) {
+	ctx, cancel := docker.CallContext()
+	defer cancel()
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return Selection{}, err
+	}
+
+	var selection Selection
+	for _, c := range containers {
+		configFiles, ok := c.Labels[composeConfigLabel]
+		if !ok {
+			continue
+		}
+		for _, configFile := range strings.Split(configFiles, ",") {
+			if configFile = strings.TrimSpace(configFile); configFile != "" {
+				selection.ComposeFiles = append(selection.ComposeFiles, configFile)
+			}
+		}
+		// Images referenced by ID instead of a tag cannot be restored by name
+		if !strings.HasPrefix(c.Image, "sha256:") {
+			selection.Images = append(selection.Images, c.Image)
+		}
+		for _, m := range c.Mounts {
+			if m.Type == "volume" && m.Name != "" {
+				selection.Volumes = append(selection.Volumes, m.Name)
+			}
+		}
+	}
+
+	selection.Images = unique(selection.Images)
+	selection.Volumes = unique(selection.Volumes)
+	selection.ComposeFiles = unique(selection.ComposeFiles)
+	return selection, nil
+}
+
+// Create writes a bundle of the selected images, volumes and compose files to dir:
+// images/<reference>.tar, volumes/<volume>.volume.tar, compose/<n>_<file name> and backup.json
+func Create(cli *client.Client, selection Selection, dir, version string) (*Manifest, error) {
+	for _, sub := range []string{"images", "volumes", "compose"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, err
+		}
+	}
+	config.Track(dir)
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	manifest := &Manifest{Host: host, CreatedAt: time.Now().UTC(), Version: version}
+
+	for _, imageName := range selection.Images {
+		file := path.Join("images", docker.EncodeName(imageName)+".tar")
+		fmt.Printf("Saving image %s...\n", imageName)
+		if err := saveImage(cli, imageName, filepath.Join(dir, file)); err != nil {
+			return nil, fmt.Errorf("failed to save image %s: %v", imageName, err)
+		}
+		manifest.Images = append(manifest.Images, Item{Name: imageName, File: file})
+	}
+
+	for _, volumeName := range selection.Volumes {
+		tarFilePath, err := docker.SaveVolume(cli, volumeName, filepath.Join(dir, "volumes"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to save volume %s: %v", volumeName, err)
+		}
+		manifest.Volumes = append(manifest.Volumes, Item{Name: volumeName, File: path.Join("volumes", filepath.Base(tarFilePath))})
+	}
+
+	for i, composeFile := range selection.ComposeFiles {
+		// Prefix with the index, projects commonly share file names such as docker-compose.yml
+		file := path.Join("compose", fmt.Sprintf("%d_%s", i+1, filepath.Base(composeFile)))
+		if err := copyFile(composeFile, filepath.Join(dir, file)); err != nil {
+			return nil, fmt.Errorf("failed to copy compose file %s: %v", composeFile, err)
+		}
+		manifest.ComposeFiles = append(manifest.ComposeFiles, Item{Name: composeFile, File: file})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), append(data, '\n'), 0644); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Upload copies the files of a bundle, then its manifest, to a cloud folder. The manifest goes last so
+// an interrupted upload is not mistaken for a complete bundle.
+func Upload(bdfsClient cloud.Storage, manifest *Manifest, dir, cloudDir string) error {
+	for _, item := range manifest.items() {
+		fmt.Printf("Uploading %s to Baidu cloud path %s...\n", item.File, path.Join(cloudDir, item.File))
+		if err := cloud.UploadVerified(bdfsClient, filepath.Join(dir, item.File), path.Join(cloudDir, item.File)); err != nil {
+			return fmt.Errorf("failed to upload %s: %v", item.File, err)
+		}
+	}
+	return cloud.UploadVerified(bdfsClient, filepath.Join(dir, ManifestFile), path.Join(cloudDir, ManifestFile))
+}
+
+// Download copies a bundle from a cloud folder to dir and returns its manifest
+func Download(bdfsClient cloud.Storage, cloudDir, dir string) (*Manifest, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	config.Track(dir)
+	if err := cloud.DownloadToFile(bdfsClient, path.Join(cloudDir, ManifestFile), filepath.Join(dir, ManifestFile)); err != nil {
+		return nil, fmt.Errorf("failed to download %s: %v", ManifestFile, err)
+	}
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range manifest.items() {
+		localPath := filepath.Join(dir, item.File)
+		if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+			return nil, err
+		}
+		fmt.Printf("Downloading %s from Baidu cloud...\n", path.Join(cloudDir, item.File))
+		if err := cloud.DownloadToFile(bdfsClient, path.Join(cloudDir, item.File), localPath); err != nil {
+			return nil, fmt.Errorf("failed to download %s: %v", item.File, err)
+		}
+	}
+	return manifest, nil
+}
+
+// ReadManifest loads the manifest of the bundle in dir
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", ManifestFile, err)
+	}
+	for _, item := range manifest.items() {
+		// Keep restored paths inside the bundle
+		if !filepath.IsLocal(item.File) {
+			return nil, fmt.Errorf("invalid file %q in %s", item.File, ManifestFile)
+		}
+	}
+	return &manifest, nil
+}
+
+// Restore loads the images of the bundle in dir, restores its volumes and writes its compose files back to their
+// original paths, or into composeDir if it is not empty. Existing compose files are never overwritten.
+func Restore(cli *client.Client, manifest *Manifest, dir, composeDir string) error {
+	for _, item := range manifest.Images {
+		fmt.Printf("Loading image %s...\n", item.Name)
+		if err := loadImage(cli, filepath.Join(dir, item.File)); err != nil {
+			return fmt.Errorf("failed to load image %s: %v", item.Name, err)
+		}
+	}
+
+	for _, item := range manifest.Volumes {
+		fmt.Printf("Restoring volume %s...\n", item.Name)
+		if err := docker.RestoreVolume(cli, item.Name, filepath.Join(dir, item.File)); err != nil {
+			return fmt.Errorf("failed to restore volume %s: %v", item.Name, err)
+		}
+	}
+
+	for _, item := range manifest.ComposeFiles {
+		target := item.Name
+		if composeDir != "" {
+			target = filepath.Join(composeDir, filepath.Base(item.File))
+		}
+		if _, err := os.Stat(target); err == nil {
+			fmt.Printf("Warning: %s already exists, the compose file from the backup is in %s\n", target, filepath.Join(dir, item.File))
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := copyFile(filepath.Join(dir, item.File), target); err != nil {
+			return fmt.Errorf("failed to restore compose file %s: %v", target, err)
+		}
+		fmt.Printf("Restored compose file %s\n", target)
+	}
+	return nil
+}
+
+// items returns all files of the bundle except the manifest
+func (m *Manifest) items() []Item {
+	var items []Item
+	items = append(items, m.Images...)
+	items = append(items, m.Volumes...)
+	items = append(items, m.ComposeFiles...)
+	return items
+}
+
+// saveImage writes the `docker save` archive of an image to filePath, failing early when it cannot fit
+func saveImage(cli *client.Client, imageName, filePath string) error {
+	inspectCtx, cancel := docker.CallContext()
+	imageInspect, _, err := cli.ImageInspectWithRaw(inspectCtx, imageName)
+	cancel()
+	if err == nil {
+		if err := docker.CheckFreeSpace(filepath.Dir(filePath), imageInspect.Size); err != nil {
+			return err
+		}
+	}
+
+	saveName := docker.SaveReference(imageInspect.RepoTags, imageName)
+	if err := docker.CheckImageAllowed(saveName); err != nil {
+		return err
+	}
+
+	ctx, watchdog := docker.StreamContext()
+	defer watchdog.Stop()
+	imageReader, err := cli.ImageSave(ctx, []string{saveName})
+	if err != nil {
+		return watchdog.Err(err)
+	}
+	defer imageReader.Close()
+
+	outFile, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+
+	_, err = io.Copy(outFile, watchdog.Reader(imageReader))
+	return err
+}
+
+// loadImage loads an image archive into Docker and lists the images it created
+func loadImage(cli *client.Client, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	refs, err := docker.LoadStream(cli, file)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		fmt.Printf("Loaded %s\n", ref)
+	}
+	return nil
+}
+
+// copyFile copies a regular file, keeping its permissions
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, info.Mode().Perm())
+}
+
+// unique returns the sorted distinct values
+func unique(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := []string{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// BackupHost captures images, volumes and compose files into a bundle in destination, or in cloudDir if it is
+// not empty. Everything used by Compose projects is included along with extraComposeFiles; unless all is set,
+// the user picks further images (narrowed by filter) and volumes.
+func BackupHost(destination, cloudDir string, filter docker.Filter, extraComposeFiles []string, all bool, version string) {
+	cli, err := docker.NewClient("")
+	if err != nil {
+		i18n.Printf("[x] Failed to create Docker client: %v\n", err)
+		os.Exit(1)
+	}
+	defer cli.Close()
+
+	selection, err := Discover(cli)
+	if err != nil {
+		i18n.Printf("[x] Failed to list containers: %v\n", err)
+		os.Exit(1)
+	}
+	selection.ComposeFiles = unique(append(selection.ComposeFiles, extraComposeFiles...))
+	fmt.Printf("Found %d image(s), %d volume(s) and %d compose file(s) used by Compose projects\n",
+		len(selection.Images), len(selection.Volumes), len(selection.ComposeFiles))
+
+	// Let the user add the images and volumes that are not part of a Compose project
+	imageEntries := docker.ListImageEntries(cli, filter)
+	volumeNames, err := docker.ListVolumes(cli)
+	if err != nil {
+		i18n.Printf("[x] Failed to list volumes: %v\n", err)
+		os.Exit(1)
+	}
+	if all {
+		for _, entry := range imageEntries {
+			selection.Images = append(selection.Images, entry.Name)
+		}
+		selection.Volumes = append(selection.Volumes, volumeNames...)
+	} else {
+		if len(imageEntries) > 0 {
+			selection.Images = append(selection.Images, docker.SelectImages(imageEntries, "Select additional images to back up:")...)
+		}
+		if len(volumeNames) > 0 {
+			selection.Volumes = append(selection.Volumes, docker.SelectVolumes(volumeNames, "Select additional volumes to back up:")...)
+		}
+	}
+	selection.Images = unique(selection.Images)
+	selection.Volumes = unique(selection.Volumes)
+
+	if len(selection.Images)+len(selection.Volumes)+len(selection.ComposeFiles) == 0 {
+		i18n.Println("[x] Nothing selected to back up")
+		os.Exit(1)
+	}
+
+	// Cloud bundles are staged locally first
+	dir := destination
+	if cloudDir != "" {
+		dir = filepath.Join(config.TempDir(), "backup-"+time.Now().Format("20060102-150405"))
+		defer os.RemoveAll(dir)
+	}
+
+	manifest, err := Create(cli, selection, dir, version)
+	if err != nil {
+		i18n.Printf("[x] Backup failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	target := dir
+	if cloudDir != "" {
+		if err := Upload(cloud.Login(), manifest, dir, cloudDir); err != nil {
+			i18n.Printf("[x] Backup upload failed: %v\n", err)
+			os.Exit(1)
+		}
+		target = cloudDir
+	}
+
+	i18n.Printf("[√] Backed up %d image(s), %d volume(s) and %d compose file(s) to %s\n",
+		len(manifest.Images), len(manifest.Volumes), len(manifest.ComposeFiles), target)
+}
+
+// RestoreHost replays a bundle from source, or from cloudDir if it is not empty. Compose files are written to
+// their original paths unless composeDir is set.
+func RestoreHost(source, cloudDir, composeDir string) {
+	cli, err := docker.NewClient("")
+	if err != nil {
+		i18n.Printf("[x] Failed to create Docker client: %v\n", err)
+		os.Exit(1)
+	}
+	defer cli.Close()
+
+	dir := source
+	var manifest *Manifest
+	if cloudDir != "" {
+		dir = filepath.Join(config.TempDir(), "restore-"+time.Now().Format("20060102-150405"))
+		defer os.RemoveAll(dir)
+		manifest, err = Download(cloud.Login(), cloudDir, dir)
+	} else {
+		manifest, err = ReadManifest(dir)
+	}
+	if err != nil {
+		i18n.Printf("[x] Failed to read backup: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Restoring backup of %s from %s\n", manifest.Host, manifest.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	if err := Restore(cli, manifest, dir, composeDir); err != nil {
+		i18n.Printf("[x] Restore failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	i18n.Printf("[√] Restored %d image(s), %d volume(s) and %d compose file(s)\n",
+		len(manifest.Images), len(manifest.Volumes), len(manifest.ComposeFiles))
+}
@@ -0,0 +1,380 @@
+package bundle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/baowuhe/go-dkci/attest"
+	"github.com/baowuhe/go-dkci/cloud"
+	"github.com/baowuhe/go-dkci/docker"
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/baowuhe/go-dkci/sbom"
+	"github.com/baowuhe/go-dkci/sign"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// sidecarSuffixes end the names of the files written next to an archive. A signed attestation
+// combines two of them.
+var sidecarSuffixes = []string{sign.SignatureSuffix, attest.AttestationSuffix, ".spdx.json", ".cdx.json", sbom.LicenseSuffix}
+
+// Manifest declares an offline bundle: the images it holds, where they are exported to and how.
+// It is kept in a TOML file, typically under version control next to the deployment it serves.
+type Manifest struct {
+	// Destination is the local directory of the bundle, Cloud the Baidu cloud folder; exactly one is set
+	Destination string `toml:"destination"`
+	Cloud       string `toml:"cloud"`
+	// NameTemplate and WithDigest name the archives like the export flags of the same name
+	NameTemplate string `toml:"name_template"`
+	WithDigest   bool   `toml:"with_digest"`
+	// Compress is the compression of the archives, "gzip", "zstd" or "none", and CompressLevel its level
+	Compress      string `toml:"compress"`
+	CompressLevel int    `toml:"compress_level"`
+	// Delta uploads only the layers missing from the cloud folder
+	Delta bool `toml:"delta"`
+	// Prune removes the archives of images that are no longer listed, once they are older than PruneOlderThan
+	Prune          bool     `toml:"prune"`
+	PruneOlderThan string   `toml:"prune_older_than"`
+	Images         []string `toml:"images"`
+}
+
+// Load reads and validates a bundle manifest. Unknown keys are rejected so that typos do not go unnoticed.
+func Load(filePath string) (*Manifest, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	decoder := toml.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(manifest); err != nil {
+		// The strict mode error only names the offending keys in its detailed form
+		var strictErr *toml.StrictMissingError
+		if errors.As(err, &strictErr) {
+			return nil, fmt.Errorf("unknown keys in %s:\n%s", filePath, strictErr.String())
+		}
+		return nil, fmt.Errorf("failed to parse %s: %v", filePath, err)
+	}
+
+	if (manifest.Destination == "") == (manifest.Cloud == "") {
+		return nil, fmt.Errorf("%s: exactly one of destination or cloud must be set", filePath)
+	}
+	if len(manifest.Images) == 0 {
+		return nil, fmt.Errorf("%s: no images listed", filePath)
+	}
+	if manifest.Delta && manifest.Destination != "" {
+		return nil, fmt.Errorf("%s: delta requires a cloud folder", filePath)
+	}
+	if manifest.Delta && manifest.Compress != "" && manifest.Compress != "none" {
+		return nil, fmt.Errorf("%s: delta cannot be combined with compress", filePath)
+	}
+	if manifest.PruneOlderThan != "" {
+		if _, err := docker.ParseAge(manifest.PruneOlderThan); err != nil {
+			return nil, fmt.Errorf("%s: prune_older_than: %v", filePath, err)
+		}
+	}
+	return manifest, nil
+}
+
+// Configure applies the naming, compression and delta settings of the manifest. nameTemplate and compressLevel
+// are used when the manifest does not set its own.
+func (m *Manifest) Configure(nameTemplate string, compressLevel int) error {
+	if m.NameTemplate != "" {
+		nameTemplate = m.NameTemplate
+	}
+	if m.WithDigest {
+		nameTemplate = docker.WithDigest(nameTemplate)
+	}
+	if err := docker.SetNameTemplate(nameTemplate); err != nil {
+		return err
+	}
+	if err := docker.SetCompression(m.Compress); err != nil {
+		return err
+	}
+	if m.CompressLevel != 0 {
+		compressLevel = m.CompressLevel
+	}
+	if err := docker.SetCompressionLevel(compressLevel); err != nil {
+		return err
+	}
+	cloud.SetDelta(m.Delta)
+	return nil
+}
+
+// target is the destination or cloud folder of the manifest
+func (m *Manifest) target() string {
+	if m.Cloud != "" {
+		return m.Cloud
+	}
+	return m.Destination
+}
+
+// join returns the path of a file in the bundle; cloud folders always use '/'
+func (m *Manifest) join(name string) string {
+	if m.Cloud != "" {
+		return path.Join(m.Cloud, name)
+	}
+	return filepath.Join(m.Destination, name)
+}
+
+// storedFile is an archive or sidecar found in the bundle
+type storedFile struct {
+	Path    string
+	ModTime time.Time
+}
+
+// Apply reconciles the bundle with the manifest: every listed image is exported unless it is up to date, and
+// with prune set the archives of other images are removed along with their sidecar files. With dryRun set
+// the planned changes are only printed.
+func Apply(manifest *Manifest, prune, dryRun bool) {
+	prune = prune || manifest.Prune
+
+	cli, err := docker.NewClient("")
+	if err != nil {
+		i18n.Printf("[x] Failed to create Docker client: %v\n", err)
+		os.Exit(1)
+	}
+	defer cli.Close()
+
+	// Name the archive of every listed image; all images must be present before anything is changed
+	archivePaths := make([]string, len(manifest.Images))
+	expected := make(map[string]bool)
+	var missing []string
+	for i, imageName := range manifest.Images {
+		ctx, cancel := docker.CallContext()
+		imageInspect, _, err := cli.ImageInspectWithRaw(ctx, imageName)
+		cancel()
+		if err != nil {
+			missing = append(missing, imageName)
+			continue
+		}
+		tarFileName, err := docker.ArchiveName(imageName, imageInspect.Os, imageInspect.Architecture, imageInspect.ID)
+		if err != nil {
+			i18n.Printf("[x] Failed to name the archive of image %s: %v\n", imageName, err)
+			os.Exit(1)
+		}
+		archivePath := manifest.join(docker.CompressedName(tarFileName))
+		if manifest.Delta {
+			archivePath += docker.DeltaSuffix
+		}
+		archivePaths[i] = archivePath
+		expected[archivePath] = true
+	}
+	if len(missing) > 0 {
+		i18n.Printf("[x] Images not found locally: %s\n", strings.Join(missing, ", "))
+		os.Exit(1)
+	}
+
+	var bdfsClient cloud.Storage
+	if manifest.Cloud != "" {
+		bdfsClient = cloud.Login()
+	}
+
+	var stored []storedFile
+	if prune {
+		if stored, err = listStored(bdfsClient, manifest); err != nil {
+			i18n.Printf("[x] Failed to list %s: %v\n", manifest.target(), err)
+			os.Exit(1)
+		}
+	}
+	obsolete, err := obsoleteFiles(stored, expected, manifest.PruneOlderThan)
+	if err != nil {
+		i18n.Printf("[x] Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if dryRun {
+		for i, imageName := range manifest.Images {
+			fmt.Printf("Would export %s to %s\n", imageName, archivePaths[i])
+		}
+		for _, filePath := range obsolete {
+			fmt.Printf("Would remove %s\n", filePath)
+		}
+		i18n.Printf("[√] Dry run: %d image(s) to export, %d file(s) to remove\n", len(manifest.Images), len(obsolete))
+		return
+	}
+
+	if manifest.Destination != "" {
+		if err := os.MkdirAll(manifest.Destination, 0755); err != nil {
+			i18n.Printf("[x] Failed to create destination directory %s: %v\n", manifest.Destination, err)
+			os.Exit(1)
+		}
+	}
+	// Images the export policies block or that are not confirmed are not exported, which fails the apply below
+	policyDestination := manifest.Destination
+	if bdfsClient != nil {
+		policyDestination = cloud.Location(manifest.Cloud)
+	} else if absPath, err := filepath.Abs(manifest.Destination); err == nil {
+		policyDestination = absPath
+	}
+	for _, imageName := range docker.ApplyPolicies(cli, manifest.Images, policyDestination) {
+		if bdfsClient != nil {
+			cloud.ExportImageToCloud(cli, imageName, manifest.Cloud, bdfsClient)
+		} else {
+			docker.ExportImage(cli, imageName, manifest.Destination)
+		}
+	}
+
+	// The exports report their own errors, so check their results before removing anything
+	var failed []string
+	for i, imageName := range manifest.Images {
+		if !exists(bdfsClient, archivePaths[i]) {
+			failed = append(failed, imageName)
+		}
+	}
+	if len(failed) > 0 {
+		i18n.Printf("[x] Failed to export %s, nothing was pruned\n", strings.Join(failed, ", "))
+		os.Exit(1)
+	}
+
+	for _, filePath := range obsolete {
+		fmt.Printf("Removing %s...\n", filePath)
+		if bdfsClient != nil {
+			err = bdfsClient.RemoveFile(filePath)
+		} else {
+			err = os.Remove(filePath)
+		}
+		if err != nil {
+			i18n.Printf("[x] Failed to remove %s: %v\n", filePath, err)
+			os.Exit(1)
+		}
+	}
+
+	i18n.Printf("[√] Bundle %s is up to date: %d image(s), %d file(s) removed\n", manifest.target(), len(manifest.Images), len(obsolete))
+}
+
+// listStored lists the files of the bundle down to the depth of the name template. Hidden folders, such as the
+// blob store of delta exports, are skipped.
+func listStored(bdfsClient cloud.Storage, manifest *Manifest) ([]storedFile, error) {
+	var stored []storedFile
+
+	if bdfsClient != nil {
+		files, err := cloud.ListFilesRecursive(bdfsClient, manifest.Cloud, docker.NameDepth())
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if file.IsDir == 1 || strings.Contains(strings.TrimPrefix(file.Path, manifest.Cloud), "/.") {
+				continue
+			}
+			stored = append(stored, storedFile{Path: file.Path, ModTime: time.Unix(file.ServerMtime, 0)})
+		}
+		return stored, nil
+	}
+
+	err := filepath.WalkDir(manifest.Destination, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && filePath == manifest.Destination {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if entry.IsDir() {
+			if filePath != manifest.Destination && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		stored = append(stored, storedFile{Path: filePath, ModTime: info.ModTime()})
+		return nil
+	})
+	return stored, err
+}
+
+// obsoleteFiles returns the image archives among stored that are not expected, together with their sidecar
+// files. Only files the name template could have produced are considered, and with olderThan set only those
+// older than that age.
+func obsoleteFiles(stored []storedFile, expected map[string]bool, olderThan string) ([]string, error) {
+	var cutoff time.Time
+	if olderThan != "" {
+		age, err := docker.ParseAge(olderThan)
+		if err != nil {
+			return nil, fmt.Errorf("prune_older_than: %v", err)
+		}
+		cutoff = time.Now().Add(-age)
+	}
+
+	obsoleteArchives := make(map[string]bool)
+	for _, file := range stored {
+		if !isImageArchive(file.Path) {
+			continue
+		}
+		if expected[file.Path] {
+			continue
+		}
+		if _, ok := docker.ParseArchiveName(file.Path); !ok {
+			continue
+		}
+		if !cutoff.IsZero() && file.ModTime.After(cutoff) {
+			continue
+		}
+		obsoleteArchives[file.Path] = true
+	}
+
+	// Sidecars are named after the archive, without the suffix of delta recipes
+	var obsolete []string
+	for _, file := range stored {
+		archivePath := archiveOf(file.Path)
+		if archivePath == "" {
+			archivePath = file.Path
+		}
+		if obsoleteArchives[archivePath] || obsoleteArchives[archivePath+docker.DeltaSuffix] || obsoleteArchives[archivePath+docker.SplitSuffix] {
+			obsolete = append(obsolete, file.Path)
+		}
+	}
+	sort.Strings(obsolete)
+	return obsolete, nil
+}
+
+// isImageArchive reports whether a file is an image archive or the recipe of a delta export
+func isImageArchive(filePath string) bool {
+	lowerPath := strings.ToLower(filePath)
+	if docker.IsFilesystemArchive(lowerPath) {
+		return false
+	}
+	return docker.IsArchiveName(lowerPath) || docker.IsIndexName(lowerPath)
+}
+
+// archiveOf returns the archive a sidecar file or a part of a split archive belongs to, or an empty string if
+// filePath is neither
+func archiveOf(filePath string) string {
+	if archivePath := docker.SplitPartOf(filePath); archivePath != "" {
+		return archivePath
+	}
+	archivePath := filePath
+	for trimmed := true; trimmed; {
+		trimmed = false
+		for _, suffix := range sidecarSuffixes {
+			if strings.HasSuffix(archivePath, suffix) {
+				archivePath = strings.TrimSuffix(archivePath, suffix)
+				trimmed = true
+			}
+		}
+	}
+	if archivePath == filePath {
+		return ""
+	}
+	return archivePath
+}
+
+// exists reports whether a file of the bundle exists, in the cloud if bdfsClient is set
+func exists(bdfsClient cloud.Storage, filePath string) bool {
+	if bdfsClient != nil {
+		_, err := bdfsClient.GetFileInfoByPath(filePath)
+		return err == nil
+	}
+	_, err := os.Stat(filePath)
+	return err == nil
+}
@@ -0,0 +1,144 @@
+package checksum
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// parallelWrite is the size from which a write is hashed by MD5 and SHA-256 in parallel; smaller writes are not
+// worth starting a goroutine for
+const parallelWrite = 16 << 10
+
+// Sums are the checksums of a file: the MD5 that cloud uploads are verified against, and the SHA-256 that
+// attestations and the state database record
+type Sums struct {
+	Size   int64
+	MD5    string
+	SHA256 string
+}
+
+// Hasher computes the Sums of what is written to it, so that a file can be hashed while it is written, uploaded
+// or downloaded instead of being read again afterwards
+type Hasher struct {
+	size   int64
+	md5    hash.Hash
+	sha256 hash.Hash
+}
+
+// New returns a Hasher
+func New() *Hasher {
+	return &Hasher{md5: md5.New(), sha256: sha256.New()}
+}
+
+// Write hashes b, computing the MD5 and SHA-256 of large writes in parallel
+func (h *Hasher) Write(b []byte) (int, error) {
+	h.size += int64(len(b))
+	if len(b) < parallelWrite {
+		h.md5.Write(b)
+		h.sha256.Write(b)
+		return len(b), nil
+	}
+	done := make(chan struct{})
+	go func() {
+		h.sha256.Write(b)
+		close(done)
+	}()
+	h.md5.Write(b)
+	<-done
+	return len(b), nil
+}
+
+// Sums returns the checksums of what was written so far
+func (h *Hasher) Sums() Sums {
+	return Sums{
+		Size:   h.size,
+		MD5:    hex.EncodeToString(h.md5.Sum(nil)),
+		SHA256: hex.EncodeToString(h.sha256.Sum(nil)),
+	}
+}
+
+// known are the sums of files, valid while the files keep their size and modification time
+type known struct {
+	size    int64
+	modTime time.Time
+	sums    Sums
+}
+
+var (
+	mu    sync.Mutex
+	files = map[string]known{}
+	// reading holds the files being hashed, so that concurrent calls of File share one read
+	reading = map[string]*sync.WaitGroup{}
+)
+
+// Remember records the sums of a file computed with a Hasher while it was written, so that File returns them
+// without reading the file again as long as it is unchanged. Sums that do not match the size of the file are
+// ignored.
+func Remember(path string, sums Sums) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() != sums.Size {
+		return
+	}
+	mu.Lock()
+	files[path] = known{size: info.Size(), modTime: info.ModTime(), sums: sums}
+	mu.Unlock()
+}
+
+// File returns the sums of a file: those remembered or computed earlier if the file is unchanged since, and
+// otherwise both checksums computed in one read. Concurrent calls for the same file wait for a single read, and
+// different files are hashed concurrently.
+func File(path string) (Sums, error) {
+	for {
+		info, err := os.Stat(path)
+		if err != nil {
+			return Sums{}, err
+		}
+
+		mu.Lock()
+		if entry, ok := files[path]; ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+			mu.Unlock()
+			return entry.sums, nil
+		}
+		if wait, ok := reading[path]; ok {
+			mu.Unlock()
+			wait.Wait()
+			continue
+		}
+		wait := &sync.WaitGroup{}
+		wait.Add(1)
+		reading[path] = wait
+		mu.Unlock()
+
+		// The sums are kept only if the file did not change while it was read
+		sums, err := hashFile(path)
+		mu.Lock()
+		if after, statErr := os.Stat(path); err == nil && statErr == nil && after.Size() == info.Size() && after.ModTime().Equal(info.ModTime()) {
+			files[path] = known{size: info.Size(), modTime: info.ModTime(), sums: sums}
+		}
+		delete(reading, path)
+		mu.Unlock()
+		wait.Done()
+		return sums, err
+	}
+}
+
+// hashFile reads a file through a Hasher
+func hashFile(path string) (Sums, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Sums{}, err
+	}
+	defer file.Close()
+
+	hasher := New()
+	if _, err := io.CopyBuffer(hasher, file, make([]byte, 1<<20)); err != nil {
+		return Sums{}, err
+	}
+	return hasher.Sums(), nil
+}
@@ -0,0 +1,53 @@
+package cloud
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Account is the membership level of a Baidu account and the upload limits that come with it
+type Account struct {
+	// Level is free, VIP or SVIP
+	Level string
+	// MaxFileSize is the largest file that can be uploaded
+	MaxFileSize int64
+}
+
+// accountLevels are the limits of the membership levels, as the upload section of the Baidu Netdisk open platform
+// documentation states them: files of 4GB, 10GB and 20GB. The larger chunks it allows VIP and SVIP accounts are of
+// no use here, the BDFS SDK always uploads in 4MB chunks.
+var accountLevels = []Account{
+	{Level: "free", MaxFileSize: 4 << 30},
+	{Level: "VIP", MaxFileSize: 10 << 30},
+	{Level: "SVIP", MaxFileSize: 20 << 30},
+}
+
+// account is the membership level uploads are sized for. The BDFS SDK does not report the level, and every account
+// accepts the limits of a free one, so those apply unless the user names a higher level.
+var account = accountLevels[0]
+
+// SetAccountLevel sets the membership level of the Baidu account, free, VIP or SVIP in any case, whose limits
+// uploads keep to
+func SetAccountLevel(level string) error {
+	for _, levelAccount := range accountLevels {
+		if strings.EqualFold(level, levelAccount.Level) {
+			account = levelAccount
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported account level %q (expected free, VIP or SVIP)", level)
+}
+
+// AccountLimits returns the membership level set by SetAccountLevel, free by default, and its upload limits
+func AccountLimits() Account {
+	return account
+}
+
+// maxUploadSize returns the largest file the storage takes as one file, or 0 if it takes files of any size: Baidu
+// cloud limits the file size by the membership level of the account
+func maxUploadSize(bdfsClient Storage) int64 {
+	if !isBaidu(bdfsClient) {
+		return 0
+	}
+	return account.MaxFileSize
+}
@@ -0,0 +1,57 @@
+package cloud
+
+import "testing"
+
+func TestSetAccountLevel(t *testing.T) {
+	t.Cleanup(func() { SetAccountLevel("free") })
+	dir, err := NewDirStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		level       string
+		want        string
+		maxFileSize int64
+		wantErr     bool
+	}{
+		{level: "free", want: "free", maxFileSize: 4 << 30},
+		{level: "vip", want: "VIP", maxFileSize: 10 << 30},
+		{level: "SVIP", want: "SVIP", maxFileSize: 20 << 30},
+		{level: "gold", wantErr: true},
+		{level: "", wantErr: true},
+	}
+	for _, test := range tests {
+		SetAccountLevel("free")
+		err := SetAccountLevel(test.level)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("SetAccountLevel(%q) accepted", test.level)
+			}
+			if AccountLimits().Level != "free" {
+				t.Errorf("SetAccountLevel(%q) changed the level to %s", test.level, AccountLimits().Level)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		limits := AccountLimits()
+		if limits.Level != test.want || limits.MaxFileSize != test.maxFileSize {
+			t.Errorf("SetAccountLevel(%q): AccountLimits = %+v", test.level, limits)
+		}
+		if size := maxUploadSize(&baiduStorage{}); size != test.maxFileSize {
+			t.Errorf("SetAccountLevel(%q): maxUploadSize of Baidu cloud = %d", test.level, size)
+		}
+		if size := maxUploadSize(dir); size != 0 {
+			t.Errorf("maxUploadSize of dir:// = %d, want no limit", size)
+		}
+	}
+}
+
+func TestAccountLimitsDefault(t *testing.T) {
+	// Without a level given, uploads keep to the limits every account accepts
+	if limits := AccountLimits(); limits != accountLevels[0] || limits.Level != "free" {
+		t.Errorf("default AccountLimits = %+v", limits)
+	}
+}
@@ -4,127 +4,258 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/AlecAivazis/survey/v2"
 	"github.com/baowuhe/go-bdfs/pan"
+	"github.com/baowuhe/go-dkci/attest"
+	"github.com/baowuhe/go-dkci/checksum"
 	"github.com/baowuhe/go-dkci/config"
 	"github.com/baowuhe/go-dkci/docker"
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/client"
+	"github.com/baowuhe/go-dkci/hook"
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/baowuhe/go-dkci/job"
+	"github.com/baowuhe/go-dkci/sbom"
+	"github.com/baowuhe/go-dkci/sign"
+	"github.com/baowuhe/go-dkci/state"
 )
 
-// ExportImagesToCloud exports the selected Docker images to Baidu cloud disk
-func ExportImagesToCloud(cloudPath string) {
-	// Get BDFS configuration
-	configData, err := config.GetBDFSConfig()
+// uploadRetries is how many times an upload is repeated when the remote checksum does not match
+var uploadRetries int
+
+// SetUploadRetries sets how many times an upload is repeated when its verification against the
+// server-side size and MD5 fails
+func SetUploadRetries(retries int) {
+	uploadRetries = retries
+}
+
+// noCache forces imports to download cloud files even if an identical copy is cached in ~/.cache/go-dkci
+var noCache bool
+
+// SetNoCache makes imports always download cloud files instead of reusing identical cached copies
+func SetNoCache(disabled bool) {
+	noCache = disabled
+}
+
+// keepTempFiles keeps the archives written by cloud exports and imports in ~/.cache/go-dkci instead of removing them
+var keepTempFiles bool
+
+// SetKeepTempFiles makes cloud exports and imports keep their temporary files in ~/.cache/go-dkci
+func SetKeepTempFiles(keep bool) {
+	keepTempFiles = keep
+}
+
+// runDir returns the working directory of this run, where cloud exports and imports write their temporary files
+func runDir() (string, error) {
+	dir, err := config.RunDir()
 	if err != nil {
-		fmt.Printf("[x] Error getting BDFS configuration: %v\n", err)
-		os.Exit(1)
+		return "", fmt.Errorf("failed to create temp directory in %s: %v", config.TempDir(), err)
 	}
+	return dir, nil
+}
 
-	// Create a BDFS client with the provided config
-	bdfsClient := pan.NewClient(configData.ClientID, configData.ClientSecret, configData.TokenPath)
+// keepFiles moves temporary files out of the working directory of this run to ~/.cache/go-dkci, so that they
+// outlive it
+func keepFiles(paths ...string) {
+	for _, filePath := range paths {
+		kept, err := config.KeepFile(filePath)
+		if err != nil {
+			fmt.Printf("Warning: Failed to keep temporary file %s: %v\n", filePath, err)
+			continue
+		}
+		fmt.Printf("Kept temporary file %s\n", kept)
+	}
+}
 
-	// Login to Baidu cloud
-	if err := bdfsClient.Authorize(context.Background()); err != nil {
-		fmt.Printf("[x] Failed to login to Baidu cloud: %v\n", err)
-		os.Exit(1)
+// transferConcurrency and transferLimit bound the archives uploaded to or downloaded from Baidu cloud at once
+var (
+	transferConcurrency = 1
+	transferLimit       = docker.NewLimiter(1)
+)
+
+// SetTransferConcurrency sets how many archives are uploaded to or downloaded from Baidu cloud at once
+func SetTransferConcurrency(concurrency int) {
+	transferConcurrency = max(concurrency, 1)
+	transferLimit = docker.NewLimiter(concurrency)
+}
+
+var (
+	// backendDir is the directory the cloud commands store their files in instead of Baidu cloud, if set
+	backendDir string
+	// backendPlugin is the plugin the cloud commands store their files through instead of Baidu cloud, if set
+	backendPlugin *PluginStorage
+	// backendSpec is the backend as selected by SetBackend, naming the plugin
+	backendSpec string
+)
+
+// SetBackend selects the storage of the cloud commands: Baidu cloud for "" or "bdfs", a local directory such as
+// a mounted USB drive or NFS share for dir://<path>, or a storage plugin for plugin:<name>
+func SetBackend(spec string) error {
+	backendDir, backendPlugin, backendSpec = "", nil, spec
+	switch {
+	case spec == "" || spec == "bdfs":
+	case strings.HasPrefix(spec, DirPrefix):
+		backendDir = strings.TrimPrefix(spec, DirPrefix)
+		if backendDir == "" {
+			return fmt.Errorf("%s needs a directory, such as %s/mnt/usb", DirPrefix, DirPrefix)
+		}
+	case strings.HasPrefix(spec, PluginPrefix):
+		plugin, err := NewPluginStorage(strings.TrimPrefix(spec, PluginPrefix))
+		if err != nil {
+			return err
+		}
+		backendPlugin = plugin
+	default:
+		return fmt.Errorf("unknown backend %q (expected bdfs, %s<path> or %s<name>)", spec, DirPrefix, PluginPrefix)
 	}
+	return nil
+}
 
-	fmt.Println("[√] Successfully logged in to Baidu cloud")
+// Location names a path of the selected backend in the state database: bdfs:<path> for Baidu cloud,
+// dir://<directory><path> for a directory and plugin:<name>:<path> for a plugin
+func Location(remotePath string) string {
+	switch {
+	case backendDir != "":
+		return DirPrefix + path.Join(filepath.ToSlash(backendDir), remotePath)
+	case backendPlugin != nil:
+		return backendSpec + ":" + remotePath
+	}
+	return "bdfs:" + remotePath
+}
 
-	// Initialize Docker client
-	cli, err := client.NewClientWithOpts(client.FromEnv)
+// DefaultDir returns the folder the cloud commands use when none is given: default_cloud_dir of the BDFS
+// configuration, or the root of the dir:// and plugin backends, which need no configuration
+func DefaultDir() (string, error) {
+	if backendDir != "" || backendPlugin != nil {
+		return "/", nil
+	}
+	configData, err := config.GetBDFSConfig()
 	if err != nil {
-		fmt.Printf("[x] Failed to create Docker client: %v\n", err)
-		os.Exit(1)
+		return "", err
 	}
-	defer cli.Close()
+	return configData.DefaultCloudDir, nil
+}
 
-	// List Docker images
-	images, err := cli.ImageList(context.Background(), types.ImageListOptions{})
-	if err != nil {
-		fmt.Printf("[x] Failed to list Docker images: %v\n", err)
-		os.Exit(1)
+// Login returns the storage of the cloud commands. For Baidu cloud, it creates a BDFS client from the
+// configuration and authorizes it; the directory of the dir:// backend only has to exist, and plugins are found
+// by SetBackend.
+func Login() Storage {
+	if backendPlugin != nil {
+		i18n.Printf("[√] Using storage plugin %s\n", backendPlugin.executable)
+		return backendPlugin
+	}
+	if backendDir != "" {
+		storage, err := NewDirStorage(backendDir)
+		if err != nil {
+			i18n.Printf("[x] Failed to open backend directory %s: %v\n", backendDir, err)
+			os.Exit(1)
+		}
+		i18n.Printf("[√] Using directory %s as cloud storage\n", backendDir)
+		return storage
 	}
 
-	if len(images) == 0 {
-		fmt.Println("[x] No Docker images found")
+	// Get BDFS configuration
+	configData, err := config.GetBDFSConfig()
+	if err != nil {
+		i18n.Printf("[x] Error getting BDFS configuration: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Format image names for selection
-	imageNames := make([]string, 0, len(images))
-	for _, img := range images {
-		for _, tag := range img.RepoTags {
-			// Skip <none>:<none> tags
-			if tag != "<none>:<none>" {
-				// If grep pattern is provided, only add images that match the pattern
-				if os.Getenv("DKCI_GREP_PATTERN") != "" { // Using env var to pass grep pattern
-					if strings.Contains(tag, os.Getenv("DKCI_GREP_PATTERN")) {
-						imageNames = append(imageNames, tag)
-					}
-				} else {
-					imageNames = append(imageNames, tag)
-				}
-			}
-		}
-	}
+	// Create a BDFS client with the provided config
+	bdfsClient := pan.NewClient(configData.ClientID, configData.ClientSecret, configData.TokenPath)
 
-	if len(imageNames) == 0 {
-		fmt.Println("[x] No tagged Docker images found")
+	// Login to Baidu cloud
+	waitForLimit()
+	if err := bdfsClient.Authorize(context.Background()); err != nil {
+		i18n.Printf("[x] Failed to login to Baidu cloud: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Found %d tagged Docker image(s)\n", len(imageNames))
+	i18n.Println("[√] Successfully logged in to Baidu cloud")
 
-	// Setup multi-select options
-	selections := []string{}
+	// Keep the API calls of the cloud commands under the rate limit
+	return &baiduStorage{client: bdfsClient}
+}
 
-	// Add an "All" option if there are multiple images
-	if len(imageNames) > 1 {
-		selections = append([]string{"All"}, imageNames...)
-	} else {
-		selections = imageNames
-	}
+// ExportImagesToCloud exports the selected Docker images to Baidu cloud disk, into the run folder of cloudPath if
+// one is set. If imageNames is not empty, exactly those images are exported without prompting.
+func ExportImagesToCloud(cloudPath string, filter docker.Filter, imageNames []string) {
+	cloudPath = path.Join(cloudPath, docker.RunFolder())
 
-	// Multi-select prompt
-	prompt := &survey.MultiSelect{
-		Message: "Select Docker images to export to cloud:",
-		Options: selections,
-	}
+	// Login to Baidu cloud
+	bdfsClient := Login()
 
-	selectedImages := []string{}
-	err = survey.AskOne(prompt, &selectedImages)
+	// Initialize Docker client
+	cli, err := docker.NewClient("")
 	if err != nil {
-		fmt.Printf("[x] Failed to get user selection: %v\n", err)
+		i18n.Printf("[x] Failed to create Docker client: %v\n", err)
 		os.Exit(1)
 	}
+	defer cli.Close()
+
+	var selectedImages []string
+	if len(imageNames) > 0 {
+		// Export exactly the images given on the command line
+		selectedImages = docker.ResolveImages(docker.ListImageEntries(cli, docker.Filter{Untagged: true}), imageNames)
+	} else {
+		// List tagged Docker images, narrowed by the filter if provided
+		imageEntries := docker.ListImageEntries(cli, filter)
+		if len(imageEntries) == 0 {
+			i18n.Println("[x] No tagged Docker images found")
+			os.Exit(1)
+		}
 
-	// Handle the "All" selection
-	if len(selectedImages) == 1 && selectedImages[0] == "All" {
-		selectedImages = imageNames // Select all images
+		fmt.Printf("Found %d tagged Docker image(s)\n", len(imageEntries))
+
+		selectedImages = docker.SelectImages(imageEntries, "Select Docker images to export to cloud:")
+		if len(selectedImages) == 0 {
+			i18n.Println("[x] No images selected")
+			os.Exit(1)
+		}
 	}
 
-	if len(selectedImages) == 0 {
-		fmt.Println("[x] No images selected")
+	i18n.Printf("Selected images: %v\n", selectedImages)
+
+	// Export images carrying several of the selected tags once if all tags are saved
+	selectedImages = docker.CollapseTags(cli, selectedImages)
+
+	// Leave out the images the export policies block or that are not confirmed
+	if selectedImages = docker.ApplyPolicies(cli, selectedImages, Location(cloudPath)); len(selectedImages) == 0 {
+		i18n.Println("[x] No images left to export")
 		os.Exit(1)
 	}
 
-	fmt.Printf("Selected images: %v\n", selectedImages)
+	// Show what the export will upload before starting it
+	if !docker.ConfirmExport(cli, selectedImages) {
+		i18n.Println("[x] Export cancelled by user")
+		return
+	}
 
-	// Export selected images to cloud
-	for _, imageName := range selectedImages {
+	// Export selected images to cloud, recording the progress so that an interrupted export can be resumed
+	job.Start(selectedImages)
+	docker.ForEach(selectedImages, max(docker.SaveConcurrency(), transferConcurrency), func(imageName string) {
+		job.MarkRunning(imageName)
 		ExportImageToCloud(cli, imageName, cloudPath, bdfsClient)
-	}
+		job.MarkFailed(imageName)
+	})
+	job.Finish()
+	docker.PrintSummary()
+	docker.WriteDeliveryReport(Location(cloudPath))
 }
 
-func ExportImageToCloud(cli *client.Client, imageName, cloudPath string, bdfsClient *pan.Client) {
+func ExportImageToCloud(cli docker.ImageClient, imageName, cloudPath string, bdfsClient Storage) {
+	// Record the export in the state database, as failed unless it completes
+	record := state.Record{Time: time.Now(), Operation: state.Export, Image: imageName, Result: state.Failed}
+	defer func() { state.Add(record) }()
+
 	// Inspect the image to get additional info like OS and architecture
-	imageInspect, _, err := cli.ImageInspectWithRaw(context.Background(), imageName)
+	ctx, cancel := docker.CallContext()
+	imageInspect, _, err := cli.ImageInspectWithRaw(ctx, imageName)
+	cancel()
 	var osInfo, archInfo string
 	if err != nil {
 		// If inspection fails, we'll use empty values for OS and arch, but log the error
@@ -135,263 +266,727 @@ func ExportImageToCloud(cli *client.Client, imageName, cloudPath string, bdfsCli
 		osInfo = imageInspect.Os
 		archInfo = imageInspect.Architecture
 	}
+	record.ImageID, record.Digests = imageInspect.ID, imageInspect.RepoDigests
 
-	// Parse the image name and tag
-	nameParts := strings.Split(imageName, ":")
-	imageNameOnly := nameParts[0]
-	tag := ""
-	if len(nameParts) > 1 {
-		tag = nameParts[1]
+	// Name the archive after the image and its platform using the file name template
+	tarFileName, err := docker.ArchiveName(imageName, osInfo, archInfo, imageInspect.ID)
+	if err != nil {
+		i18n.Printf("[x] Failed to name the archive of image %s: %v\n", imageName, err)
+		return
 	}
 
-	// Sanitize the image name for filename (replace '/' with '·')
-	sanitizedImageName := strings.ReplaceAll(imageNameOnly, "/", "·")
+	// A remote file named after the image digest already holds exactly this image, so skip the export; an archive
+	// too large for the account is stored in parts under its split manifest. Other names that are taken are kept,
+	// numbered or timestamped as --on-conflict says.
+	exists := func(name string) bool {
+		remotePath := path.Join(cloudPath, docker.SealedName(docker.CompressedName(name)))
+		for _, existingPath := range []string{remotePath, remotePath + docker.SplitSuffix, remotePath + docker.DeltaSuffix} {
+			if _, err := bdfsClient.GetFileInfoByPath(existingPath); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+	upToDate := docker.NameHasDigest() && imageInspect.ID != "" && exists(tarFileName)
+	skip := upToDate
+	if !upToDate {
+		tarFileName, skip = docker.ResolveConflict(tarFileName, exists, time.Now())
+	}
 
-	var tarFileName string
-	// Format: <image_name>_<tag>_<os>_<arch>.tar
-	var suffixParts []string
-	if tag != "" {
-		suffixParts = append(suffixParts, tag)
-	} else {
-		// Always include a tag value, use "latest" as default if not available
-		suffixParts = append(suffixParts, "latest")
+	plainFileName := docker.CompressedName(tarFileName)
+	tarFileName = docker.SealedName(plainFileName)
+	remoteFilePath := path.Join(cloudPath, tarFileName)
+	record.Location = Location(remoteFilePath)
+	if deltaExports {
+		record.Location += docker.DeltaSuffix
 	}
-	if osInfo != "" {
-		suffixParts = append(suffixParts, osInfo)
-	} else {
-		// If OS info is not available, add a placeholder
-		suffixParts = append(suffixParts, "unknown")
+	if skip {
+		if upToDate {
+			i18n.Printf("[√] %s is already up to date at %s\n", imageName, remoteFilePath)
+		} else {
+			i18n.Printf("[√] Skipped %s, %s already exists\n", imageName, remoteFilePath)
+		}
+		record.Result = state.Skipped
+		job.MarkSkipped(imageName)
+		return
 	}
-	if archInfo != "" {
-		suffixParts = append(suffixParts, archInfo)
-	} else {
-		// If architecture info is not available, add a placeholder
-		suffixParts = append(suffixParts, "unknown")
+
+	// Create the folder of the archive before the image is saved, so that a folder that cannot be created does not
+	// fail the export only once it uploads
+	if err := ensureFolder(bdfsClient, path.Dir(remoteFilePath)); err != nil {
+		i18n.Printf("[x] %v\n", err)
+		return
 	}
 
-	tarFileName = fmt.Sprintf("%s_%s.tar", sanitizedImageName, strings.Join(suffixParts, "_"))
+	// Stream the archive into the storage instead of writing it to a temporary file first if requested
+	if streamUploads && canStream(bdfsClient) {
+		streamImageToCloud(cli, imageName, imageInspect.RepoTags, remoteFilePath, bdfsClient, &record)
+		return
+	}
 
-	// Create temporary file to save the image
-	tempDir := "/tmp/go-dkci"
+	// Create temporary file to save the image in the directory of this run (the name template may place it in
+	// a subdirectory)
+	workDir, err := runDir()
+	if err != nil {
+		i18n.Printf("[x] %v\n", err)
+		return
+	}
+	tempFilePath := filepath.Join(workDir, tarFileName)
+	tempDir := filepath.Dir(tempFilePath)
 	err = os.MkdirAll(tempDir, 0755)
 	if err != nil {
-		fmt.Printf("[x] Failed to create temp directory %s: %v\n", tempDir, err)
+		i18n.Printf("[x] Failed to create temp directory %s: %v\n", tempDir, err)
 		return
 	}
 
-	tempFilePath := filepath.Join(tempDir, tarFileName)
+	// Let the pre_export hook stop the export
+	if err := hook.Run(hook.Context{Hook: hook.PreExport, Image: imageName, Archive: tempFilePath, CloudPath: remoteFilePath}); err != nil {
+		i18n.Printf("[x] %v\n", err)
+		return
+	}
 
 	fmt.Printf("Exporting image %s to temporary file %s...\n", imageName, tempFilePath)
 
-	// Export the image to temporary file
-	imageReader, err := cli.ImageSave(context.Background(), []string{imageName})
-	if err != nil {
-		fmt.Printf("[x] Failed to export image %s: %v\n", imageName, err)
+	// Export the image to the temporary file, compressing it if requested; it is wrapped in a
+	// password-protected container afterwards if requested
+	plainFilePath := filepath.Join(workDir, plainFileName)
+	if err := docker.SaveImage(cli, docker.SaveReference(imageInspect.RepoTags, imageName), plainFilePath); err != nil {
+		i18n.Printf("[x] %v\n", err)
 		return
 	}
-	defer imageReader.Close()
 
-	// Create the output file
-	outFile, err := os.Create(tempFilePath)
-	if err != nil {
-		fmt.Printf("[x] Failed to create temporary file %s: %v\n", tempFilePath, err)
+	// Wrap the archive in a password-protected container if requested
+	if docker.Sealed() {
+		if _, err := docker.SealArchive(plainFilePath); err != nil {
+			i18n.Printf("[x] Failed to encrypt %s: %v\n", plainFilePath, err)
+			os.Remove(plainFilePath)
+			return
+		}
+	}
+
+	// Skip the upload if a bit-identical archive already exists remotely
+	if !deltaExports && remoteUpToDate(bdfsClient, tempFilePath, remoteFilePath) {
+		record.SetArchive(tempFilePath)
+		record.Result = state.Skipped
+		if keepTempFiles {
+			keepFiles(tempFilePath)
+		} else {
+			os.Remove(tempFilePath)
+		}
+		i18n.Printf("[√] %s is already up to date at %s\n", imageName, remoteFilePath)
+		job.MarkSkipped(imageName)
 		return
 	}
-	defer outFile.Close()
 
-	// Copy the image data to the temporary tar file
-	_, err = io.Copy(outFile, imageReader)
+	// Files uploaded next to the archive, such as its signature and SBOM
+	var sidecars []string
+	removeTempFiles := func() {
+		os.Remove(tempFilePath)
+		for _, sidecar := range sidecars {
+			os.Remove(sidecar)
+		}
+	}
+
+	// Generate the SBOM from the archive if requested
+	if sbom.Enabled() {
+		sbomPath, err := sbom.Generate(tempFilePath)
+		if err != nil {
+			i18n.Printf("[x] Failed to generate SBOM for %s: %v\n", tempFilePath, err)
+			removeTempFiles()
+			return
+		}
+		sidecars = append(sidecars, sbomPath)
+
+		// Summarize the licenses of its packages for compliance if requested
+		if sbom.LicenseReportEnabled() {
+			reportPath, err := sbom.WriteLicenseReport(tempFilePath, imageName, sbomPath)
+			if err != nil {
+				i18n.Printf("[x] Failed to write license report for %s: %v\n", tempFilePath, err)
+				removeTempFiles()
+				return
+			}
+			sidecars = append(sidecars, reportPath)
+		}
+	}
+
+	// Record the provenance of the archive if requested
+	signedFiles := []string{tempFilePath}
+	if attest.Enabled() {
+		attestationPath, err := attest.Write(tempFilePath, imageName, imageInspect.ID, imageInspect.RepoDigests)
+		if err != nil {
+			i18n.Printf("[x] Failed to write attestation for %s: %v\n", tempFilePath, err)
+			removeTempFiles()
+			return
+		}
+		sidecars = append(sidecars, attestationPath)
+		signedFiles = append(signedFiles, attestationPath)
+	}
+
+	// Sign the archive (and its attestation) before uploading so the signature covers exactly the uploaded bytes
+	if sign.Enabled() {
+		for _, filePath := range signedFiles {
+			signaturePath, err := sign.SignFile(filePath)
+			if err != nil {
+				i18n.Printf("[x] Failed to sign %s: %v\n", filePath, err)
+				removeTempFiles()
+				return
+			}
+			sidecars = append(sidecars, signaturePath)
+		}
+	}
+
+	// Upload the temporary file to Baidu cloud, or only its new layers and a recipe for delta exports, or in parts
+	// if it is larger than the account can upload as one file
+	transferLimit.Acquire()
+	if deltaExports {
+		fmt.Printf("Uploading the new layers of %s to Baidu cloud path %s...\n", tempFilePath, remoteFilePath+docker.DeltaSuffix)
+		err = uploadDelta(bdfsClient, tempFilePath, remoteFilePath, cloudPath)
+	} else if limit, size := maxUploadSize(bdfsClient), localFileSize(tempFilePath); limit > 0 && size > limit {
+		fmt.Printf("Uploading %s to Baidu cloud path %s in parts of %s, the largest files the account can upload...\n",
+			tempFilePath, remoteFilePath+docker.SplitSuffix, docker.FormatSize(limit))
+		err = uploadSplit(bdfsClient, tempFilePath, remoteFilePath, limit)
+		record.Location = Location(remoteFilePath + docker.SplitSuffix)
+	} else {
+		fmt.Printf("Uploading %s to Baidu cloud path %s...\n", tempFilePath, remoteFilePath)
+		err = UploadVerified(bdfsClient, tempFilePath, remoteFilePath)
+	}
+	transferLimit.Release()
 	if err != nil {
-		fmt.Printf("[x] Failed to write image %s to temporary file %s: %v\n", imageName, tempFilePath, err)
+		i18n.Printf("[x] Failed to upload %s to Baidu cloud: %v\n", tempFilePath, err)
+		// Clean up the temporary files
+		removeTempFiles()
 		return
 	}
+	record.SetArchive(tempFilePath)
 
-	// Upload the temporary file to Baidu cloud
-	remoteFilePath := filepath.Join(cloudPath, tarFileName)
-
-	fmt.Printf("Uploading %s to Baidu cloud path %s...\n", tempFilePath, remoteFilePath)
-	if err := bdfsClient.UploadFile(tempFilePath, remoteFilePath); err != nil {
-		fmt.Printf("[x] Failed to upload %s to Baidu cloud: %v\n", tempFilePath, err)
-		// Clean up the temporary file
-		os.Remove(tempFilePath)
+	// The post_export hook runs once the archive is uploaded, while the temporary file still exists
+	if err := hook.Run(hook.Context{Hook: hook.PostExport, Image: imageName, Archive: tempFilePath, CloudPath: remoteFilePath}); err != nil {
+		i18n.Printf("[x] %v\n", err)
+		removeTempFiles()
 		return
 	}
 
-	// Clean up the temporary file after successful upload
-	if err := os.Remove(tempFilePath); err != nil {
+	// Clean up the temporary file after successful upload unless it should be kept
+	if keepTempFiles {
+		keepFiles(tempFilePath)
+	} else if err := os.Remove(tempFilePath); err != nil {
 		fmt.Printf("Warning: Failed to remove temporary file %s: %v\n", tempFilePath, err)
 	}
 
-	fmt.Printf("[√] Successfully exported and uploaded image %s to %s\n", imageName, remoteFilePath)
+	// Upload the sidecar files alongside the archive
+	for _, sidecar := range sidecars {
+		remoteSidecarPath := remoteFilePath + strings.TrimPrefix(sidecar, tempFilePath)
+		fmt.Printf("Uploading %s to Baidu cloud path %s...\n", sidecar, remoteSidecarPath)
+		err := UploadVerified(bdfsClient, sidecar, remoteSidecarPath)
+		if err == nil && keepTempFiles {
+			keepFiles(sidecar)
+		} else {
+			os.Remove(sidecar)
+		}
+		if err != nil {
+			i18n.Printf("[x] Failed to upload %s to Baidu cloud: %v\n", sidecar, err)
+			return
+		}
+	}
+
+	i18n.Printf("[√] Successfully exported and uploaded image %s to %s\n", imageName, remoteFilePath)
+	record.Result = state.OK
+	job.MarkDone(imageName)
 }
 
-// ImportImagesFromCloud downloads Docker images from Baidu cloud disk and imports them to local Docker
-func ImportImagesFromCloud(cloudPath string, grepPattern string) {
-	// Get BDFS configuration
-	configData, err := config.GetBDFSConfig()
+// streamImageToCloud exports an image by streaming its archive from Docker, through the compressor if requested,
+// into the storage, without a temporary file. The hooks are given no archive.
+func streamImageToCloud(cli docker.ImageClient, imageName string, tags []string, remoteFilePath string, bdfsClient Storage, record *state.Record) {
+	// Let the pre_export hook stop the export
+	if err := hook.Run(hook.Context{Hook: hook.PreExport, Image: imageName, CloudPath: remoteFilePath}); err != nil {
+		i18n.Printf("[x] %v\n", err)
+		return
+	}
+
+	fmt.Printf("Streaming image %s to Baidu cloud path %s...\n", imageName, remoteFilePath)
+	saveName := docker.SaveReference(tags, imageName)
+	transferLimit.Acquire()
+	size, checksum, err := UploadStreamVerified(bdfsClient, remoteFilePath, func(w io.Writer) error {
+		return docker.WriteImage(cli, saveName, w)
+	})
+	transferLimit.Release()
 	if err != nil {
-		fmt.Printf("[x] Error getting BDFS configuration: %v\n", err)
-		os.Exit(1)
+		i18n.Printf("[x] Failed to upload %s to Baidu cloud: %v\n", remoteFilePath, err)
+		return
 	}
+	record.Size, record.SHA256 = size, checksum
 
-	// Create a BDFS client with the provided config
-	bdfsClient := pan.NewClient(configData.ClientID, configData.ClientSecret, configData.TokenPath)
+	if err := hook.Run(hook.Context{Hook: hook.PostExport, Image: imageName, CloudPath: remoteFilePath}); err != nil {
+		i18n.Printf("[x] %v\n", err)
+		return
+	}
 
+	i18n.Printf("[√] Successfully exported and uploaded image %s to %s\n", imageName, remoteFilePath)
+	record.Result = state.OK
+	job.MarkDone(imageName)
+}
+
+// ImportImagesFromCloud downloads Docker images from Baidu cloud disk and imports them to local Docker.
+// If fileNames is not empty, exactly those files of the cloud directory are imported without prompting.
+func ImportImagesFromCloud(cloudPath string, filter docker.Filter, fileNames []string) {
 	// Login to Baidu cloud
-	if err := bdfsClient.Authorize(context.Background()); err != nil {
-		fmt.Printf("[x] Failed to login to Baidu cloud: %v\n", err)
-		os.Exit(1)
-	}
+	bdfsClient := Login()
 
-	fmt.Println("[√] Successfully logged in to Baidu cloud")
+	// Import the latest run folder of the cloud folder if requested
+	if docker.LatestRun() {
+		cloudPath = latestCloudRun(bdfsClient, cloudPath)
+	}
 
-	// Check if the cloud path is a directory by trying to list it
-	files, err := bdfsClient.ListFiles(cloudPath)
+	// Check if the cloud path is a directory by trying to list it. Archives named by a template
+	// with subdirectories are searched for as deep as the template goes.
+	files, err := ListFilesRecursive(bdfsClient, cloudPath, docker.NameDepth())
 	if err != nil {
 		// If listing fails, assume it's a single file
 		// Check if it's a tar file
 		fileInfo, err := bdfsClient.GetFileInfoByPath(cloudPath)
 		if err != nil {
-			fmt.Printf("[x] Error accessing cloud file %s: %v\n", cloudPath, err)
+			i18n.Printf("[x] Error accessing cloud file %s: %v\n", cloudPath, err)
 			os.Exit(1)
 		}
 
-		if strings.HasSuffix(strings.ToLower(fileInfo.Path), ".tar") ||
-			strings.HasSuffix(strings.ToLower(fileInfo.Path), ".tar.gz") ||
-			strings.HasSuffix(strings.ToLower(fileInfo.Path), ".tgz") {
+		if docker.IsArchiveName(fileInfo.Path) || docker.IsIndexName(fileInfo.Path) {
 
 			// Directly download and import the single file
-			downloadAndImportFromCloud(bdfsClient, fileInfo.Path)
+			DownloadAndImportFromCloud(bdfsClient, fileInfo.Path)
 		} else {
 			// The path is a file but not a tar file
-			fmt.Printf("[x] The specified file %s is not a .tar file\n", cloudPath)
+			i18n.Printf("[x] The specified file %s is not a .tar file\n", cloudPath)
 			os.Exit(1)
 		}
 	} else {
+		// Files named on the command line are matched without the filter
+		if len(fileNames) > 0 {
+			filter = docker.Filter{}
+		}
+
 		// It's a directory, filter files to only include .tar files
 		tarFiles := []pan.FileInfo{}
 		for _, file := range files {
-			if strings.HasSuffix(strings.ToLower(file.Path), ".tar") ||
-				strings.HasSuffix(strings.ToLower(file.Path), ".tar.gz") ||
-				strings.HasSuffix(strings.ToLower(file.Path), ".tgz") {
-
-				// Apply grep filter if pattern is provided
-				if grepPattern != "" {
-					// Extract image name information from the file name for filtering
-					baseName := strings.TrimSuffix(filepath.Base(file.Path), filepath.Ext(file.Path))
-					// If the file name (without extension) contains the grep pattern, include it
-					if strings.Contains(baseName, grepPattern) {
-						tarFiles = append(tarFiles, file)
-					}
-				} else {
+			if docker.IsArchiveName(file.Path) || docker.IsIndexName(file.Path) {
+
+				// If the file name matches the filter, include it; container and volume exports are not image archives
+				if filter.MatchFile(file.Path) && !docker.IsFilesystemArchive(file.Path) {
 					tarFiles = append(tarFiles, file)
 				}
 			}
 		}
 
 		if len(tarFiles) == 0 {
-			fmt.Println("[x] No .tar files found in the specified cloud directory")
+			i18n.Println("[x] No .tar files found in the specified cloud directory")
 			os.Exit(1)
 		}
 
-		// Prepare options for selection
-		selectionOptions := make([]string, len(tarFiles))
+		fileEntries := make([]docker.FileEntry, len(tarFiles))
 		for i, file := range tarFiles {
-			selectionOptions[i] = filepath.Base(file.Path)
+			fileEntries[i] = docker.FileEntry{Path: file.Path, Size: file.Size, Modified: file.ServerMtime}
 		}
 
-		// Add "All" option if there are more than 1 files
-		if len(tarFiles) > 1 {
-			selectionOptions = append([]string{"All"}, selectionOptions...)
+		var selectedFilePaths []string
+		if len(fileNames) > 0 {
+			// Import exactly the files given on the command line
+			selectedFilePaths = docker.ResolveFiles(fileEntries, fileNames)
+		} else {
+			// Offer only the newest archive of each image if requested
+			selectedFilePaths = docker.SelectFiles(docker.LatestFiles(fileEntries), "Select .tar files to download and import as Docker images:")
+			if len(selectedFilePaths) == 0 {
+				i18n.Println("[x] No files selected for import")
+				os.Exit(1)
+			}
 		}
 
-		// Show multi-select list to the user
-		selectedFiles := []string{}
-		prompt := &survey.MultiSelect{
-			Message: "Select .tar files to download and import as Docker images:",
-			Options: selectionOptions,
+		// Download and import the selected files
+		docker.ImportEach(selectedFilePaths, max(docker.LoadConcurrency(), transferConcurrency), func(filePath string) ([]string, error) {
+			return downloadAndImport(bdfsClient, filePath)
+		})
+	}
+}
+
+// DownloadAndImportFromCloud downloads a file from cloud and imports it as a Docker image
+func DownloadAndImportFromCloud(bdfsClient Storage, cloudFilePath string) {
+	docker.ImportOne(cloudFilePath, func(cloudFilePath string) ([]string, error) {
+		return downloadAndImport(bdfsClient, cloudFilePath)
+	})
+}
+
+// fetchArchive downloads a cloud file to the directory of this run, rebuilding delta exports, and returns the path
+// of the local archive. A copy kept in ~/.cache/go-dkci by an earlier run is used instead if it is up to date;
+// cached is then true, and the caller must leave the file in place for later runs. At most the transfer
+// concurrency of files are fetched at once.
+func fetchArchive(bdfsClient Storage, cloudFilePath string) (localPath string, cached bool, err error) {
+	workDir, err := runDir()
+	if err != nil {
+		return "", false, err
+	}
+	cachedFilePath := filepath.Join(config.TempDir(), filepath.Base(cloudFilePath))
+	localFilePath := filepath.Join(workDir, filepath.Base(cloudFilePath))
+
+	transferLimit.Acquire()
+	defer transferLimit.Release()
+
+	if isDeltaRecipe(cloudFilePath) {
+		// Rebuild the archive of a delta export from its recipe and layers
+		localFilePath = localFilePath[:len(localFilePath)-len(docker.DeltaSuffix)]
+		fmt.Printf("Rebuilding %s from delta recipe %s...\n", localFilePath, cloudFilePath)
+		if err := restoreDelta(bdfsClient, cloudFilePath, localFilePath); err != nil {
+			os.Remove(localFilePath)
+			return "", false, fmt.Errorf("failed to rebuild %s from Baidu cloud: %v", cloudFilePath, err)
 		}
+	} else if isSplitManifest(cloudFilePath) {
+		// Join the parts of an archive too large to be stored as one file
+		localFilePath = localFilePath[:len(localFilePath)-len(docker.SplitSuffix)]
+		fmt.Printf("Downloading %s in the parts listed by %s...\n", localFilePath, cloudFilePath)
+		if err := restoreSplit(bdfsClient, cloudFilePath, localFilePath); err != nil {
+			os.Remove(localFilePath)
+			return "", false, fmt.Errorf("failed to download %s from Baidu cloud: %v", cloudFilePath, err)
+		}
+	} else if !noCache && remoteUpToDate(bdfsClient, cachedFilePath, cloudFilePath) {
+		// Reuse a cached copy with the same size and checksum instead of downloading the file again
+		fmt.Printf("Using cached file %s for %s\n", cachedFilePath, cloudFilePath)
+		return cachedFilePath, true, nil
+	} else {
+		fmt.Printf("Downloading %s from Baidu cloud to temporary file %s...\n", cloudFilePath, localFilePath)
+		if err := DownloadToFile(bdfsClient, cloudFilePath, localFilePath); err != nil {
+			return "", false, fmt.Errorf("failed to download %s from Baidu cloud: %v", cloudFilePath, err)
+		}
+	}
+	return localFilePath, false, nil
+}
 
-		err = survey.AskOne(prompt, &selectedFiles)
-		if err != nil {
-			fmt.Printf("[x] Failed to get user selection: %v\n", err)
-			os.Exit(1)
+// downloadAndImport downloads a file from cloud and imports it, returning the loaded images and reporting
+// failures instead of exiting
+func downloadAndImport(bdfsClient Storage, cloudFilePath string) (images []string, err error) {
+	// Record the import in the state database
+	record := state.Record{Time: time.Now(), Operation: state.Import, Location: Location(cloudFilePath)}
+	defer func() {
+		record.Images = images
+		record.Finish(err)
+		state.Add(record)
+	}()
+
+	// Download the file to the directory of this run
+	localFilePath, cached, err := fetchArchive(bdfsClient, cloudFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch the detached signature as well so the import can verify it. A missing signature is
+	// not an error here; the verification reports it.
+	if sign.VerifyEnabled() {
+		localSignaturePath := sign.SignatureFile(localFilePath)
+		if err := DownloadToFile(bdfsClient, sign.SignatureFile(cloudFilePath), localSignaturePath); err != nil {
+			os.Remove(localSignaturePath)
+		} else if keepTempFiles {
+			defer keepFiles(localSignaturePath)
+		} else {
+			defer os.Remove(localSignaturePath)
 		}
+	}
 
-		// Handle "All" selection
-		if len(selectedFiles) == 1 && selectedFiles[0] == "All" {
-			// Select all tar files
-			selectedFiles = []string{}
-			for _, file := range tarFiles {
-				selectedFiles = append(selectedFiles, filepath.Base(file.Path))
-			}
+	// Import the downloaded file using the existing docker import functionality
+	images, err = docker.ImportFile(localFilePath)
+	record.SetArchive(localFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clean up the temporary file after successful import unless it should be kept, or is the cached copy the
+	// import came from
+	if cached {
+		return images, nil
+	}
+	if keepTempFiles {
+		keepFiles(localFilePath)
+		return images, nil
+	}
+	if err := os.Remove(localFilePath); err != nil {
+		fmt.Printf("Warning: Failed to remove temporary file %s: %v\n", localFilePath, err)
+	}
+	return images, nil
+}
+
+// ListFilesRecursive lists the files of a cloud directory and of its subdirectories down to depth levels
+func ListFilesRecursive(bdfsClient Storage, dirPath string, depth int) ([]pan.FileInfo, error) {
+	files, err := bdfsClient.ListFiles(dirPath)
+	if err != nil || depth <= 1 {
+		return files, err
+	}
+
+	var result []pan.FileInfo
+	for _, file := range files {
+		if file.IsDir != 1 {
+			result = append(result, file)
+			continue
+		}
+		subFiles, err := ListFilesRecursive(bdfsClient, file.Path, depth-1)
+		if err != nil {
+			return nil, err
 		}
+		result = append(result, subFiles...)
+	}
+	return result, nil
+}
 
-		if len(selectedFiles) == 0 {
-			fmt.Println("[x] No files selected for import")
-			os.Exit(1)
+// remoteUpToDate reports whether the remote file has the same size and MD5 checksum as the local file.
+// Any error while fetching the remote metadata is treated as "not up to date".
+func remoteUpToDate(bdfsClient Storage, localFilePath, remoteFilePath string) bool {
+	remoteInfo, err := bdfsClient.GetDetailedFileInfo(remoteFilePath)
+	if err != nil || remoteInfo.MD5 == "" {
+		return false
+	}
+	return compareWithRemote(localFilePath, remoteInfo) == nil
+}
+
+// UploadVerified uploads a file and checks the size and MD5 reported by the server against the local file,
+// repeating the upload up to uploadRetries times on mismatch
+func UploadVerified(bdfsClient Storage, localFilePath, remoteFilePath string) error {
+	for attempt := 0; ; attempt++ {
+		if err := uploadFile(bdfsClient, localFilePath, remoteFilePath); err != nil {
+			return err
 		}
 
-		// Map selected filenames back to full paths
-		selectedFilePaths := []string{}
-		for _, selectedFile := range selectedFiles {
-			for _, tarFile := range tarFiles {
-				if filepath.Base(tarFile.Path) == selectedFile {
-					selectedFilePaths = append(selectedFilePaths, tarFile.Path)
-					break
-				}
-			}
+		remoteInfo, err := bdfsClient.GetDetailedFileInfo(remoteFilePath)
+		if err == nil {
+			err = compareWithRemote(localFilePath, remoteInfo)
+		}
+		if err == nil {
+			job.AddBytes(remoteInfo.Size)
+			return nil
 		}
 
-		// Download and import each selected file
-		for _, filePath := range selectedFilePaths {
-			downloadAndImportFromCloud(bdfsClient, filePath)
+		if attempt >= uploadRetries {
+			return fmt.Errorf("upload verification failed: %v", err)
 		}
+		fmt.Printf("Warning: Upload verification of %s failed: %v, retrying (%d/%d)...\n", remoteFilePath, err, attempt+1, uploadRetries)
 	}
 }
 
-// downloadAndImportFromCloud downloads a file from cloud and imports it as a Docker image
-func downloadAndImportFromCloud(bdfsClient *pan.Client, cloudFilePath string) {
-	// Create temporary directory for downloads
-	tempDir := "/tmp/go-dkci"
-	err := os.MkdirAll(tempDir, 0755)
+// compareWithRemote returns an error if the size or MD5 of the local file differs from the remote metadata.
+// The MD5 is only compared when the server reports one, and comes from the hashing of the file while it was
+// written or uploaded, if it was.
+func compareWithRemote(localFilePath string, remoteInfo *pan.FileInfo) error {
+	localInfo, err := os.Stat(localFilePath)
 	if err != nil {
-		fmt.Printf("[x] Failed to create temp directory %s: %v\n", tempDir, err)
-		os.Exit(1)
+		return err
+	}
+	if localInfo.Size() != remoteInfo.Size {
+		return fmt.Errorf("size mismatch (local %d bytes, remote %d bytes)", localInfo.Size(), remoteInfo.Size)
 	}
 
-	// Download the file to the temporary directory
-	localFilePath := filepath.Join(tempDir, filepath.Base(cloudFilePath))
+	if remoteInfo.MD5 == "" {
+		return nil
+	}
+	sums, err := checksum.File(localFilePath)
+	if err != nil {
+		return err
+	}
+	return compareMD5(sums.MD5, remoteInfo.MD5)
+}
 
-	fmt.Printf("Downloading %s from Baidu cloud to temporary file %s...\n", cloudFilePath, localFilePath)
+// compareMD5 returns an error if the MD5 of a local file differs from the one the server reports
+func compareMD5(localMD5, remoteMD5 string) error {
+	if remoteMD5 != "" && !strings.EqualFold(localMD5, remoteMD5) {
+		return fmt.Errorf("MD5 mismatch (local %s, remote %s)", localMD5, remoteMD5)
+	}
+	return nil
+}
+
+// DownloadToFile streams a Baidu cloud file into a local file
+func DownloadToFile(bdfsClient Storage, cloudFilePath, localFilePath string) error {
 	// Download file content as stream
 	resp, err := bdfsClient.DownloadFile(cloudFilePath)
 	if err != nil {
-		fmt.Printf("[x] Failed to download %s from Baidu cloud: %v\n", cloudFilePath, err)
-		os.Exit(1)
+		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download request failed with status %d", resp.StatusCode)
+	}
+
+	// Fail before writing anything when the file cannot fit
+	if err := docker.CheckFreeSpace(filepath.Dir(localFilePath), resp.ContentLength); err != nil {
+		return err
+	}
+
 	// Create local file to write to
 	outFile, err := os.Create(localFilePath)
 	if err != nil {
-		fmt.Printf("[x] Failed to create local file %s: %v\n", localFilePath, err)
-		os.Exit(1)
+		return fmt.Errorf("failed to create local file %s: %v", localFilePath, err)
 	}
 	defer outFile.Close()
+	config.Track(localFilePath)
+
+	// Copy downloaded content to local file, giving up when the connection stalls, and hash it on the way
+	watchdog := docker.NewWatchdog(timeout, "Baidu cloud", func() { resp.Body.Close() })
+	defer watchdog.Stop()
+	hasher := checksum.New()
+	written, err := io.Copy(io.MultiWriter(outFile, hasher), watchdog.Reader(resp.Body))
+	if err == nil {
+		err = outFile.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write downloaded content to %s: %v", localFilePath, err)
+	}
+	checksum.Remember(localFilePath, hasher.Sums())
+	job.AddBytes(written)
+	return nil
+}
 
-	// Copy downloaded content to local file
-	_, err = io.Copy(outFile, resp.Body)
+// FetchAttestation downloads the attestation of a cloud archive (and its signature, if any) to the directory of
+// this run and returns the local attestation path. The caller removes the downloaded files.
+func FetchAttestation(cloudFilePath string) string {
+	bdfsClient := Login()
+
+	tempDir, err := runDir()
 	if err != nil {
-		fmt.Printf("[x] Failed to write downloaded content to %s: %v\n", localFilePath, err)
+		i18n.Printf("[x] %v\n", err)
 		os.Exit(1)
 	}
 
-	// Import the downloaded file using the existing docker import functionality
-	docker.ImportImagesFromSource(localFilePath, "") // No grep pattern needed for single file download
+	remoteAttestationPath := cloudFilePath
+	if !strings.HasSuffix(remoteAttestationPath, attest.AttestationSuffix) {
+		remoteAttestationPath = attest.File(remoteAttestationPath)
+	}
+	localAttestationPath := filepath.Join(tempDir, filepath.Base(remoteAttestationPath))
 
-	// Clean up the temporary file after successful import
-	if err := os.Remove(localFilePath); err != nil {
-		fmt.Printf("Warning: Failed to remove temporary file %s: %v\n", localFilePath, err)
+	if err := DownloadToFile(bdfsClient, remoteAttestationPath, localAttestationPath); err != nil {
+		os.Remove(localAttestationPath)
+		i18n.Printf("[x] Failed to download attestation %s from Baidu cloud: %v\n", remoteAttestationPath, err)
+		os.Exit(1)
+	}
+
+	// The signature is optional; verification reports it missing
+	localSignaturePath := sign.SignatureFile(localAttestationPath)
+	if err := DownloadToFile(bdfsClient, sign.SignatureFile(remoteAttestationPath), localSignaturePath); err != nil {
+		os.Remove(localSignaturePath)
+	}
+
+	return localAttestationPath
+}
+
+// ExportContainersToCloud exports the filesystems of the given containers to Baidu cloud disk
+func ExportContainersToCloud(cloudPath string, containerNames []string) {
+	// Login to Baidu cloud
+	bdfsClient := Login()
+
+	// Initialize Docker client
+	cli, err := docker.NewClient("")
+	if err != nil {
+		i18n.Printf("[x] Failed to create Docker client: %v\n", err)
+		os.Exit(1)
+	}
+	defer cli.Close()
+
+	tempDir, err := runDir()
+	if err != nil {
+		i18n.Printf("[x] %v\n", err)
+		os.Exit(1)
+	}
+	if err := ensureFolder(bdfsClient, cloudPath); err != nil {
+		i18n.Printf("[x] %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, containerName := range containerNames {
+		tempFilePath, err := docker.SaveContainer(cli, containerName, tempDir)
+		if err != nil {
+			i18n.Printf("[x] Failed to export container %s: %v\n", containerName, err)
+			continue
+		}
+
+		remoteFilePath := path.Join(cloudPath, filepath.Base(tempFilePath))
+		fmt.Printf("Uploading %s to Baidu cloud path %s...\n", tempFilePath, remoteFilePath)
+		err = UploadVerified(bdfsClient, tempFilePath, remoteFilePath)
+		if err == nil && keepTempFiles {
+			keepFiles(tempFilePath)
+		} else {
+			os.Remove(tempFilePath)
+		}
+		if err != nil {
+			i18n.Printf("[x] Failed to upload %s to Baidu cloud: %v\n", tempFilePath, err)
+			continue
+		}
+
+		i18n.Printf("[√] Successfully exported and uploaded container %s to %s\n", containerName, remoteFilePath)
+	}
+}
+
+// ExportVolumesToCloud exports the contents of the given volumes to Baidu cloud disk
+func ExportVolumesToCloud(cloudPath string, volumeNames []string) {
+	// Login to Baidu cloud
+	bdfsClient := Login()
+
+	// Initialize Docker client
+	cli, err := docker.NewClient("")
+	if err != nil {
+		i18n.Printf("[x] Failed to create Docker client: %v\n", err)
+		os.Exit(1)
+	}
+	defer cli.Close()
+
+	tempDir, err := runDir()
+	if err != nil {
+		i18n.Printf("[x] %v\n", err)
+		os.Exit(1)
+	}
+	if err := ensureFolder(bdfsClient, cloudPath); err != nil {
+		i18n.Printf("[x] %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, volumeName := range volumeNames {
+		tempFilePath, err := docker.SaveVolume(cli, volumeName, tempDir)
+		if err != nil {
+			i18n.Printf("[x] Failed to export volume %s: %v\n", volumeName, err)
+			continue
+		}
+
+		remoteFilePath := path.Join(cloudPath, filepath.Base(tempFilePath))
+		fmt.Printf("Uploading %s to Baidu cloud path %s...\n", tempFilePath, remoteFilePath)
+		err = UploadVerified(bdfsClient, tempFilePath, remoteFilePath)
+		if err == nil && keepTempFiles {
+			keepFiles(tempFilePath)
+		} else {
+			os.Remove(tempFilePath)
+		}
+		if err != nil {
+			i18n.Printf("[x] Failed to upload %s to Baidu cloud: %v\n", tempFilePath, err)
+			continue
+		}
+
+		i18n.Printf("[√] Successfully exported and uploaded volume %s to %s\n", volumeName, remoteFilePath)
+	}
+}
+
+// ImportVolumeFromCloud downloads a volume export from Baidu cloud disk and restores it into the named volume.
+// An empty volumeName restores into the volume the file was exported from.
+func ImportVolumeFromCloud(cloudFilePath, volumeName string) {
+	// Login to Baidu cloud
+	bdfsClient := Login()
+
+	tempDir, err := runDir()
+	if err != nil {
+		i18n.Printf("[x] %v\n", err)
+		os.Exit(1)
+	}
+
+	localFilePath := filepath.Join(tempDir, filepath.Base(cloudFilePath))
+	fmt.Printf("Downloading %s from Baidu cloud to temporary file %s...\n", cloudFilePath, localFilePath)
+	if err := DownloadToFile(bdfsClient, cloudFilePath, localFilePath); err != nil {
+		os.Remove(localFilePath)
+		i18n.Printf("[x] Failed to download %s from Baidu cloud: %v\n", cloudFilePath, err)
+		os.Exit(1)
+	}
+
+	docker.ImportVolume(localFilePath, volumeName)
+	if keepTempFiles {
+		keepFiles(localFilePath)
+	} else {
+		os.Remove(localFilePath)
 	}
 }
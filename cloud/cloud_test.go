@@ -0,0 +1,112 @@
+package cloud
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/docker"
+)
+
+// newTestStorage returns a dir:// storage holding the archive of nginx:1.27 at /images, with the working
+// directory and configuration of go-dkci in temporary directories
+func newTestStorage(t *testing.T) (*DirStorage, string) {
+	t.Helper()
+	t.Setenv("DKCI_TEMP_DIR", filepath.Join(t.TempDir(), "cache"))
+	t.Setenv("BDFS_CONFIG_FILE", filepath.Join(t.TempDir(), "config.toml"))
+	t.Cleanup(config.RemoveRunDir)
+
+	source := docker.NewFakeClient(docker.FakeImage{
+		RepoTags:     []string{"nginx:1.27"},
+		Os:           "linux",
+		Architecture: "amd64",
+		Created:      time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		Layers:       [][]byte{[]byte("layer")},
+	})
+	archive, err := source.ImageSave(context.Background(), []string{"nginx:1.27"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+
+	storage, err := NewDirStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cloudFilePath := "/images/nginx_1.27_linux_amd64.tar"
+	err = storage.UploadStream(cloudFilePath, func(w io.Writer) error {
+		_, err := io.Copy(w, archive)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage, cloudFilePath
+}
+
+func TestImportReusesCachedDownload(t *testing.T) {
+	storage, cloudFilePath := newTestStorage(t)
+	target := docker.NewFakeClient()
+	docker.SetImageClient(target)
+	t.Cleanup(func() { docker.SetImageClient(nil) })
+
+	// The first import keeps its download in the working directory
+	keepTempFiles = true
+	t.Cleanup(func() { keepTempFiles = false })
+	images, err := downloadAndImport(storage, cloudFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(images, "nginx:1.27") {
+		t.Fatalf("first import loaded %q", images)
+	}
+	cachedFilePath := filepath.Join(config.TempDir(), filepath.Base(cloudFilePath))
+	if _, err := os.Stat(cachedFilePath); err != nil {
+		t.Fatalf("download not kept: %v", err)
+	}
+
+	// The second import, without --keep-download, loads the cached copy and leaves it for later runs
+	keepTempFiles = false
+	if images, err = downloadAndImport(storage, cloudFilePath); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(images, "nginx:1.27") {
+		t.Fatalf("second import loaded %q", images)
+	}
+	if _, err := os.Stat(cachedFilePath); err != nil {
+		t.Fatalf("cached copy removed by the import: %v", err)
+	}
+
+	localFilePath, cached, err := fetchArchive(storage, cloudFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cached || localFilePath != cachedFilePath {
+		t.Errorf("fetchArchive = %s, cached %v, want the cached copy %s", localFilePath, cached, cachedFilePath)
+	}
+}
+
+func TestImportRemovesDownload(t *testing.T) {
+	storage, cloudFilePath := newTestStorage(t)
+	docker.SetImageClient(docker.NewFakeClient())
+	t.Cleanup(func() { docker.SetImageClient(nil) })
+
+	if _, err := downloadAndImport(storage, cloudFilePath); err != nil {
+		t.Fatal(err)
+	}
+	workDir, err := config.RunDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, filepath.Base(cloudFilePath))); !os.IsNotExist(err) {
+		t.Errorf("download left behind: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(config.TempDir(), filepath.Base(cloudFilePath))); !os.IsNotExist(err) {
+		t.Errorf("download kept without --keep-download: %v", err)
+	}
+}
@@ -0,0 +1,295 @@
+package cloud
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/docker"
+)
+
+// blobDirName is the folder, directly under the export folder, holding the layers of delta exports
+const blobDirName = ".dkci-blobs"
+
+// minBlobSize is the size from which archive entries are stored as shared blobs; smaller entries such as
+// the image config and manifest.json are kept inline in the recipe
+const minBlobSize = 64 << 10
+
+// localBlobDir caches the blobs of delta exports and imports
+var localBlobDir = filepath.Join(config.TempDir(), config.BlobsDir)
+
+// deltaExports makes cloud exports upload a recipe plus the layers missing from the blob store instead of the archive
+var deltaExports bool
+
+// SetDelta makes cloud exports upload only the layers that are not yet in the cloud, plus a recipe to rebuild the archive
+func SetDelta(enabled bool) {
+	deltaExports = enabled
+}
+
+// deltaRecipe lists the entries of an image archive in order. Large entries reference a blob by its SHA-256,
+// the others carry their content.
+type deltaRecipe struct {
+	// BlobDir is the blob store, relative to the folder of the recipe
+	BlobDir string       `json:"blobDir"`
+	Entries []deltaEntry `json:"entries"`
+}
+
+// deltaEntry is a tar entry of an image archive
+type deltaEntry struct {
+	Name     string    `json:"name"`
+	Type     byte      `json:"type"`
+	Linkname string    `json:"linkname,omitempty"`
+	Mode     int64     `json:"mode"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"modTime"`
+	Data     []byte    `json:"data,omitempty"`
+	Blob     string    `json:"blob,omitempty"`
+}
+
+// uploadDelta uploads the entries of an image archive that are missing from the blob store of cloudPath and a
+// recipe describing the archive to remoteFilePath + DeltaSuffix
+func uploadDelta(bdfsClient Storage, tarPath, remoteFilePath, cloudPath string) error {
+	// Cloud paths always use '/'; filepath.Rel works on them once converted to the separator of the platform
+	remoteBlobDir := path.Join(cloudPath, blobDirName)
+	relativeBlobDir, err := filepath.Rel(filepath.FromSlash(path.Dir(remoteFilePath)), filepath.FromSlash(remoteBlobDir))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(localBlobDir, 0755); err != nil {
+		return err
+	}
+	config.Track(localBlobDir)
+	if createFolders {
+		if err := ensureFolder(bdfsClient, remoteBlobDir); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.Open(tarPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	recipe := deltaRecipe{BlobDir: filepath.ToSlash(relativeBlobDir)}
+	var uploaded, reused int64
+
+	tarReader := tar.NewReader(file)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %v", err)
+		}
+
+		entry := deltaEntry{
+			Name:     header.Name,
+			Type:     header.Typeflag,
+			Linkname: header.Linkname,
+			Mode:     header.Mode,
+			Size:     header.Size,
+			ModTime:  header.ModTime,
+		}
+
+		if header.Typeflag != tar.TypeReg || header.Size < minBlobSize {
+			if entry.Data, err = io.ReadAll(tarReader); err != nil {
+				return fmt.Errorf("failed to read %s: %v", header.Name, err)
+			}
+			recipe.Entries = append(recipe.Entries, entry)
+			continue
+		}
+
+		// Hash the entry into a temporary blob, then upload it unless the blob store already has it
+		blobPath, digest, err := writeBlob(tarReader)
+		if err != nil {
+			return fmt.Errorf("failed to store %s: %v", header.Name, err)
+		}
+		entry.Blob = digest
+		recipe.Entries = append(recipe.Entries, entry)
+
+		remoteBlobPath := path.Join(remoteBlobDir, digest)
+		if _, err := bdfsClient.GetFileInfoByPath(remoteBlobPath); err == nil {
+			reused += header.Size
+		} else {
+			fmt.Printf("Uploading layer %s (%s)...\n", docker.ShortID(digest), docker.FormatSize(header.Size))
+			if err := UploadVerified(bdfsClient, blobPath, remoteBlobPath); err != nil {
+				os.Remove(blobPath)
+				return err
+			}
+			uploaded += header.Size
+		}
+		os.Remove(blobPath)
+	}
+
+	data, err := json.Marshal(recipe)
+	if err != nil {
+		return err
+	}
+	recipePath := tarPath + docker.DeltaSuffix
+	if err := os.WriteFile(recipePath, data, 0644); err != nil {
+		return err
+	}
+	defer os.Remove(recipePath)
+
+	if err := UploadVerified(bdfsClient, recipePath, remoteFilePath+docker.DeltaSuffix); err != nil {
+		return err
+	}
+
+	fmt.Printf("Uploaded %s of new layers, reused %s already in the cloud\n", docker.FormatSize(uploaded), docker.FormatSize(reused))
+	return nil
+}
+
+// restoreDelta downloads the recipe of a delta export and the blobs it references, and rebuilds the image
+// archive at localFilePath
+func restoreDelta(bdfsClient Storage, cloudRecipePath, localFilePath string) error {
+	recipe, err := readRecipe(bdfsClient, cloudRecipePath, localFilePath+docker.DeltaSuffix)
+	if err != nil {
+		return err
+	}
+	remoteBlobDir := path.Join(path.Dir(cloudRecipePath), recipe.BlobDir)
+
+	if err := os.MkdirAll(localBlobDir, 0755); err != nil {
+		return err
+	}
+	config.Track(localBlobDir)
+
+	outFile, err := os.Create(localFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to create local file %s: %v", localFilePath, err)
+	}
+	defer outFile.Close()
+	config.Track(localFilePath)
+
+	tarWriter := tar.NewWriter(outFile)
+	for _, entry := range recipe.Entries {
+		header := &tar.Header{
+			Name:     entry.Name,
+			Typeflag: entry.Type,
+			Linkname: entry.Linkname,
+			Mode:     entry.Mode,
+			Size:     entry.Size,
+			ModTime:  entry.ModTime,
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if entry.Blob == "" {
+			if _, err := tarWriter.Write(entry.Data); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := copyBlob(bdfsClient, path.Join(remoteBlobDir, entry.Blob), entry.Blob, tarWriter); err != nil {
+			return fmt.Errorf("failed to restore %s: %v", entry.Name, err)
+		}
+	}
+	return tarWriter.Close()
+}
+
+// readRecipe downloads the recipe of a delta export to localRecipePath and parses it, removing the download
+func readRecipe(bdfsClient Storage, cloudRecipePath, localRecipePath string) (*deltaRecipe, error) {
+	if err := DownloadToFile(bdfsClient, cloudRecipePath, localRecipePath); err != nil {
+		os.Remove(localRecipePath)
+		return nil, err
+	}
+	data, err := os.ReadFile(localRecipePath)
+	os.Remove(localRecipePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var recipe deltaRecipe
+	if err := json.Unmarshal(data, &recipe); err != nil {
+		return nil, fmt.Errorf("invalid delta recipe %s: %v", cloudRecipePath, err)
+	}
+	return &recipe, nil
+}
+
+// copyBlob writes a blob of the cloud blob store to w, downloading it to the local blob cache first
+// and checking its digest
+func copyBlob(bdfsClient Storage, remoteBlobPath, digest string, w io.Writer) error {
+	localBlobPath := filepath.Join(localBlobDir, digest)
+	if _, err := os.Stat(localBlobPath); err != nil || noCache {
+		// Download to the directory of this run and move the blob into the cache once complete, so that runs
+		// restoring the same layer at the same time do not write to the same file
+		workDir, err := runDir()
+		if err != nil {
+			return err
+		}
+		downloadPath := filepath.Join(workDir, "blob-"+digest)
+		fmt.Printf("Downloading layer %s...\n", docker.ShortID(digest))
+		if err := DownloadToFile(bdfsClient, remoteBlobPath, downloadPath); err != nil {
+			os.Remove(downloadPath)
+			return err
+		}
+		if err := os.Rename(downloadPath, localBlobPath); err != nil {
+			os.Remove(downloadPath)
+			return err
+		}
+	}
+	if !keepTempFiles {
+		defer os.Remove(localBlobPath)
+	}
+
+	blob, err := os.Open(localBlobPath)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, hash), blob); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != digest {
+		os.Remove(localBlobPath)
+		return fmt.Errorf("blob checksum mismatch (expected %s, got %s)", digest, actual)
+	}
+	return nil
+}
+
+// writeBlob copies reader into a temporary file of the local blob cache and returns its path and hex SHA-256
+func writeBlob(reader io.Reader) (string, string, error) {
+	temp, err := os.CreateTemp(localBlobDir, "upload-")
+	if err != nil {
+		return "", "", err
+	}
+	defer temp.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(temp, hash), reader); err != nil {
+		os.Remove(temp.Name())
+		return "", "", err
+	}
+	return temp.Name(), hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// DownloadArchive downloads an image archive to localFilePath, rebuilding it first if cloudFilePath is the
+// recipe of a delta export, or joining its parts if it is the manifest of an archive stored in parts
+func DownloadArchive(bdfsClient Storage, cloudFilePath, localFilePath string) error {
+	if isDeltaRecipe(cloudFilePath) {
+		return restoreDelta(bdfsClient, cloudFilePath, localFilePath)
+	}
+	if isSplitManifest(cloudFilePath) {
+		return restoreSplit(bdfsClient, cloudFilePath, localFilePath)
+	}
+	return DownloadToFile(bdfsClient, cloudFilePath, localFilePath)
+}
+
+// isDeltaRecipe reports whether a cloud file is the recipe of a delta export
+func isDeltaRecipe(filePath string) bool {
+	return strings.HasSuffix(strings.ToLower(filePath), docker.DeltaSuffix)
+}
@@ -0,0 +1,208 @@
+package cloud
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/baowuhe/go-bdfs/pan"
+	"github.com/baowuhe/go-dkci/checksum"
+)
+
+// DirPrefix starts the backend of a local directory, such as dir:///mnt/usb
+const DirPrefix = "dir://"
+
+// DirStorage stores the files of the cloud commands under a local directory, such as a mounted USB drive or NFS
+// share, with the same layout as in Baidu cloud: the cloud path /docker-images is the folder docker-images of
+// the directory.
+type DirStorage struct {
+	root string
+}
+
+// NewDirStorage returns the storage of a directory, which must exist
+func NewDirStorage(root string) (*DirStorage, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	return &DirStorage{root: root}, nil
+}
+
+// local returns the path of a cloud path in the directory; paths cannot leave it
+func (d *DirStorage) local(cloudPath string) string {
+	return filepath.Join(d.root, filepath.FromSlash(path.Clean("/"+cloudPath)))
+}
+
+// fileInfo describes a file of the directory as Baidu cloud would
+func fileInfo(cloudPath string, info os.FileInfo) pan.FileInfo {
+	file := pan.FileInfo{
+		Path:           path.Clean("/" + cloudPath),
+		ServerFilename: info.Name(),
+		Size:           info.Size(),
+		ServerMtime:    info.ModTime().Unix(),
+		ServerCtime:    info.ModTime().Unix(),
+		LocalMtime:     info.ModTime().Unix(),
+	}
+	if info.IsDir() {
+		file.IsDir = 1
+		file.Size = 0
+	}
+	return file
+}
+
+// ListFiles lists the files and folders of a folder
+func (d *DirStorage) ListFiles(dirPath string) ([]pan.FileInfo, error) {
+	entries, err := os.ReadDir(d.local(dirPath))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]pan.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, fileInfo(path.Join(dirPath, entry.Name()), info))
+	}
+	return files, nil
+}
+
+// GetFileInfoByPath describes a file or folder
+func (d *DirStorage) GetFileInfoByPath(filePath string) (*pan.FileInfo, error) {
+	info, err := os.Stat(d.local(filePath))
+	if err != nil {
+		return nil, err
+	}
+	file := fileInfo(filePath, info)
+	return &file, nil
+}
+
+// GetDetailedFileInfo describes a file with its MD5 checksum, which uploads are verified against
+func (d *DirStorage) GetDetailedFileInfo(filePath string) (*pan.FileInfo, error) {
+	file, err := d.GetFileInfoByPath(filePath)
+	if err != nil || file.IsDir == 1 {
+		return file, err
+	}
+
+	sums, err := checksum.File(d.local(filePath))
+	if err != nil {
+		return nil, err
+	}
+	file.MD5 = sums.MD5
+	return file, nil
+}
+
+// UploadFile copies a local file into the directory, creating its folder
+func (d *DirStorage) UploadFile(localFilePath, remoteFilePath string) error {
+	source, err := os.Open(localFilePath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	return d.UploadStream(remoteFilePath, func(w io.Writer) error {
+		_, err := io.Copy(w, source)
+		return err
+	})
+}
+
+// UploadStream stores what write writes as a file of the directory, creating its folder. The file is written
+// next to the target and renamed once complete, so that an interrupted upload never leaves a partial file behind.
+func (d *DirStorage) UploadStream(remoteFilePath string, write func(w io.Writer) error) error {
+	target := d.local(remoteFilePath)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".upload-")
+	if err != nil {
+		return err
+	}
+	// The file is hashed while it is written, for the verification of the upload not to read it again
+	hasher := checksum.New()
+	if err := write(io.MultiWriter(temp, hasher)); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	if err := os.Rename(temp.Name(), target); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	checksum.Remember(target, hasher.Sums())
+	return nil
+}
+
+// CopyFile copies a file of the directory to another path of it, creating its folder
+func (d *DirStorage) CopyFile(srcPath, dstPath string) error {
+	source, err := os.Open(d.local(srcPath))
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	return d.UploadStream(dstPath, func(w io.Writer) error {
+		_, err := io.Copy(w, source)
+		return err
+	})
+}
+
+// MoveFile moves a file or folder of the directory to another path of it, creating its folder
+func (d *DirStorage) MoveFile(srcPath, dstPath string) error {
+	target := d.local(dstPath)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return os.Rename(d.local(srcPath), target)
+}
+
+// DownloadFile opens a file of the directory as the response of a download
+func (d *DirStorage) DownloadFile(filePath string) (*http.Response, error) {
+	file, err := os.Open(d.local(filePath))
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, fmt.Errorf("%s is a folder", filePath)
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Body:          file,
+		ContentLength: info.Size(),
+	}, nil
+}
+
+// RemoveFile removes a file, or a folder with its contents
+func (d *DirStorage) RemoveFile(filePath string) error {
+	if strings.Trim(path.Clean("/"+filePath), "/") == "" {
+		return fmt.Errorf("refusing to remove the root of %s", d.root)
+	}
+	target := d.local(filePath)
+	if _, err := os.Lstat(target); err != nil {
+		return err
+	}
+	return os.RemoveAll(target)
+}
+
+// EnsureRemoteDirExists creates a folder and its parents
+func (d *DirStorage) EnsureRemoteDirExists(remotePath string) error {
+	return os.MkdirAll(d.local(remotePath), 0755)
+}
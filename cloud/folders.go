@@ -0,0 +1,109 @@
+package cloud
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/baowuhe/go-dkci/docker"
+	"github.com/baowuhe/go-dkci/i18n"
+)
+
+// createFolders makes cloud exports create the folder they upload to and its missing parents
+var createFolders = true
+
+// SetCreateFolders sets whether cloud exports create missing folders, like mkdir -p. Without, an export to a
+// folder that does not exist fails before the image is saved.
+func SetCreateFolders(enabled bool) {
+	createFolders = enabled
+}
+
+var (
+	foldersMu sync.Mutex
+	// readyFolders are the folders found or created during this run
+	readyFolders = make(map[string]bool)
+)
+
+// ensureFolder makes sure that the cloud folder an export uploads to exists, creating it and its missing parents
+// unless SetCreateFolders disabled it
+func ensureFolder(bdfsClient Storage, dirPath string) error {
+	dirPath = path.Clean("/" + dirPath)
+	foldersMu.Lock()
+	defer foldersMu.Unlock()
+	if readyFolders[dirPath] {
+		return nil
+	}
+
+	if createFolders {
+		if err := makeFolders(bdfsClient, dirPath); err != nil {
+			return fmt.Errorf("failed to create cloud folder %s: %v", dirPath, err)
+		}
+	} else if dirPath != "/" {
+		info, err := bdfsClient.GetFileInfoByPath(dirPath)
+		if err != nil {
+			return fmt.Errorf("cloud folder %s does not exist, and --no-create-folders keeps it from being created", dirPath)
+		}
+		if info.IsDir != 1 {
+			return fmt.Errorf("cloud path %s is a file, not a folder", dirPath)
+		}
+	}
+	readyFolders[dirPath] = true
+	return nil
+}
+
+// makeFolders creates a folder and its missing parents. The local directory backend and storage plugins do so
+// themselves; Baidu cloud creates one folder per call, so the path is walked down from the root.
+func makeFolders(bdfsClient Storage, dirPath string) error {
+	client, baidu := bdfsClient.(*baiduStorage)
+	if !baidu {
+		return bdfsClient.EnsureRemoteDirExists(dirPath)
+	}
+
+	current := "/"
+	for _, element := range strings.Split(strings.Trim(dirPath, "/"), "/") {
+		if element == "" {
+			continue
+		}
+		current = path.Join(current, element)
+		if readyFolders[current] {
+			continue
+		}
+		info, err := client.GetFileInfoByPath(current)
+		switch {
+		case err != nil:
+			fmt.Printf("Creating cloud folder %s\n", current)
+			if err := client.CreateDir(current); err != nil {
+				return err
+			}
+		case info.IsDir != 1:
+			return fmt.Errorf("%s is a file, not a folder", current)
+		}
+		readyFolders[current] = true
+	}
+	return nil
+}
+
+// latestCloudRun returns the latest run folder of a cloud folder, exiting if there is none
+func latestCloudRun(bdfsClient Storage, dirPath string) string {
+	files, err := bdfsClient.ListFiles(dirPath)
+	if err != nil {
+		i18n.Printf("[x] Error accessing cloud folder %s: %v\n", dirPath, err)
+		os.Exit(1)
+	}
+	var names []string
+	for _, file := range files {
+		if file.IsDir == 1 {
+			names = append(names, path.Base(file.Path))
+		}
+	}
+	name, ok := docker.LatestRunFolder(names)
+	if !ok {
+		i18n.Printf("[x] No run folders found in %s\n", dirPath)
+		os.Exit(1)
+	}
+	runPath := path.Join(dirPath, name)
+	fmt.Printf("Importing the latest run %s\n", runPath)
+	return runPath
+}
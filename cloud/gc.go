@@ -0,0 +1,111 @@
+package cloud
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/baowuhe/go-dkci/docker"
+	"github.com/baowuhe/go-dkci/i18n"
+)
+
+// gcGracePeriod protects recently uploaded blobs from collection: a delta export uploads its layers before its
+// recipe, so a running export has blobs no recipe references yet
+const gcGracePeriod = 24 * time.Hour
+
+// CollectGarbage deletes the blobs in the store of delta exports of a cloud folder that no recipe of the folder
+// references any more, in the cloud and in the local blob cache, and reports the space reclaimed. Blobs uploaded
+// within the last day are kept. The blobs are listed and the deletion confirmed first, unless assumeYes is set;
+// with dryRun set they are only listed. Nothing is deleted if any recipe cannot be read.
+func CollectGarbage(cloudPath string, assumeYes, dryRun bool) {
+	bdfsClient := Login()
+
+	remoteBlobDir := path.Join(cloudPath, blobDirName)
+	blobs, err := bdfsClient.ListFiles(remoteBlobDir)
+	if err != nil || len(blobs) == 0 {
+		i18n.Printf("No delta export layers found in %s\n", cloudPath)
+		return
+	}
+
+	// Collect the blobs referenced by the recipes of the folder that use this store
+	files, err := ListFilesRecursive(bdfsClient, cloudPath, docker.NameDepth())
+	if err != nil {
+		i18n.Printf("[x] Failed to list cloud folder %s: %v\n", cloudPath, err)
+		os.Exit(1)
+	}
+	workDir, err := runDir()
+	if err != nil {
+		i18n.Printf("[x] Error: %v\n", err)
+		os.Exit(1)
+	}
+	referenced := make(map[string]bool)
+	recipes := 0
+	for _, file := range files {
+		if file.IsDir == 1 || !isDeltaRecipe(file.Path) {
+			continue
+		}
+		recipe, err := readRecipe(bdfsClient, file.Path, filepath.Join(workDir, path.Base(file.Path)))
+		if err != nil {
+			i18n.Printf("[x] Failed to read delta recipe %s, nothing was deleted: %v\n", file.Path, err)
+			os.Exit(1)
+		}
+		if path.Join(path.Dir(file.Path), recipe.BlobDir) != remoteBlobDir {
+			continue
+		}
+		recipes++
+		for _, entry := range recipe.Entries {
+			if entry.Blob != "" {
+				referenced[entry.Blob] = true
+			}
+		}
+	}
+
+	cutoff := time.Now().Add(-gcGracePeriod)
+	var unreferenced []string
+	var total int64
+	for _, blob := range blobs {
+		digest := path.Base(blob.Path)
+		if blob.IsDir == 1 || referenced[digest] || time.Unix(blob.ServerMtime, 0).After(cutoff) {
+			continue
+		}
+		fmt.Printf("%s  %10s  %s\n", docker.ShortID(digest), docker.FormatSize(blob.Size), docker.FormatAge(blob.ServerMtime))
+		unreferenced = append(unreferenced, digest)
+		total += blob.Size
+	}
+	i18n.Printf("%d delta recipe(s) use %d layer(s); %d unreferenced layer(s) hold %s\n",
+		recipes, len(referenced), len(unreferenced), docker.FormatSize(total))
+	if len(unreferenced) == 0 || dryRun {
+		return
+	}
+
+	if !docker.ConfirmDeletion(assumeYes) {
+		i18n.Println("[x] Garbage collection cancelled by user")
+		return
+	}
+
+	failed := 0
+	var localTotal int64
+	for _, digest := range unreferenced {
+		if err := bdfsClient.RemoveFile(path.Join(remoteBlobDir, digest)); err != nil {
+			i18n.Printf("[x] Failed to remove %s: %v\n", path.Join(remoteBlobDir, digest), err)
+			failed++
+			continue
+		}
+
+		// Drop the cached copy too, it can no longer be restored from
+		localBlobPath := filepath.Join(localBlobDir, digest)
+		if info, err := os.Stat(localBlobPath); err == nil {
+			if err := os.Remove(localBlobPath); err == nil {
+				localTotal += info.Size()
+			}
+		}
+	}
+	if failed > 0 {
+		i18n.Printf("[x] %d of %d file(s) could not be removed\n", failed, len(unreferenced))
+		os.Exit(1)
+	}
+	i18n.Printf("[√] Removed %d unreferenced layer(s), reclaiming %s in Baidu cloud and %s locally\n",
+		len(unreferenced), docker.FormatSize(total), docker.FormatSize(localTotal))
+}
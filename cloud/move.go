@@ -0,0 +1,133 @@
+package cloud
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/baowuhe/go-dkci/docker"
+	"github.com/baowuhe/go-dkci/i18n"
+)
+
+// MoveCloud moves or renames a file or folder of the cloud storage (cloud mv). A target that is an existing folder
+// receives the source under its own name; an existing file is never replaced. An archive is moved with its
+// signature, attestation and SBOMs and the parts of a split archive, renamed after it, and a delta recipe moved to
+// another folder gets the layers it references copied into the blob store it uses there; the layers stay in the
+// old store for gc.
+func MoveCloud(srcPath, dstPath string) {
+	bdfsClient := Login()
+	srcPath, dstPath = path.Clean("/"+srcPath), path.Clean("/"+dstPath)
+
+	source, err := bdfsClient.GetFileInfoByPath(srcPath)
+	if err != nil {
+		i18n.Printf("[x] Error accessing cloud file %s: %v\n", srcPath, err)
+		os.Exit(1)
+	}
+	if target, err := bdfsClient.GetFileInfoByPath(dstPath); err == nil {
+		if target.IsDir != 1 {
+			i18n.Printf("[x] %s already exists\n", dstPath)
+			os.Exit(1)
+		}
+		dstPath = path.Join(dstPath, path.Base(srcPath))
+		if _, err := bdfsClient.GetFileInfoByPath(dstPath); err == nil {
+			i18n.Printf("[x] %s already exists\n", dstPath)
+			os.Exit(1)
+		}
+	}
+	if srcPath == dstPath || strings.HasPrefix(dstPath, srcPath+"/") {
+		i18n.Printf("[x] Cannot move %s into itself\n", srcPath)
+		os.Exit(1)
+	}
+	if err := ensureFolder(bdfsClient, path.Dir(dstPath)); err != nil {
+		i18n.Printf("[x] %v\n", err)
+		os.Exit(1)
+	}
+
+	if source.IsDir == 1 {
+		err = moveFolder(bdfsClient, srcPath, dstPath)
+	} else {
+		err = moveArchive(bdfsClient, srcPath, dstPath)
+	}
+	if err != nil {
+		i18n.Printf("[x] Failed to move %s: %v\n", srcPath, err)
+		os.Exit(1)
+	}
+	i18n.Printf("[√] Moved %s to %s\n", srcPath, dstPath)
+}
+
+// moveFolder moves a folder with all of its files, one file at a time unless the storage moves files itself
+func moveFolder(bdfsClient Storage, srcPath, dstPath string) error {
+	if mover, ok := bdfsClient.(MoveStorage); ok {
+		return mover.MoveFile(srcPath, dstPath)
+	}
+
+	files, err := ListFilesRecursive(bdfsClient, srcPath, math.MaxInt)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.IsDir == 1 {
+			continue
+		}
+		if err := moveFile(bdfsClient, file.Path, dstPath+strings.TrimPrefix(file.Path, srcPath)); err != nil {
+			return err
+		}
+	}
+	return bdfsClient.RemoveFile(srcPath)
+}
+
+// moveArchive moves a file, and if it is an archive, delta recipe or split manifest, the files stored with it
+func moveArchive(bdfsClient Storage, srcPath, dstPath string) error {
+	if !docker.IsArchiveName(srcPath) && !docker.IsIndexName(srcPath) {
+		return moveFile(bdfsClient, srcPath, dstPath)
+	}
+
+	// A recipe moved to another folder references the blob store relative to it
+	if isDeltaRecipe(srcPath) && path.Dir(srcPath) != path.Dir(dstPath) {
+		if err := copyBlobs(bdfsClient, srcPath, path.Dir(dstPath)); err != nil {
+			return err
+		}
+	}
+
+	// Sidecars and the parts of split archives are named after the archive, without the suffix of delta recipes
+	// and split manifests; they are moved before the archive, so that it never sits at the target without them
+	files, err := bdfsClient.ListFiles(path.Dir(srcPath))
+	if err != nil {
+		return err
+	}
+	stored := make(map[string]int64)
+	for _, file := range files {
+		stored[file.Path] = file.Size
+	}
+	srcArchive, dstArchive := docker.TrimIndexSuffix(srcPath), docker.TrimIndexSuffix(dstPath)
+	for i, part := range storedParts(stored, srcPath) {
+		if err := moveFile(bdfsClient, part, docker.SplitPartName(dstArchive, i+1)); err != nil {
+			return err
+		}
+	}
+	dstSidecars := docker.SidecarFiles(dstArchive)
+	for i, sidecar := range docker.SidecarFiles(srcArchive) {
+		if _, ok := stored[sidecar]; !ok {
+			continue
+		}
+		if err := moveFile(bdfsClient, sidecar, dstSidecars[i]); err != nil {
+			return err
+		}
+	}
+	return moveFile(bdfsClient, srcPath, dstPath)
+}
+
+// moveFile moves one file. Storages that move files themselves, Baidu cloud on the server and the dir:// backend
+// within its directory, do it in place; storage plugins copy the file with copyRemote and remove the source.
+func moveFile(bdfsClient Storage, srcPath, dstPath string) error {
+	fmt.Printf("Moving %s to %s...\n", srcPath, dstPath)
+	if mover, ok := bdfsClient.(MoveStorage); ok {
+		return mover.MoveFile(srcPath, dstPath)
+	}
+	if err := copyRemote(bdfsClient, srcPath, dstPath); err != nil {
+		return err
+	}
+	return bdfsClient.RemoveFile(srcPath)
+}
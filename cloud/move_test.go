@@ -0,0 +1,59 @@
+package cloud
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/docker"
+)
+
+func TestMoveArchive(t *testing.T) {
+	for _, moves := range []string{"in place", "through a local file"} {
+		t.Run(moves, func(t *testing.T) {
+			t.Setenv("DKCI_TEMP_DIR", t.TempDir())
+			t.Setenv("BDFS_CONFIG_FILE", filepath.Join(t.TempDir(), "config.toml"))
+			t.Cleanup(config.RemoveRunDir)
+			dir, err := NewDirStorage(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var storage Storage = dir
+			if moves != "in place" {
+				storage = uploadOnly{dir}
+			}
+
+			srcArchive := "/images/app_1.2_linux_amd64.tar"
+			storeFile(t, dir, srcArchive+docker.SplitSuffix, "manifest")
+			storeFile(t, dir, docker.SplitPartName(srcArchive, 1), "part 1")
+			storeFile(t, dir, docker.SplitPartName(srcArchive, 2), "part 2")
+			storeFile(t, dir, docker.SidecarFiles(srcArchive)[0], "signature")
+			storeFile(t, dir, "/images/other/notes.txt", "notes")
+
+			dstArchive := "/projects/app/app_1.2-rc1_linux_amd64.tar"
+			if err := moveArchive(storage, srcArchive+docker.SplitSuffix, dstArchive+docker.SplitSuffix); err != nil {
+				t.Fatal(err)
+			}
+			moved := []string{dstArchive + docker.SplitSuffix, docker.SplitPartName(dstArchive, 1), docker.SplitPartName(dstArchive, 2), docker.SidecarFiles(dstArchive)[0]}
+			for _, filePath := range moved {
+				if _, err := dir.GetFileInfoByPath(filePath); err != nil {
+					t.Errorf("%s not moved: %v", filePath, err)
+				}
+			}
+			if files, _ := dir.ListFiles("/images"); len(files) != 1 {
+				t.Errorf("files left in the source folder: %+v", files)
+			}
+
+			// A folder moves with its subfolders
+			if err := moveFolder(storage, "/images", "/archive/images"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := dir.GetFileInfoByPath("/archive/images/other/notes.txt"); err != nil {
+				t.Errorf("folder not moved: %v", err)
+			}
+			if _, err := dir.GetFileInfoByPath("/images"); err == nil {
+				t.Error("source folder left behind")
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package cloud
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/baowuhe/go-bdfs/pan"
+)
+
+// PluginPrefix starts the backend of a storage plugin, such as plugin:my-storage
+const PluginPrefix = "plugin:"
+
+// pluginExecutablePrefix starts the name of the executable of a plugin named without a path
+const pluginExecutablePrefix = "go-dkci-plugin-"
+
+// PluginStorage stores the files of the cloud commands through an external executable, so that storage go-dkci
+// does not support can be plugged in without changing it. Every operation runs the executable once, writes a
+// PluginRequest to its stdin and reads a PluginResponse from its stdout; downloads read the file contents from
+// stdout instead. A plugin must implement list, upload and download; without stat, files are looked up by
+// listing their folder, without mkdir folders are left to uploads, and without remove nothing can be deleted.
+type PluginStorage struct {
+	executable string
+}
+
+// PluginRequest is the operation a plugin is asked to perform: Op is list, stat, upload, download, remove or
+// mkdir, Path the '/'-separated path in the storage, and Local the file an upload reads
+type PluginRequest struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Local string `json:"local,omitempty"`
+}
+
+// PluginResponse is the answer of a plugin. Error fails the operation, and Unsupported marks an operation the
+// plugin does not implement.
+type PluginResponse struct {
+	Error       string       `json:"error,omitempty"`
+	Unsupported bool         `json:"unsupported,omitempty"`
+	Files       []PluginFile `json:"files,omitempty"`
+	File        *PluginFile  `json:"file,omitempty"`
+}
+
+// PluginFile describes a file or folder of a plugin. MD5 is optional; uploads are verified against it if given.
+type PluginFile struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	IsDir bool   `json:"is_dir,omitempty"`
+	MTime int64  `json:"mtime,omitempty"`
+	MD5   string `json:"md5,omitempty"`
+}
+
+// errUnsupported is the error of an operation the plugin does not implement
+var errUnsupported = errors.New("not supported by the plugin")
+
+// NewPluginStorage returns the storage of a plugin: the executable at name if it is a path, or the executable
+// go-dkci-plugin-<name> on the PATH
+func NewPluginStorage(name string) (*PluginStorage, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%s needs the name of a plugin, such as %smy-storage", PluginPrefix, PluginPrefix)
+	}
+	executable := name
+	if !strings.ContainsAny(name, `/\`) {
+		executable = pluginExecutablePrefix + name
+	}
+	resolved, err := exec.LookPath(executable)
+	if err != nil {
+		return nil, fmt.Errorf("storage plugin %s not found: %v", name, err)
+	}
+	return &PluginStorage{executable: resolved}, nil
+}
+
+// command returns the process of an operation, with the request on its stdin and its stderr collected for errors
+func (p *PluginStorage) command(request PluginRequest, stderr *bytes.Buffer) (*exec.Cmd, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(p.executable)
+	cmd.Stdin = bytes.NewReader(append(data, '\n'))
+	cmd.Stderr = stderr
+	return cmd, nil
+}
+
+// call performs an operation and returns the answer of the plugin
+func (p *PluginStorage) call(request PluginRequest) (*PluginResponse, error) {
+	var stdout, stderr bytes.Buffer
+	cmd, err := p.command(request, &stderr)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout = &stdout
+	runErr := cmd.Run()
+
+	var response PluginResponse
+	if err := json.Unmarshal(bytes.TrimSpace(stdout.Bytes()), &response); err != nil {
+		if runErr != nil {
+			return nil, pluginError(request.Op, runErr, &stderr)
+		}
+		return nil, fmt.Errorf("plugin %s answered %s with invalid JSON: %v", filepath.Base(p.executable), request.Op, err)
+	}
+	if response.Unsupported {
+		return nil, errUnsupported
+	}
+	if response.Error != "" {
+		return nil, errors.New(response.Error)
+	}
+	if runErr != nil {
+		return nil, pluginError(request.Op, runErr, &stderr)
+	}
+	return &response, nil
+}
+
+// pluginError describes a failed plugin process with what it wrote to stderr
+func pluginError(op string, err error, stderr *bytes.Buffer) error {
+	if message := strings.TrimSpace(stderr.String()); message != "" {
+		return fmt.Errorf("plugin %s failed: %s", op, message)
+	}
+	return fmt.Errorf("plugin %s failed: %v", op, err)
+}
+
+// fileInfo describes a file of the plugin as Baidu cloud would
+func (f PluginFile) fileInfo() pan.FileInfo {
+	file := pan.FileInfo{
+		Path:           path.Clean("/" + f.Path),
+		ServerFilename: path.Base(f.Path),
+		Size:           f.Size,
+		ServerMtime:    f.MTime,
+		ServerCtime:    f.MTime,
+		LocalMtime:     f.MTime,
+		MD5:            f.MD5,
+	}
+	if f.IsDir {
+		file.IsDir = 1
+		file.Size = 0
+	}
+	return file
+}
+
+// ListFiles lists the files and folders of a folder
+func (p *PluginStorage) ListFiles(dirPath string) ([]pan.FileInfo, error) {
+	response, err := p.call(PluginRequest{Op: "list", Path: dirPath})
+	if err != nil {
+		return nil, err
+	}
+	files := make([]pan.FileInfo, 0, len(response.Files))
+	for _, f := range response.Files {
+		files = append(files, f.fileInfo())
+	}
+	return files, nil
+}
+
+// GetFileInfoByPath describes a file or folder, with stat or by listing its folder
+func (p *PluginStorage) GetFileInfoByPath(filePath string) (*pan.FileInfo, error) {
+	response, err := p.call(PluginRequest{Op: "stat", Path: filePath})
+	if err == nil {
+		if response.File == nil {
+			return nil, fmt.Errorf("plugin stat answered without a file for %s", filePath)
+		}
+		file := response.File.fileInfo()
+		return &file, nil
+	}
+	if !errors.Is(err, errUnsupported) {
+		return nil, err
+	}
+
+	filePath = path.Clean("/" + filePath)
+	if filePath == "/" {
+		return &pan.FileInfo{Path: "/", ServerFilename: "/", IsDir: 1}, nil
+	}
+	files, err := p.ListFiles(path.Dir(filePath))
+	if err != nil {
+		return nil, err
+	}
+	for i := range files {
+		if files[i].Path == filePath {
+			return &files[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%s not found", filePath)
+}
+
+// GetDetailedFileInfo describes a file with the MD5 checksum the plugin reports, if any
+func (p *PluginStorage) GetDetailedFileInfo(filePath string) (*pan.FileInfo, error) {
+	return p.GetFileInfoByPath(filePath)
+}
+
+// UploadFile has the plugin store a local file, which it reads itself
+func (p *PluginStorage) UploadFile(localFilePath, remoteFilePath string) error {
+	local, err := filepath.Abs(localFilePath)
+	if err != nil {
+		return err
+	}
+	_, err = p.call(PluginRequest{Op: "upload", Path: remoteFilePath, Local: local})
+	return err
+}
+
+// DownloadFile streams a file from the stdout of the plugin as the response of a download. The plugin reports a
+// failure by exiting with an error, which fails the read of the last bytes.
+func (p *PluginStorage) DownloadFile(filePath string) (*http.Response, error) {
+	info, err := p.GetFileInfoByPath(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir == 1 {
+		return nil, fmt.Errorf("%s is a folder", filePath)
+	}
+
+	stderr := &bytes.Buffer{}
+	cmd, err := p.command(PluginRequest{Op: "download", Path: filePath}, stderr)
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Body:          &pluginDownload{cmd: cmd, stdout: stdout, stderr: stderr},
+		ContentLength: info.Size,
+	}, nil
+}
+
+// pluginDownload is the body of a download, which ends with the exit status of the plugin
+type pluginDownload struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr *bytes.Buffer
+	done   bool
+	err    error
+}
+
+func (d *pluginDownload) Read(b []byte) (int, error) {
+	n, err := d.stdout.Read(b)
+	if err == io.EOF {
+		if waitErr := d.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// Close stops a download that was not read to the end
+func (d *pluginDownload) Close() error {
+	if !d.done && d.cmd.Process != nil {
+		d.cmd.Process.Kill()
+	}
+	d.wait()
+	return nil
+}
+
+// wait reaps the plugin once and returns its failure, if any
+func (d *pluginDownload) wait() error {
+	if !d.done {
+		d.done = true
+		if err := d.cmd.Wait(); err != nil {
+			d.err = pluginError("download", err, d.stderr)
+		}
+	}
+	return d.err
+}
+
+// RemoveFile has the plugin remove a file, or a folder with its contents
+func (p *PluginStorage) RemoveFile(filePath string) error {
+	_, err := p.call(PluginRequest{Op: "remove", Path: filePath})
+	if errors.Is(err, errUnsupported) {
+		return fmt.Errorf("remove is %v", err)
+	}
+	return err
+}
+
+// EnsureRemoteDirExists has the plugin create a folder, if it supports folders
+func (p *PluginStorage) EnsureRemoteDirExists(remotePath string) error {
+	_, err := p.call(PluginRequest{Op: "mkdir", Path: remotePath})
+	if errors.Is(err, errUnsupported) {
+		return nil
+	}
+	return err
+}
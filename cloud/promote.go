@@ -0,0 +1,248 @@
+package cloud
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/baowuhe/go-bdfs/pan"
+	"github.com/baowuhe/go-dkci/docker"
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/baowuhe/go-dkci/state"
+)
+
+// promotion is an archive to promote and the files stored with it
+type promotion struct {
+	archive pan.FileInfo
+	// files are the archive, its split parts and its signature, attestation and SBOMs, as stored
+	files []string
+}
+
+// Promote copies the archives of cloud folders matching the source patterns, such as /staging/app_1.2*.tar, into
+// the folder target, for a staged release flow inside the cloud drive. The signatures, attestations and SBOMs of
+// the archives, the parts of split archives and the layers of delta exports are copied with them, and every
+// promoted archive is recorded in the state database under its new location. With move set the sources are removed
+// once copied; the layers of delta exports stay in the blob store of their folder for gc. With dryRun set the files
+// are only listed.
+func Promote(sources []string, target string, move, dryRun bool) {
+	bdfsClient := Login()
+
+	promotions, err := findPromotions(bdfsClient, sources)
+	if err != nil {
+		i18n.Printf("[x] %v\n", err)
+		os.Exit(1)
+	}
+	if len(promotions) == 0 {
+		i18n.Println("[x] No archives match the source patterns")
+		os.Exit(1)
+	}
+
+	fmt.Println()
+	var total int64
+	count := 0
+	for _, p := range promotions {
+		for _, filePath := range p.files {
+			if path.Dir(filePath) == path.Clean(target) {
+				i18n.Printf("[x] %s is already in %s\n", filePath, target)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("%s  %10s\n", p.archive.Path, docker.FormatSize(p.archive.Size))
+		for _, filePath := range p.files[1:] {
+			fmt.Printf("  %s\n", filePath)
+		}
+		total += p.archive.Size
+		count += len(p.files)
+	}
+	i18n.Printf("%d file(s) to promote to %s, %s in total\n", count, target, docker.FormatSize(total))
+	if dryRun {
+		return
+	}
+
+	if err := ensureFolder(bdfsClient, target); err != nil {
+		i18n.Printf("[x] %v\n", err)
+		os.Exit(1)
+	}
+
+	exports := lastExports()
+	failed := 0
+	for _, p := range promotions {
+		if err := promote(bdfsClient, p, target, move, exports); err != nil {
+			i18n.Printf("[x] Failed to promote %s: %v\n", p.archive.Path, err)
+			failed++
+			continue
+		}
+		i18n.Printf("[√] Promoted %s to %s\n", p.archive.Path, target)
+	}
+	if failed > 0 {
+		i18n.Printf("[x] %d of %d archive(s) could not be promoted\n", failed, len(promotions))
+		os.Exit(1)
+	}
+}
+
+// findPromotions lists the archives matching the source patterns, whose last path element may contain the
+// wildcards of path.Match, with the files stored with them
+func findPromotions(bdfsClient Storage, sources []string) ([]promotion, error) {
+	var promotions []promotion
+	seen := make(map[string]bool)
+	for _, source := range sources {
+		dirPath, pattern := path.Split(path.Clean("/" + source))
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid source pattern %s: %v", source, err)
+		}
+		files, err := bdfsClient.ListFiles(dirPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list cloud folder %s: %v", dirPath, err)
+		}
+
+		stored := make(map[string]int64)
+		for _, file := range files {
+			stored[file.Path] = file.Size
+		}
+		for _, file := range files {
+			if file.IsDir == 1 || seen[file.Path] || !docker.IsArchiveName(file.Path) && !docker.IsIndexName(file.Path) {
+				continue
+			}
+			if matched, _ := path.Match(pattern, path.Base(file.Path)); !matched {
+				continue
+			}
+			seen[file.Path] = true
+
+			// Sidecars and the parts of split archives are named after the archive, without the suffix of delta
+			// recipes and split manifests
+			p := promotion{archive: file, files: []string{file.Path}}
+			archivePath := docker.TrimIndexSuffix(file.Path)
+			for _, sidecar := range append(storedParts(stored, file.Path), docker.SidecarFiles(archivePath)...) {
+				if _, ok := stored[sidecar]; ok {
+					p.files = append(p.files, sidecar)
+				}
+			}
+			promotions = append(promotions, p)
+		}
+	}
+	sort.Slice(promotions, func(i, j int) bool { return promotions[i].archive.Path < promotions[j].archive.Path })
+	return promotions, nil
+}
+
+// promote copies an archive and the files stored with it into the folder target, the layers of a delta export
+// first so that its recipe never references missing ones, and records it in the state database; with move set the
+// files are moved with moveFile instead. The archive itself comes after its parts and sidecars, so that an
+// interrupted promotion leaves no archive without them in the target folder.
+func promote(bdfsClient Storage, p promotion, target string, move bool, exports map[string]state.Record) (err error) {
+	// Exports record split archives under the archive path and delta exports under their recipe
+	sourcePath := p.archive.Path
+	if isSplitManifest(sourcePath) {
+		sourcePath = docker.TrimIndexSuffix(sourcePath)
+	}
+	record := state.Record{Time: time.Now(), Operation: state.Promote, Location: Location(path.Join(target, path.Base(sourcePath))), Size: p.archive.Size}
+	if export, ok := exports[Location(sourcePath)]; ok {
+		record.Image, record.ImageID, record.Digests = export.Image, export.ImageID, export.Digests
+	} else if fields, ok := docker.ParseArchiveName(sourcePath); ok {
+		record.Image = fields.Repository + ":" + fields.Tag
+	}
+	defer func() {
+		record.Finish(err)
+		state.Add(record)
+	}()
+
+	if isDeltaRecipe(p.archive.Path) {
+		if err := copyBlobs(bdfsClient, p.archive.Path, target); err != nil {
+			return err
+		}
+	}
+	for i := len(p.files) - 1; i >= 0; i-- {
+		dstPath := path.Join(target, path.Base(p.files[i]))
+		if move {
+			if err := moveFile(bdfsClient, p.files[i], dstPath); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Printf("Copying %s to %s...\n", p.files[i], target)
+		if err := copyRemote(bdfsClient, p.files[i], dstPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyBlobs copies the layers a delta recipe references into the blob store its copy in the folder target uses,
+// unless they are stored there already
+func copyBlobs(bdfsClient Storage, recipePath, target string) error {
+	workDir, err := runDir()
+	if err != nil {
+		return err
+	}
+	recipe, err := readRecipe(bdfsClient, recipePath, filepath.Join(workDir, path.Base(recipePath)))
+	if err != nil {
+		return err
+	}
+
+	sourceBlobDir := path.Join(path.Dir(recipePath), recipe.BlobDir)
+	targetBlobDir := path.Join(target, recipe.BlobDir)
+	if sourceBlobDir == targetBlobDir {
+		return nil
+	}
+	if err := ensureFolder(bdfsClient, targetBlobDir); err != nil {
+		return err
+	}
+	copied := make(map[string]bool)
+	for _, entry := range recipe.Entries {
+		if entry.Blob == "" || copied[entry.Blob] {
+			continue
+		}
+		copied[entry.Blob] = true
+		targetBlobPath := path.Join(targetBlobDir, entry.Blob)
+		if _, err := bdfsClient.GetFileInfoByPath(targetBlobPath); err == nil {
+			continue
+		}
+		if err := copyRemote(bdfsClient, path.Join(sourceBlobDir, entry.Blob), targetBlobPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyRemote copies a file of the storage to another path of it. Storages that copy files themselves, Baidu cloud
+// on the server and the dir:// backend within its directory, do it in place; storage plugins go through a
+// temporary file in the directory of this run.
+func copyRemote(bdfsClient Storage, srcPath, dstPath string) error {
+	if copier, ok := bdfsClient.(CopyStorage); ok {
+		return copier.CopyFile(srcPath, dstPath)
+	}
+
+	workDir, err := runDir()
+	if err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp(workDir, path.Base(dstPath)+".*")
+	if err != nil {
+		return err
+	}
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	if err := DownloadToFile(bdfsClient, srcPath, tempFile.Name()); err != nil {
+		return fmt.Errorf("failed to download %s: %v", srcPath, err)
+	}
+	return UploadVerified(bdfsClient, tempFile.Name(), dstPath)
+}
+
+// lastExports returns the last successful export recorded in the state database for every location
+func lastExports() map[string]state.Record {
+	exports := make(map[string]state.Record)
+	records, err := state.Records()
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return exports
+	}
+	for _, record := range records {
+		if (record.Operation == state.Export || record.Operation == state.Promote) && record.Result == state.OK {
+			exports[record.Location] = record
+		}
+	}
+	return exports
+}
@@ -0,0 +1,87 @@
+package cloud
+
+import (
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/docker"
+	"github.com/baowuhe/go-dkci/state"
+)
+
+// storeFile writes a file of the given content into a storage
+func storeFile(t *testing.T, storage *DirStorage, filePath, content string) {
+	t.Helper()
+	err := storage.UploadStream(filePath, func(w io.Writer) error {
+		_, err := io.WriteString(w, content)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPromote(t *testing.T) {
+	for _, copies := range []string{"in place", "through a local file"} {
+		t.Run(copies, func(t *testing.T) {
+			t.Setenv("DKCI_TEMP_DIR", t.TempDir())
+			t.Setenv("BDFS_CONFIG_FILE", filepath.Join(t.TempDir(), "config.toml"))
+			t.Cleanup(config.RemoveRunDir)
+			dir, err := NewDirStorage(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var storage Storage = dir
+			if copies != "in place" {
+				storage = uploadOnly{dir}
+			}
+
+			archive := "/staging/app_1.2_linux_amd64.tar"
+			sidecar := docker.SidecarFiles(archive)[0]
+			storeFile(t, dir, archive, "archive")
+			storeFile(t, dir, sidecar, "signature")
+			storeFile(t, dir, "/staging/app_1.3_linux_amd64.tar", "newer")
+			storeFile(t, dir, "/staging/notes.txt", "notes")
+
+			promotions, err := findPromotions(storage, []string{"/staging/app_1.2*"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(promotions) != 1 || !slices.Equal(promotions[0].files, []string{archive, sidecar}) {
+				t.Fatalf("findPromotions = %+v", promotions)
+			}
+
+			if err := promote(storage, promotions[0], "/production", true, lastExports()); err != nil {
+				t.Fatal(err)
+			}
+			for _, filePath := range []string{archive, sidecar} {
+				promoted := "/production/" + filepath.Base(filePath)
+				response, err := dir.DownloadFile(promoted)
+				if err != nil {
+					t.Fatalf("%s not promoted: %v", promoted, err)
+				}
+				content, _ := io.ReadAll(response.Body)
+				response.Body.Close()
+				if want := map[string]string{archive: "archive", sidecar: "signature"}[filePath]; string(content) != want {
+					t.Errorf("%s holds %q, want %q", promoted, content, want)
+				}
+				if _, err := dir.GetFileInfoByPath(filePath); err == nil {
+					t.Errorf("%s not removed by the move", filePath)
+				}
+			}
+
+			records, err := state.Records()
+			if err != nil || len(records) != 1 {
+				t.Fatalf("state records = %+v, %v", records, err)
+			}
+			record := records[0]
+			if record.Operation != state.Promote || record.Result != state.OK || record.Image != "app:1.2" ||
+				!strings.HasSuffix(record.Location, ":/production/app_1.2_linux_amd64.tar") {
+				t.Errorf("promotion recorded as %+v", record)
+			}
+		})
+	}
+}
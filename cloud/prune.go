@@ -0,0 +1,94 @@
+package cloud
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/baowuhe/go-bdfs/pan"
+	"github.com/baowuhe/go-dkci/docker"
+	"github.com/baowuhe/go-dkci/i18n"
+)
+
+// PruneCloud deletes the archives of a Baidu cloud folder matching the grep patterns and creation bounds of the
+// filter, judged by their upload time, together with their signatures, attestations and SBOMs. The files are
+// listed and the deletion confirmed first, unless assumeYes is set; with dryRun set they are only listed. The
+// layers of pruned delta exports stay in the blob store of the folder.
+func PruneCloud(cloudPath string, filter docker.Filter, assumeYes, dryRun bool) {
+	bdfsClient := Login()
+
+	files, err := ListFilesRecursive(bdfsClient, cloudPath, docker.NameDepth())
+	if err != nil {
+		i18n.Printf("[x] Failed to list cloud folder %s: %v\n", cloudPath, err)
+		os.Exit(1)
+	}
+
+	// Hidden folders, such as the blob store of delta exports, are left alone
+	stored := make(map[string]int64)
+	var archives []pan.FileInfo
+	for _, file := range files {
+		if file.IsDir == 1 || strings.Contains(strings.TrimPrefix(file.Path, cloudPath), "/.") {
+			continue
+		}
+		stored[file.Path] = file.Size
+		if !docker.IsArchiveName(file.Path) && !docker.IsIndexName(file.Path) {
+			continue
+		}
+		if filter.MatchFile(file.Path) && filter.MatchCreated(file.ServerMtime) {
+			archives = append(archives, file)
+		}
+	}
+	if len(archives) == 0 {
+		i18n.Printf("No matching files found in %s\n", cloudPath)
+		return
+	}
+	sort.Slice(archives, func(i, j int) bool { return archives[i].Path < archives[j].Path })
+
+	// Sidecars and the parts of split archives are named after the archive, without the suffix of delta recipes and
+	// split manifests
+	var doomed []string
+	var total int64
+	pathWidth := 0
+	for _, archive := range archives {
+		pathWidth = max(pathWidth, len(archive.Path))
+	}
+	fmt.Println()
+	for _, archive := range archives {
+		fmt.Printf("%-*s  %10s  %s\n", pathWidth, archive.Path, docker.FormatSize(archive.Size), docker.FormatAge(archive.ServerMtime))
+		total += archive.Size
+		doomed = append(doomed, archive.Path)
+		archivePath := docker.TrimIndexSuffix(archive.Path)
+		for _, sidecar := range append(docker.SidecarFiles(archivePath), storedParts(stored, archive.Path)...) {
+			if size, ok := stored[sidecar]; ok {
+				fmt.Printf("  %s\n", sidecar)
+				total += size
+				doomed = append(doomed, sidecar)
+			}
+		}
+	}
+	i18n.Printf("%d file(s) to delete, %s in total\n", len(doomed), docker.FormatSize(total))
+
+	if dryRun {
+		return
+	}
+
+	if !docker.ConfirmDeletion(assumeYes) {
+		i18n.Println("[x] Prune cancelled by user")
+		return
+	}
+
+	failed := 0
+	for _, filePath := range doomed {
+		fmt.Printf("Removing %s...\n", filePath)
+		if err := bdfsClient.RemoveFile(filePath); err != nil {
+			i18n.Printf("[x] Failed to remove %s: %v\n", filePath, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		i18n.Printf("[x] %d of %d file(s) could not be removed\n", failed, len(doomed))
+		os.Exit(1)
+	}
+	i18n.Printf("[√] Removed %d file(s) from %s, reclaiming %s\n", len(doomed), cloudPath, docker.FormatSize(total))
+}
@@ -0,0 +1,127 @@
+package cloud
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"path"
+
+	"github.com/baowuhe/go-bdfs/pan"
+	"golang.org/x/time/rate"
+)
+
+// DefaultQPS is how many Baidu cloud API requests are sent per second unless --cloud-qps or cloud_qps in the
+// configuration says otherwise
+const DefaultQPS = 5
+
+// apiLimit is the token bucket every call of the BDFS client waits for; nil sends them unthrottled
+var apiLimit = newAPILimit(DefaultQPS)
+
+// SetQPS limits the calls to the Baidu cloud API to qps per second, in bursts of at most one second's worth; 0 or
+// less sends them unthrottled
+func SetQPS(qps float64) {
+	apiLimit = newAPILimit(qps)
+}
+
+// newAPILimit returns the token bucket of qps requests per second, or nil for no limit
+func newAPILimit(qps float64) *rate.Limiter {
+	if qps <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(qps), max(int(math.Ceil(qps)), 1))
+}
+
+// waitForLimit waits for the turn of a Baidu cloud API call
+func waitForLimit() {
+	if limit := apiLimit; limit != nil {
+		limit.Wait(context.Background())
+	}
+}
+
+// baiduStorage is the Storage of Baidu cloud: the BDFS client, with every call waiting for the rate limit first.
+// The SDK does not let callers set its HTTP client, so the limit applies to its calls rather than its requests;
+// an upload or download waits once, however many requests the SDK sends for it. Only the cloud code goes
+// through it, Docker and registry traffic is never throttled.
+type baiduStorage struct {
+	client *pan.Client
+}
+
+// isBaidu reports whether a storage is Baidu cloud
+func isBaidu(bdfsClient Storage) bool {
+	_, ok := bdfsClient.(*baiduStorage)
+	return ok
+}
+
+func (b *baiduStorage) ListFiles(dirPath string) ([]pan.FileInfo, error) {
+	waitForLimit()
+	return b.client.ListFiles(dirPath)
+}
+
+func (b *baiduStorage) GetFileInfoByPath(filePath string) (*pan.FileInfo, error) {
+	waitForLimit()
+	return b.client.GetFileInfoByPath(filePath)
+}
+
+func (b *baiduStorage) GetDetailedFileInfo(filePath string) (*pan.FileInfo, error) {
+	waitForLimit()
+	return b.client.GetDetailedFileInfo(filePath)
+}
+
+func (b *baiduStorage) UploadFile(localFilePath, remoteFilePath string) error {
+	waitForLimit()
+	return b.client.UploadFile(localFilePath, remoteFilePath)
+}
+
+func (b *baiduStorage) DownloadFile(filePath string) (*http.Response, error) {
+	waitForLimit()
+	return b.client.DownloadFile(filePath)
+}
+
+func (b *baiduStorage) RemoveFile(filePath string) error {
+	waitForLimit()
+	return b.client.RemoveFile(filePath)
+}
+
+func (b *baiduStorage) EnsureRemoteDirExists(remotePath string) error {
+	waitForLimit()
+	return b.client.EnsureRemoteDirExists(remotePath)
+}
+
+// CreateDir creates one folder, whose parent must exist
+func (b *baiduStorage) CreateDir(dirPath string) error {
+	waitForLimit()
+	return b.client.CreateDir(dirPath)
+}
+
+// CopyFile copies a file on the server, creating its folder and replacing dstPath if it exists
+func (b *baiduStorage) CopyFile(srcPath, dstPath string) error {
+	if err := b.clearTarget(dstPath); err != nil {
+		return err
+	}
+	waitForLimit()
+	return b.client.CopyFiles([]pan.CopyRequest{{Path: srcPath, Dest: path.Dir(dstPath), NewName: path.Base(dstPath)}})
+}
+
+// MoveFile moves or renames a file or folder on the server, creating its folder and replacing dstPath if it exists
+func (b *baiduStorage) MoveFile(srcPath, dstPath string) error {
+	if err := b.clearTarget(dstPath); err != nil {
+		return err
+	}
+	waitForLimit()
+	if path.Dir(srcPath) == path.Dir(dstPath) {
+		return b.client.RenameFiles([]pan.RenameRequest{{Path: srcPath, NewName: path.Base(dstPath)}})
+	}
+	return b.client.MoveFiles([]pan.MoveRequest{{Path: srcPath, Dest: path.Dir(dstPath), NewName: path.Base(dstPath)}})
+}
+
+// clearTarget prepares dstPath for a copy or move: it creates its folder and removes a file already there, which
+// Baidu cloud would otherwise keep, storing the new one under another name
+func (b *baiduStorage) clearTarget(dstPath string) error {
+	if err := b.EnsureRemoteDirExists(path.Dir(dstPath)); err != nil {
+		return err
+	}
+	if _, err := b.GetFileInfoByPath(dstPath); err == nil {
+		return b.RemoveFile(dstPath)
+	}
+	return nil
+}
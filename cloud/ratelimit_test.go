@@ -0,0 +1,44 @@
+package cloud
+
+import (
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestSetQPS(t *testing.T) {
+	t.Cleanup(func() { SetQPS(DefaultQPS) })
+	tests := []struct {
+		qps   float64
+		limit rate.Limit
+		burst int
+	}{
+		{5, 5, 5},
+		{0.5, 0.5, 1},
+		{2.5, 2.5, 3},
+	}
+	for _, test := range tests {
+		SetQPS(test.qps)
+		if apiLimit == nil || apiLimit.Limit() != test.limit || apiLimit.Burst() != test.burst {
+			t.Errorf("SetQPS(%v) = %v", test.qps, apiLimit)
+		}
+	}
+	for _, qps := range []float64{0, -1} {
+		if SetQPS(qps); apiLimit != nil {
+			t.Errorf("SetQPS(%v) kept a limit", qps)
+		}
+	}
+}
+
+func TestIsBaidu(t *testing.T) {
+	storage, err := NewDirStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isBaidu(storage) {
+		t.Error("dir:// storage taken for Baidu cloud")
+	}
+	if !isBaidu(&baiduStorage{}) {
+		t.Error("Baidu cloud storage not recognized")
+	}
+}
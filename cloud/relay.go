@@ -0,0 +1,45 @@
+package cloud
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+
+	"github.com/baowuhe/go-dkci/docker"
+)
+
+// RelayImage moves an image from the daemon of source to the daemon of target through the storage: its archive is
+// uploaded as remoteFilePath, then downloaded into target as it is loaded. The archive is removed once the image is
+// loaded unless keep is set, or when the load fails, so that the migration can be finished with import-cloud. It
+// returns the size of the archive.
+func RelayImage(bdfsClient Storage, source, target docker.ImageClient, imageName, remoteFilePath string, keep bool) (int64, error) {
+	if err := ensureFolder(bdfsClient, path.Dir(remoteFilePath)); err != nil {
+		return 0, err
+	}
+	size, _, err := UploadStreamVerified(bdfsClient, remoteFilePath, func(w io.Writer) error {
+		return docker.WriteImage(source, imageName, w)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to upload image %s to %s: %v", imageName, remoteFilePath, err)
+	}
+
+	resp, err := bdfsClient.DownloadFile(remoteFilePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to download %s: %v", remoteFilePath, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to download %s: download request failed with status %d", remoteFilePath, resp.StatusCode)
+	}
+	if _, err := docker.LoadStream(target, resp.Body); err != nil {
+		return 0, fmt.Errorf("failed to load %s: %v (the archive is kept for import-cloud)", remoteFilePath, err)
+	}
+
+	if !keep {
+		if err := bdfsClient.RemoveFile(remoteFilePath); err != nil {
+			fmt.Printf("Warning: Failed to remove %s: %v\n", remoteFilePath, err)
+		}
+	}
+	return size, nil
+}
@@ -0,0 +1,159 @@
+package cloud
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/baowuhe/go-dkci/docker"
+	"github.com/baowuhe/go-dkci/i18n"
+)
+
+// RepackCloud recompresses archives in Baidu cloud with the configured compression and level: each is downloaded,
+// repacked, uploaded with its checksums verified, and replaces the original. If fileNames is not empty, exactly
+// those files of the directory are repacked without prompting.
+func RepackCloud(cloudPath string, filter docker.Filter, fileNames []string) {
+	bdfsClient := Login()
+
+	var selectedFilePaths []string
+	files, err := ListFilesRecursive(bdfsClient, cloudPath, docker.NameDepth())
+	if err != nil {
+		// If listing fails, assume it's a single file
+		fileInfo, err := bdfsClient.GetFileInfoByPath(cloudPath)
+		if err != nil {
+			i18n.Printf("[x] Error accessing cloud file %s: %v\n", cloudPath, err)
+			os.Exit(1)
+		}
+		if !docker.IsArchiveName(fileInfo.Path) {
+			i18n.Printf("[x] The specified file %s is not a .tar file\n", cloudPath)
+			os.Exit(1)
+		}
+		selectedFilePaths = []string{fileInfo.Path}
+	} else {
+		// Files named on the command line are matched without the filter
+		if len(fileNames) > 0 {
+			filter = docker.Filter{}
+		}
+
+		// Delta exports are left alone: their layers are shared with other exports
+		fileEntries := []docker.FileEntry{}
+		for _, file := range files {
+			if docker.IsArchiveName(file.Path) && filter.MatchFile(file.Path) {
+				fileEntries = append(fileEntries, docker.FileEntry{Path: file.Path, Size: file.Size, Modified: file.ServerMtime})
+			}
+		}
+		if len(fileEntries) == 0 {
+			i18n.Println("[x] No .tar files found in the specified cloud directory")
+			os.Exit(1)
+		}
+
+		if len(fileNames) > 0 {
+			selectedFilePaths = docker.ResolveFiles(fileEntries, fileNames)
+		} else {
+			selectedFilePaths = docker.SelectFiles(fileEntries, "Select .tar files to repack:")
+			if len(selectedFilePaths) == 0 {
+				i18n.Println("[x] No files selected for repacking")
+				os.Exit(1)
+			}
+		}
+	}
+
+	failed := 0
+	for _, cloudFilePath := range selectedFilePaths {
+		if err := repackCloudFile(bdfsClient, cloudFilePath); err != nil {
+			i18n.Printf("[x] Failed to repack %s: %v\n", cloudFilePath, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// repackCloudFile downloads, repacks and uploads one archive, removing the original if the repacked archive has
+// another name. The original is only removed once the upload is verified.
+func repackCloudFile(bdfsClient Storage, cloudFilePath string) error {
+	localFilePath, cached, err := fetchArchive(bdfsClient, cloudFilePath)
+	if err != nil {
+		return err
+	}
+	if cached {
+		// Repacking replaces the archive, so work on a copy and keep the cached one for later runs
+		if localFilePath, err = copyToRunDir(localFilePath); err != nil {
+			return err
+		}
+	}
+
+	repackedPath, err := docker.RepackFile(localFilePath)
+	if err != nil {
+		os.Remove(localFilePath)
+		return err
+	}
+	if repackedPath == "" {
+		// Already in the requested format, nothing to upload
+		if !keepTempFiles {
+			os.Remove(localFilePath)
+		}
+		return nil
+	}
+
+	remoteFilePath := path.Join(path.Dir(cloudFilePath), filepath.Base(repackedPath))
+	fmt.Printf("Uploading %s to Baidu cloud at %s...\n", repackedPath, remoteFilePath)
+	if err := UploadVerified(bdfsClient, repackedPath, remoteFilePath); err != nil {
+		return fmt.Errorf("failed to upload %s: %v (kept at %s)", remoteFilePath, err, repackedPath)
+	}
+	i18n.Printf("[√] Uploaded %s to Baidu cloud at %s\n", filepath.Base(repackedPath), remoteFilePath)
+
+	if remoteFilePath != cloudFilePath {
+		if err := bdfsClient.RemoveFile(cloudFilePath); err != nil {
+			fmt.Printf("Warning: Failed to remove %s from Baidu cloud: %v\n", cloudFilePath, err)
+		}
+	}
+
+	// The sidecars in the cloud were made for the original archive
+	var stale []string
+	for _, sidecar := range docker.SidecarFiles(cloudFilePath) {
+		if _, err := bdfsClient.GetFileInfoByPath(sidecar); err == nil {
+			stale = append(stale, sidecar)
+		}
+	}
+	docker.WarnStaleSidecars(cloudFilePath, stale)
+
+	if keepTempFiles {
+		keepFiles(repackedPath)
+	} else if err := os.Remove(repackedPath); err != nil {
+		fmt.Printf("Warning: Failed to remove temporary file %s: %v\n", repackedPath, err)
+	}
+	return nil
+}
+
+// copyToRunDir copies a file into the directory of this run, hard linking it where the filesystem allows, and
+// returns the path of the copy
+func copyToRunDir(filePath string) (string, error) {
+	workDir, err := runDir()
+	if err != nil {
+		return "", err
+	}
+	copyPath := filepath.Join(workDir, filepath.Base(filePath))
+	if err := os.Link(filePath, copyPath); err == nil {
+		return copyPath, nil
+	}
+
+	source, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+	target, err := os.Create(copyPath)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		os.Remove(copyPath)
+		return "", fmt.Errorf("failed to copy %s: %v", filePath, err)
+	}
+	return copyPath, target.Close()
+}
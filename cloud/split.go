@@ -0,0 +1,168 @@
+package cloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/baowuhe/go-dkci/checksum"
+	"github.com/baowuhe/go-dkci/docker"
+)
+
+// splitManifest lists the parts of an archive larger than the largest file the account can upload. It is stored
+// next to them as the archive name with docker.SplitSuffix.
+type splitManifest struct {
+	Size   int64       `json:"size"`
+	SHA256 string      `json:"sha256"`
+	Parts  []splitPart `json:"parts"`
+}
+
+// splitPart is a part of a split archive, named after the archive by docker.SplitPartName
+type splitPart struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// isSplitManifest reports whether a cloud file is the manifest of an archive stored in parts
+func isSplitManifest(filePath string) bool {
+	return strings.HasSuffix(strings.ToLower(filePath), docker.SplitSuffix)
+}
+
+// uploadSplit uploads a local archive in parts of at most partSize bytes, staged in a temporary file for storages
+// that cannot take streams, then the manifest listing them to remoteFilePath + docker.SplitSuffix
+func uploadSplit(bdfsClient Storage, localFilePath, remoteFilePath string, partSize int64) error {
+	sums, err := checksum.File(localFilePath)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(localFilePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	manifest := splitManifest{Size: sums.Size, SHA256: sums.SHA256}
+	count := int((sums.Size + partSize - 1) / partSize)
+	for i := range count {
+		offset := int64(i) * partSize
+		size := min(partSize, sums.Size-offset)
+		remotePartPath := docker.SplitPartName(remoteFilePath, i+1)
+		fmt.Printf("Uploading part %d of %d (%s) to %s...\n", i+1, count, docker.FormatSize(size), remotePartPath)
+		written, partSHA256, err := UploadStreamVerified(bdfsClient, remotePartPath, func(w io.Writer) error {
+			_, err := io.Copy(w, io.NewSectionReader(file, offset, size))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("part %d: %v", i+1, err)
+		}
+		manifest.Parts = append(manifest.Parts, splitPart{Name: path.Base(remotePartPath), Size: written, SHA256: partSHA256})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	manifestPath := localFilePath + docker.SplitSuffix
+	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+		return err
+	}
+	defer os.Remove(manifestPath)
+	return UploadVerified(bdfsClient, manifestPath, remoteFilePath+docker.SplitSuffix)
+}
+
+// restoreSplit downloads the parts listed by the manifest of a split archive one after the other and joins them
+// into localFilePath, checking each part and the whole archive against the manifest
+func restoreSplit(bdfsClient Storage, cloudManifestPath, localFilePath string) error {
+	manifestPath := localFilePath + docker.SplitSuffix
+	if err := DownloadToFile(bdfsClient, cloudManifestPath, manifestPath); err != nil {
+		os.Remove(manifestPath)
+		return err
+	}
+	data, err := os.ReadFile(manifestPath)
+	os.Remove(manifestPath)
+	if err != nil {
+		return err
+	}
+	var manifest splitManifest
+	if err := json.Unmarshal(data, &manifest); err != nil || len(manifest.Parts) == 0 {
+		return fmt.Errorf("invalid split manifest %s", cloudManifestPath)
+	}
+
+	outFile, err := os.Create(localFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to create local file %s: %v", localFilePath, err)
+	}
+	defer outFile.Close()
+
+	hasher := checksum.New()
+	for i, part := range manifest.Parts {
+		remotePartPath := path.Join(path.Dir(cloudManifestPath), part.Name)
+		localPartPath := docker.SplitPartName(localFilePath, i+1)
+		fmt.Printf("Downloading part %d of %d (%s) from %s...\n", i+1, len(manifest.Parts), docker.FormatSize(part.Size), remotePartPath)
+		if err := DownloadToFile(bdfsClient, remotePartPath, localPartPath); err != nil {
+			os.Remove(localPartPath)
+			return fmt.Errorf("part %d: %v", i+1, err)
+		}
+		err := appendPart(outFile, hasher, localPartPath, part)
+		os.Remove(localPartPath)
+		if err != nil {
+			return fmt.Errorf("part %d: %v", i+1, err)
+		}
+	}
+	if err := outFile.Close(); err != nil {
+		return err
+	}
+
+	if sums := hasher.Sums(); sums.Size != manifest.Size || sums.SHA256 != manifest.SHA256 {
+		return fmt.Errorf("checksum mismatch of the joined archive (expected %s, got %s)", manifest.SHA256, sums.SHA256)
+	}
+	checksum.Remember(localFilePath, hasher.Sums())
+	return nil
+}
+
+// appendPart appends a downloaded part to the joined archive after checking it against the manifest
+func appendPart(outFile io.Writer, hasher *checksum.Hasher, localPartPath string, part splitPart) error {
+	sums, err := checksum.File(localPartPath)
+	if err != nil {
+		return err
+	}
+	if sums.Size != part.Size || sums.SHA256 != part.SHA256 {
+		return fmt.Errorf("checksum mismatch (expected %s, got %s)", part.SHA256, sums.SHA256)
+	}
+	partFile, err := os.Open(localPartPath)
+	if err != nil {
+		return err
+	}
+	defer partFile.Close()
+	_, err = io.Copy(io.MultiWriter(outFile, hasher), partFile)
+	return err
+}
+
+// storedParts returns the parts stored next to the manifest of a split archive, or none for other files
+func storedParts(stored map[string]int64, manifestPath string) []string {
+	if !isSplitManifest(manifestPath) {
+		return nil
+	}
+	archivePath := docker.TrimIndexSuffix(manifestPath)
+	var parts []string
+	for part := 1; ; part++ {
+		partPath := docker.SplitPartName(archivePath, part)
+		if _, ok := stored[partPath]; !ok {
+			return parts
+		}
+		parts = append(parts, partPath)
+	}
+}
+
+// localFileSize returns the size of a local file, or 0 if it cannot be read, which the upload then reports
+func localFileSize(localFilePath string) int64 {
+	info, err := os.Stat(localFilePath)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
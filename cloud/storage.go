@@ -0,0 +1,53 @@
+package cloud
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/baowuhe/go-bdfs/pan"
+)
+
+// Storage is the file store behind the cloud commands: Baidu cloud through the BDFS client, or a local directory
+// or storage plugin selected with SetBackend. Paths are '/'-separated and absolute within the store.
+type Storage interface {
+	ListFiles(dirPath string) ([]pan.FileInfo, error)
+	GetFileInfoByPath(filePath string) (*pan.FileInfo, error)
+	GetDetailedFileInfo(filePath string) (*pan.FileInfo, error)
+	UploadFile(localFilePath, remoteFilePath string) error
+	DownloadFile(filePath string) (*http.Response, error)
+	RemoveFile(filePath string) error
+	EnsureRemoteDirExists(remotePath string) error
+}
+
+// StreamStorage is a Storage that stores a file while it is written, without a local copy of it. Streamed cloud
+// exports use it; the other storages upload from a temporary file instead.
+type StreamStorage interface {
+	Storage
+	// UploadStream stores what write writes as remoteFilePath, leaving no file behind if write fails
+	UploadStream(remoteFilePath string, write func(w io.Writer) error) error
+}
+
+// CopyStorage is a Storage that copies files itself, without sending them through a local copy. Promotions use it;
+// the other storages download and upload the files instead.
+type CopyStorage interface {
+	Storage
+	// CopyFile copies srcPath to dstPath, replacing it if it exists
+	CopyFile(srcPath, dstPath string) error
+}
+
+// MoveStorage is a Storage that moves and renames files and folders itself. Moves use it; the other storages copy
+// the files with copyRemote and remove them instead.
+type MoveStorage interface {
+	Storage
+	// MoveFile moves the file or folder srcPath to dstPath, creating its folder
+	MoveFile(srcPath, dstPath string) error
+}
+
+var (
+	_ CopyStorage   = (*baiduStorage)(nil)
+	_ MoveStorage   = (*baiduStorage)(nil)
+	_ StreamStorage = (*DirStorage)(nil)
+	_ CopyStorage   = (*DirStorage)(nil)
+	_ MoveStorage   = (*DirStorage)(nil)
+	_ Storage       = (*PluginStorage)(nil)
+)
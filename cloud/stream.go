@@ -0,0 +1,101 @@
+package cloud
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/baowuhe/go-dkci/checksum"
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/job"
+)
+
+// streamUploads makes cloud exports stream the archives from Docker into the storage, instead of writing them to a
+// temporary file and uploading that
+var streamUploads bool
+
+// SetStream makes cloud exports stream the archives into the storage, compressing them on the way if requested.
+// Only backends for which CanStream holds take streams.
+func SetStream(enabled bool) {
+	streamUploads = enabled
+}
+
+// CanStream reports whether the backend selected by SetBackend takes files while they are written, as SetStream
+// needs: only the dir:// backend does. The BDFS SDK and storage plugins upload local files.
+func CanStream() bool {
+	return backendDir != "" && backendPlugin == nil
+}
+
+// canStream reports whether a storage takes files while they are written: the dir:// backend does. Others get
+// what is written staged in a temporary file by uploadStream.
+func canStream(bdfsClient Storage) bool {
+	_, ok := bdfsClient.(StreamStorage)
+	return ok
+}
+
+// UploadStreamVerified stores what write writes as remoteFilePath and checks the size and MD5 reported by the
+// server against it, uploading again up to the upload retries if they differ. write may be called more than once
+// and must write the same bytes every time. It returns the size and SHA-256 checksum of the stored file.
+func UploadStreamVerified(bdfsClient Storage, remoteFilePath string, write func(w io.Writer) error) (int64, string, error) {
+	for attempt := 0; ; attempt++ {
+		// The last pass of the upload is what is stored, so it is the one hashed
+		var hasher *checksum.Hasher
+		measured := func(w io.Writer) error {
+			hasher = checksum.New()
+			return write(io.MultiWriter(w, hasher))
+		}
+		if err := uploadStream(bdfsClient, remoteFilePath, measured); err != nil {
+			return 0, "", err
+		}
+
+		sums := hasher.Sums()
+		remoteInfo, err := bdfsClient.GetDetailedFileInfo(remoteFilePath)
+		if err == nil && remoteInfo.Size != sums.Size {
+			err = fmt.Errorf("size mismatch (local %d bytes, remote %d bytes)", sums.Size, remoteInfo.Size)
+		}
+		if err == nil {
+			err = compareMD5(sums.MD5, remoteInfo.MD5)
+		}
+		if err == nil {
+			job.AddBytes(remoteInfo.Size)
+			return sums.Size, sums.SHA256, nil
+		}
+
+		if attempt >= uploadRetries {
+			return 0, "", fmt.Errorf("upload verification failed: %v", err)
+		}
+		fmt.Printf("Warning: Upload verification of %s failed: %v, retrying (%d/%d)...\n", remoteFilePath, err, attempt+1, uploadRetries)
+	}
+}
+
+// uploadStream stores what write writes as remoteFilePath, through the storage if it takes streams. Other storages
+// upload local files, so what write writes is staged in a temporary file of the run directory, in one pass, and
+// uploaded from there.
+func uploadStream(bdfsClient Storage, remoteFilePath string, write func(w io.Writer) error) error {
+	if storage, ok := bdfsClient.(StreamStorage); ok {
+		return storage.UploadStream(remoteFilePath, write)
+	}
+
+	workDir, err := runDir()
+	if err != nil {
+		return err
+	}
+	stagedFile, err := os.CreateTemp(workDir, path.Base(remoteFilePath)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary file for %s: %v", remoteFilePath, err)
+	}
+	stagedFilePath := stagedFile.Name()
+	config.Track(stagedFilePath)
+	defer os.Remove(stagedFilePath)
+
+	err = write(stagedFile)
+	if closeErr := stagedFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", filepath.Base(stagedFilePath), err)
+	}
+	return uploadFile(bdfsClient, stagedFilePath, remoteFilePath)
+}
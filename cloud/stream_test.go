@@ -0,0 +1,75 @@
+package cloud
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/baowuhe/go-dkci/config"
+)
+
+// uploadOnly hides the streaming of a storage, like the BDFS SDK and storage plugins that upload local files
+type uploadOnly struct {
+	Storage
+}
+
+func TestUploadStreamStaged(t *testing.T) {
+	t.Setenv("DKCI_TEMP_DIR", t.TempDir())
+	t.Cleanup(config.RemoveRunDir)
+	dir, err := NewDirStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage := uploadOnly{dir}
+	if canStream(storage) {
+		t.Fatal("upload-only storage taken for a stream storage")
+	}
+
+	writes := 0
+	content := strings.Repeat("archive", 1000)
+	size, sha256, err := UploadStreamVerified(storage, "/images/app.tar", func(w io.Writer) error {
+		writes++
+		_, err := io.WriteString(w, content)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if writes != 1 {
+		t.Errorf("archive written %d times, want once", writes)
+	}
+	if size != int64(len(content)) || sha256 == "" {
+		t.Errorf("UploadStreamVerified = %d, %q", size, sha256)
+	}
+
+	response, err := dir.DownloadFile("/images/app.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if stored, _ := io.ReadAll(response.Body); string(stored) != content {
+		t.Errorf("stored %d bytes, want %d", len(stored), len(content))
+	}
+
+	// The staged copy is removed once uploaded
+	workDir, err := config.RunDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(workDir); len(entries) != 0 {
+		t.Errorf("staged files left behind: %v", entries)
+	}
+}
+
+func TestCanStream(t *testing.T) {
+	t.Cleanup(func() { SetBackend("") })
+	for spec, want := range map[string]bool{"bdfs": false, DirPrefix + t.TempDir(): true} {
+		if err := SetBackend(spec); err != nil {
+			t.Fatal(err)
+		}
+		if got := CanStream(); got != want {
+			t.Errorf("CanStream() with --backend %s = %v, want %v", spec, got, want)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package cloud
+
+import "time"
+
+// DefaultTimeout is how long a download from Baidu cloud may pass no data unless --cloud-timeout or cloud_timeout
+// in the configuration says otherwise
+const DefaultTimeout = time.Minute
+
+// timeout is how long a download may stall before it fails; 0 waits forever. Other Baidu cloud requests, uploads
+// included, are bounded by the BDFS SDK itself.
+var timeout = DefaultTimeout
+
+// SetTimeout sets how long a download from Baidu cloud may pass no data before it fails; 0 waits forever
+func SetTimeout(d time.Duration) {
+	timeout = d
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,6 +15,35 @@ type BDFSConfig struct {
 	ClientSecret    string `toml:"client_secret"`
 	TokenPath       string `toml:"token_path"`
 	DefaultCloudDir string `toml:"default_cloud_dir"`
+	NameTemplate    string `toml:"name_template"`
+
+	SaveConcurrency     int `toml:"save_concurrency"`
+	TransferConcurrency int `toml:"transfer_concurrency"`
+	LoadConcurrency     int `toml:"load_concurrency"`
+
+	DockerTimeout string `toml:"docker_timeout"`
+	CloudTimeout  string `toml:"cloud_timeout"`
+
+	DockerSockets []string `toml:"docker_sockets"`
+
+	CloudQPS *float64 `toml:"cloud_qps"`
+
+	AccountLevel string `toml:"account_level"`
+
+	CompressLevel int `toml:"compress_level"`
+
+	AllowImages []string `toml:"allow_images"`
+	DenyImages  []string `toml:"deny_images"`
+
+	PolicyCommand string       `toml:"policy_command"`
+	PolicyRules   []PolicyRule `toml:"policy"`
+
+	Email map[string]EmailProfile `toml:"email"`
+
+	PreExport  string `toml:"pre_export"`
+	PostExport string `toml:"post_export"`
+	PreImport  string `toml:"pre_import"`
+	PostImport string `toml:"post_import"`
 }
 
 // GetBDFSConfig retrieves the BDFS configuration from environment variables or TOML file
@@ -40,15 +70,9 @@ func GetBDFSConfig() (*BDFSConfig, error) {
 	}
 
 	// If individual variables aren't all set, check for config file path
-	configFilePath := os.Getenv("BDFS_CONFIG_FILE")
-
-	// If BDFS_CONFIG_FILE is not set, use the default path
-	if configFilePath == "" {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get home directory: %v", err)
-		}
-		configFilePath = filepath.Join(homeDir, ".local", "app", "dkci", "config.toml")
+	configFilePath, err := getConfigFilePath()
+	if err != nil {
+		return nil, err
 	}
 
 	// Read and parse the TOML configuration file
@@ -60,6 +84,19 @@ func GetBDFSConfig() (*BDFSConfig, error) {
 	if err := toml.Unmarshal(data, config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %v", err)
 	}
+	for _, field := range []struct {
+		key   string
+		value *string
+	}{
+		{"client_id", &config.ClientID},
+		{"client_secret", &config.ClientSecret},
+		{"token_path", &config.TokenPath},
+		{"default_cloud_dir", &config.DefaultCloudDir},
+	} {
+		if err := expand(field.key, field.value); err != nil {
+			return nil, err
+		}
+	}
 
 	// Ensure all required values are present
 	if config.ClientID == "" || config.ClientSecret == "" || config.TokenPath == "" {
@@ -73,3 +110,285 @@ func GetBDFSConfig() (*BDFSConfig, error) {
 
 	return config, nil
 }
+
+// GetNameTemplate returns the file name template for exported archives from the DKCI_NAME_TEMPLATE environment
+// variable or the name_template key of the configuration file. It returns an empty string if neither is set;
+// unlike GetBDFSConfig it does not require the Baidu cloud credentials.
+func GetNameTemplate() (string, error) {
+	if nameTemplate := os.Getenv("DKCI_NAME_TEMPLATE"); nameTemplate != "" {
+		return nameTemplate, nil
+	}
+
+	config, err := readConfigFile()
+	if err != nil {
+		return "", nil
+	}
+	if err := expand("name_template", &config.NameTemplate); err != nil {
+		return "", err
+	}
+	return config.NameTemplate, nil
+}
+
+// Concurrency holds the limits of the parallel phases of exports and imports; zero leaves a limit unset
+type Concurrency struct {
+	Save     int
+	Transfer int
+	Load     int
+}
+
+// GetConcurrency returns the default concurrency limits from the save_concurrency, transfer_concurrency and
+// load_concurrency keys of the configuration file. Limits missing from the file, or all of them when there is
+// no readable file, are zero.
+func GetConcurrency() Concurrency {
+	config, err := readConfigFile()
+	if err != nil {
+		return Concurrency{}
+	}
+	return Concurrency{Save: config.SaveConcurrency, Transfer: config.TransferConcurrency, Load: config.LoadConcurrency}
+}
+
+// Timeouts holds the default timeouts of the configuration file as durations such as "90s" or "5m"; an empty
+// string leaves a timeout unset
+type Timeouts struct {
+	Docker string
+	Cloud  string
+}
+
+// GetTimeouts returns the default timeouts from the docker_timeout and cloud_timeout keys of the configuration
+// file. Timeouts missing from the file, or all of them when there is no readable file, are empty.
+func GetTimeouts() (Timeouts, error) {
+	config, err := readConfigFile()
+	if err != nil {
+		return Timeouts{}, nil
+	}
+	if err := expand("docker_timeout", &config.DockerTimeout); err != nil {
+		return Timeouts{}, err
+	}
+	if err := expand("cloud_timeout", &config.CloudTimeout); err != nil {
+		return Timeouts{}, err
+	}
+	return Timeouts{Docker: config.DockerTimeout, Cloud: config.CloudTimeout}, nil
+}
+
+// GetCloudQPS returns the rate limit of Baidu cloud API requests from the cloud_qps key of the configuration file,
+// and whether the file sets it; 0 turns the limit off
+func GetCloudQPS() (float64, bool) {
+	config, err := readConfigFile()
+	if err != nil || config.CloudQPS == nil {
+		return 0, false
+	}
+	return *config.CloudQPS, true
+}
+
+// GetAccountLevel returns the membership level of the Baidu account from the account_level key of the
+// configuration file, or an empty string when the file does not set it
+func GetAccountLevel() (string, error) {
+	config, err := readConfigFile()
+	if err != nil {
+		return "", nil
+	}
+	if err := expand("account_level", &config.AccountLevel); err != nil {
+		return "", err
+	}
+	return config.AccountLevel, nil
+}
+
+// GetCompressLevel returns the default compression level of exports from the compress_level key of the
+// configuration file, or 0 when the file does not set it
+func GetCompressLevel() int {
+	config, err := readConfigFile()
+	if err != nil {
+		return 0
+	}
+	return config.CompressLevel
+}
+
+// GetDockerSockets returns the docker_sockets key of the configuration file, the order in which the sockets of
+// Docker installations are probed, or nil when the file does not set it
+func GetDockerSockets() ([]string, error) {
+	config, err := readConfigFile()
+	if err != nil {
+		return nil, nil
+	}
+	for i := range config.DockerSockets {
+		if err := expand("docker_sockets", &config.DockerSockets[i]); err != nil {
+			return nil, err
+		}
+	}
+	return config.DockerSockets, nil
+}
+
+// ImageRules holds the regular expressions of the image references that may be exported (Allow, all of them when
+// empty) and that may not (Deny)
+type ImageRules struct {
+	Allow []string
+	Deny  []string
+}
+
+// GetImageRules returns the allow_images and deny_images keys of the configuration file. Without a configuration
+// file there are no rules; unlike the other settings, a file that cannot be read or parsed is an error, so that
+// a broken file never lets images out that it forbids.
+func GetImageRules() (ImageRules, error) {
+	if _, err := getConfigFilePath(); err != nil {
+		return ImageRules{}, nil
+	}
+	config, err := readConfigFile()
+	if errors.Is(err, os.ErrNotExist) {
+		return ImageRules{}, nil
+	}
+	if err != nil {
+		return ImageRules{}, fmt.Errorf("failed to read the image rules of the configuration file: %v", err)
+	}
+	return ImageRules{Allow: config.AllowImages, Deny: config.DenyImages}, nil
+}
+
+// PolicyRule is a [[policy]] table of the configuration file: the decision taken for the exports matching all of
+// its conditions. Conditions left empty match every export.
+type PolicyRule struct {
+	Name string `toml:"name"`
+	// Image is a regular expression matching the whole image reference
+	Image string `toml:"image"`
+	// Destination is a regular expression matching the whole destination, a local directory or a cloud location
+	Destination string `toml:"destination"`
+	// Labels are the labels the image must carry, as key=value or only key for any value
+	Labels []string `toml:"labels"`
+	// MinSize is the size from which an image matches, such as 2GB
+	MinSize string `toml:"min_size"`
+	// Decision is warn, confirm or block
+	Decision string `toml:"decision"`
+}
+
+// Policy holds the rules and the command deciding whether images may be exported
+type Policy struct {
+	Command string
+	Rules   []PolicyRule
+}
+
+// GetPolicy returns the policy_command key and the [[policy]] tables of the configuration file. Like
+// GetImageRules, it only returns no policy without a configuration file, and an error for a file that cannot be
+// read or parsed.
+func GetPolicy() (Policy, error) {
+	if _, err := getConfigFilePath(); err != nil {
+		return Policy{}, nil
+	}
+	config, err := readConfigFile()
+	if errors.Is(err, os.ErrNotExist) {
+		return Policy{}, nil
+	}
+	if err != nil {
+		return Policy{}, fmt.Errorf("failed to read the export policy of the configuration file: %v", err)
+	}
+	return Policy{Command: config.PolicyCommand, Rules: config.PolicyRules}, nil
+}
+
+// EmailProfile is an [email.<name>] table of the configuration file: the SMTP server and the recipients of the
+// summaries emailed at the end of runs given --notify <name>
+type EmailProfile struct {
+	SMTPHost string   `toml:"smtp_host"`
+	SMTPPort int      `toml:"smtp_port"`
+	Username string   `toml:"username"`
+	Password string   `toml:"password"`
+	From     string   `toml:"from"`
+	To       []string `toml:"to"`
+	// On is when to send: always (the default) or failure
+	On string `toml:"on"`
+}
+
+// GetEmailProfile returns the [email.<name>] table of the configuration file, or an error if the file cannot be
+// read or has no such table
+func GetEmailProfile(name string) (EmailProfile, error) {
+	config, err := readConfigFile()
+	if err != nil {
+		return EmailProfile{}, fmt.Errorf("failed to read the email profiles of the configuration file: %v", err)
+	}
+	profile, ok := config.Email[name]
+	if !ok {
+		return EmailProfile{}, fmt.Errorf("the configuration file has no [email.%s] table", name)
+	}
+	for _, field := range []struct {
+		key   string
+		value *string
+	}{
+		{"smtp_host", &profile.SMTPHost},
+		{"username", &profile.Username},
+		{"password", &profile.Password},
+		{"from", &profile.From},
+	} {
+		if err := expand("email."+name+"."+field.key, field.value); err != nil {
+			return EmailProfile{}, err
+		}
+	}
+	return profile, nil
+}
+
+// Hooks holds the shell commands run around the exports and imports of single images; empty commands are not run
+type Hooks struct {
+	PreExport  string
+	PostExport string
+	PreImport  string
+	PostImport string
+}
+
+// GetHooks returns the hook commands from the pre_export, post_export, pre_import and post_import keys of the
+// configuration file. Hooks missing from the file, or all of them when there is no readable file, are empty.
+func GetHooks() Hooks {
+	config, err := readConfigFile()
+	if err != nil {
+		return Hooks{}
+	}
+	return Hooks{PreExport: config.PreExport, PostExport: config.PostExport, PreImport: config.PreImport, PostImport: config.PostImport}
+}
+
+// readConfigFile parses the configuration file without checking the Baidu cloud credentials. The environment
+// variable references are left in place for the getters to expand in the keys they return.
+func readConfigFile() (*BDFSConfig, error) {
+	configFilePath, err := getConfigFilePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(configFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &BDFSConfig{}
+	if err := toml.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// StateFile returns the path of the state database recording the exports and imports, state.jsonl next to the
+// configuration file
+func StateFile() (string, error) {
+	configFilePath, err := getConfigFilePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(configFilePath), "state.jsonl"), nil
+}
+
+// ServiceEnvFile returns the path of the environment file of a service installed by install-service, <name>.env next
+// to the configuration file
+func ServiceEnvFile(name string) (string, error) {
+	configFilePath, err := getConfigFilePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(configFilePath), name+".env"), nil
+}
+
+// getConfigFilePath returns the path of the TOML configuration file from BDFS_CONFIG_FILE or the default location
+func getConfigFilePath() (string, error) {
+	configFilePath := os.Getenv("BDFS_CONFIG_FILE")
+
+	// If BDFS_CONFIG_FILE is not set, use the default path
+	if configFilePath == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %v", err)
+		}
+		configFilePath = filepath.Join(homeDir, ".local", "app", "dkci", "config.toml")
+	}
+	return configFilePath, nil
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// variablePattern matches the ${NAME} and ${NAME:-default} references of configuration values, and the $${
+// escape of a literal ${
+var variablePattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expand replaces the environment variable references in the value of the key of the configuration file, so that
+// one file can be shared across machines with secrets injected at runtime, such as the SMTP passwords of the email
+// profiles. The getters expand only the keys they return, so that a reference to an unset variable fails the
+// commands reading that key and no other. The hook commands are left alone, as the shell running them expands
+// their variables.
+func expand(key string, value *string) error {
+	expanded, err := expandVariables(*value)
+	if err != nil {
+		return fmt.Errorf("%s in the config file refers to %v", key, err)
+	}
+	*value = expanded
+	return nil
+}
+
+// expandVariables replaces ${NAME} with the value of the environment variable, or the default of
+// ${NAME:-default} if it is unset or empty. A variable that is unset without a default is an error rather than
+// an empty value, which would fail later and less clearly.
+func expandVariables(value string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+
+	var missing string
+	expanded := variablePattern.ReplaceAllStringFunc(value, func(reference string) string {
+		if reference == "$${" {
+			return "${"
+		}
+		match := variablePattern.FindStringSubmatch(reference)
+		if v := os.Getenv(match[1]); v != "" {
+			return v
+		}
+		if match[2] != "" {
+			return match[3]
+		}
+		if _, ok := os.LookupEnv(match[1]); !ok && missing == "" {
+			missing = match[1]
+		}
+		return ""
+	})
+	if missing != "" {
+		return "", fmt.Errorf("unset environment variable %s", missing)
+	}
+	return expanded, nil
+}
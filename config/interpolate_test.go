@@ -0,0 +1,103 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// useConfigFile points BDFS_CONFIG_FILE at a file holding content, without the BDFS credentials of the environment
+func useConfigFile(t *testing.T, content string) {
+	t.Helper()
+	configFilePath := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(configFilePath, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BDFS_CONFIG_FILE", configFilePath)
+	t.Setenv("BDFS_CLIENT_ID", "")
+	t.Setenv("DKCI_NAME_TEMPLATE", "")
+}
+
+func TestExpandVariables(t *testing.T) {
+	t.Setenv("DKCI_TEST_SET", "value")
+	t.Setenv("DKCI_TEST_EMPTY", "")
+	os.Unsetenv("DKCI_TEST_UNSET")
+
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "plain", want: "plain"},
+		{value: "${DKCI_TEST_SET}/token.json", want: "value/token.json"},
+		{value: "${DKCI_TEST_UNSET:-/docker-images}", want: "/docker-images"},
+		{value: "${DKCI_TEST_EMPTY:-fallback}", want: "fallback"},
+		{value: "${DKCI_TEST_SET:-fallback}", want: "value"},
+		{value: "${DKCI_TEST_UNSET:-}", want: ""},
+		{value: "${DKCI_TEST_EMPTY}", want: ""},
+		{value: "$${DKCI_TEST_SET}", want: "${DKCI_TEST_SET}"},
+		{value: "${DKCI_TEST_UNSET}", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := expandVariables(test.value)
+		if test.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "DKCI_TEST_UNSET") {
+				t.Errorf("expandVariables(%q) = %q, %v, want an error naming the variable", test.value, got, err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("expandVariables(%q) = %q, %v, want %q", test.value, got, err, test.want)
+		}
+	}
+}
+
+func TestUnsetVariableFailsOnlyItsKey(t *testing.T) {
+	os.Unsetenv("DKCI_TEST_SECRET")
+	t.Setenv("DKCI_TEST_LEVEL", "svip")
+	useConfigFile(t, `
+client_id = "id"
+client_secret = "${DKCI_TEST_SECRET}"
+token_path = "/tmp/token.json"
+name_template = "{{.Repository}}_{{.Tag}}.tar"
+docker_timeout = "${DKCI_TEST_TIMEOUT:-90s}"
+account_level = "${DKCI_TEST_LEVEL}"
+docker_sockets = ["${DKCI_TEST_SOCKET:-default}"]
+save_concurrency = 3
+`)
+
+	if _, err := GetBDFSConfig(); err == nil || !strings.Contains(err.Error(), "client_secret") || !strings.Contains(err.Error(), "DKCI_TEST_SECRET") {
+		t.Errorf("GetBDFSConfig error = %v, want one naming client_secret and DKCI_TEST_SECRET", err)
+	}
+
+	// The keys without unset variables are read as usual
+	if nameTemplate, err := GetNameTemplate(); err != nil || nameTemplate != "{{.Repository}}_{{.Tag}}.tar" {
+		t.Errorf("GetNameTemplate = %q, %v", nameTemplate, err)
+	}
+	if timeouts, err := GetTimeouts(); err != nil || timeouts.Docker != "90s" || timeouts.Cloud != "" {
+		t.Errorf("GetTimeouts = %+v, %v", timeouts, err)
+	}
+	if level, err := GetAccountLevel(); err != nil || level != "svip" {
+		t.Errorf("GetAccountLevel = %q, %v", level, err)
+	}
+	if sockets, err := GetDockerSockets(); err != nil || !slices.Equal(sockets, []string{"default"}) {
+		t.Errorf("GetDockerSockets = %q, %v", sockets, err)
+	}
+	if concurrency := GetConcurrency(); concurrency.Save != 3 {
+		t.Errorf("GetConcurrency = %+v", concurrency)
+	}
+	if _, err := GetImageRules(); err != nil {
+		t.Errorf("GetImageRules: %v", err)
+	}
+	if _, err := GetPolicy(); err != nil {
+		t.Errorf("GetPolicy: %v", err)
+	}
+
+	// The key with the unset variable fails the getter reading it
+	os.Unsetenv("DKCI_TEST_LEVEL")
+	if level, err := GetAccountLevel(); err == nil || !strings.Contains(err.Error(), "account_level") {
+		t.Errorf("GetAccountLevel = %q, %v, want an error naming account_level", level, err)
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// runDirPrefix starts the names of the directories of runs in TempDir
+const runDirPrefix = "run-"
+
+// The working directory of this run, created on first use by RunDir
+var (
+	runDirMu sync.Mutex
+	runDir   string
+)
+
+// RunDir returns the directory of TempDir belonging to this run of go-dkci, run-<pid>-<random>, creating it on
+// first use. Intermediate files, such as the archives of cloud exports and the downloads of cloud imports, are
+// written there, so that runs working on the same image at the same time do not overwrite each other's files.
+// RemoveRunDir deletes it when the run ends.
+func RunDir() (string, error) {
+	runDirMu.Lock()
+	defer runDirMu.Unlock()
+
+	if runDir != "" {
+		return runDir, nil
+	}
+	dir := TempDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	created, err := os.MkdirTemp(dir, fmt.Sprintf("%s%d-", runDirPrefix, os.Getpid()))
+	if err != nil {
+		return "", err
+	}
+	Track(created)
+	runDir = created
+	return runDir, nil
+}
+
+// KeepFile moves a file of RunDir to the same path relative to TempDir, where it outlives the run, and returns
+// its new path. Files outside RunDir are left where they are.
+func KeepFile(path string) (string, error) {
+	runDirMu.Lock()
+	dir := runDir
+	runDirMu.Unlock()
+
+	rel, err := filepath.Rel(dir, path)
+	if dir == "" || err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path, nil
+	}
+	kept := filepath.Join(TempDir(), rel)
+	if err := os.MkdirAll(filepath.Dir(kept), 0755); err != nil {
+		return path, err
+	}
+	if err := os.Rename(path, kept); err != nil {
+		return path, err
+	}
+	Track(kept)
+	return kept, nil
+}
+
+// RemoveRunDir deletes RunDir with the files left in it, if this run created it. Runs ending on an error may
+// leave theirs behind; `go-dkci clean` deletes them.
+func RemoveRunDir() {
+	runDirMu.Lock()
+	defer runDirMu.Unlock()
+
+	if runDir == "" {
+		return
+	}
+	if err := os.RemoveAll(runDir); err != nil {
+		return
+	}
+
+	// Forget the directory, so that the list of created files does not grow with every run
+	name := filepath.Base(runDir)
+	runDir = ""
+	Untrack(name)
+}
+
+// RunDirPID returns the id of the process whose run directory is the entry name of TempDir, and false if name is
+// not a run directory
+func RunDirPID(name string) (int, bool) {
+	rest, ok := strings.CutPrefix(name, runDirPrefix)
+	if !ok {
+		return 0, false
+	}
+	pid, _, ok := strings.Cut(rest, "-")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.Atoi(pid)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
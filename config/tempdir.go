@@ -0,0 +1,159 @@
+package config
+
+import (
+	"bufio"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// TrackFile is the file in TempDir listing the entries go-dkci created there, one name per line
+const TrackFile = ".go-dkci-files"
+
+// trackLockFile is locked by the go-dkci process updating TrackFile
+const trackLockFile = TrackFile + ".lock"
+
+// JobsDir is the directory of TempDir holding the state of queued and resumable jobs
+const JobsDir = "jobs"
+
+// RegistryDir is the directory of TempDir caching the archives and blobs served by the registry
+const RegistryDir = "registry"
+
+// BlobsDir is the directory of TempDir caching the layers of delta exports and imports
+const BlobsDir = "blobs"
+
+// trackMu serializes the updates of TrackFile by the goroutines of a command; lockFile serializes those of
+// different processes
+var trackMu sync.Mutex
+
+// TempDir returns the working directory of go-dkci, which belongs to the current user: go-dkci in the user cache
+// directory (~/.cache/go-dkci on Linux, %LocalAppData%\go-dkci on Windows, ~/Library/Caches/go-dkci on macOS), or
+// go-dkci-<user> in the temporary directory of the system when there is none. DKCI_TEMP_DIR overrides it.
+// Exports, downloads, caches and job state are kept there.
+func TempDir() string {
+	if dir := os.Getenv("DKCI_TEMP_DIR"); dir != "" {
+		return dir
+	}
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(cacheDir, "go-dkci")
+	}
+	name := "go-dkci"
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		// Domain accounts on Windows are named DOMAIN\user
+		name += "-" + filepath.Base(strings.ReplaceAll(current.Username, `\`, "/"))
+	}
+	return filepath.Join(os.TempDir(), name)
+}
+
+// Track records that go-dkci created path, so that `go-dkci clean` may delete it. Paths outside TempDir are
+// ignored. Paths inside a subdirectory of it are recorded by their own path relative to TempDir, with '/'
+// separators, and not by the subdirectory, which may hold files of the user too; paths inside a recorded directory,
+// such as the files of a run directory, are already covered. Failures are ignored, since the worst outcome is a file
+// clean leaves behind.
+func Track(path string) {
+	dir := TempDir()
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return
+	}
+	name := filepath.ToSlash(rel)
+	if name == TrackFile || name == trackLockFile {
+		return
+	}
+
+	updateTracked(func(names []string) []string {
+		if slices.ContainsFunc(names, func(entry string) bool { return name == entry || strings.HasPrefix(name, entry+"/") }) {
+			return names
+		}
+		// A recorded directory covers the entries recorded inside it
+		names = slices.DeleteFunc(names, func(entry string) bool { return strings.HasPrefix(entry, name+"/") })
+		return append(names, name)
+	})
+}
+
+// Tracked returns the paths relative to TempDir of the entries recorded by Track
+func Tracked() ([]string, error) {
+	file, err := os.Open(filepath.Join(TempDir(), TrackFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, scanner.Err()
+}
+
+// Untrack forgets entries recorded by Track, once they are deleted
+func Untrack(names ...string) error {
+	return updateTracked(func(tracked []string) []string {
+		return slices.DeleteFunc(tracked, func(entry string) bool {
+			return slices.Contains(names, entry)
+		})
+	})
+}
+
+// updateTracked replaces the entries recorded by Track with what update returns for them. Every go-dkci process
+// of the user shares the list, so it is updated under a file lock and replaced by a rename, for concurrent runs
+// not to lose each other's entries and for readers never to see it half written. The list is removed when no
+// entries remain.
+func updateTracked(update func(names []string) []string) error {
+	trackMu.Lock()
+	defer trackMu.Unlock()
+
+	dir := TempDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	unlock, err := lockFile(filepath.Join(dir, trackLockFile))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tracked, err := Tracked()
+	if err != nil {
+		return err
+	}
+	names := update(tracked)
+
+	path := filepath.Join(dir, TrackFile)
+	if len(names) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	temp, err := os.CreateTemp(dir, TrackFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.WriteString(strings.Join(names, "\n") + "\n"); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
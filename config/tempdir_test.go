@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestTrackConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DKCI_TEMP_DIR", dir)
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Track(filepath.Join(dir, fmt.Sprintf("file-%d.tar", i)))
+		}()
+	}
+	wg.Wait()
+
+	tracked, err := Tracked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracked) != 50 {
+		t.Fatalf("tracked %d entries, want 50", len(tracked))
+	}
+
+	if err := Untrack("file-0.tar", "file-1.tar"); err != nil {
+		t.Fatal(err)
+	}
+	tracked, _ = Tracked()
+	if len(tracked) != 48 || slices.Contains(tracked, "file-0.tar") {
+		t.Errorf("after Untrack: %q", tracked)
+	}
+
+	// No temporary files of the updates are left behind
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if name := entry.Name(); name != TrackFile && name != trackLockFile {
+			t.Errorf("unexpected file %s", name)
+		}
+	}
+}
+
+func TestTrackIgnoresOutsidePaths(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DKCI_TEMP_DIR", dir)
+
+	Track(filepath.Join(t.TempDir(), "elsewhere.tar"))
+	Track(filepath.Join(dir, "sub", "nested.tar"))
+	Track(filepath.Join(dir, "sub", "other.tar"))
+
+	// Files in subdirectories are tracked themselves, not their directory
+	tracked, err := Tracked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(tracked, []string{"sub/nested.tar", "sub/other.tar"}) {
+		t.Errorf("tracked %q, want [sub/nested.tar sub/other.tar]", tracked)
+	}
+
+	// A tracked directory covers the files inside it
+	Track(filepath.Join(dir, "run-1-a"))
+	Track(filepath.Join(dir, "run-1-a", "staged.tar"))
+	Track(filepath.Join(dir, "sub"))
+	if tracked, _ = Tracked(); !slices.Equal(tracked, []string{"run-1-a", "sub"}) {
+		t.Errorf("tracked %q, want [run-1-a sub]", tracked)
+	}
+
+	if err := Untrack("run-1-a", "sub"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, TrackFile)); !os.IsNotExist(err) {
+		t.Errorf("empty track file not removed: %v", err)
+	}
+}
+
+func TestRunDirPID(t *testing.T) {
+	tests := []struct {
+		name string
+		pid  int
+		ok   bool
+	}{
+		{"run-1234-567890", 1234, true},
+		{"run-1-a", 1, true},
+		{"run-1234", 0, false},
+		{"run-abc-123", 0, false},
+		{"jobs", 0, false},
+		{"nginx_latest_linux_amd64.tar", 0, false},
+	}
+	for _, test := range tests {
+		pid, ok := RunDirPID(test.name)
+		if pid != test.pid || ok != test.ok {
+			t.Errorf("RunDirPID(%q) = %d, %v, want %d, %v", test.name, pid, ok, test.pid, test.ok)
+		}
+	}
+}
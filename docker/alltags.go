@@ -0,0 +1,64 @@
+package docker
+
+import (
+	"fmt"
+	"slices"
+)
+
+// withAllTags saves every tag of an image into its archive
+var withAllTags bool
+
+// SetWithAllTags makes exports save an image with all of its tags in one archive, instead of one archive per
+// selected tag, so that loading the archive restores every tag
+func SetWithAllTags(enabled bool) {
+	withAllTags = enabled
+}
+
+// CollapseTags keeps the first of the selected images sharing an image ID when exports save all tags, as its
+// archive holds the others as well. Images that cannot be inspected are kept, for the export to report them.
+func CollapseTags(cli ImageClient, imageNames []string) []string {
+	if !withAllTags {
+		return imageNames
+	}
+
+	kept := make([]string, 0, len(imageNames))
+	byID := make(map[string]string)
+	for _, imageName := range imageNames {
+		ctx, cancel := CallContext()
+		imageInspect, _, err := cli.ImageInspectWithRaw(ctx, imageName)
+		cancel()
+		if err != nil {
+			kept = append(kept, imageName)
+			continue
+		}
+		if first, ok := byID[imageInspect.ID]; ok {
+			fmt.Printf("%s is saved in the archive of %s, which holds all tags of image %s\n", imageName, first, ShortID(imageInspect.ID))
+			continue
+		}
+		byID[imageInspect.ID] = imageName
+		kept = append(kept, imageName)
+	}
+	return kept
+}
+
+// saveReferences returns the references an image is saved by: the reference itself, followed by the other tags
+// of the image when exports save all tags
+func saveReferences(cli ImageClient, imageName string) []string {
+	refs := []string{imageName}
+	if !withAllTags {
+		return refs
+	}
+
+	ctx, cancel := CallContext()
+	imageInspect, _, err := cli.ImageInspectWithRaw(ctx, imageName)
+	cancel()
+	if err != nil {
+		return refs
+	}
+	for _, tag := range imageInspect.RepoTags {
+		if tag != "<none>:<none>" && !slices.Contains(refs, tag) {
+			refs = append(refs, tag)
+		}
+	}
+	return refs
+}
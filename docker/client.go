@@ -0,0 +1,60 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+)
+
+// ImageClient is the part of the Docker API that listing, exporting, importing, tagging and deleting images use. The
+// clients of NewClient implement it, and so does FakeClient, which keeps its images in memory so that this logic
+// can run without a daemon.
+type ImageClient interface {
+	ImageList(ctx context.Context, options types.ImageListOptions) ([]image.Summary, error)
+	ImageInspectWithRaw(ctx context.Context, imageID string) (types.ImageInspect, []byte, error)
+	ImageSave(ctx context.Context, imageIDs []string) (io.ReadCloser, error)
+	ImageLoad(ctx context.Context, input io.Reader, quiet bool) (types.ImageLoadResponse, error)
+	ImageRemove(ctx context.Context, imageID string, options types.ImageRemoveOptions) ([]image.DeleteResponse, error)
+	ImageTag(ctx context.Context, source, target string) error
+}
+
+var (
+	_ ImageClient = (*client.Client)(nil)
+	_ ImageClient = (*FakeClient)(nil)
+)
+
+// localClient stands in for the local daemon in ImportFile when set by SetImageClient
+var localClient ImageClient
+
+// SetImageClient makes ImportFile load images with cli instead of a client of the local daemon, such as a
+// FakeClient; nil connects to the daemon again
+func SetImageClient(cli ImageClient) {
+	localClient = cli
+}
+
+// localImageClient returns the client of the local daemon, or the one set by SetImageClient, and the function
+// closing it
+func localImageClient() (ImageClient, func() error, error) {
+	if localClient != nil {
+		return localClient, func() error { return nil }, nil
+	}
+	cli, err := NewClient("")
+	if err != nil {
+		return nil, nil, err
+	}
+	return cli, cli.Close, nil
+}
+
+// daemonClient returns the Docker client behind cli for the operations outside ImageClient, such as squashing
+// an image or loading it into kind nodes through containers
+func daemonClient(cli ImageClient, operation string) (*client.Client, error) {
+	daemon, ok := cli.(*client.Client)
+	if !ok {
+		return nil, fmt.Errorf("%s needs a Docker daemon", operation)
+	}
+	return daemon, nil
+}
@@ -0,0 +1,283 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// archiveExtensions are the extensions of compressed image archives. Archives produced by other tooling use all
+// of them; exports are only written as .tar, .tar.gz or .tar.zst. The extensions select the files listed for
+// import, the compression itself is detected from the content.
+var archiveExtensions = []string{".tar.gz", ".tgz", ".tar.zst", ".tzst", ".tar.xz", ".txz", ".tar.bz2", ".tbz2"}
+
+// compressionExtensions are the extensions appended to exported archives by the formats they can be compressed with
+var compressionExtensions = map[string]string{
+	"gzip": ".gz",
+	"zstd": ".zst",
+}
+
+// maxCompressionLevels are the highest compression levels of the formats; the lowest is 1
+var maxCompressionLevels = map[string]int{
+	"gzip": gzip.BestCompression,
+	"zstd": 19,
+}
+
+// compression is the format exported archives are compressed with; empty writes plain .tar files
+var compression string
+
+// compressionLevel is the level exported archives are compressed at; 0 uses the default of the format
+var compressionLevel int
+
+// SetCompression sets the format exported archives are compressed with: "gzip", "zstd", or "" and "none" for
+// plain .tar files. Compressed archives are named .tar.gz or .tar.zst and imported transparently. zstd
+// archives are written by the zstd executable, which must be in PATH.
+func SetCompression(format string) error {
+	switch format {
+	case "", "none":
+		compression = ""
+	case "gzip":
+		compression = format
+	case "zstd":
+		if _, err := exec.LookPath(format); err != nil {
+			return fmt.Errorf("zstd executable not found in PATH: %v", err)
+		}
+		compression = format
+	default:
+		return fmt.Errorf("unsupported compression %q (expected gzip, zstd or none)", format)
+	}
+	return nil
+}
+
+// SetCompressionLevel sets the level exported archives are compressed at, 1-9 for gzip and 1-19 for zstd, trading
+// CPU time for smaller files; 0 uses the default of the format. Call it after SetCompression, which selects the
+// range the level is checked against.
+func SetCompressionLevel(level int) error {
+	if maxLevel, ok := maxCompressionLevels[compression]; ok && level != 0 && (level < 1 || level > maxLevel) {
+		return fmt.Errorf("compression level %d is out of range for %s (1-%d)", level, compression, maxLevel)
+	}
+	compressionLevel = level
+	return nil
+}
+
+// Compressed reports whether exported archives are compressed
+func Compressed() bool {
+	return compression != ""
+}
+
+// CompressedName returns the name of an exported archive with the extension of the configured compression
+func CompressedName(tarFileName string) string {
+	return tarFileName + compressionExtensions[compression]
+}
+
+// CompressWriter wraps w in the configured compressor. Closing the result flushes the compressor but
+// does not close w.
+func CompressWriter(w io.Writer) (io.WriteCloser, error) {
+	switch compression {
+	case "gzip":
+		if compressionLevel == 0 {
+			return gzip.NewWriter(w), nil
+		}
+		return gzip.NewWriterLevel(w, compressionLevel)
+	case "zstd":
+		args := []string{"-q", "-c"}
+		if compressionLevel != 0 {
+			args = append(args, fmt.Sprintf("-%d", compressionLevel))
+		}
+		cmd := exec.Command("zstd", args...)
+		cmd.Stdout = w
+		return CommandInput(cmd)
+	}
+	return nopWriteCloser{w}, nil
+}
+
+// nopWriteCloser is a writer whose Close does nothing
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// IsArchiveName reports whether a file name has the extension of an image archive, plain or compressed, possibly
+// wrapped in a zip or 7z container
+func IsArchiveName(name string) bool {
+	lowerName := trimSealedExtension(strings.ToLower(name))
+	if strings.HasSuffix(lowerName, ".tar") {
+		return true
+	}
+	for _, extension := range archiveExtensions {
+		if strings.HasSuffix(lowerName, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// PlainArchiveName replaces the compression and container extensions of an archive name with .tar
+func PlainArchiveName(name string) string {
+	name = trimSealedExtension(name)
+	lowerName := strings.ToLower(name)
+	for _, extension := range archiveExtensions {
+		if strings.HasSuffix(lowerName, extension) {
+			return name[:len(name)-len(extension)] + ".tar"
+		}
+	}
+	return name
+}
+
+// trimSealedExtension removes the extension of a zip or 7z container from an archive name
+func trimSealedExtension(name string) string {
+	lowerName := strings.ToLower(name)
+	for _, m := range sealedMagics {
+		if strings.HasSuffix(lowerName, "."+m.format) {
+			return name[:len(name)-len(m.format)-1]
+		}
+	}
+	return name
+}
+
+// compressionMagics are the leading bytes of the compressed formats
+var compressionMagics = []struct {
+	magic       []byte
+	compression string
+}{
+	{[]byte{0x1f, 0x8b}, "gzip"},
+	{[]byte{0x28, 0xb5, 0x2f, 0xfd}, "zstd"},
+	{[]byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, "xz"},
+	{[]byte("BZh"), "bzip2"},
+}
+
+// Decompress returns a reader uncompressing an archive stream. The compression is detected from the first
+// bytes rather than the file name, which is often misleading for downloaded or renamed files; streams in no
+// known format, such as plain tar, are returned unchanged. Closing the result does not close r.
+func Decompress(r io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+	// A short stream cannot be compressed; Peek returns what there is
+	header, _ := buffered.Peek(6)
+	for _, m := range compressionMagics {
+		if bytes.HasPrefix(header, m.magic) {
+			return decompress(buffered, m.compression)
+		}
+	}
+	return io.NopCloser(buffered), nil
+}
+
+// decompress returns a reader uncompressing r. gzip and bzip2 are handled in process, zstd and xz by the
+// zstd and xz executables.
+func decompress(r io.Reader, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case "gzip":
+		gzipReader, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %v", err)
+		}
+		return gzipReader, nil
+	case "bzip2":
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	case "zstd", "xz":
+		toolPath, err := exec.LookPath(compression)
+		if err != nil {
+			return nil, fmt.Errorf("%s executable not found in PATH: %v", compression, err)
+		}
+		cmd := exec.Command(toolPath, "-dc")
+		cmd.Stdin = r
+		return CommandOutput(cmd)
+	}
+	return io.NopCloser(r), nil
+}
+
+// CommandOutput starts cmd and returns its standard output. A failure of the command is reported in place of
+// the end of its output, so that a truncated stream is not mistaken for a complete one; Close stops waiting
+// for more output and returns the failure as well.
+func CommandOutput(cmd *exec.Cmd) (io.ReadCloser, error) {
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &commandReader{ReadCloser: stdout, cmd: cmd, stderr: stderr}, nil
+}
+
+// CommandInput starts cmd and returns its standard input. Closing it ends the input and waits for the command,
+// returning its failure with the error output.
+func CommandInput(cmd *exec.Cmd) (io.WriteCloser, error) {
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &commandWriter{WriteCloser: stdin, cmd: cmd, stderr: stderr}, nil
+}
+
+// commandWriter writes the standard input of a command started by CommandInput
+type commandWriter struct {
+	io.WriteCloser
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+}
+
+func (w *commandWriter) Close() error {
+	w.WriteCloser.Close()
+	if err := w.cmd.Wait(); err != nil {
+		return fmt.Errorf("%s failed: %v: %s", filepath.Base(w.cmd.Path), err, strings.TrimSpace(w.stderr.String()))
+	}
+	return nil
+}
+
+// commandReader reads the standard output of a command started by CommandOutput
+type commandReader struct {
+	io.ReadCloser
+	cmd     *exec.Cmd
+	stderr  *bytes.Buffer
+	waited  bool
+	waitErr error
+}
+
+func (r *commandReader) Read(p []byte) (int, error) {
+	// Waiting closes the pipe, so the end of the output has been reached
+	if r.waited {
+		if r.waitErr != nil {
+			return 0, r.waitErr
+		}
+		return 0, io.EOF
+	}
+	n, err := r.ReadCloser.Read(p)
+	if err == io.EOF {
+		if waitErr := r.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+func (r *commandReader) Close() error {
+	r.ReadCloser.Close()
+	return r.wait()
+}
+
+// wait waits for the command once and returns its failure with the error output
+func (r *commandReader) wait() error {
+	if !r.waited {
+		r.waited = true
+		if err := r.cmd.Wait(); err != nil {
+			r.waitErr = fmt.Errorf("%s failed: %v: %s", filepath.Base(r.cmd.Path), err, strings.TrimSpace(r.stderr.String()))
+		}
+	}
+	return r.waitErr
+}
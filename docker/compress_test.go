@@ -0,0 +1,120 @@
+package docker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// archiveContent stands in for a tar stream; Decompress does not look past the compression
+var archiveContent = bytes.Repeat([]byte("manifest.json layer.tar "), 1000)
+
+// compressWith compresses archiveContent with an executable, skipping the test if it is not installed
+func compressWith(t *testing.T, tool string) []byte {
+	t.Helper()
+	if _, err := exec.LookPath(tool); err != nil {
+		t.Skipf("%s not installed", tool)
+	}
+	cmd := exec.Command(tool, "-c")
+	cmd.Stdin = bytes.NewReader(archiveContent)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestDecompress(t *testing.T) {
+	var gzipped bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipped)
+	gzipWriter.Write(archiveContent)
+	gzipWriter.Close()
+
+	tests := []struct {
+		name string
+		data func(t *testing.T) []byte
+		want []byte
+	}{
+		{"plain", func(*testing.T) []byte { return archiveContent }, archiveContent},
+		{"short", func(*testing.T) []byte { return []byte("BZ") }, []byte("BZ")},
+		{"empty", func(*testing.T) []byte { return nil }, nil},
+		{"gzip", func(*testing.T) []byte { return gzipped.Bytes() }, archiveContent},
+		{"bzip2", func(t *testing.T) []byte { return compressWith(t, "bzip2") }, archiveContent},
+		{"zstd", func(t *testing.T) []byte { return compressWith(t, "zstd") }, archiveContent},
+		{"xz", func(t *testing.T) []byte { return compressWith(t, "xz") }, archiveContent},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader, err := Decompress(bytes.NewReader(test.data(t)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reader.Close()
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, test.want) {
+				t.Errorf("read %d bytes, want %d", len(got), len(test.want))
+			}
+		})
+	}
+}
+
+func TestOpenArchiveIgnoresExtension(t *testing.T) {
+	// A gzip archive named like a plain one, as downloads and renamed files often are
+	filePath := filepath.Join(t.TempDir(), "nginx_latest_linux_amd64.tar")
+	file, err := os.Create(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzipWriter := gzip.NewWriter(file)
+	gzipWriter.Write(archiveContent)
+	gzipWriter.Close()
+	file.Close()
+
+	reader, err := OpenArchive(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, archiveContent) {
+		t.Errorf("read %d bytes, want the %d uncompressed bytes", len(got), len(archiveContent))
+	}
+}
+
+func TestArchiveNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive bool
+		plain   string
+	}{
+		{"nginx_latest_linux_amd64.tar", true, "nginx_latest_linux_amd64.tar"},
+		{"nginx_latest_linux_amd64.tar.gz", true, "nginx_latest_linux_amd64.tar"},
+		{"nginx.TGZ", true, "nginx.tar"},
+		{"nginx.tar.zst", true, "nginx.tar"},
+		{"nginx.txz", true, "nginx.tar"},
+		{"nginx.tar.bz2", true, "nginx.tar"},
+		{"nginx.tar.gz.7z", true, "nginx.tar"},
+		{"nginx.tar.zip", true, "nginx.tar"},
+		{"nginx.zip", false, "nginx"},
+		{"notes.txt", false, "notes.txt"},
+		{"nginx.tar.sig", false, "nginx.tar.sig"},
+	}
+	for _, test := range tests {
+		if got := IsArchiveName(test.name); got != test.archive {
+			t.Errorf("IsArchiveName(%q) = %v, want %v", test.name, got, test.archive)
+		}
+		if got := PlainArchiveName(test.name); got != test.plain {
+			t.Errorf("PlainArchiveName(%q) = %q, want %q", test.name, got, test.plain)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package docker
+
+import "sync"
+
+// Limiter bounds how many operations of a phase, such as saving images or loading archives, run at once
+type Limiter chan struct{}
+
+// NewLimiter returns a limiter letting n operations run at once; less than 1 runs them one at a time
+func NewLimiter(n int) Limiter {
+	return make(Limiter, max(n, 1))
+}
+
+// Acquire waits until another operation may run
+func (l Limiter) Acquire() {
+	l <- struct{}{}
+}
+
+// Release lets the next waiting operation run
+func (l Limiter) Release() {
+	<-l
+}
+
+var (
+	// saveConcurrency and saveLimit bound the images saved from the Docker daemon to archives at once
+	saveConcurrency = 1
+	saveLimit       = NewLimiter(1)
+	// loadConcurrency and loadLimit bound the archives loaded into the Docker daemon or a kind cluster at once
+	loadConcurrency = 1
+	loadLimit       = NewLimiter(1)
+)
+
+// SetSaveConcurrency sets how many images are saved to archives at once; 1 or less saves them one after the other
+func SetSaveConcurrency(concurrency int) {
+	saveConcurrency = max(concurrency, 1)
+	saveLimit = NewLimiter(concurrency)
+}
+
+// SaveConcurrency returns how many images are saved to archives at once
+func SaveConcurrency() int {
+	return saveConcurrency
+}
+
+// SetLoadConcurrency sets how many archives are loaded at once; 1 or less loads them one after the other
+func SetLoadConcurrency(concurrency int) {
+	loadConcurrency = max(concurrency, 1)
+	loadLimit = NewLimiter(concurrency)
+}
+
+// LoadConcurrency returns how many archives are loaded at once
+func LoadConcurrency() int {
+	return loadConcurrency
+}
+
+// ForEach calls run for every item, concurrency items at a time, and returns when all of them are done
+func ForEach(items []string, concurrency int, run func(item string)) {
+	if concurrency <= 1 {
+		for _, item := range items {
+			run(item)
+		}
+		return
+	}
+
+	limit := NewLimiter(concurrency)
+	var wg sync.WaitGroup
+	for _, item := range items {
+		wg.Add(1)
+		limit.Acquire()
+		go func(item string) {
+			defer wg.Done()
+			defer limit.Release()
+			run(item)
+		}(item)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,82 @@
+package docker
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The strategies of --on-conflict for an archive whose name is taken at the destination
+const (
+	// ConflictOverwrite replaces the existing file
+	ConflictOverwrite = "overwrite"
+	// ConflictSkip keeps the existing file and skips the export of the image
+	ConflictSkip = "skip"
+	// ConflictNumber writes the archive under the name with the first free number, e.g. "nginx_1.27_linux_amd64~1.tar"
+	ConflictNumber = "number"
+	// ConflictTimestamp writes the archive under the name with the export time, e.g.
+	// "nginx_1.27_linux_amd64~20240601-020000.tar"
+	ConflictTimestamp = "timestamp"
+)
+
+// onConflict is the strategy applied to archive names that are taken
+var onConflict = ConflictOverwrite
+
+// conflictSuffixPattern matches the suffix a conflict strategy adds before the extension of an archive name. '~'
+// appears neither in tags nor in names encoded with EncodeName, so the suffix never eats into a template field.
+var conflictSuffixPattern = regexp.MustCompile(`~[0-9][0-9-]*(\.[^./~]*)?$`)
+
+// SetOnConflict sets what exports do when the archive name of an image is taken at the destination: overwrite,
+// skip, number or timestamp. An empty strategy restores overwrite.
+func SetOnConflict(strategy string) error {
+	switch strategy {
+	case "":
+		onConflict = ConflictOverwrite
+	case ConflictOverwrite, ConflictSkip, ConflictNumber, ConflictTimestamp:
+		onConflict = strategy
+	default:
+		return fmt.Errorf("unsupported conflict strategy %q (expected overwrite, skip, number or timestamp)", strategy)
+	}
+	return nil
+}
+
+// ResolveConflict returns the archive name an export writes to in place of name, rendered by ArchiveName, when
+// exists reports names that are taken at the destination, and whether the export is skipped instead. exists is
+// passed candidate names in the form of name, so that the caller can add the destination and its extensions.
+func ResolveConflict(name string, exists func(string) bool, now time.Time) (string, bool) {
+	if onConflict == ConflictOverwrite || !exists(name) {
+		return name, false
+	}
+
+	extension := path.Ext(name)
+	base := strings.TrimSuffix(name, extension)
+	switch onConflict {
+	case ConflictSkip:
+		return name, true
+	case ConflictTimestamp:
+		base += "~" + now.Format("20060102-150405")
+		if candidate := base + extension; !exists(candidate) {
+			return candidate, false
+		}
+		// Several exports of the same second are numbered after the time
+		for n := 2; ; n++ {
+			if candidate := fmt.Sprintf("%s-%d%s", base, n, extension); !exists(candidate) {
+				return candidate, false
+			}
+		}
+	default:
+		for n := 1; ; n++ {
+			if candidate := fmt.Sprintf("%s~%d%s", base, n, extension); !exists(candidate) {
+				return candidate, false
+			}
+		}
+	}
+}
+
+// trimConflictSuffix removes the suffix added by a conflict strategy from an archive name, so that the name
+// parses like the one it was derived from
+func trimConflictSuffix(name string) string {
+	return conflictSuffixPattern.ReplaceAllString(name, "$1")
+}
@@ -0,0 +1,60 @@
+package docker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolveConflict(t *testing.T) {
+	now := time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC)
+	taken := map[string]bool{
+		"nginx_1.27_linux_amd64.tar":                true,
+		"nginx_1.27_linux_amd64~1.tar":              true,
+		"redis_7_linux_arm64.tar":                   true,
+		"redis_7_linux_arm64~20240601-020000.tar":   true,
+		"redis_7_linux_arm64~20240601-020000-2.tar": true,
+	}
+	exists := func(name string) bool { return taken[name] }
+
+	tests := []struct {
+		strategy string
+		name     string
+		want     string
+		wantSkip bool
+	}{
+		{ConflictOverwrite, "nginx_1.27_linux_amd64.tar", "nginx_1.27_linux_amd64.tar", false},
+		{ConflictSkip, "nginx_1.27_linux_amd64.tar", "nginx_1.27_linux_amd64.tar", true},
+		{ConflictSkip, "alpine_3_linux_amd64.tar", "alpine_3_linux_amd64.tar", false},
+		{ConflictNumber, "nginx_1.27_linux_amd64.tar", "nginx_1.27_linux_amd64~2.tar", false},
+		{ConflictNumber, "redis_7_linux_arm64.tar", "redis_7_linux_arm64~1.tar", false},
+		{ConflictTimestamp, "nginx_1.27_linux_amd64.tar", "nginx_1.27_linux_amd64~20240601-020000.tar", false},
+		{ConflictTimestamp, "redis_7_linux_arm64.tar", "redis_7_linux_arm64~20240601-020000-3.tar", false},
+	}
+	for _, test := range tests {
+		if err := SetOnConflict(test.strategy); err != nil {
+			t.Fatal(err)
+		}
+		got, skip := ResolveConflict(test.name, exists, now)
+		if got != test.want || skip != test.wantSkip {
+			t.Errorf("%s: ResolveConflict(%q) = %q, %v, want %q, %v", test.strategy, test.name, got, skip, test.want, test.wantSkip)
+		}
+	}
+	SetOnConflict("")
+
+	if err := SetOnConflict("rename"); err == nil {
+		t.Error("SetOnConflict accepted an unknown strategy")
+	}
+}
+
+func TestParseConflictName(t *testing.T) {
+	for _, name := range []string{
+		"nginx_1.27_linux_amd64~1.tar",
+		"nginx_1.27_linux_amd64~20240601-020000-2.tar.gz",
+		"nginx_1.27_linux_amd64~3.tar.split.json",
+	} {
+		fields, ok := ParseArchiveName("/docker-images/" + name)
+		if !ok || fields.Repository != "nginx" || fields.Tag != "1.27" || fields.Arch != "amd64" {
+			t.Errorf("ParseArchiveName(%q) = %+v, %v", name, fields, ok)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// ContainerSuffix ends the file names of container filesystem exports. Unlike image archives they are
+// restored with `docker import`, so they are named apart from them.
+const ContainerSuffix = ".rootfs.tar"
+
+// ExportContainers exports the filesystems of the given containers to a local destination
+func ExportContainers(destination string, containerNames []string) {
+	// Initialize Docker client
+	cli, err := NewClient("")
+	if err != nil {
+		i18n.Printf("[x] Failed to create Docker client: %v\n", err)
+		os.Exit(1)
+	}
+	defer cli.Close()
+
+	// Create destination directory if it doesn't exist
+	if err := os.MkdirAll(destination, 0755); err != nil {
+		i18n.Printf("[x] Failed to create destination directory %s: %v\n", destination, err)
+		os.Exit(1)
+	}
+
+	for _, containerName := range containerNames {
+		tarFilePath, err := SaveContainer(cli, containerName, destination)
+		if err != nil {
+			i18n.Printf("[x] Failed to export container %s: %v\n", containerName, err)
+			continue
+		}
+		i18n.Printf("[√] Successfully exported container %s to %s\n", containerName, tarFilePath)
+	}
+}
+
+// SaveContainer writes the filesystem of a running or stopped container (`docker export`) to
+// <name>_<short id>.rootfs.tar in dir and returns the file path
+func SaveContainer(cli *client.Client, containerName, dir string) (string, error) {
+	ctx, cancel := CallContext()
+	containerInspect, err := cli.ContainerInspect(ctx, containerName)
+	cancel()
+	if err != nil {
+		return "", err
+	}
+
+	name := strings.TrimPrefix(containerInspect.Name, "/")
+	tarFilePath := filepath.Join(dir, EncodeName(name)+"_"+ShortID(containerInspect.ID)+ContainerSuffix)
+
+	fmt.Printf("Exporting container %s to %s...\n", name, tarFilePath)
+
+	streamCtx, watchdog := StreamContext()
+	defer watchdog.Stop()
+
+	containerReader, err := cli.ContainerExport(streamCtx, containerInspect.ID)
+	if err != nil {
+		return "", watchdog.Err(err)
+	}
+	defer containerReader.Close()
+
+	outFile, err := os.Create(tarFilePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create output file %s: %v", tarFilePath, err)
+	}
+	defer outFile.Close()
+	config.Track(tarFilePath)
+
+	if _, err := io.Copy(outFile, watchdog.Reader(containerReader)); err != nil {
+		os.Remove(tarFilePath)
+		return "", fmt.Errorf("failed to write file %s: %v", tarFilePath, watchdog.Err(err))
+	}
+	return tarFilePath, nil
+}
+
+// CommitContainer commits a container to an image tagged tag and returns the tag. An empty tag defaults to
+// <container name>:snapshot-<YYYYMMDD-HHMMSS>. The container is paused during the commit if pause is set.
+func CommitContainer(containerName, tag string, pause bool) (string, error) {
+	cli, err := NewClient("")
+	if err != nil {
+		return "", fmt.Errorf("failed to create Docker client: %v", err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := CallContext()
+	containerInspect, err := cli.ContainerInspect(ctx, containerName)
+	cancel()
+	if err != nil {
+		return "", err
+	}
+
+	if tag == "" {
+		// Container names may contain upper case letters, which image references do not allow
+		name := strings.ToLower(strings.TrimPrefix(containerInspect.Name, "/"))
+		tag = name + ":snapshot-" + time.Now().Format("20060102-150405")
+	}
+
+	fmt.Printf("Committing container %s to image %s...\n", containerName, tag)
+	// Committing a large container takes as long as it takes and streams nothing, so it is not bounded
+	response, err := cli.ContainerCommit(context.Background(), containerInspect.ID, container.CommitOptions{
+		Reference: tag,
+		Comment:   "go-dkci snapshot of " + strings.TrimPrefix(containerInspect.Name, "/"),
+		Pause:     pause,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	i18n.Printf("[√] Committed container %s as %s (%s)\n", containerName, tag, ShortID(response.ID))
+	return tag, nil
+}
@@ -0,0 +1,143 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/tlsconfig"
+)
+
+// DefaultContext is the Docker context of the daemon DOCKER_HOST or the platform default points to
+const DefaultContext = "default"
+
+// dockerContext is the docker endpoint of a context of the Docker CLI
+type dockerContext struct {
+	Name string
+	Host string
+	// SkipTLSVerify leaves the certificate of a tcp:// daemon unverified
+	SkipTLSVerify bool
+	// tlsDir holds the ca.pem, cert.pem and key.pem the context connects with, if it has any
+	tlsDir string
+}
+
+// contextMeta is the meta.json of a context in the context store of the Docker CLI
+type contextMeta struct {
+	Name      string `json:"Name"`
+	Endpoints map[string]struct {
+		Host          string `json:"Host"`
+		SkipTLSVerify bool   `json:"SkipTLSVerify"`
+	} `json:"Endpoints"`
+}
+
+// dockerConfigDir returns the configuration directory of the Docker CLI, DOCKER_CONFIG or ~/.docker
+func dockerConfigDir() (string, error) {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".docker"), nil
+}
+
+// CurrentContext returns the name of the Docker context the docker CLI uses: DOCKER_CONTEXT, the currentContext of
+// its config.json, or DefaultContext
+func CurrentContext() string {
+	if name := os.Getenv("DOCKER_CONTEXT"); name != "" {
+		return name
+	}
+	configDir, err := dockerConfigDir()
+	if err != nil {
+		return DefaultContext
+	}
+	data, err := os.ReadFile(filepath.Join(configDir, "config.json"))
+	if err != nil {
+		return DefaultContext
+	}
+	var cliConfig struct {
+		CurrentContext string `json:"currentContext"`
+	}
+	if json.Unmarshal(data, &cliConfig) != nil || cliConfig.CurrentContext == "" {
+		return DefaultContext
+	}
+	return cliConfig.CurrentContext
+}
+
+// loadContext reads the docker endpoint of a context from the context store of the Docker CLI, where every context
+// is kept under the SHA-256 of its name
+func loadContext(name string) (*dockerContext, error) {
+	configDir, err := dockerConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(name))
+	id := hex.EncodeToString(sum[:])
+
+	data, err := os.ReadFile(filepath.Join(configDir, "contexts", "meta", id, "meta.json"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown Docker context %q: %v", name, err)
+	}
+	var meta contextMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("invalid Docker context %q: %v", name, err)
+	}
+	endpoint, ok := meta.Endpoints["docker"]
+	if !ok || endpoint.Host == "" {
+		return nil, fmt.Errorf("the Docker context %q has no docker endpoint", name)
+	}
+
+	ctx := &dockerContext{Name: name, Host: endpoint.Host, SkipTLSVerify: endpoint.SkipTLSVerify}
+	tlsDir := filepath.Join(configDir, "contexts", "tls", id, "docker")
+	if _, err := os.Stat(tlsDir); err == nil {
+		ctx.tlsDir = tlsDir
+	}
+	return ctx, nil
+}
+
+// currentContext returns the current context of the Docker CLI, or nil when the local daemon is configured by the
+// environment: when DOCKER_HOST is set, which the docker CLI also prefers, or the current context is the default one
+func currentContext() (*dockerContext, error) {
+	if os.Getenv(client.EnvOverrideHost) != "" {
+		return nil, nil
+	}
+	name := CurrentContext()
+	if name == DefaultContext {
+		return nil, nil
+	}
+	return loadContext(name)
+}
+
+// contextClient returns a client of the daemon of a context, connecting with its certificates if it has any
+func contextClient(ctx *dockerContext) (*client.Client, error) {
+	opts := []client.Opt{client.FromEnv}
+	if ctx.tlsDir != "" || ctx.SkipTLSVerify {
+		options := tlsconfig.Options{InsecureSkipVerify: ctx.SkipTLSVerify, ExclusiveRootPools: true}
+		if ctx.tlsDir != "" {
+			options.CAFile = tlsFile(ctx.tlsDir, "ca.pem")
+			options.CertFile = tlsFile(ctx.tlsDir, "cert.pem")
+			options.KeyFile = tlsFile(ctx.tlsDir, "key.pem")
+		}
+		tlsConfig, err := tlsconfig.Client(options)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS material of Docker context %q: %v", ctx.Name, err)
+		}
+		opts = append(opts, client.WithHTTPClient(&http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}))
+	}
+	return client.NewClientWithOpts(append(opts, client.WithHost(ctx.Host), versionOption())...)
+}
+
+// tlsFile returns the path of a file of the TLS material of a context, or an empty string if it has none
+func tlsFile(tlsDir, name string) string {
+	filePath := filepath.Join(tlsDir, name)
+	if _, err := os.Stat(filePath); err != nil {
+		return ""
+	}
+	return filePath
+}
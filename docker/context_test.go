@@ -0,0 +1,89 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeContext stores a context with a docker endpoint in the context store of the Docker CLI under configDir
+func writeContext(t *testing.T, configDir, name, host string) {
+	t.Helper()
+	sum := sha256.Sum256([]byte(name))
+	metaDir := filepath.Join(configDir, "contexts", "meta", hex.EncodeToString(sum[:]))
+	if err := os.MkdirAll(metaDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	meta := `{"Name":"` + name + `","Metadata":{},"Endpoints":{"docker":{"Host":"` + host + `","SkipTLSVerify":false}}}`
+	if err := os.WriteFile(filepath.Join(metaDir, "meta.json"), []byte(meta), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCurrentContext(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", configDir)
+	t.Setenv("DOCKER_CONTEXT", "")
+	t.Setenv("DOCKER_HOST", "")
+	writeContext(t, configDir, "colima", "unix:///home/ops/.colima/default/docker.sock")
+	writeContext(t, configDir, "prod", "tcp://prod.example.com:2376")
+
+	tests := []struct {
+		name     string
+		config   string
+		context  string
+		host     string
+		wantName string
+		wantHost string
+		wantErr  bool
+	}{
+		{name: "no config", wantName: DefaultContext},
+		{name: "default", config: `{"currentContext":"default"}`, wantName: DefaultContext},
+		{name: "current context", config: `{"currentContext":"colima"}`, wantName: "colima", wantHost: "unix:///home/ops/.colima/default/docker.sock"},
+		{name: "DOCKER_CONTEXT", config: `{"currentContext":"colima"}`, context: "prod", wantName: "prod", wantHost: "tcp://prod.example.com:2376"},
+		{name: "DOCKER_HOST", config: `{"currentContext":"colima"}`, host: "unix:///var/run/docker.sock", wantName: "colima"},
+		{name: "unknown", context: "missing", wantName: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(configDir, "config.json")
+			os.Remove(configFile)
+			if tt.config != "" {
+				if err := os.WriteFile(configFile, []byte(tt.config), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Setenv("DOCKER_CONTEXT", tt.context)
+			t.Setenv("DOCKER_HOST", tt.host)
+
+			if got := CurrentContext(); got != tt.wantName {
+				t.Errorf("CurrentContext() = %q, want %q", got, tt.wantName)
+			}
+			ctx, err := currentContext()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("currentContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			host := ""
+			if ctx != nil {
+				host = ctx.Host
+			}
+			if host != tt.wantHost {
+				t.Errorf("currentContext() host = %q, want %q", host, tt.wantHost)
+			}
+			if tt.wantErr || tt.wantHost == "" {
+				return
+			}
+
+			cli, err := NewClient("local")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cli.Close()
+			if cli.DaemonHost() != tt.wantHost {
+				t.Errorf("NewClient() connects to %q, want %q", cli.DaemonHost(), tt.wantHost)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package docker
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/baowuhe/go-dkci/job"
+	"github.com/baowuhe/go-dkci/state"
+)
+
+var (
+	// deliveryReportPath is where the delivery report of the export is written; empty writes none
+	deliveryReportPath string
+	// deliveryVersion is the version of go-dkci named in the report
+	deliveryVersion string
+)
+
+// SetDeliveryReport makes exports write a delivery report of the batch to filePath once it ends, as HTML if its
+// name ends in .html or .htm and as Markdown otherwise. version is the version of go-dkci named in the report.
+func SetDeliveryReport(filePath, version string) {
+	deliveryReportPath, deliveryVersion = filePath, version
+}
+
+// deliveryRow is an exported image as listed in the delivery report
+type deliveryRow struct {
+	Image    string
+	ImageID  string
+	Digest   string
+	Size     string
+	SHA256   string
+	Scan     string
+	Location string
+	Result   string
+}
+
+// deliveryReport is the content of the delivery report
+type deliveryReport struct {
+	Generated   string
+	Version     string
+	Host        string
+	Destination string
+	Totals      string
+	Rows        []deliveryRow
+}
+
+// WriteDeliveryReport writes the delivery report of the batch exported to destination, if SetDeliveryReport was
+// given a path: the totals of the batch and, for every export recorded in the state database by this run, the
+// image, its ID and digest, the size and SHA-256 checksum of the archive, the outcome of the secret scan, where the
+// archive was written and the result. The report is meant to be attached to a change ticket or handed to the
+// receiving site.
+func WriteDeliveryReport(destination string) {
+	if deliveryReportPath == "" {
+		return
+	}
+
+	report := deliveryReport{
+		Generated:   time.Now().Format("2006-01-02 15:04:05 -0700"),
+		Version:     deliveryVersion,
+		Destination: destination,
+	}
+	report.Host, _ = os.Hostname()
+	totals := job.Totals()
+	report.Totals = fmt.Sprintf("%d succeeded, %d failed, %d skipped; %s transferred in %s", totals.Succeeded,
+		totals.Failed, totals.Skipped, FormatSize(totals.Bytes), time.Duration(totals.Seconds*float64(time.Second)).Round(time.Second))
+	for _, record := range state.Session() {
+		if record.Operation != state.Export {
+			continue
+		}
+		row := deliveryRow{Image: record.Image, ImageID: ShortID(record.ImageID), Scan: SecretScanStatus(record.Image),
+			Location: record.Location, Result: record.Result, SHA256: record.SHA256}
+		if len(record.Digests) > 0 {
+			row.Digest = record.Digests[0]
+		}
+		if record.Size > 0 {
+			row.Size = FormatSize(record.Size)
+		}
+		if record.Error != "" {
+			row.Result += ": " + record.Error
+		}
+		report.Rows = append(report.Rows, row)
+	}
+
+	var content string
+	switch strings.ToLower(filepath.Ext(deliveryReportPath)) {
+	case ".html", ".htm":
+		var builder strings.Builder
+		if err := deliveryTemplate.Execute(&builder, report); err != nil {
+			i18n.Printf("[x] Failed to write delivery report %s: %v\n", deliveryReportPath, err)
+			return
+		}
+		content = builder.String()
+	default:
+		content = report.markdown()
+	}
+	if err := os.WriteFile(deliveryReportPath, []byte(content), 0644); err != nil {
+		i18n.Printf("[x] Failed to write delivery report %s: %v\n", deliveryReportPath, err)
+		return
+	}
+	i18n.Printf("[√] Wrote delivery report to %s\n", deliveryReportPath)
+}
+
+// markdown renders the report as a Markdown document with a table of the exports
+func (r deliveryReport) markdown() string {
+	cell := func(value string) string {
+		if value == "" {
+			return "-"
+		}
+		return strings.ReplaceAll(strings.ReplaceAll(value, "|", "\\|"), "\n", " ")
+	}
+
+	var b strings.Builder
+	b.WriteString("# Delivery Report\n\n")
+	fmt.Fprintf(&b, "- Generated: %s by go-dkci %s on %s\n", r.Generated, cell(r.Version), cell(r.Host))
+	fmt.Fprintf(&b, "- Destination: %s\n", cell(r.Destination))
+	fmt.Fprintf(&b, "- Images: %s\n\n", r.Totals)
+	b.WriteString("| Image | Image ID | Digest | Size | SHA-256 | Secret scan | Location | Result |\n")
+	b.WriteString("| --- | --- | --- | ---: | --- | --- | --- | --- |\n")
+	for _, row := range r.Rows {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s | %s | %s |\n", cell(row.Image), cell(row.ImageID), cell(row.Digest),
+			cell(row.Size), cell(row.SHA256), cell(row.Scan), cell(row.Location), cell(row.Result))
+	}
+	return b.String()
+}
+
+// deliveryTemplate renders the report as a standalone HTML page
+var deliveryTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Delivery Report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; font-size: 90%; }
+td.hash { font-family: monospace; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>Delivery Report</h1>
+<ul>
+<li>Generated: {{.Generated}} by go-dkci {{.Version}} on {{.Host}}</li>
+<li>Destination: {{.Destination}}</li>
+<li>Images: {{.Totals}}</li>
+</ul>
+<table>
+<tr><th>Image</th><th>Image ID</th><th>Digest</th><th>Size</th><th>SHA-256</th><th>Secret scan</th><th>Location</th><th>Result</th></tr>
+{{range .Rows}}<tr><td>{{.Image}}</td><td class="hash">{{.ImageID}}</td><td class="hash">{{.Digest}}</td><td>{{.Size}}</td><td class="hash">{{.SHA256}}</td><td>{{.Scan}}</td><td>{{.Location}}</td><td>{{.Result}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,45 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/state"
+)
+
+func TestWriteDeliveryReport(t *testing.T) {
+	t.Setenv("DKCI_TEMP_DIR", t.TempDir())
+	t.Setenv("BDFS_CONFIG_FILE", filepath.Join(t.TempDir(), "config.toml"))
+	t.Cleanup(config.RemoveRunDir)
+	t.Cleanup(func() { SetDeliveryReport("", "") })
+
+	state.Add(state.Record{Operation: state.Export, Image: "app:1.2", ImageID: "sha256:4a1c2b3d4e5f60718293", Digests: []string{"registry.example.com/app@sha256:99aa"},
+		Location: "bdfs:/releases/app_1.2_linux_amd64.tar", Size: 3 << 20, SHA256: "e3b0c442", Result: state.OK})
+	state.Add(state.Record{Operation: state.Export, Image: "web|beta:2", Result: state.Failed, Error: "no space left"})
+	recordSecretScan("app:1.2", nil, nil)
+
+	dir := t.TempDir()
+	for _, name := range []string{"report.md", "report.html"} {
+		reportPath := filepath.Join(dir, name)
+		SetDeliveryReport(reportPath, "1.0.0")
+		WriteDeliveryReport("bdfs:/releases")
+		content, err := os.ReadFile(reportPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"Delivery Report", "go-dkci 1.0.0", "bdfs:/releases/app_1.2_linux_amd64.tar", "4a1c2b3d4e5f", "registry.example.com/app@sha256:99aa", "3.0 MB", "e3b0c442", "clean", "failed: no space left"}
+		if name == "report.md" {
+			want = append(want, `| web\|beta:2 |`)
+		} else {
+			want = append(want, "<td>web|beta:2</td>", "<td>not scanned</td>")
+		}
+		for _, text := range want {
+			if !strings.Contains(string(content), text) {
+				t.Errorf("%s lacks %q:\n%s", name, text, content)
+			}
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CheckFreeSpace returns an error when the filesystem holding dir has less than size bytes available, so that a
+// save or download fails before it starts instead of midway with "no space left on device". dir need not exist
+// yet. Filesystems that do not report their free space pass the check.
+func CheckFreeSpace(dir string, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+
+	// Check the closest existing parent of a directory that is about to be created
+	existing := dir
+	for {
+		if _, err := os.Stat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return nil
+		}
+		existing = parent
+	}
+
+	available, err := freeSpace(existing)
+	if err != nil {
+		return nil
+	}
+	if uint64(size) > available {
+		return fmt.Errorf("not enough free space in %s: %s needed, %s available", dir, FormatSize(size), FormatSize(int64(available)))
+	}
+	return nil
+}
@@ -1,116 +1,202 @@
 package docker
 
 import (
-	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
-
-	"github.com/AlecAivazis/survey/v2"
+	"time"
+
+	"github.com/baowuhe/go-dkci/attest"
+	"github.com/baowuhe/go-dkci/checksum"
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/hook"
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/baowuhe/go-dkci/job"
+	"github.com/baowuhe/go-dkci/sbom"
+	"github.com/baowuhe/go-dkci/sign"
+	"github.com/baowuhe/go-dkci/state"
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 )
 
-// ExportImages exports the selected Docker images to a local destination
-func ExportImages(destination string) {
+// ExportImages exports the selected Docker images to a local destination, or to its run folder if one is set.
+// If imageNames is not empty, exactly those images are exported without prompting.
+func ExportImages(destination string, filter Filter, imageNames []string) {
+	destination = filepath.Join(destination, runFolder)
+
 	// Initialize Docker client
-	cli, err := client.NewClientWithOpts(client.FromEnv)
+	cli, err := NewClient("")
 	if err != nil {
-		fmt.Printf("[x] Failed to create Docker client: %v\n", err)
+		i18n.Printf("[x] Failed to create Docker client: %v\n", err)
 		os.Exit(1)
 	}
 	defer cli.Close()
 
-	// List Docker images
-	images, err := cli.ImageList(context.Background(), types.ImageListOptions{})
-	if err != nil {
-		fmt.Printf("[x] Failed to list Docker images: %v\n", err)
-		os.Exit(1)
-	}
+	var selectedImages []string
+	if len(imageNames) > 0 {
+		// Export exactly the images given on the command line
+		selectedImages = ResolveImages(ListImageEntries(cli, Filter{Untagged: true}), imageNames)
+	} else {
+		// List tagged Docker images, narrowed by the filter if provided
+		imageEntries := ListImageEntries(cli, filter)
+		if len(imageEntries) == 0 {
+			i18n.Println("[x] No tagged Docker images found")
+			os.Exit(1)
+		}
 
-	if len(images) == 0 {
-		fmt.Println("[x] No Docker images found")
-		os.Exit(1)
-	}
+		fmt.Printf("Found %d tagged Docker image(s)\n", len(imageEntries))
 
-	// Format image names for selection
-	imageNames := make([]string, 0, len(images))
-	for _, img := range images {
-		for _, tag := range img.RepoTags {
-			// Skip <none>:<none> tags
-			if tag != "<none>:<none>" {
-				// If grep pattern is provided, only add images that match the pattern
-				if os.Getenv("DKCI_GREP_PATTERN") != "" { // Using env var to pass grep pattern
-					if strings.Contains(tag, os.Getenv("DKCI_GREP_PATTERN")) {
-						imageNames = append(imageNames, tag)
-					}
-				} else {
-					imageNames = append(imageNames, tag)
-				}
-			}
+		selectedImages = SelectImages(imageEntries, "Select Docker images to export:")
+		if len(selectedImages) == 0 {
+			i18n.Println("[x] No images selected")
+			os.Exit(1)
 		}
 	}
 
-	if len(imageNames) == 0 {
-		fmt.Println("[x] No tagged Docker images found")
-		os.Exit(1)
-	}
-
-	fmt.Printf("Found %d tagged Docker image(s)\n", len(imageNames))
+	i18n.Printf("Selected images: %v\n", selectedImages)
 
-	// Setup multi-select options
-	selections := []string{}
+	// Export images carrying several of the selected tags once if all tags are saved
+	selectedImages = CollapseTags(cli, selectedImages)
 
-	// Add an "All" option if there are multiple images
-	if len(imageNames) > 1 {
-		selections = append([]string{"All"}, imageNames...)
-	} else {
-		selections = imageNames
+	// Leave out the images the export policies block or that are not confirmed
+	policyDestination := destination
+	if absPath, err := filepath.Abs(destination); err == nil {
+		policyDestination = absPath
+	}
+	if selectedImages = ApplyPolicies(cli, selectedImages, policyDestination); len(selectedImages) == 0 {
+		i18n.Println("[x] No images left to export")
+		os.Exit(1)
 	}
 
-	// Multi-select prompt
-	prompt := &survey.MultiSelect{
-		Message: "Select Docker images to export:",
-		Options: selections,
+	// Show what the export will write before starting it
+	if !ConfirmExport(cli, selectedImages) {
+		i18n.Println("[x] Export cancelled by user")
+		return
 	}
 
-	selectedImages := []string{}
-	err = survey.AskOne(prompt, &selectedImages)
+	// Create destination directory if it doesn't exist
+	err = os.MkdirAll(destination, 0755)
 	if err != nil {
-		fmt.Printf("[x] Failed to get user selection: %v\n", err)
+		i18n.Printf("[x] Failed to create destination directory %s: %v\n", destination, err)
 		os.Exit(1)
 	}
 
-	// Handle the "All" selection
-	if len(selectedImages) == 1 && selectedImages[0] == "All" {
-		selectedImages = imageNames // Select all images
+	// Export selected images, recording the progress so that an interrupted export can be resumed
+	job.Start(selectedImages)
+	ForEach(selectedImages, saveConcurrency, func(imageName string) {
+		job.MarkRunning(imageName)
+		ExportImage(cli, imageName, destination)
+		job.MarkFailed(imageName)
+	})
+	job.Finish()
+	PrintSummary()
+	WriteDeliveryReport(policyDestination)
+}
+
+// SaveImage writes an image from the Docker daemon to an archive file, compressing it if requested. Uncompressed
+// archives are as large as the image, so the save fails early when the filesystem of filePath has less free space.
+// A failed save removes the file.
+func SaveImage(cli ImageClient, imageName, filePath string) error {
+	if compression == "" {
+		ctx, cancel := CallContext()
+		imageInspect, _, err := cli.ImageInspectWithRaw(ctx, imageName)
+		cancel()
+		if err == nil {
+			if err := CheckFreeSpace(filepath.Dir(filePath), imageInspect.Size); err != nil {
+				return fmt.Errorf("failed to export image %s: %v", imageName, err)
+			}
+		}
 	}
 
-	if len(selectedImages) == 0 {
-		fmt.Println("[x] No images selected")
-		os.Exit(1)
+	outFile, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file %s: %v", filePath, err)
 	}
+	defer outFile.Close()
+	config.Track(filePath)
+
+	// Hash the archive while it is written, for its upload, attestation and record not to read it again
+	hasher := checksum.New()
+	err = WriteImage(cli, imageName, io.MultiWriter(outFile, hasher))
+	if err == nil {
+		if err = outFile.Close(); err != nil {
+			err = fmt.Errorf("failed to write image %s to file %s: %v", imageName, filePath, err)
+		}
+	}
+	if err != nil {
+		outFile.Close()
+		os.Remove(filePath)
+		return err
+	}
+	checksum.Remember(filePath, hasher.Sums())
+	return nil
+}
 
-	fmt.Printf("Selected images: %v\n", selectedImages)
+// WriteImage writes the archive of an image from the Docker daemon to w, compressing it if requested, so that it
+// can be streamed to where it is stored. At most the save concurrency of images are saved at once. Images the rules
+// of SetImageRules forbid are not saved.
+func WriteImage(cli ImageClient, imageName string, w io.Writer) error {
+	saveLimit.Acquire()
+	defer saveLimit.Release()
+
+	// Save a flattened copy of the image if requested, renamed to the tag of the image while saving; otherwise save
+	// it with all of its tags if requested
+	sources, suffix := saveReferences(cli, imageName), ""
+	if err := CheckImagesAllowed(sources); err != nil {
+		return err
+	}
+	if squash {
+		daemon, err := daemonClient(cli, "squashing")
+		if err != nil {
+			return fmt.Errorf("failed to squash image %s: %v", imageName, err)
+		}
+		fmt.Printf("Squashing image %s...\n", imageName)
+		squashed, err := squashImage(daemon, imageName)
+		if err != nil {
+			return fmt.Errorf("failed to squash image %s: %v", imageName, err)
+		}
+		defer removeSquashed(daemon, squashed)
+		sources, suffix = []string{squashed.ref}, squashed.suffix
+	}
 
-	// Create destination directory if it doesn't exist
-	err = os.MkdirAll(destination, 0755)
+	ctx, watchdog := StreamContext()
+	defer watchdog.Stop()
+
+	imageReader, err := cli.ImageSave(ctx, sources)
 	if err != nil {
-		fmt.Printf("[x] Failed to create destination directory %s: %v\n", destination, err)
-		os.Exit(1)
+		return fmt.Errorf("failed to export image %s: %v", imageName, watchdog.Err(err))
 	}
+	defer imageReader.Close()
+	archiveReader := unsquashTags(watchdog.Reader(imageReader), suffix)
+	defer archiveReader.Close()
 
-	// Export selected images
-	for _, imageName := range selectedImages {
-		ExportImage(cli, imageName, destination)
+	compressor, err := CompressWriter(w)
+	if err != nil {
+		return fmt.Errorf("failed to compress image %s: %v", imageName, err)
+	}
+	_, err = io.Copy(compressor, archiveReader)
+	// Close the compressor also on failure, so that a compressing process does not wait for more input
+	if closeErr := compressor.Close(); err == nil {
+		err = closeErr
 	}
+	if err != nil {
+		return fmt.Errorf("failed to write image %s: %v", imageName, watchdog.Err(err))
+	}
+	return nil
 }
 
-func ExportImage(cli *client.Client, imageName, destination string) {
+func ExportImage(cli ImageClient, imageName, destination string) {
+	// Record the export in the state database, as failed unless it completes
+	record := state.Record{Time: time.Now(), Operation: state.Export, Image: imageName, Result: state.Failed}
+	defer func() { state.Add(record) }()
+
 	// Inspect the image to get additional info like OS and architecture
-	imageInspect, _, err := cli.ImageInspectWithRaw(context.Background(), imageName)
+	ctx, cancel := CallContext()
+	imageInspect, _, err := cli.ImageInspectWithRaw(ctx, imageName)
+	cancel()
 	var osInfo, archInfo string
 	if err != nil {
 		// If inspection fails, we'll use empty values for OS and arch, but log the error
@@ -121,232 +207,389 @@ func ExportImage(cli *client.Client, imageName, destination string) {
 		osInfo = imageInspect.Os
 		archInfo = imageInspect.Architecture
 	}
+	record.ImageID, record.Digests = imageInspect.ID, imageInspect.RepoDigests
 
-	// Parse the image name and tag
-	nameParts := strings.Split(imageName, ":")
-	imageNameOnly := nameParts[0]
-	tag := ""
-	if len(nameParts) > 1 {
-		tag = nameParts[1]
+	// Name the archive after the image and its platform using the file name template
+	tarFileName, err := ArchiveName(imageName, osInfo, archInfo, imageInspect.ID)
+	if err != nil {
+		i18n.Printf("[x] Failed to name the archive of image %s: %v\n", imageName, err)
+		return
 	}
 
-	// Sanitize the image name for filename (replace '/' with '·')
-	sanitizedImageName := strings.ReplaceAll(imageNameOnly, "/", "·")
-
-	var tarFileName string
-	// Format: <image_name>_<tag>_<os>_<arch>.tar
-	var suffixParts []string
-	if tag != "" {
-		suffixParts = append(suffixParts, tag)
-	} else {
-		// Always include a tag value, use "latest" as default if not available
-		suffixParts = append(suffixParts, "latest")
+	// A file named after the image digest already holds exactly this image; other names that are taken are kept,
+	// numbered or timestamped as --on-conflict says
+	archivePath := func(name string) string { return SealedName(filepath.Join(destination, CompressedName(name))) }
+	exists := func(name string) bool {
+		_, err := os.Stat(archivePath(name))
+		return err == nil
 	}
-	if osInfo != "" {
-		suffixParts = append(suffixParts, osInfo)
-	} else {
-		// If OS info is not available, add a placeholder
-		suffixParts = append(suffixParts, "unknown")
+	upToDate := NameHasDigest() && imageInspect.ID != "" && exists(tarFileName)
+	skip := upToDate
+	if !upToDate {
+		tarFileName, skip = ResolveConflict(tarFileName, exists, time.Now())
 	}
-	if archInfo != "" {
-		suffixParts = append(suffixParts, archInfo)
-	} else {
-		// If architecture info is not available, add a placeholder
-		suffixParts = append(suffixParts, "unknown")
+
+	// The archive is written in full before it is wrapped in a password-protected container, if requested
+	plainFilePath := filepath.Join(destination, CompressedName(tarFileName))
+	tarFilePath := SealedName(plainFilePath)
+	if absPath, err := filepath.Abs(tarFilePath); err == nil {
+		record.Location = absPath
+	}
+	if skip {
+		if upToDate {
+			i18n.Printf("[√] %s is already up to date at %s\n", imageName, tarFilePath)
+		} else {
+			i18n.Printf("[√] Skipped %s, %s already exists\n", imageName, tarFilePath)
+		}
+		record.Result = state.Skipped
+		job.MarkSkipped(imageName)
+		return
 	}
 
-	tarFileName = fmt.Sprintf("%s_%s.tar", sanitizedImageName, strings.Join(suffixParts, "_"))
+	// The name template may place the archive in a subdirectory
+	if err := os.MkdirAll(filepath.Dir(tarFilePath), 0755); err != nil {
+		i18n.Printf("[x] Failed to create directory %s: %v\n", filepath.Dir(tarFilePath), err)
+		return
+	}
+	config.Track(tarFilePath)
 
-	tarFilePath := filepath.Join(destination, tarFileName)
+	// Let the pre_export hook stop the export
+	if err := hook.Run(hook.Context{Hook: hook.PreExport, Image: imageName, Archive: tarFilePath}); err != nil {
+		i18n.Printf("[x] %v\n", err)
+		return
+	}
 
 	fmt.Printf("Exporting image %s to %s...\n", imageName, tarFilePath)
 
-	// Export the image
-	imageReader, err := cli.ImageSave(context.Background(), []string{imageName})
-	if err != nil {
-		fmt.Printf("[x] Failed to export image %s: %v\n", imageName, err)
+	// Export the image, compressing it if requested
+	if err := SaveImage(cli, SaveReference(imageInspect.RepoTags, imageName), plainFilePath); err != nil {
+		i18n.Printf("[x] %v\n", err)
 		return
 	}
-	defer imageReader.Close()
 
-	// Create the output file
-	outFile, err := os.Create(tarFilePath)
-	if err != nil {
-		fmt.Printf("[x] Failed to create output file %s: %v\n", tarFilePath, err)
-		return
+	// Wrap the archive in a password-protected container if requested
+	if Sealed() {
+		if _, err := SealArchive(plainFilePath); err != nil {
+			i18n.Printf("[x] Failed to encrypt %s: %v\n", plainFilePath, err)
+			return
+		}
 	}
-	defer outFile.Close()
 
-	// Copy the image data to the tar file
-	_, err = io.Copy(outFile, imageReader)
-	if err != nil {
-		fmt.Printf("[x] Failed to write image %s to file %s: %v\n", imageName, tarFilePath, err)
+	// Write an SBOM next to the archive if requested
+	if sbom.Enabled() {
+		sbomPath, err := sbom.Generate(tarFilePath)
+		if err != nil {
+			i18n.Printf("[x] Failed to generate SBOM for %s: %v\n", tarFilePath, err)
+			return
+		}
+		fmt.Printf("Wrote SBOM for %s to %s\n", tarFilePath, sbomPath)
+
+		// Summarize the licenses of its packages for compliance if requested
+		if sbom.LicenseReportEnabled() {
+			reportPath, err := sbom.WriteLicenseReport(tarFilePath, imageName, sbomPath)
+			if err != nil {
+				i18n.Printf("[x] Failed to write license report for %s: %v\n", tarFilePath, err)
+				return
+			}
+			fmt.Printf("Wrote license report for %s to %s\n", tarFilePath, reportPath)
+		}
+	}
+
+	// Record the provenance of the archive next to it if requested
+	attestationPath := ""
+	if attest.Enabled() {
+		attestationPath, err = attest.Write(tarFilePath, imageName, imageInspect.ID, imageInspect.RepoDigests)
+		if err != nil {
+			i18n.Printf("[x] Failed to write attestation for %s: %v\n", tarFilePath, err)
+			return
+		}
+		fmt.Printf("Wrote attestation for %s to %s\n", tarFilePath, attestationPath)
+	}
+
+	// Write detached signatures of the archive and its attestation if signing is enabled
+	if sign.Enabled() {
+		for _, filePath := range []string{tarFilePath, attestationPath} {
+			if filePath == "" {
+				continue
+			}
+			signaturePath, err := sign.SignFile(filePath)
+			if err != nil {
+				i18n.Printf("[x] Failed to sign %s: %v\n", filePath, err)
+				return
+			}
+			fmt.Printf("Signed %s (signature: %s)\n", filePath, signaturePath)
+		}
+	}
+
+	if err := hook.Run(hook.Context{Hook: hook.PostExport, Image: imageName, Archive: tarFilePath}); err != nil {
+		i18n.Printf("[x] %v\n", err)
 		return
 	}
 
-	fmt.Printf("[√] Successfully exported image %s to %s\n", imageName, tarFilePath)
+	i18n.Printf("[√] Successfully exported image %s to %s\n", imageName, tarFilePath)
+	if info, err := os.Stat(tarFilePath); err == nil {
+		job.AddBytes(info.Size())
+	}
+	record.SetArchive(tarFilePath)
+	record.Result = state.OK
+	job.MarkDone(imageName)
 }
 
-// DeleteImages deletes the selected Docker images
-func DeleteImages(grepPattern string) {
+// DeleteImages deletes the selected Docker images.
+// If imageNames is not empty, exactly those images are deleted without prompting; they may be tags, IDs or digests.
+func DeleteImages(filter Filter, imageNames []string) {
 	// Initialize Docker client
-	cli, err := client.NewClientWithOpts(client.FromEnv)
+	cli, err := NewClient("")
 	if err != nil {
-		fmt.Printf("[x] Failed to create Docker client: %v\n", err)
+		i18n.Printf("[x] Failed to create Docker client: %v\n", err)
 		os.Exit(1)
 	}
 	defer cli.Close()
 
-	// List Docker images
-	images, err := cli.ImageList(context.Background(), types.ImageListOptions{})
-	if err != nil {
-		fmt.Printf("[x] Failed to list Docker images: %v\n", err)
-		os.Exit(1)
-	}
-
-	if len(images) == 0 {
-		fmt.Println("[x] No Docker images found")
-		os.Exit(1)
-	}
-
-	// Format image names for selection
-	imageNames := make([]string, 0, len(images))
-	for _, img := range images {
-		for _, tag := range img.RepoTags {
-			// Skip <none>:<none> tags
-			if tag != "<none>:<none>" {
-				// If grep pattern is provided, only add images that match the pattern
-				if grepPattern != "" {
-					if strings.Contains(tag, grepPattern) {
-						imageNames = append(imageNames, tag)
-					}
-				} else {
-					imageNames = append(imageNames, tag)
-				}
+	var selectedImages []string
+	if len(imageNames) > 0 {
+		// Delete exactly the images given on the command line, which may also be IDs or digests
+		selectedImages = resolveDeleteTargets(cli, imageNames)
+	} else {
+		// List tagged Docker images, and untagged ones if requested, narrowed by the filter if provided
+		imageEntries := ListImageEntries(cli, filter)
+		if len(imageEntries) == 0 {
+			if filter.Untagged {
+				i18n.Println("[x] No Docker images found")
+			} else {
+				i18n.Println("[x] No tagged Docker images found")
 			}
+			os.Exit(1)
 		}
-	}
 
-	if len(imageNames) == 0 {
-		fmt.Println("[x] No tagged Docker images found")
-		os.Exit(1)
-	}
-
-	fmt.Printf("Found %d tagged Docker image(s)\n", len(imageNames))
+		if filter.Untagged {
+			fmt.Printf("Found %d Docker image(s)\n", len(imageEntries))
+		} else {
+			fmt.Printf("Found %d tagged Docker image(s)\n", len(imageEntries))
+		}
 
-	// Setup multi-select options
-	selections := []string{}
+		// Show which containers use each image, as Docker refuses to delete those images
+		addContainers(cli, imageEntries)
 
-	// Add an "All" option if there are multiple images
-	if len(imageNames) > 1 {
-		selections = append([]string{"All"}, imageNames...)
-	} else {
-		selections = imageNames
+		selectedImages = SelectImages(imageEntries, "Select Docker images to delete:")
+		if len(selectedImages) == 0 {
+			i18n.Println("[x] No images selected")
+			os.Exit(1)
+		}
 	}
 
-	// Multi-select prompt
-	prompt := &survey.MultiSelect{
-		Message: "Select Docker images to delete:",
-		Options: selections,
-	}
+	i18n.Printf("Selected images: %v\n", selectedImages)
 
-	selectedImages := []string{}
-	err = survey.AskOne(prompt, &selectedImages)
-	if err != nil {
-		fmt.Printf("[x] Failed to get user selection: %v\n", err)
-		os.Exit(1)
+	// Delete selected images
+	for _, imageName := range selectedImages {
+		DeleteImage(cli, imageName)
 	}
+}
 
-	// Handle the "All" selection
-	if len(selectedImages) == 1 && selectedImages[0] == "All" {
-		selectedImages = imageNames // Select all images
+// resolveDeleteTargets checks that the images named on the command line exist, exiting with an error if any of
+// them does not. Besides tags, Docker accepts image IDs, their unambiguous prefixes and repo@sha256 digests.
+func resolveDeleteTargets(cli ImageClient, names []string) []string {
+	resolved := make([]string, 0, len(names))
+	missing := false
+	for _, name := range names {
+		ctx, cancel := CallContext()
+		_, _, err := cli.ImageInspectWithRaw(ctx, name)
+		cancel()
+		if client.IsErrNotFound(err) {
+			i18n.Printf("[x] Docker image not found: %s\n", name)
+			missing = true
+			continue
+		} else if err != nil {
+			i18n.Printf("[x] Failed to inspect image %s: %v\n", name, err)
+			missing = true
+			continue
+		}
+		resolved = append(resolved, name)
 	}
 
-	if len(selectedImages) == 0 {
-		fmt.Println("[x] No images selected")
+	if missing {
 		os.Exit(1)
 	}
+	return resolved
+}
 
-	fmt.Printf("Selected images: %v\n", selectedImages)
+// addContainers records the containers created from each image, running or stopped, in the entries. Listing
+// them is only informative, so a failure is reported as a warning.
+func addContainers(cli *client.Client, entries []ImageEntry) {
+	ctx, cancel := CallContext()
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+	cancel()
+	if err != nil {
+		fmt.Printf("Warning: Could not list containers: %v\n", err)
+		return
+	}
 
-	// Delete selected images
-	for _, imageName := range selectedImages {
-		DeleteImage(cli, imageName)
+	byImage := make(map[string][]string)
+	for _, c := range containers {
+		name := ShortID(c.ID)
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		byImage[c.ImageID] = append(byImage[c.ImageID], name+" ("+c.State+")")
+	}
+	for i := range entries {
+		entries[i].Containers = byImage[entries[i].ID]
 	}
 }
 
-func DeleteImage(cli *client.Client, imageName string) {
+func DeleteImage(cli ImageClient, imageName string) {
 	fmt.Printf("Deleting image %s...\n", imageName)
 
 	// Delete the image
-	_, err := cli.ImageRemove(context.Background(), imageName, types.ImageRemoveOptions{
+	ctx, cancel := CallContext()
+	defer cancel()
+	_, err := cli.ImageRemove(ctx, imageName, types.ImageRemoveOptions{
 		Force:         false, // Don't force deletion by default
 		PruneChildren: true,  // Remove dependent images too
 	})
 	if err != nil {
-		fmt.Printf("[x] Failed to delete image %s: %v\n", imageName, err)
+		i18n.Printf("[x] Failed to delete image %s: %v\n", imageName, err)
 		return
 	}
 
-	fmt.Printf("[√] Successfully deleted image %s\n", imageName)
+	i18n.Printf("[√] Successfully deleted image %s\n", imageName)
 }
 
-// CleanCache deletes all files in the cache directory
-func CleanCache() {
-	cacheDir := "/tmp/go-dkci"
+// CleanCache deletes all files in the cache directory, after asking for confirmation unless assumeYes is set
+func CleanCache(assumeYes bool) {
+	cacheDir := config.TempDir()
 
 	// Check if directory exists
 	if _, err := os.Stat(cacheDir); os.IsNotExist(err) {
-		fmt.Printf("[x] Cache directory does not exist: %s\n", cacheDir)
+		i18n.Printf("[x] Cache directory does not exist: %s\n", cacheDir)
 		os.Exit(1)
 	}
 
 	// Read all files in the directory
 	files, err := os.ReadDir(cacheDir)
 	if err != nil {
-		fmt.Printf("[x] Failed to read cache directory %s: %v\n", cacheDir, err)
+		i18n.Printf("[x] Failed to read cache directory %s: %v\n", cacheDir, err)
 		os.Exit(1)
 	}
 
-	if len(files) == 0 {
-		fmt.Printf("No files found in cache directory: %s\n", cacheDir)
-		return
+	// Only the entries go-dkci recorded creating, and the sidecar files next to them, are deleted
+	tracked, err := config.Tracked()
+	if err != nil {
+		i18n.Printf("[x] Failed to read the list of files created by go-dkci: %v\n", err)
+		os.Exit(1)
 	}
 
-	// List and count files to be deleted
+	// List the tracked entries and their sidecar files, and count the other entries, which are left alone. The
+	// state of queued and resumable jobs, and the run directories of go-dkci processes still running, are in use and
+	// kept.
 	var filesToDelete []string
-	for _, file := range files {
-		filePath := filepath.Join(cacheDir, file.Name())
+	names := make(map[string]string)
+	owned := ownedEntries(cacheDir, tracked)
+	for _, name := range owned {
+		if name == config.JobsDir || strings.HasPrefix(name, config.JobsDir+"/") {
+			i18n.Printf("Keeping the job state in %s\n", filepath.Join(cacheDir, config.JobsDir))
+			continue
+		}
+		if pid, ok := config.RunDirPID(name); ok && job.ProcessAlive(pid) {
+			i18n.Printf("Keeping %s, in use by running process %d\n", filepath.Join(cacheDir, name), pid)
+			continue
+		}
+		filePath := filepath.Join(cacheDir, filepath.FromSlash(name))
+		if _, err := os.Lstat(filePath); err != nil {
+			continue
+		}
+		names[filePath] = name
 		filesToDelete = append(filesToDelete, filePath)
 		fmt.Printf("- %s\n", filePath)
 	}
+	var untouched int
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), config.TrackFile) && !holdsOwned(file.Name(), owned) {
+			untouched++
+		}
+	}
+	if untouched > 0 {
+		i18n.Printf("Leaving %d file(s) in %s that were not created by go-dkci\n", untouched, cacheDir)
+	}
 
-	// Confirm deletion with user
-	fmt.Printf("\nFound %d file(s) in cache directory. Are you sure you want to delete all?\n", len(filesToDelete))
+	// Forget the entries deleted by other means
+	var gone []string
+	for _, entry := range tracked {
+		if _, err := os.Lstat(filepath.Join(cacheDir, filepath.FromSlash(entry))); os.IsNotExist(err) {
+			gone = append(gone, entry)
+		}
+	}
+	config.Untrack(gone...)
 
-	// Simple confirmation - in a real app we might want to use a proper confirmation prompt
-	confirmed := false
-	fmt.Print("Type 'yes' to confirm deletion: ")
-	var response string
-	fmt.Scanln(&response)
-	if response == "yes" {
-		confirmed = true
+	if len(filesToDelete) == 0 {
+		i18n.Printf("No files created by go-dkci found in cache directory: %s\n", cacheDir)
+		return
 	}
 
-	if !confirmed {
-		fmt.Println("[x] Cache cleanup cancelled by user")
+	// Confirm deletion with user
+	i18n.Printf("\nFound %d file(s) created by go-dkci in cache directory. Are you sure you want to delete them?\n", len(filesToDelete))
+
+	if !ConfirmDeletion(assumeYes) {
+		i18n.Println("[x] Cache cleanup cancelled by user")
 		return
 	}
 
-	// Delete all files
+	// Delete the files, keeping the ones that could not be deleted in the list. The directories that held files in
+	// subdirectories are removed once empty, and kept while they hold anything else.
 	deletedCount := 0
+	var deleted []string
 	for _, filePath := range filesToDelete {
 		if err := os.RemoveAll(filePath); err != nil {
-			fmt.Printf("[x] Failed to delete %s: %v\n", filePath, err)
-		} else {
-			deletedCount++
+			i18n.Printf("[x] Failed to delete %s: %v\n", filePath, err)
+			continue
+		}
+		deletedCount++
+		deleted = append(deleted, names[filePath])
+		for dir := filepath.Dir(filePath); dir != cacheDir && strings.HasPrefix(dir, cacheDir); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	if err := config.Untrack(deleted...); err != nil {
+		fmt.Printf("Warning: Failed to update the list of files created by go-dkci: %v\n", err)
+	}
+
+	i18n.Printf("[√] Successfully cleaned cache directory. Deleted %d file(s)\n", deletedCount)
+}
+
+// ownedEntries returns the entries of cacheDir tracked by go-dkci, followed by the sidecar files it writes next to
+// tracked files (the signatures, attestation, SBOMs and license report), each once. Other files merely named after
+// a tracked entry, such as a backup copy of it, are not included.
+func ownedEntries(cacheDir string, tracked []string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
 		}
 	}
+	for _, entry := range tracked {
+		add(entry)
+		if info, err := os.Stat(filepath.Join(cacheDir, filepath.FromSlash(entry))); err == nil && info.IsDir() {
+			continue
+		}
+		for _, sidecar := range SidecarFiles(entry) {
+			add(sidecar)
+		}
+		add(sign.SignatureFile(attest.File(entry)))
+	}
+	return names
+}
 
-	fmt.Printf("[√] Successfully cleaned cache directory. Deleted %d file(s)\n", deletedCount)
+// holdsOwned reports whether a top-level entry of the cache directory is one of the owned entries returned by
+// ownedEntries, or a directory holding some of them
+func holdsOwned(name string, owned []string) bool {
+	for _, entry := range owned {
+		if entry == name || strings.HasPrefix(entry, name+"/") {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,231 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/docker/docker/client"
+)
+
+// testImages are the images of the fake daemons of the tests: nginx with two tags, a smaller arm64 redis and an
+// untagged image
+var testImages = []FakeImage{
+	{
+		RepoTags:     []string{"nginx:1.27", "nginx:latest"},
+		Os:           "linux",
+		Architecture: "amd64",
+		Created:      time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		Layers:       [][]byte{bytes.Repeat([]byte("n"), 3000)},
+	},
+	{
+		RepoTags:     []string{"redis:7"},
+		Os:           "linux",
+		Architecture: "arm64",
+		Created:      time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		Layers:       [][]byte{bytes.Repeat([]byte("r"), 1000)},
+	},
+	{
+		Os:           "linux",
+		Architecture: "amd64",
+		Created:      time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		Layers:       [][]byte{[]byte("dangling")},
+	},
+}
+
+func TestListImageEntries(t *testing.T) {
+	cli := NewFakeClient(testImages...)
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "all tags", want: []string{"nginx:1.27", "nginx:latest", "redis:7"}},
+		{name: "grep", filter: Filter{Grep: GrepFilter{Patterns: []string{"redis"}}}, want: []string{"redis:7"}},
+		{name: "grep full reference", filter: Filter{Grep: GrepFilter{Patterns: []string{"docker.io/library/nginx:1"}}}, want: []string{"nginx:1.27"}},
+		{name: "platform", filter: Filter{Arch: "arm64"}, want: []string{"redis:7"}},
+		{name: "min size", filter: Filter{MinSize: 2000}, want: []string{"nginx:1.27", "nginx:latest"}},
+		{name: "created before", filter: Filter{CreatedBefore: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, want: []string{"redis:7"}},
+		{name: "untagged", filter: Filter{Untagged: true, MaxSize: 100}, want: []string{"<id>"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var names []string
+			for _, entry := range ListImageEntries(cli, test.filter) {
+				name := entry.Name
+				if name == ShortID(entry.ID) {
+					name = "<id>"
+				}
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			if !slices.Equal(names, test.want) {
+				t.Errorf("ListImageEntries = %q, want %q", names, test.want)
+			}
+		})
+	}
+}
+
+func TestSaveImageRoundTrip(t *testing.T) {
+	t.Setenv("DKCI_TEMP_DIR", t.TempDir())
+	for _, format := range []string{"none", "gzip"} {
+		t.Run(format, func(t *testing.T) {
+			if err := SetCompression(format); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { SetCompression("") })
+
+			source := NewFakeClient(testImages...)
+			filePath := filepath.Join(t.TempDir(), "redis_7_linux_arm64.tar")
+			if err := SaveImage(source, "redis:7", filePath); err != nil {
+				t.Fatal(err)
+			}
+
+			target := NewFakeClient()
+			refs, err := loadFile(target, filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(refs, []string{"redis:7"}) {
+				t.Errorf("loaded %q, want [redis:7]", refs)
+			}
+			want, _, _ := source.ImageInspectWithRaw(context.Background(), "redis:7")
+			got, _, err := target.ImageInspectWithRaw(context.Background(), "redis:7")
+			if err != nil || got.ID != want.ID || got.Architecture != "arm64" {
+				t.Errorf("loaded image %s %s, want %s arm64: %v", got.ID, got.Architecture, want.ID, err)
+			}
+		})
+	}
+}
+
+func TestSaveImageMissing(t *testing.T) {
+	t.Setenv("DKCI_TEMP_DIR", t.TempDir())
+	filePath := filepath.Join(t.TempDir(), "missing.tar")
+	if err := SaveImage(NewFakeClient(), "missing:1", filePath); err == nil {
+		t.Fatal("saved a missing image")
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Errorf("failed save left %s behind: %v", filePath, err)
+	}
+}
+
+func TestWithAllTags(t *testing.T) {
+	cli := NewFakeClient(testImages...)
+	SetWithAllTags(true)
+	t.Cleanup(func() { SetWithAllTags(false) })
+
+	kept := CollapseTags(cli, []string{"nginx:latest", "redis:7", "nginx:1.27", "missing:1"})
+	if want := []string{"nginx:latest", "redis:7", "missing:1"}; !slices.Equal(kept, want) {
+		t.Errorf("CollapseTags = %q, want %q", kept, want)
+	}
+	if refs := saveReferences(cli, "nginx:latest"); !slices.Equal(refs, []string{"nginx:latest", "nginx:1.27"}) {
+		t.Errorf("saveReferences = %q", refs)
+	}
+
+	// The archive of one tag restores all of them
+	var archive bytes.Buffer
+	if err := WriteImage(cli, "nginx:latest", &archive); err != nil {
+		t.Fatal(err)
+	}
+	refs, err := LoadStream(NewFakeClient(), &archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(refs)
+	if want := []string{"nginx:1.27", "nginx:latest"}; !slices.Equal(refs, want) {
+		t.Errorf("LoadStream = %q, want %q", refs, want)
+	}
+}
+
+func TestTagLoaded(t *testing.T) {
+	t.Setenv("DKCI_TEMP_DIR", t.TempDir())
+	source := NewFakeClient(testImages...)
+	filePath := filepath.Join(t.TempDir(), "redis.tar")
+	if err := SaveImage(source, "redis:7", filePath); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetAlsoTags([]string{"redis:stable", "registry.local/cache/redis"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetAlsoTags(nil) })
+
+	target := NewFakeClient()
+	refs, err := loadFile(target, filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"redis:7", "redis:stable", "registry.local/cache/redis:latest"}; !slices.Equal(refs, want) {
+		t.Errorf("loadFile = %q, want %q", refs, want)
+	}
+	for _, ref := range refs {
+		if _, _, err := target.ImageInspectWithRaw(context.Background(), ref); err != nil {
+			t.Errorf("%s not tagged: %v", ref, err)
+		}
+	}
+}
+
+func TestDeleteImage(t *testing.T) {
+	cli := NewFakeClient(testImages...)
+
+	// Deleting one of several tags only untags the image
+	DeleteImage(cli, "nginx:latest")
+	if _, _, err := cli.ImageInspectWithRaw(context.Background(), "nginx:1.27"); err != nil {
+		t.Errorf("nginx:1.27 deleted with nginx:latest: %v", err)
+	}
+	if _, _, err := cli.ImageInspectWithRaw(context.Background(), "nginx:latest"); !client.IsErrNotFound(err) {
+		t.Errorf("nginx:latest still tagged: %v", err)
+	}
+
+	DeleteImage(cli, "redis:7")
+	if _, _, err := cli.ImageInspectWithRaw(context.Background(), "redis:7"); !client.IsErrNotFound(err) {
+		t.Errorf("redis:7 not deleted: %v", err)
+	}
+	if resolved := resolveDeleteTargets(cli, []string{"nginx:1.27"}); !slices.Equal(resolved, []string{"nginx:1.27"}) {
+		t.Errorf("resolveDeleteTargets = %q", resolved)
+	}
+}
+
+func TestCleanCacheOwnedOnly(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DKCI_TEMP_DIR", dir)
+	write := func(name string) string {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	config.Track(write("nginx.tar"))
+	config.Track(write("team/app.tar"))
+	config.Track(write("only/app.tar"))
+	ours := []string{"nginx.tar.sig", "nginx.tar.att.json", "nginx.tar.att.json.sig", "nginx.tar.spdx.json", "team/app.tar.licenses.md"}
+	theirs := []string{"nginx.tar.mine", "nginx.tar.bak", "jobs.bak", "team/notes.txt", "team/app.tar.orig"}
+	for _, name := range append(ours, theirs...) {
+		write(name)
+	}
+
+	CleanCache(true)
+
+	for _, name := range append([]string{"nginx.tar", "team/app.tar", "only/app.tar", "only"}, ours...) {
+		if _, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(name))); !os.IsNotExist(err) {
+			t.Errorf("%s not deleted: %v", name, err)
+		}
+	}
+	for _, name := range theirs {
+		if _, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			t.Errorf("%s of the user deleted: %v", name, err)
+		}
+	}
+	if tracked, _ := config.Tracked(); len(tracked) != 0 {
+		t.Errorf("deleted entries still tracked: %q", tracked)
+	}
+}
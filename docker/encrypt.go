@@ -0,0 +1,136 @@
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/baowuhe/go-dkci/config"
+)
+
+// sealedMagics are the leading bytes of the password-protected containers archives may be wrapped in
+var sealedMagics = []struct {
+	magic  []byte
+	format string
+}{
+	{[]byte("PK\x03\x04"), "zip"},
+	{[]byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}, "7z"},
+}
+
+// sealFormat is the container exported archives are wrapped in; empty leaves them unwrapped
+var sealFormat string
+
+// sealPassword encrypts exported containers and decrypts imported ones
+var sealPassword string
+
+// SetArchive sets the password-protected container exported archives are wrapped in: "zip", "7z", or "" and
+// "none" to leave them unwrapped. The password encrypts exported containers and opens imported ones; it is
+// required when format is set.
+func SetArchive(format, password string) error {
+	switch format {
+	case "", "none":
+		sealFormat = ""
+	case "zip", "7z":
+		if password == "" {
+			return fmt.Errorf("a password is required for %s archives", format)
+		}
+		sealFormat = format
+	default:
+		return fmt.Errorf("unsupported archive %q (expected zip, 7z or none)", format)
+	}
+	sealPassword = password
+	return nil
+}
+
+// Sealed reports whether exported archives are wrapped in a password-protected container
+func Sealed() bool {
+	return sealFormat != ""
+}
+
+// SealedName returns the name of an exported archive with the extension of the configured container
+func SealedName(fileName string) string {
+	if sealFormat != "" {
+		return fileName + "." + sealFormat
+	}
+	return fileName
+}
+
+// SealArchive wraps an exported archive in the configured password-protected container, removes the
+// unwrapped file and returns the path of the container. zip containers use AES-256, 7z containers also
+// encrypt the file names.
+func SealArchive(filePath string) (string, error) {
+	if sealFormat == "" {
+		return filePath, nil
+	}
+	toolPath, err := sevenZipPath()
+	if err != nil {
+		return "", err
+	}
+
+	// 7z adds to an existing container instead of replacing it
+	sealedPath := SealedName(filePath)
+	os.Remove(sealedPath)
+	config.Track(sealedPath)
+
+	// -p without a password makes 7z ask for it, so that it is not on the command line for other users to see
+	args := []string{"a", "-t" + sealFormat, "-p", "-y", "-bd", "-bso0"}
+	if sealFormat == "zip" {
+		args = append(args, "-mem=AES256")
+	} else {
+		args = append(args, "-mhe=on")
+	}
+	args = append(args, sealedPath, filePath)
+	// 7z asks for the password of a new container twice, the second time to confirm it
+	cmd := passwordCommand(exec.Command(toolPath, args...), 2)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(sealedPath)
+		return "", fmt.Errorf("failed to create %s: %v: %s", sealedPath, err, strings.TrimSpace(string(out)))
+	}
+
+	if err := os.Remove(filePath); err != nil {
+		return "", err
+	}
+	return sealedPath, nil
+}
+
+// sealedFormat returns the container format of a file from its first bytes, or "" if it is not a container
+func sealedFormat(file *os.File) string {
+	header := make([]byte, 6)
+	n, _ := file.ReadAt(header, 0)
+	for _, m := range sealedMagics {
+		if bytes.HasPrefix(header[:n], m.magic) {
+			return m.format
+		}
+	}
+	return ""
+}
+
+// openSealed streams the archive stored in a zip or 7z container, decrypting it with the configured password
+func openSealed(filePath string) (io.ReadCloser, error) {
+	toolPath, err := sevenZipPath()
+	if err != nil {
+		return nil, err
+	}
+	return CommandOutput(passwordCommand(exec.Command(toolPath, "e", "-so", "-p", "-bd", filePath), 1))
+}
+
+// passwordCommand answers the password prompts of a 7z command with the configured password through its standard
+// input, times times, rather than passing the password in its arguments, where ps shows it to every user
+func passwordCommand(cmd *exec.Cmd, times int) *exec.Cmd {
+	cmd.Stdin = strings.NewReader(strings.Repeat(sealPassword+"\n", times))
+	readPasswordFromStdin(cmd)
+	return cmd
+}
+
+// sevenZipPath finds a 7-Zip executable; 7z, 7zz and 7za all handle both zip and 7z containers
+func sevenZipPath() (string, error) {
+	for _, name := range []string{"7z", "7zz", "7za"} {
+		if toolPath, err := exec.LookPath(name); err == nil {
+			return toolPath, nil
+		}
+	}
+	return "", fmt.Errorf("7z executable not found in PATH (needed for zip and 7z archives)")
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package docker
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// readPasswordFromStdin starts a 7z command without a controlling terminal. p7zip reads passwords with getpass,
+// which prefers the terminal to the standard input; without one, it reads the password given on the input.
+func readPasswordFromStdin(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,6 @@
+package docker
+
+import "os/exec"
+
+// readPasswordFromStdin does nothing; 7-Zip on Windows reads passwords from its standard input
+func readPasswordFromStdin(cmd *exec.Cmd) {}
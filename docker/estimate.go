@@ -0,0 +1,75 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/baowuhe/go-dkci/job"
+)
+
+// assumeYes starts exports without asking for confirmation of their size
+var assumeYes bool
+
+// SetAssumeYes makes exports start without asking for confirmation of their size
+func SetAssumeYes(yes bool) {
+	assumeYes = yes
+}
+
+// compressionRatios are typical sizes of compressed image archives relative to the plain archive, used to
+// estimate the size of exports
+var compressionRatios = map[string]float64{
+	"gzip": 0.4,
+	"zstd": 0.35,
+}
+
+// ConfirmExport prints the size of every image to export, their total and an estimate of their compressed size,
+// and asks whether to go on. It only asks on a terminal, and neither after SetAssumeYes nor for resumed and
+// background jobs, which were confirmed when they started.
+func ConfirmExport(cli ImageClient, imageNames []string) bool {
+	nameWidth := len("IMAGE")
+	for _, imageName := range imageNames {
+		nameWidth = max(nameWidth, len(imageName))
+	}
+
+	var total int64
+	fmt.Println()
+	fmt.Printf("%-*s  %10s\n", nameWidth, "IMAGE", "SIZE")
+	for _, imageName := range imageNames {
+		ctx, cancel := CallContext()
+		imageInspect, _, err := cli.ImageInspectWithRaw(ctx, imageName)
+		cancel()
+		if err != nil {
+			fmt.Printf("%-*s  %10s\n", nameWidth, imageName, "?")
+			continue
+		}
+		total += imageInspect.Size
+		fmt.Printf("%-*s  %10s\n", nameWidth, imageName, FormatSize(imageInspect.Size))
+	}
+
+	format := compression
+	if format == "" {
+		format = "gzip"
+	}
+	estimate := FormatSize(int64(float64(total) * compressionRatios[format]))
+	if Compressed() {
+		i18n.Printf("Total: %s, about %s once compressed with %s\n", FormatSize(total), estimate, format)
+	} else {
+		i18n.Printf("Total: %s (about %s if compressed with %s)\n", FormatSize(total), estimate, format)
+	}
+
+	if assumeYes || job.Resuming() || !Interactive() {
+		return true
+	}
+	confirmed := true
+	prompt := &survey.Confirm{
+		Message: i18n.Sprintf("Export %d image(s)?", len(imageNames)),
+		Default: true,
+	}
+	if err := survey.AskOne(prompt, &confirmed); err != nil {
+		i18n.Printf("[x] Failed to get user input: %v\n", err)
+		os.Exit(1)
+	}
+	return confirmed
+}
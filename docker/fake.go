@@ -0,0 +1,361 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+)
+
+// FakeImage describes an image held by a FakeClient
+type FakeImage struct {
+	RepoTags     []string
+	Os           string
+	Architecture string
+	Created      time.Time
+	// Layers holds the contents of the layers, which make up the size of the image
+	Layers [][]byte
+}
+
+// FakeClient is an in-memory ImageClient. Saves write and loads read archives in the layout of docker save, with
+// a manifest.json, the image config and a layer.tar per layer, so that they round-trip through the archive code
+// of this package. Image IDs are the SHA-256 of the config, as with Docker.
+type FakeClient struct {
+	mu     sync.Mutex
+	images map[string]*fakeImage
+}
+
+// fakeImage is an image of a FakeClient with its config
+type fakeImage struct {
+	FakeImage
+	id     string
+	config []byte
+}
+
+// fakeConfig is the part of an image config a FakeClient writes and reads
+type fakeConfig struct {
+	Architecture string    `json:"architecture"`
+	OS           string    `json:"os"`
+	Created      time.Time `json:"created"`
+	RootFS       struct {
+		Type    string   `json:"type"`
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+}
+
+// fakeManifestEntry is an entry of the manifest.json of a saved archive
+type fakeManifestEntry struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// fakeNotFound is the error of a missing image; client.IsErrNotFound recognizes it by its NotFound method
+type fakeNotFound struct {
+	ref string
+}
+
+func (e fakeNotFound) Error() string {
+	return "No such image: " + e.ref
+}
+
+func (e fakeNotFound) NotFound() {}
+
+// NewFakeClient returns a FakeClient holding the images
+func NewFakeClient(images ...FakeImage) *FakeClient {
+	f := &FakeClient{images: make(map[string]*fakeImage)}
+	for _, img := range images {
+		f.add(img)
+	}
+	return f
+}
+
+// add stores an image, moving its tags away from the images holding them, and returns its ID. The caller holds
+// f.mu unless f is not shared yet.
+func (f *FakeClient) add(img FakeImage) string {
+	config := fakeConfig{Architecture: img.Architecture, OS: img.Os, Created: img.Created.UTC()}
+	config.RootFS.Type = "layers"
+	config.RootFS.DiffIDs = []string{}
+	for _, layer := range img.Layers {
+		config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, digestOf(layer))
+	}
+	data, _ := json.Marshal(config)
+	id := digestOf(data)
+
+	for _, other := range f.images {
+		other.RepoTags = slices.DeleteFunc(other.RepoTags, func(tag string) bool { return slices.Contains(img.RepoTags, tag) })
+	}
+	if existing, ok := f.images[id]; ok {
+		for _, tag := range img.RepoTags {
+			if !slices.Contains(existing.RepoTags, tag) {
+				existing.RepoTags = append(existing.RepoTags, tag)
+			}
+		}
+		return id
+	}
+	img.RepoTags = slices.Clone(img.RepoTags)
+	f.images[id] = &fakeImage{FakeImage: img, id: id, config: data}
+	return id
+}
+
+// find returns the image a reference names: a tag (":latest" when it has none), an ID or an unambiguous prefix
+// of an ID. The caller holds f.mu.
+func (f *FakeClient) find(ref string) (*fakeImage, error) {
+	tag := ref
+	if !strings.Contains(path.Base(ref), ":") {
+		tag = ref + ":latest"
+	}
+	var matches []*fakeImage
+	for _, img := range f.images {
+		if slices.Contains(img.RepoTags, ref) || slices.Contains(img.RepoTags, tag) {
+			return img, nil
+		}
+		if strings.HasPrefix(img.id, ref) || strings.HasPrefix(strings.TrimPrefix(img.id, "sha256:"), ref) {
+			matches = append(matches, img)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0], nil
+	}
+	if len(matches) > 1 {
+		return nil, fmt.Errorf("ambiguous image reference %s", ref)
+	}
+	return nil, fakeNotFound{ref: ref}
+}
+
+// size returns the size of an image, the total of its layers
+func (img *fakeImage) size() int64 {
+	var size int64
+	for _, layer := range img.Layers {
+		size += int64(len(layer))
+	}
+	return size
+}
+
+// ImageList lists the images, ordered by ID. The options are ignored.
+func (f *FakeClient) ImageList(ctx context.Context, options types.ImageListOptions) ([]image.Summary, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	summaries := []image.Summary{}
+	for _, img := range f.images {
+		summaries = append(summaries, image.Summary{
+			ID:          img.id,
+			RepoTags:    slices.Clone(img.RepoTags),
+			RepoDigests: []string{},
+			Created:     img.Created.Unix(),
+			Size:        img.size(),
+			Containers:  -1,
+			SharedSize:  -1,
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].ID < summaries[j].ID })
+	return summaries, nil
+}
+
+// ImageInspectWithRaw returns the details of an image and its config as the raw response
+func (f *FakeClient) ImageInspectWithRaw(ctx context.Context, imageID string) (types.ImageInspect, []byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	img, err := f.find(imageID)
+	if err != nil {
+		return types.ImageInspect{}, nil, err
+	}
+	return types.ImageInspect{
+		ID:           img.id,
+		RepoTags:     slices.Clone(img.RepoTags),
+		RepoDigests:  []string{},
+		Created:      img.Created.UTC().Format(time.RFC3339Nano),
+		Os:           img.Os,
+		Architecture: img.Architecture,
+		Size:         img.size(),
+	}, slices.Clone(img.config), nil
+}
+
+// ImageSave writes the images to an archive in the layout of docker save. Images named by a tag are saved with
+// that tag, images named by ID with all of their tags.
+func (f *FakeClient) ImageSave(ctx context.Context, imageIDs []string) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var buffer bytes.Buffer
+	tarWriter := tar.NewWriter(&buffer)
+	writeFile := func(name string, data []byte) error {
+		if err := tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: time.Unix(0, 0)}); err != nil {
+			return err
+		}
+		_, err := tarWriter.Write(data)
+		return err
+	}
+
+	manifest := []fakeManifestEntry{}
+	written := make(map[string]bool)
+	for _, ref := range imageIDs {
+		img, err := f.find(ref)
+		if err != nil {
+			return nil, err
+		}
+		entry := fakeManifestEntry{Config: strings.TrimPrefix(img.id, "sha256:") + ".json", RepoTags: []string{}, Layers: []string{}}
+		if slices.Contains(img.RepoTags, ref) {
+			entry.RepoTags = []string{ref}
+		} else if strings.Contains(img.id, ref) {
+			entry.RepoTags = slices.Clone(img.RepoTags)
+		} else {
+			entry.RepoTags = []string{ref + ":latest"}
+		}
+		for _, layer := range img.Layers {
+			entry.Layers = append(entry.Layers, strings.TrimPrefix(digestOf(layer), "sha256:")+"/layer.tar")
+		}
+		manifest = append(manifest, entry)
+
+		if written[img.id] {
+			continue
+		}
+		written[img.id] = true
+		if err := writeFile(entry.Config, img.config); err != nil {
+			return nil, err
+		}
+		for i, layer := range img.Layers {
+			if err := writeFile(entry.Layers[i], layer); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile("manifest.json", data); err != nil {
+		return nil, err
+	}
+	if err := tarWriter.Close(); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(&buffer), nil
+}
+
+// ImageLoad adds the images of an archive in the layout of docker save and answers in plain text, as old
+// daemons do, naming the loaded tags, or the IDs of images without tags
+func (f *FakeClient) ImageLoad(ctx context.Context, input io.Reader, quiet bool) (types.ImageLoadResponse, error) {
+	files := make(map[string][]byte)
+	tarReader := tar.NewReader(input)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return types.ImageLoadResponse{}, fmt.Errorf("invalid archive: %v", err)
+		}
+		data, err := io.ReadAll(tarReader)
+		if err != nil {
+			return types.ImageLoadResponse{}, err
+		}
+		files[header.Name] = data
+	}
+
+	var manifest []fakeManifestEntry
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+		return types.ImageLoadResponse{}, fmt.Errorf("invalid manifest.json: %v", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var output strings.Builder
+	for _, entry := range manifest {
+		var config fakeConfig
+		if err := json.Unmarshal(files[entry.Config], &config); err != nil {
+			return types.ImageLoadResponse{}, fmt.Errorf("invalid config %s: %v", entry.Config, err)
+		}
+		img := FakeImage{RepoTags: entry.RepoTags, Os: config.OS, Architecture: config.Architecture, Created: config.Created}
+		for _, layerPath := range entry.Layers {
+			layer, ok := files[layerPath]
+			if !ok {
+				return types.ImageLoadResponse{}, fmt.Errorf("missing layer %s", layerPath)
+			}
+			img.Layers = append(img.Layers, layer)
+		}
+
+		id := f.add(img)
+		for _, tag := range entry.RepoTags {
+			output.WriteString(loadedImagePrefix + tag + "\n")
+		}
+		if len(entry.RepoTags) == 0 {
+			output.WriteString(loadedImageIDPrefix + id + "\n")
+		}
+	}
+	return types.ImageLoadResponse{Body: io.NopCloser(strings.NewReader(output.String()))}, nil
+}
+
+// ImageRemove untags an image named by one of several tags, and deletes it otherwise. An image with several tags
+// is only deleted by ID when forced, as with Docker.
+func (f *FakeClient) ImageRemove(ctx context.Context, imageID string, options types.ImageRemoveOptions) ([]image.DeleteResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	img, err := f.find(imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []image.DeleteResponse
+	tag := imageID
+	if !strings.Contains(path.Base(tag), ":") {
+		tag += ":latest"
+	}
+	byTag := slices.Contains(img.RepoTags, imageID) || slices.Contains(img.RepoTags, tag)
+	if byTag && len(img.RepoTags) > 1 {
+		img.RepoTags = slices.DeleteFunc(img.RepoTags, func(t string) bool { return t == imageID || t == tag })
+		return []image.DeleteResponse{{Untagged: tag}}, nil
+	}
+	if !byTag && len(img.RepoTags) > 1 && !options.Force {
+		return nil, fmt.Errorf("conflict: unable to delete %s (must be forced) - image is referenced in multiple repositories", ShortID(img.id))
+	}
+
+	for _, t := range img.RepoTags {
+		responses = append(responses, image.DeleteResponse{Untagged: t})
+	}
+	delete(f.images, img.id)
+	return append(responses, image.DeleteResponse{Deleted: img.id}), nil
+}
+
+// ImageTag adds a tag to an image, moving it away from the image holding it
+func (f *FakeClient) ImageTag(ctx context.Context, source, target string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	img, err := f.find(source)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(path.Base(target), ":") {
+		target += ":latest"
+	}
+	for _, other := range f.images {
+		other.RepoTags = slices.DeleteFunc(other.RepoTags, func(tag string) bool { return tag == target })
+	}
+	img.RepoTags = append(img.RepoTags, target)
+	return nil
+}
+
+// digestOf returns the sha256:<hex> digest of data
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,171 @@
+package docker
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter combines the criteria used to narrow the images and files offered for selection
+type Filter struct {
+	Grep GrepFilter
+	// OS and Arch keep only images built for that platform; empty values match anything
+	OS   string
+	Arch string
+	// MinSize and MaxSize bound the image size in bytes; zero means no bound
+	MinSize int64
+	MaxSize int64
+	// CreatedAfter and CreatedBefore bound the image creation time; zero values mean no bound
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// Untagged also lists the images without a tag, named by their short ID
+	Untagged bool
+}
+
+// MatchSize reports whether size lies within the size bounds
+func (f Filter) MatchSize(size int64) bool {
+	return (f.MinSize == 0 || size >= f.MinSize) && (f.MaxSize == 0 || size <= f.MaxSize)
+}
+
+// HasPlatform reports whether the filter restricts the OS or architecture
+func (f Filter) HasPlatform() bool {
+	return f.OS != "" || f.Arch != ""
+}
+
+// MatchPlatform reports whether the given OS and architecture satisfy the platform criteria
+func (f Filter) MatchPlatform(osName, arch string) bool {
+	return (f.OS == "" || f.OS == osName) && (f.Arch == "" || f.Arch == arch)
+}
+
+// MatchFile reports whether an exported archive satisfies the filter. The grep patterns are matched
+// against the file name without extension and the platform against the fields parsed by ParseArchiveName.
+func (f Filter) MatchFile(fileName string) bool {
+	relativeName := archiveRelativeName(fileName)
+	if !f.Grep.Match(strings.TrimSuffix(relativeName, filepath.Ext(relativeName))) {
+		return false
+	}
+	if !f.HasPlatform() {
+		return true
+	}
+
+	fields, ok := ParseArchiveName(fileName)
+	if !ok {
+		return false
+	}
+	return f.MatchPlatform(fields.OS, fields.Arch)
+}
+
+// GrepFilter matches image references or file names against one or more patterns
+type GrepFilter struct {
+	Patterns []string
+	// MatchAll requires every pattern to match instead of any of them
+	MatchAll bool
+}
+
+// IsEmpty reports whether the filter has no patterns and therefore matches everything
+func (g GrepFilter) IsEmpty() bool {
+	return len(g.Patterns) == 0
+}
+
+// Match reports whether name contains any of the patterns (or all of them when MatchAll is set).
+// An empty filter matches every name.
+func (g GrepFilter) Match(name string) bool {
+	if g.IsEmpty() {
+		return true
+	}
+
+	for _, pattern := range g.Patterns {
+		matched := strings.Contains(name, pattern)
+		if matched && !g.MatchAll {
+			return true
+		}
+		if !matched && g.MatchAll {
+			return false
+		}
+	}
+	return g.MatchAll
+}
+
+// MatchReference reports whether an image reference matches like Match, with every pattern also tried against
+// the fully qualified form of the reference, so that docker.io/library/nginx finds nginx:1.26
+func (g GrepFilter) MatchReference(ref string) bool {
+	if g.IsEmpty() {
+		return true
+	}
+
+	full := fullReference(ref)
+	for _, pattern := range g.Patterns {
+		matched := strings.Contains(ref, pattern) || strings.Contains(full, pattern)
+		if matched && !g.MatchAll {
+			return true
+		}
+		if !matched && g.MatchAll {
+			return false
+		}
+	}
+	return g.MatchAll
+}
+
+// MatchCreated reports whether the Unix creation timestamp lies within the creation time bounds
+func (f Filter) MatchCreated(created int64) bool {
+	createdAt := time.Unix(created, 0)
+	return (f.CreatedAfter.IsZero() || !createdAt.Before(f.CreatedAfter)) &&
+		(f.CreatedBefore.IsZero() || createdAt.Before(f.CreatedBefore))
+}
+
+// ParseAge parses an age such as "7d", "2w" or "36h" into a duration. Besides the units understood by
+// time.ParseDuration, "d" (days) and "w" (weeks) are accepted.
+func ParseAge(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, ok := strings.CutSuffix(value, suffix); ok {
+			n, err := strconv.ParseFloat(number, 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid age %q", value)
+			}
+			return time.Duration(n * float64(unit)), nil
+		}
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid age %q", value)
+	}
+	return duration, nil
+}
+
+// ParseSize parses a human-readable size such as "100MB", "1.5G" or "2048" into bytes (1 KB = 1024 B)
+func ParseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	units := []struct {
+		suffix string
+		factor int64
+	}{
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			multiplier = unit.factor
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(number * float64(multiplier)), nil
+}
@@ -0,0 +1,101 @@
+package docker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"2048", 2048, false},
+		{"512B", 512, false},
+		{"1k", 1 << 10, false},
+		{"100MB", 100 << 20, false},
+		{"100 mb", 100 << 20, false},
+		{"1.5G", 3 << 29, false},
+		{"2GB", 2 << 30, false},
+		{"1TB", 1 << 40, false},
+		{"-1MB", 0, true},
+		{"MB", 0, true},
+		{"ten", 0, true},
+	}
+	for _, test := range tests {
+		got, err := ParseSize(test.value)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d, error %v", test.value, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestMatchSize(t *testing.T) {
+	tests := []struct {
+		filter Filter
+		size   int64
+		want   bool
+	}{
+		{Filter{}, 1 << 30, true},
+		{Filter{MinSize: 100 << 20}, 100 << 20, true},
+		{Filter{MinSize: 100 << 20}, 100<<20 - 1, false},
+		{Filter{MaxSize: 1 << 30}, 1 << 30, true},
+		{Filter{MaxSize: 1 << 30}, 1<<30 + 1, false},
+		{Filter{MinSize: 1 << 20, MaxSize: 1 << 30}, 512 << 20, true},
+		{Filter{MinSize: 1 << 20, MaxSize: 1 << 30}, 2 << 30, false},
+	}
+	for _, test := range tests {
+		if got := test.filter.MatchSize(test.size); got != test.want {
+			t.Errorf("%+v.MatchSize(%d) = %v, want %v", test.filter, test.size, got, test.want)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"36h", 36 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"1.5d", 36 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"-1d", 0, true},
+		{"-1h", 0, true},
+		{"d", 0, true},
+		{"week", 0, true},
+	}
+	for _, test := range tests {
+		got, err := ParseAge(test.value)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("ParseAge(%q) = %v, %v, want %v, error %v", test.value, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestMatchCreated(t *testing.T) {
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		filter  Filter
+		created time.Time
+		want    bool
+	}{
+		{Filter{}, day, true},
+		{Filter{CreatedAfter: day}, day, true},
+		{Filter{CreatedAfter: day}, day.Add(-time.Second), false},
+		{Filter{CreatedBefore: day}, day, false},
+		{Filter{CreatedBefore: day}, day.Add(-time.Second), true},
+		{Filter{CreatedAfter: day, CreatedBefore: day.AddDate(0, 0, 7)}, day.AddDate(0, 0, 3), true},
+		{Filter{CreatedAfter: day, CreatedBefore: day.AddDate(0, 0, 7)}, day.AddDate(0, 0, 8), false},
+	}
+	for _, test := range tests {
+		if got := test.filter.MatchCreated(test.created.Unix()); got != test.want {
+			t.Errorf("MatchCreated(%s) with bounds %s - %s = %v, want %v", test.created, test.filter.CreatedAfter, test.filter.CreatedBefore, got, test.want)
+		}
+	}
+}
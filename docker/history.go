@@ -0,0 +1,69 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/baowuhe/go-dkci/state"
+)
+
+// PrintLog prints the last exports and imports of the state database, newest first, as a table or as JSON
+// records. A last of 0 prints them all.
+func PrintLog(last int, output string) {
+	records, err := state.Records()
+	if err != nil {
+		i18n.Printf("[x] %v\n", err)
+		os.Exit(1)
+	}
+	if last > 0 && len(records) > last {
+		records = records[len(records)-last:]
+	}
+
+	if output == "json" {
+		if records == nil {
+			records = []state.Record{}
+		}
+		data, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			i18n.Printf("[x] %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	if len(records) == 0 {
+		fmt.Println("No exports or imports recorded yet")
+		return
+	}
+	fmt.Printf("%-16s  %-7s  %-7s  %10s  %8s  %-30s  %s\n", "TIME", "OP", "RESULT", "SIZE", "DURATION", "WHAT", "WHERE")
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		size := "-"
+		if record.Size > 0 {
+			size = FormatSize(record.Size)
+		}
+		duration := time.Duration(record.Seconds * float64(time.Second)).Round(time.Second)
+		line := fmt.Sprintf("%-16s  %-7s  %-7s  %10s  %8s  %-30s  %s", record.Time.Local().Format("2006-01-02 15:04"),
+			record.Operation, record.Result, size, duration, recordSubject(record), record.Location)
+		fmt.Println(strings.TrimRight(line, " "))
+		if record.Error != "" {
+			fmt.Printf("  %s\n", record.Error)
+		}
+	}
+}
+
+// recordSubject returns what a record is about: the exported image, or the images an import loaded
+func recordSubject(record state.Record) string {
+	if record.Image != "" {
+		return record.Image
+	}
+	if len(record.Images) > 0 {
+		return strings.Join(record.Images, ", ")
+	}
+	return "-"
+}
@@ -0,0 +1,95 @@
+package docker
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// imageRule is a pattern of allow_images or deny_images, as written and compiled
+type imageRule struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// matches reports whether the rule matches one of the forms of a reference
+func (r imageRule) matches(forms []string) bool {
+	for _, form := range forms {
+		if r.re.MatchString(form) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// allowedImages are the patterns one of which the references of exported images must match, if any
+	allowedImages []imageRule
+	// deniedImages are the patterns the references of exported images must not match
+	deniedImages []imageRule
+)
+
+// SetImageRules sets the regular expressions deciding which images may leave the host, from the allow_images and
+// deny_images keys of the configuration file. A pattern matches a whole reference, in its normalized form such as
+// nginx:latest or in its fully qualified form such as docker.io/library/nginx:latest. With allow patterns, only
+// the images matching one of them may be exported; images matching a deny pattern never may.
+func SetImageRules(allow, deny []string) error {
+	var err error
+	if allowedImages, err = compileImageRules("allow_images", allow); err != nil {
+		return err
+	}
+	deniedImages, err = compileImageRules("deny_images", deny)
+	return err
+}
+
+// compileImageRules compiles the patterns of a key of the configuration file, anchored to match whole references
+func compileImageRules(key string, patterns []string) ([]imageRule, error) {
+	compiled := make([]imageRule, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %v", key, pattern, err)
+		}
+		compiled = append(compiled, imageRule{pattern: pattern, re: re})
+	}
+	return compiled, nil
+}
+
+// referenceForms returns the forms of a reference patterns may match: as given, normalized and fully qualified
+func referenceForms(name string) []string {
+	forms := []string{name}
+	if normalized, err := NormalizeReference(name); err == nil {
+		forms = append(forms, normalized, fullReference(normalized))
+	}
+	return forms
+}
+
+// CheckImageAllowed returns an error if the rules set by SetImageRules forbid exporting the image named by a
+// reference. Names that are not references, such as the IDs of untagged images, only match patterns written
+// for them.
+func CheckImageAllowed(name string) error {
+	forms := referenceForms(name)
+	for _, rule := range deniedImages {
+		if rule.matches(forms) {
+			return fmt.Errorf("image %s may not be exported, it matches the deny_images pattern %q of the configuration file", name, rule.pattern)
+		}
+	}
+	if len(allowedImages) == 0 {
+		return nil
+	}
+	for _, rule := range allowedImages {
+		if rule.matches(forms) {
+			return nil
+		}
+	}
+	return fmt.Errorf("image %s may not be exported, it matches none of the allow_images patterns of the configuration file", name)
+}
+
+// CheckImagesAllowed returns the error of CheckImageAllowed for the first of the images that may not be exported
+func CheckImagesAllowed(names []string) error {
+	for _, name := range names {
+		if err := CheckImageAllowed(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
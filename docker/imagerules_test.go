@@ -0,0 +1,36 @@
+package docker
+
+import "testing"
+
+func TestCheckImageAllowed(t *testing.T) {
+	t.Cleanup(func() { SetImageRules(nil, nil) })
+
+	tests := []struct {
+		allow, deny []string
+		name        string
+		want        bool
+	}{
+		{nil, nil, "nginx", true},
+		{nil, []string{".*:latest"}, "nginx", false},
+		{nil, []string{".*:latest"}, "nginx:1.27", true},
+		{nil, []string{`docker\.io/library/.*`}, "redis:7", false},
+		{nil, []string{`docker\.io/library/.*`}, "ghcr.io/org/app:1", true},
+		{nil, []string{"nginx"}, "nginx:1.27", true},
+		{[]string{`ghcr\.io/org/.*`}, nil, "ghcr.io/org/app:1", true},
+		{[]string{`ghcr\.io/org/.*`}, nil, "redis:7", false},
+		{[]string{`ghcr\.io/org/.*`}, nil, "sha256:4a1c", false},
+		{[]string{`.*`}, []string{`.*:latest`}, "alpine:latest", false},
+	}
+	for _, test := range tests {
+		if err := SetImageRules(test.allow, test.deny); err != nil {
+			t.Fatal(err)
+		}
+		if err := CheckImageAllowed(test.name); (err == nil) != test.want {
+			t.Errorf("allow %q, deny %q: CheckImageAllowed(%q) = %v, want allowed %v", test.allow, test.deny, test.name, err, test.want)
+		}
+	}
+
+	if err := SetImageRules(nil, []string{"nginx:["}); err == nil {
+		t.Error("SetImageRules accepted an invalid pattern")
+	}
+}
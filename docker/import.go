@@ -2,257 +2,515 @@ package docker
 
 import (
 	"archive/tar"
-	"compress/gzip"
-	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/AlecAivazis/survey/v2"
-	"github.com/docker/docker/client"
+	"github.com/baowuhe/go-dkci/hook"
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/baowuhe/go-dkci/job"
+	"github.com/baowuhe/go-dkci/sign"
+	"github.com/baowuhe/go-dkci/state"
 )
 
-// ImportImagesFromSource imports Docker images from a specified source file or directory
-func ImportImagesFromSource(source string, grepPattern string) {
+// ImportImagesFromSource imports Docker images from a specified source file or directory.
+// If fileNames is not empty, exactly those files of the source directory are imported without prompting.
+func ImportImagesFromSource(source string, filter Filter, fileNames []string) {
 	// Check if the source is a file or directory
 	fileInfo, err := os.Stat(source)
 	if err != nil {
-		fmt.Printf("[x] Error accessing source: %v\n", err)
+		i18n.Printf("[x] Error accessing source: %v\n", err)
 		os.Exit(1)
 	}
 
 	if fileInfo.IsDir() {
-		// Handle directory import
-		importFromDirectory(source, grepPattern)
+		// Handle directory import, from the latest run folder of the directory if requested
+		if latestRun {
+			source = latestLocalRun(source)
+		}
+		importFromDirectory(source, filter, fileNames)
 	} else {
 		// Handle single file import
-		importFromFile(source)
+		ImportOne(source, importLocalFile)
 	}
 }
 
-func importFromDirectory(dirPath string, grepPattern string) {
+func importFromDirectory(dirPath string, filter Filter, fileNames []string) {
+	// Files named on the command line are matched without the filter
+	if len(fileNames) > 0 {
+		filter = Filter{}
+	}
+
 	// Find all .tar files in the directory
-	tarFiles, err := findTarFilesInDirectory(dirPath, grepPattern)
+	tarFiles, err := findTarFilesInDirectory(dirPath, filter)
 	if err != nil {
-		fmt.Printf("[x] Error finding .tar files: %v\n", err)
+		i18n.Printf("[x] Error finding .tar files: %v\n", err)
 		os.Exit(1)
 	}
 
 	if len(tarFiles) == 0 {
-		fmt.Println("[x] No .tar files found in the specified directory")
+		i18n.Println("[x] No .tar files found in the specified directory")
 		os.Exit(1)
 	}
 
-	// Prepare options for selection
-	selectionOptions := make([]string, len(tarFiles))
-	for i, file := range tarFiles {
-		selectionOptions[i] = filepath.Base(file)
+	var selectedFilePaths []string
+	if len(fileNames) > 0 {
+		// Import exactly the files given on the command line
+		selectedFilePaths = ResolveFiles(tarFiles, fileNames)
+	} else {
+		// Offer only the newest archive of each image if requested
+		selectedFilePaths = SelectFiles(LatestFiles(tarFiles), "Select .tar files to import as Docker images:")
+		if len(selectedFilePaths) == 0 {
+			i18n.Println("[x] No files selected for import")
+			os.Exit(1)
+		}
 	}
 
-	// Add "All" option if there are more than 1 files
-	if len(tarFiles) > 1 {
-		selectionOptions = append([]string{"All"}, selectionOptions...)
-	}
+	// Import the selected files
+	ImportEach(selectedFilePaths, loadConcurrency, importLocalFile)
+}
 
-	// Show multi-select list to the user
-	selectedFiles := []string{}
-	prompt := &survey.MultiSelect{
-		Message: "Select .tar files to import as Docker images:",
-		Options: selectionOptions,
+// importLocalFile imports a local archive with ImportFile, counting its size as transferred for the summary and
+// recording the import in the state database
+func importLocalFile(filePath string) ([]string, error) {
+	record := state.Record{Time: time.Now(), Operation: state.Import, Location: filePath}
+	if absPath, err := filepath.Abs(filePath); err == nil {
+		record.Location = absPath
 	}
 
-	err = survey.AskOne(prompt, &selectedFiles)
-	if err != nil {
-		fmt.Printf("[x] Failed to get user selection: %v\n", err)
-		os.Exit(1)
+	images, err := ImportFile(filePath)
+	if err == nil {
+		if info, statErr := os.Stat(filePath); statErr == nil {
+			job.AddBytes(info.Size())
+		}
+		record.Images = images
 	}
+	record.SetArchive(filePath)
+	record.Finish(err)
+	state.Add(record)
+	return images, err
+}
 
-	// Handle "All" selection
-	if len(selectedFiles) == 1 && selectedFiles[0] == "All" {
-		// Select all tar files
-		for _, file := range tarFiles {
-			selectedFiles = append(selectedFiles[1:], filepath.Base(file)) // Replace "All" with actual files
+// importResult is the outcome of importing one item
+type importResult struct {
+	item     string
+	images   []string
+	duration time.Duration
+	err      error
+}
+
+// ImportEach imports the items (local files, or cloud files for cloud imports) with importItem, recording the
+// progress in the running job, concurrency items at a time. One after the other, the first failure ends the
+// import; with a concurrency above 1 every item is imported and a table of the results follows. The output of
+// concurrent imports is interleaved line by line, every line naming its file.
+func ImportEach(items []string, concurrency int, importItem func(item string) ([]string, error)) {
+	job.Start(items)
+	defer job.Finish()
+
+	if concurrency <= 1 || len(items) == 1 {
+		var results []importResult
+		for _, item := range items {
+			job.MarkRunning(item)
+			start := time.Now()
+			images, err := importItem(item)
+			results = append(results, importResult{item: item, images: images, duration: time.Since(start), err: err})
+			if err != nil {
+				job.MarkFailed(item)
+				writeReport(results)
+				i18n.Printf("[x] %v\n", err)
+				job.Finish()
+				PrintSummary()
+				os.Exit(1)
+			}
+			job.MarkDone(item)
 		}
+		writeReport(results)
+		PrintSummary()
+		return
+	}
+
+	i18n.Printf("Importing %d files, %d at a time\n", len(items), concurrency)
+	results := make([]importResult, len(items))
+	limit := NewLimiter(concurrency)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		limit.Acquire()
+		go func(i int, item string) {
+			defer wg.Done()
+			defer limit.Release()
+
+			job.MarkRunning(item)
+			start := time.Now()
+			images, err := importItem(item)
+			results[i] = importResult{item: item, images: images, duration: time.Since(start), err: err}
+			if err != nil {
+				i18n.Printf("[x] %v\n", err)
+				job.MarkFailed(item)
+				return
+			}
+			job.MarkDone(item)
+		}(i, item)
 	}
+	wg.Wait()
 
-	if len(selectedFiles) == 0 {
-		fmt.Println("[x] No files selected for import")
+	failed := printImportResults(results)
+	writeReport(results)
+	PrintSummary()
+	if failed > 0 {
+		job.Finish()
+		i18n.Printf("[x] %d of %d file(s) failed to import\n", failed, len(items))
 		os.Exit(1)
 	}
+}
 
-	// Map selected filenames back to full paths
-	selectedFilePaths := []string{}
-	for _, selectedFile := range selectedFiles {
-		for _, tarFile := range tarFiles {
-			if filepath.Base(tarFile) == selectedFile {
-				selectedFilePaths = append(selectedFilePaths, tarFile)
-				break
-			}
+// ImportOne imports a single item with importItem, exiting on failure
+func ImportOne(item string, importItem func(item string) ([]string, error)) {
+	job.StartSummary([]string{item})
+	job.MarkRunning(item)
+	start := time.Now()
+	images, err := importItem(item)
+	if err != nil {
+		job.MarkFailed(item)
+	} else {
+		job.MarkDone(item)
+	}
+	writeReport([]importResult{{item: item, images: images, duration: time.Since(start), err: err}})
+	PrintSummary()
+	if err != nil {
+		i18n.Printf("[x] %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printImportResults prints a table of the imported files and returns the number of failures
+func printImportResults(results []importResult) int {
+	nameWidth := len("FILE")
+	for _, result := range results {
+		nameWidth = max(nameWidth, len(filepath.Base(result.item)))
+	}
+
+	failed := 0
+	fmt.Println()
+	fmt.Printf("%-*s  %-6s  %8s  %s\n", nameWidth, "FILE", "RESULT", "TIME", "IMAGES OR ERROR")
+	for _, result := range results {
+		status, message := "ok", strings.Join(result.images, ", ")
+		if result.err != nil {
+			status, message = "failed", result.err.Error()
+			failed++
 		}
+		line := fmt.Sprintf("%-*s  %-6s  %8s  %s", nameWidth, filepath.Base(result.item), status, result.duration.Round(time.Second), message)
+		fmt.Println(strings.TrimRight(line, " "))
 	}
+	return failed
+}
+
+// reportWriter receives the JSON report of an import; nil writes none
+var reportWriter io.Writer
+
+// SetReport makes imports write a JSON report of the files, the images loaded from them and the summary of the
+// import to w when done
+func SetReport(w io.Writer) {
+	reportWriter = w
+}
+
+// fileReport is the JSON report of an imported file
+type fileReport struct {
+	File    string   `json:"file"`
+	Images  []string `json:"images"`
+	Seconds float64  `json:"seconds"`
+	Error   string   `json:"error,omitempty"`
+}
 
-	// Import each selected file
-	for _, filePath := range selectedFilePaths {
-		importFromFile(filePath)
+// importReport is the JSON report of an import
+type importReport struct {
+	Files   []fileReport `json:"files"`
+	Summary job.Summary  `json:"summary"`
+}
+
+// writeReport writes the JSON report of the import results and the totals of the running job if requested
+func writeReport(results []importResult) {
+	if reportWriter == nil {
+		return
+	}
+	reports := importReport{Files: []fileReport{}, Summary: job.Totals()}
+	for _, result := range results {
+		report := fileReport{File: result.item, Images: result.images, Seconds: result.duration.Round(time.Millisecond).Seconds()}
+		if report.Images == nil {
+			report.Images = []string{}
+		}
+		if result.err != nil {
+			report.Error = result.err.Error()
+		}
+		reports.Files = append(reports.Files, report)
+	}
+	encoder := json.NewEncoder(reportWriter)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(reports); err != nil {
+		fmt.Printf("Warning: Failed to write the import report: %v\n", err)
 	}
 }
 
-func importFromFile(filePath string) {
-	fmt.Printf("Importing image from file: %s\n", filePath)
+// ImportFile loads an image archive into Docker, or into the kind cluster set by SetKindCluster, and returns the
+// references of the loaded images. It reports failures instead of exiting so that several files can be imported
+// at once.
+func ImportFile(filePath string) ([]string, error) {
+	i18n.Printf("Importing image from file: %s\n", filePath)
+
+	// Refuse archives whose signature is missing or invalid when verification is enabled
+	if err := sign.CheckFile(filePath); err != nil {
+		return nil, fmt.Errorf("refusing to import %s: %v", filePath, err)
+	}
+
+	// Let the pre_import hook refuse the archive, for example after scanning it
+	if err := hook.Run(hook.Context{Hook: hook.PreImport, Archive: filePath}); err != nil {
+		return nil, err
+	}
 
 	// Initialize Docker client
-	cli, err := client.NewClientWithOpts(client.FromEnv)
+	cli, closeClient, err := localImageClient()
 	if err != nil {
-		fmt.Printf("[x] Failed to create Docker client: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to create Docker client: %v", err)
 	}
-	defer cli.Close()
+	defer closeClient()
 
-	// Open the tar file
-	file, err := os.Open(filePath)
+	images, err := loadFile(cli, filePath)
 	if err != nil {
-		fmt.Printf("[x] Failed to open file %s: %v\n", filePath, err)
-		os.Exit(1)
+		return nil, err
+	}
+	if err := hook.Run(hook.Context{Hook: hook.PostImport, Archive: filePath, Images: images}); err != nil {
+		return images, err
 	}
-	defer file.Close()
+	return images, nil
+}
 
-	// Check if file is compressed with gzip
-	_, err = file.Stat()
+// loadFile loads an image archive into the daemon of cli, or into the kind cluster set by SetKindCluster, and
+// returns the references of the loaded images
+func loadFile(cli ImageClient, filePath string) ([]string, error) {
+	// Open the tar file, uncompressing it if needed
+	imageReader, err := OpenArchive(filePath)
 	if err != nil {
-		fmt.Printf("[x] Failed to get file info: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to open file %s: %v", filePath, err)
 	}
+	defer imageReader.Close()
 
-	var imageReader io.Reader
-	if strings.HasSuffix(strings.ToLower(filePath), ".tar.gz") || strings.HasSuffix(strings.ToLower(filePath), ".tgz") {
-		// Uncompress gzip
-		gzipReader, err := gzip.NewReader(file)
+	// Wait for a load slot; the archive is read while it is loaded
+	loadLimit.Acquire()
+	defer loadLimit.Release()
+
+	// Load the image into the nodes of a kind cluster instead of the local Docker daemon
+	if kindCluster != "" {
+		daemon, err := daemonClient(cli, "loading into kind")
 		if err != nil {
-			fmt.Printf("[x] Failed to create gzip reader: %v\n", err)
-			os.Exit(1)
+			return nil, err
 		}
-		defer gzipReader.Close()
-		imageReader = gzipReader
-	} else {
-		imageReader = file
+		if err := loadIntoKind(daemon, kindCluster, imageReader); err != nil {
+			return nil, fmt.Errorf("failed to load image from %s into kind cluster %s: %v", filePath, kindCluster, err)
+		}
+		// kind does not report what it loaded; the archive lists its tags
+		refs, err := archiveRepoTags(filePath)
+		if err != nil || len(refs) == 0 {
+			i18n.Printf("[√] Successfully loaded %s into kind cluster %s\n", filePath, kindCluster)
+		} else {
+			i18n.Printf("[√] Successfully loaded %s into kind cluster %s from %s\n", strings.Join(refs, ", "), kindCluster, filePath)
+		}
+		return refs, nil
 	}
 
-	// Import the image
-	response, err := cli.ImageLoad(context.Background(), imageReader, true) // quiet = true
+	// Import the image, streaming the progress of its layers so that large archives do not look stuck
+	ctx, watchdog := StreamContext()
+	defer watchdog.Stop()
+	response, err := cli.ImageLoad(ctx, watchdog.Reader(imageReader), false)
 	if err != nil {
-		fmt.Printf("[x] Failed to load image from %s: %v\n", filePath, err)
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to load image from %s: %v", filePath, watchdog.Err(err))
 	}
+	response.Body = watchdog.ReadCloser(response.Body)
 	defer response.Body.Close()
 
-	// Read and display the response
-	_, err = io.ReadAll(response.Body)
+	// Read the response for the references of the loaded images
+	refs, err := ReadLoadResponse(response, filepath.Base(filePath))
 	if err != nil {
-		fmt.Printf("[x] Failed to read import response: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to load image from %s: %v", filePath, watchdog.Err(err))
+	}
+	if len(refs) == 0 {
+		i18n.Printf("[√] Successfully imported image from %s\n", filePath)
+	} else {
+		i18n.Printf("[√] Successfully imported %s from %s\n", strings.Join(refs, ", "), filePath)
+	}
+	return tagLoaded(cli, filePath, refs)
+}
+
+// alsoTags are the references every imported image is tagged with in addition to its own
+var alsoTags []string
+
+// SetAlsoTags makes imports tag the image of every archive with the references as well, such as app:latest for
+// promoting the imported version. The references are normalized with NormalizeReference.
+func SetAlsoTags(refs []string) error {
+	alsoTags = nil
+	for _, ref := range refs {
+		normalized, err := NormalizeReference(ref)
+		if err != nil {
+			return err
+		}
+		if pinnedDigest(normalized) != "" {
+			return fmt.Errorf("cannot tag an image with the digest reference %s", ref)
+		}
+		alsoTags = append(alsoTags, normalized)
+	}
+	return nil
+}
+
+// tagLoaded tags the image loaded from an archive with the references of SetAlsoTags and returns the references
+// of the loaded image with the added tags. An archive holding several images cannot be tagged, as it is not
+// clear which of them the tags are meant for.
+func tagLoaded(cli ImageClient, filePath string, refs []string) ([]string, error) {
+	if len(alsoTags) == 0 {
+		return refs, nil
+	}
+
+	imageID := ""
+	for _, ref := range refs {
+		ctx, cancel := CallContext()
+		imageInspect, _, err := cli.ImageInspectWithRaw(ctx, ref)
+		cancel()
+		if err != nil {
+			return refs, fmt.Errorf("failed to inspect image %s loaded from %s: %v", ref, filePath, err)
+		}
+		if imageID != "" && imageInspect.ID != imageID {
+			return refs, fmt.Errorf("cannot apply --also-tag to %s, which holds more than one image", filePath)
+		}
+		imageID = imageInspect.ID
+	}
+	if imageID == "" {
+		return refs, fmt.Errorf("cannot apply --also-tag to %s, the loaded image is unknown", filePath)
+	}
+
+	for _, tag := range alsoTags {
+		ctx, cancel := CallContext()
+		err := cli.ImageTag(ctx, imageID, tag)
+		cancel()
+		if err != nil {
+			return refs, fmt.Errorf("failed to tag image %s as %s: %v", ShortID(imageID), tag, err)
+		}
+		i18n.Printf("[√] Tagged %s as %s\n", ShortID(imageID), tag)
+		if !slices.Contains(refs, tag) {
+			refs = append(refs, tag)
+		}
+	}
+	return refs, nil
+}
+
+// archiveReader reads an image archive and closes both the decompressor and the underlying file
+type archiveReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (a *archiveReader) Close() error {
+	var firstErr error
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		if err := a.closers[i].Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// OpenArchive opens an image archive, transparently uncompressing gzip, zstd, xz and bzip2 files whatever their
+// extension. zstd and xz archives need the zstd and xz executables; archives in password-protected zip and 7z
+// containers are extracted with 7z and the password set by SetArchive.
+func OpenArchive(filePath string) (io.ReadCloser, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var source io.ReadCloser = file
+	if sealedFormat(file) != "" {
+		file.Close()
+		if source, err = openSealed(filePath); err != nil {
+			return nil, err
+		}
 	}
 
-	// Try to parse the tar file to get image information
-	imageInfo, err := getImageInfoFromTar(filePath)
+	reader, err := Decompress(source)
 	if err != nil {
-		// If we can't determine the image name, just report success
-		fmt.Printf("[√] Successfully imported image from %s\n", filePath)
-	} else {
-		fmt.Printf("[√] Successfully imported image from %s: %s\n", filePath, imageInfo)
+		source.Close()
+		return nil, err
 	}
+	return &archiveReader{Reader: reader, closers: []io.Closer{source, reader}}, nil
 }
 
-func findTarFilesInDirectory(dirPath string, grepPattern string) ([]string, error) {
-	var tarFiles []string
-	
+func findTarFilesInDirectory(dirPath string, filter Filter) ([]FileEntry, error) {
+	var tarFiles []FileEntry
+
 	// Walk through the directory to find .tar files
 	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		
+
 		if !info.IsDir() {
-			lowerName := strings.ToLower(info.Name())
-			if strings.HasSuffix(lowerName, ".tar") || 
-				strings.HasSuffix(lowerName, ".tar.gz") || 
-				strings.HasSuffix(lowerName, ".tgz") {
-				
-				// Apply grep filter if pattern is provided
-				if grepPattern != "" {
-					// Extract image name information from the file name for filtering
-					baseName := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
-					// If the file name (without extension) contains the grep pattern, include it
-					if strings.Contains(baseName, grepPattern) {
-						tarFiles = append(tarFiles, path)
-					}
-				} else {
-					tarFiles = append(tarFiles, path)
+			if IsArchiveName(info.Name()) {
+				// Container and volume exports are not image archives
+				if IsFilesystemArchive(strings.ToLower(info.Name())) {
+					return nil
+				}
+
+				// If the file name matches the filter, include it
+				if filter.MatchFile(path) {
+					tarFiles = append(tarFiles, FileEntry{Path: path, Size: info.Size(), Modified: info.ModTime().Unix()})
 				}
 			}
 		}
 		return nil
 	})
-	
+
 	if err != nil {
 		return nil, err
 	}
-	
+
 	return tarFiles, nil
 }
 
-func getImageInfoFromTar(tarPath string) (string, error) {
-	// Open the tar file
-	file, err := os.Open(tarPath)
+// archiveRepoTags returns the tags of the images in an archive, as listed in its manifest.json
+func archiveRepoTags(tarPath string) ([]string, error) {
+	// Open the tar file, uncompressing it if needed
+	archive, err := OpenArchive(tarPath)
 	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-
-	// Check if file is compressed with gzip
-	var tarReader io.Reader
-	if strings.HasSuffix(strings.ToLower(tarPath), ".tar.gz") || strings.HasSuffix(strings.ToLower(tarPath), ".tgz") {
-		gzipReader, err := gzip.NewReader(file)
-		if err != nil {
-			return "", err
-		}
-		defer gzipReader.Close()
-		tarReader = gzipReader
-	} else {
-		// Seek back to the beginning if not compressed
-		file.Seek(0, 0)
-		tarReader = file
+		return nil, err
 	}
+	defer archive.Close()
 
-	// Create a tar reader
-	tarReaderVar := tar.NewReader(tarReader)
-
-	// Look for the manifest.json file in the tar archive
-	var manifestContent []byte
+	tarReader := tar.NewReader(archive)
 	for {
-		header, err := tarReaderVar.Next()
+		header, err := tarReader.Next()
 		if err == io.EOF {
-			break
+			return nil, fmt.Errorf("%s has no manifest.json", tarPath)
 		}
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-
-		if header.Name == "manifest.json" {
-			manifestContent, err = io.ReadAll(tarReaderVar)
-			if err != nil {
-				return "", err
-			}
-			break
+		if header.Name != "manifest.json" {
+			continue
 		}
-	}
 
-	// If we found manifest.json content, we could parse it to get image information
-	// For now, we'll just return the file name as basic information
-	if len(manifestContent) > 0 {
-		return filepath.Base(tarPath), nil
+		var manifest []struct {
+			RepoTags []string
+		}
+		if err := json.NewDecoder(tarReader).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest.json of %s: %v", tarPath, err)
+		}
+		var tags []string
+		for _, entry := range manifest {
+			tags = append(tags, entry.RepoTags...)
+		}
+		return tags, nil
 	}
-
-	return filepath.Base(tarPath), nil
-}
\ No newline at end of file
+}
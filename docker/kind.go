@@ -0,0 +1,113 @@
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// kindClusterLabel is the label kind puts on the node containers of a cluster
+const kindClusterLabel = "io.x-k8s.kind.cluster"
+
+// kindCluster is the kind cluster imported archives are loaded into; empty loads them into the local Docker daemon
+var kindCluster string
+
+// SetKindCluster makes imports load archives into every node of the named kind cluster
+func SetKindCluster(name string) {
+	kindCluster = name
+}
+
+// loadIntoKind streams an image archive into the containerd image store of every node of a kind cluster,
+// which is what `kind load image-archive` does
+func loadIntoKind(cli *client.Client, clusterName string, archive io.Reader) error {
+	ctx, cancel := CallContext()
+	nodes, err := cli.ContainerList(ctx, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", kindClusterLabel+"="+clusterName)),
+	})
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to list kind nodes: %v", err)
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("no nodes found for kind cluster %s", clusterName)
+	}
+
+	// The archive is a stream, so buffer it once when it has to be sent to several nodes
+	var data []byte
+	if len(nodes) > 1 {
+		data, err = io.ReadAll(archive)
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %v", err)
+		}
+	}
+
+	for _, node := range nodes {
+		nodeName := strings.TrimPrefix(node.Names[0], "/")
+		reader := archive
+		if data != nil {
+			reader = bytes.NewReader(data)
+		}
+
+		fmt.Printf("Loading into node %s...\n", nodeName)
+		if err := importIntoNode(cli, node.ID, reader); err != nil {
+			return fmt.Errorf("node %s: %v", nodeName, err)
+		}
+	}
+
+	return nil
+}
+
+// importIntoNode runs `ctr images import` inside a kind node container, feeding it the archive on stdin. The
+// import itself is not bounded by the Docker timeout, as ctr prints nothing while it unpacks large layers.
+func importIntoNode(cli *client.Client, containerID string, archive io.Reader) error {
+	ctx, cancel := CallContext()
+	defer cancel()
+	exec, err := cli.ContainerExecCreate(ctx, containerID, types.ExecConfig{
+		Privileged:   true,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          []string{"ctr", "--namespace=k8s.io", "images", "import", "--all-platforms", "--digests", "--snapshotter=overlayfs", "-"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create exec: %v", err)
+	}
+
+	hijacked, err := cli.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{})
+	if err != nil {
+		return fmt.Errorf("failed to attach to exec: %v", err)
+	}
+	defer hijacked.Close()
+
+	if _, err := io.Copy(hijacked.Conn, archive); err != nil {
+		return fmt.Errorf("failed to send archive: %v", err)
+	}
+	if err := hijacked.CloseWrite(); err != nil {
+		return fmt.Errorf("failed to close archive stream: %v", err)
+	}
+
+	// Wait for ctr to finish, keeping its output for error reporting
+	var output bytes.Buffer
+	if _, err := stdcopy.StdCopy(&output, &output, hijacked.Reader); err != nil {
+		return fmt.Errorf("failed to read ctr output: %v", err)
+	}
+
+	inspectCtx, inspectCancel := CallContext()
+	defer inspectCancel()
+	inspect, err := cli.ContainerExecInspect(inspectCtx, exec.ID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect exec: %v", err)
+	}
+	if inspect.ExitCode != 0 {
+		return fmt.Errorf("ctr exited with code %d: %s", inspect.ExitCode, strings.TrimSpace(output.String()))
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package docker
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// latestOnly keeps only the newest of the archives of an image when importing from a folder
+var latestOnly bool
+
+// SetLatestOnly makes imports from a folder keep only the most recent of the matching archives of each image,
+// instead of offering every version of it
+func SetLatestOnly(enabled bool) {
+	latestOnly = enabled
+}
+
+// LatestFiles keeps the most recent of the archives holding the same image, as named by the file name template:
+// the same repository, tag and platform, exported at different times or with different digests. The newest is the
+// one modified last, or uploaded last for cloud files. Archives whose names cannot be parsed are kept, and the
+// order of the entries is preserved. The entries are returned unchanged unless SetLatestOnly is enabled.
+func LatestFiles(entries []FileEntry) []FileEntry {
+	if !latestOnly {
+		return entries
+	}
+
+	// Find the newest archive of every image
+	newest := make(map[string]int)
+	keys := make([]string, len(entries))
+	for i, entry := range entries {
+		fields, ok := ParseArchiveName(entry.Path)
+		if !ok {
+			continue
+		}
+		keys[i] = fmt.Sprintf("%s:%s %s/%s", fields.Repository, fields.Tag, fields.OS, fields.Arch)
+		if j, seen := newest[keys[i]]; !seen || entry.Modified > entries[j].Modified ||
+			entry.Modified == entries[j].Modified && entry.Path > entries[j].Path {
+			newest[keys[i]] = i
+		}
+	}
+
+	kept := make([]FileEntry, 0, len(entries))
+	for i, entry := range entries {
+		if keys[i] != "" {
+			if j := newest[keys[i]]; j != i {
+				fmt.Printf("Skipping %s, %s is a newer archive of the same image\n", filepath.Base(entry.Path), filepath.Base(entries[j].Path))
+				continue
+			}
+		}
+		kept = append(kept, entry)
+	}
+	return kept
+}
@@ -0,0 +1,37 @@
+package docker
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLatestFiles(t *testing.T) {
+	entries := []FileEntry{
+		{Path: "/images/2024-05-01T02-00/nginx_1.27_linux_amd64.tar", Modified: 100},
+		{Path: "/images/2024-06-01T02-00/nginx_1.27_linux_amd64.tar", Modified: 200},
+		{Path: "/images/nginx_1.27_linux_arm64.tar", Modified: 50},
+		{Path: "/images/nginx_1.27_linux_amd64~1.tar.gz", Modified: 150},
+		{Path: "/images/redis_7_linux_amd64.tar", Modified: 10},
+		{Path: "/images/notes.tar", Modified: 5},
+	}
+
+	if got := LatestFiles(entries); len(got) != len(entries) {
+		t.Errorf("LatestFiles without SetLatestOnly kept %d of %d entries", len(got), len(entries))
+	}
+
+	SetLatestOnly(true)
+	t.Cleanup(func() { SetLatestOnly(false) })
+	var paths []string
+	for _, entry := range LatestFiles(entries) {
+		paths = append(paths, entry.Path)
+	}
+	want := []string{
+		"/images/2024-06-01T02-00/nginx_1.27_linux_amd64.tar",
+		"/images/nginx_1.27_linux_arm64.tar",
+		"/images/redis_7_linux_amd64.tar",
+		"/images/notes.tar",
+	}
+	if !slices.Equal(paths, want) {
+		t.Errorf("LatestFiles = %q, want %q", paths, want)
+	}
+}
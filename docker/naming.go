@@ -0,0 +1,356 @@
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// DefaultNameTemplate is the file name template of exported archives: <image_name>_<tag>_<os>_<arch>.tar
+const DefaultNameTemplate = "{{.Name}}_{{.Tag}}_{{.OS}}_{{.Arch}}.tar"
+
+// DeltaSuffix is appended to the archive name to name the recipe of a delta export, which lists the
+// archive entries and references its layers in a shared blob store instead of containing them
+const DeltaSuffix = ".delta.json"
+
+// SplitSuffix is appended to the archive name to name the manifest of an archive stored in parts, because it is
+// larger than the storage takes as a single file. The parts are named after the archive by SplitPartName.
+const SplitSuffix = ".split.json"
+
+// splitPartPattern matches the names of the parts of an archive stored in parts
+var splitPartPattern = regexp.MustCompile(`^(.+)\.part\d{3,}$`)
+
+// SplitPartName returns the name of a part of an archive stored in parts, numbered from 1
+func SplitPartName(archivePath string, part int) string {
+	return fmt.Sprintf("%s.part%03d", archivePath, part)
+}
+
+// SplitPartOf returns the archive a part named by SplitPartName belongs to, or an empty string for other files
+func SplitPartOf(filePath string) string {
+	match := splitPartPattern.FindStringSubmatch(filePath)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// IsIndexName reports whether a file stands for an archive stored in pieces: the recipe of a delta export or the
+// manifest of an archive stored in parts
+func IsIndexName(filePath string) bool {
+	lowerPath := strings.ToLower(filePath)
+	return strings.HasSuffix(lowerPath, DeltaSuffix) || strings.HasSuffix(lowerPath, SplitSuffix)
+}
+
+// TrimIndexSuffix returns the archive a delta recipe or split manifest stands for, and other paths as they are
+func TrimIndexSuffix(filePath string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(filePath, DeltaSuffix), SplitSuffix)
+}
+
+// ArchiveFields are the values available to the file name template
+type ArchiveFields struct {
+	// Name is the repository encoded with EncodeName, usable as a single path element
+	Name string
+	// Repository is the repository as is but for its '/' characters, which are percent-encoded ("%2F") so that it
+	// stays a single path element and can be parsed back
+	Repository string
+	Tag        string
+	OS         string
+	Arch       string
+	// Digest is the short content address of the image, e.g. "sha256-ab12cd34ef56"
+	Digest string
+}
+
+var (
+	// nameTemplate renders the file names of exported archives
+	nameTemplate = template.Must(template.New("name").Parse(DefaultNameTemplate))
+	// namePattern recognizes file names produced by nameTemplate; nil if the template cannot be parsed back
+	namePattern = compileNamePattern(DefaultNameTemplate)
+	// nameDepth is the number of path elements a rendered file name consists of
+	nameDepth = 1
+	// nameHasDigest is set when nameTemplate references the image digest
+	nameHasDigest = false
+)
+
+// templateField matches a plain field reference such as {{.Tag}} or {{ .Arch }}
+var templateField = regexp.MustCompile(`\{\{\s*\.(\w+)\s*\}\}`)
+
+// fieldPatterns are the expressions matching each field when parsing a file name back. OS and Arch never
+// contain '_', which keeps the default template unambiguous for tags that do.
+var fieldPatterns = map[string]string{
+	"Name":       `[^/]+?`,
+	"Repository": `[^/]+?`,
+	"Tag":        `[^/]+?`,
+	"OS":         `[^/_]+`,
+	"Arch":       `[^/_]+`,
+	"Digest":     `sha256-[0-9a-f]+|unknown`,
+}
+
+// digestField is inserted by WithDigest before the extension of a name template
+const digestField = "_{{.Digest}}"
+
+// SetNameTemplate sets the Go template used to name exported archives, e.g. "{{.Name}}/{{.Tag}}/{{.Arch}}.tar".
+// '/' in the rendered name creates subdirectories. An empty text restores DefaultNameTemplate.
+func SetNameTemplate(text string) error {
+	if text == "" {
+		text = DefaultNameTemplate
+	}
+
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid name template: %v", err)
+	}
+	// Render a sample to catch unknown fields early
+	if err := tmpl.Execute(&bytes.Buffer{}, ArchiveFields{}); err != nil {
+		return fmt.Errorf("invalid name template: %v", err)
+	}
+
+	nameTemplate = tmpl
+	namePattern = compileNamePattern(text)
+	nameDepth = strings.Count(text, "/") + 1
+	nameHasDigest = strings.Contains(text, ".Digest")
+	return nil
+}
+
+// NameDepth returns the number of path elements of the archive names produced by the name template
+func NameDepth() int {
+	return nameDepth
+}
+
+// WithDigest returns the name template with the image digest appended to the file name, before the extension:
+// "{{.Name}}_{{.Tag}}_{{.OS}}_{{.Arch}}.tar" becomes "{{.Name}}_{{.Tag}}_{{.OS}}_{{.Arch}}_{{.Digest}}.tar"
+func WithDigest(text string) string {
+	if text == "" {
+		text = DefaultNameTemplate
+	}
+	if strings.Contains(text, ".Digest") {
+		return text
+	}
+	if base, ok := strings.CutSuffix(text, ".tar"); ok {
+		return base + digestField + ".tar"
+	}
+	return text + digestField
+}
+
+// NameHasDigest reports whether archive names contain the image digest. Such names identify the image
+// content, so an existing file with the same name can be taken as up to date.
+func NameHasDigest() bool {
+	return nameHasDigest
+}
+
+// ArchiveName renders the relative file name of the archive of an image for the given platform. The image name
+// is split with ParseReference, so registry ports do not end up in the tag, and references pinned by digest are
+// named after the digest instead of their tag; names that are not references, such as short image IDs, are split
+// at the last ':' of their last path element.
+// Missing tag, OS or architecture values are replaced by "latest", "unknown" and "unknown".
+func ArchiveName(imageName, osName, arch, imageID string) (string, error) {
+	repository, tag := imageName, "latest"
+	if ref, err := ParseReference(imageName); err == nil {
+		repository, tag = ref.Repository, ref.archiveTag()
+	} else if i := strings.LastIndex(imageName, ":"); i > strings.LastIndex(imageName, "/") {
+		repository, tag = imageName[:i], imageName[i+1:]
+	}
+	if osName == "" {
+		osName = "unknown"
+	}
+	if arch == "" {
+		arch = "unknown"
+	}
+
+	fields := ArchiveFields{
+		Name:       EncodeName(repository),
+		Repository: strings.ReplaceAll(repository, "/", "%2F"),
+		Tag:        tag,
+		OS:         osName,
+		Arch:       arch,
+		Digest:     "unknown",
+	}
+	if imageID != "" {
+		fields.Digest = "sha256-" + ShortID(imageID)
+	}
+
+	var name bytes.Buffer
+	if err := nameTemplate.Execute(&name, fields); err != nil {
+		return "", err
+	}
+
+	// Keep the archive inside the destination directory
+	cleaned := path.Clean(PortableName(name.String()))
+	if cleaned == "." || path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("name template produced an invalid file name %q", name.String())
+	}
+	return cleaned, nil
+}
+
+// ParseArchiveName recovers the template fields from the path of an archive. Only the trailing path elements
+// produced by the template are considered; compressed archives, delta recipes, split manifests and names numbered
+// or timestamped by --on-conflict are parsed like their .tar counterpart.
+func ParseArchiveName(filePath string) (ArchiveFields, bool) {
+	if namePattern == nil {
+		return ArchiveFields{}, false
+	}
+
+	name := trimConflictSuffix(PlainArchiveName(archiveRelativeName(TrimIndexSuffix(filePath))))
+
+	match := namePattern.FindStringSubmatch(name)
+	if match == nil {
+		return ArchiveFields{}, false
+	}
+
+	var fields ArchiveFields
+	for i, group := range namePattern.SubexpNames() {
+		switch group {
+		case "Name":
+			fields.Name = match[i]
+			fields.Repository = DecodeName(match[i])
+		case "Repository":
+			fields.Repository = DecodeName(match[i])
+			fields.Name = EncodeName(fields.Repository)
+		case "Tag":
+			fields.Tag = match[i]
+		case "OS":
+			fields.OS = match[i]
+		case "Arch":
+			fields.Arch = match[i]
+		case "Digest":
+			fields.Digest = match[i]
+		}
+	}
+	return fields, true
+}
+
+// EncodeName encodes a repository name for use as a single file name element. Every byte other than ASCII
+// letters, digits, '.' and '-' is percent-encoded, so "ghcr.io/my_org/app" becomes "ghcr.io%2Fmy%5Forg%2Fapp".
+// The result contains no '/', no '_' (the separator of the default template) and no characters that need
+// quoting in shells or are invalid on common filesystems, and DecodeName restores the original name.
+func EncodeName(name string) string {
+	var encoded strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '-' {
+			encoded.WriteByte(c)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+	return encoded.String()
+}
+
+// DecodeName reverses EncodeName. Legacy names, which replaced '/' with '·', are decoded as well.
+func DecodeName(name string) string {
+	if strings.Contains(name, "·") {
+		return strings.ReplaceAll(name, "·", "/")
+	}
+	decoded, err := url.PathUnescape(name)
+	if err != nil {
+		return name
+	}
+	return decoded
+}
+
+// windowsReserved are the device names Windows does not accept as file names, with or without an extension
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// PortableName makes a '/'-separated relative file name valid on Windows as well as Unix, so that archives can be
+// written on one platform and imported on another. Characters Windows rejects, such as the ':' of a registry
+// port in {{.Repository}}, are percent-encoded like EncodeName does, and so are trailing dots and spaces and the
+// first letter of reserved device names such as "con". DecodeName restores the original.
+func PortableName(name string) string {
+	elements := strings.Split(name, "/")
+	for i, element := range elements {
+		var encoded strings.Builder
+		for j := 0; j < len(element); j++ {
+			c := element[j]
+			if c < 0x20 || strings.IndexByte(`<>:"\|?*`, c) >= 0 {
+				fmt.Fprintf(&encoded, "%%%02X", c)
+			} else {
+				encoded.WriteByte(c)
+			}
+		}
+		element = encoded.String()
+
+		// Windows drops trailing dots and spaces; "." and ".." are left for the caller to reject
+		if element != "." && element != ".." {
+			trimmed := strings.TrimRight(element, ". ")
+			for _, c := range []byte(element[len(trimmed):]) {
+				trimmed += fmt.Sprintf("%%%02X", c)
+			}
+			element = trimmed
+		}
+
+		base, _, _ := strings.Cut(element, ".")
+		if windowsReserved[strings.ToUpper(base)] {
+			element = fmt.Sprintf("%%%02X", element[0]) + element[1:]
+		}
+		elements[i] = element
+	}
+	return strings.Join(elements, "/")
+}
+
+// archiveRelativeName returns the trailing path elements of filePath that the name template produces. Every
+// field renders as a single element, since the '/' of {{.Name}} and {{.Repository}} are percent-encoded.
+func archiveRelativeName(filePath string) string {
+	filePath = strings.ReplaceAll(filePath, "\\", "/")
+	elements := strings.Split(filePath, "/")
+	if len(elements) > nameDepth {
+		elements = elements[len(elements)-nameDepth:]
+	}
+	return strings.Join(elements, "/")
+}
+
+// compileNamePattern turns a name template made of plain field references into a regular expression that
+// parses rendered names back. It returns nil for templates using other actions.
+func compileNamePattern(text string) *regexp.Regexp {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+
+	seen := make(map[string]bool)
+	rest := text
+	for {
+		loc := templateField.FindStringSubmatchIndex(rest)
+		if loc == nil {
+			break
+		}
+		literal := rest[:loc[0]]
+		if strings.Contains(literal, "{{") {
+			return nil
+		}
+		pattern.WriteString(regexp.QuoteMeta(literal))
+
+		field := rest[loc[2]:loc[3]]
+		fieldPattern, ok := fieldPatterns[field]
+		if !ok {
+			return nil
+		}
+		// A field used twice is only captured the first time
+		if seen[field] {
+			pattern.WriteString("(?:" + fieldPattern + ")")
+		} else {
+			pattern.WriteString("(?P<" + field + ">" + fieldPattern + ")")
+			seen[field] = true
+		}
+		rest = rest[loc[1]:]
+	}
+	if strings.Contains(rest, "{{") {
+		return nil
+	}
+	// Names exported with WithDigest are recognized even if the template does not mention the digest
+	if !seen["Digest"] {
+		if base, ok := strings.CutSuffix(rest, ".tar"); ok {
+			pattern.WriteString(regexp.QuoteMeta(base))
+			pattern.WriteString("(?:_(?P<Digest>" + fieldPatterns["Digest"] + "))?")
+			rest = ".tar"
+		}
+	}
+	pattern.WriteString(regexp.QuoteMeta(rest))
+	pattern.WriteString("$")
+
+	return regexp.MustCompile(pattern.String())
+}
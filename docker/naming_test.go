@@ -0,0 +1,151 @@
+package docker
+
+import "testing"
+
+// useNameTemplate sets the name template for the duration of a test
+func useNameTemplate(t *testing.T, text string) {
+	t.Helper()
+	if err := SetNameTemplate(text); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetNameTemplate("") })
+}
+
+func TestArchiveNameRoundTrip(t *testing.T) {
+	tests := []struct {
+		template   string
+		image      string
+		want       string
+		repository string
+		tag        string
+	}{
+		{"", "nginx:1.27", "nginx_1.27_linux_amd64.tar", "nginx", "1.27"},
+		{"", "library/nginx:1.27", "nginx_1.27_linux_amd64.tar", "nginx", "1.27"},
+		{"", "myorg/app:v1", "myorg%2Fapp_v1_linux_amd64.tar", "myorg/app", "v1"},
+		{"", "ghcr.io/org/app:1.0", "ghcr.io%2Forg%2Fapp_1.0_linux_amd64.tar", "ghcr.io/org/app", "1.0"},
+		{"", "localhost:5000/my_app:dev_1", "localhost%3A5000%2Fmy%5Fapp_dev_1_linux_amd64.tar", "localhost:5000/my_app", "dev_1"},
+		{"{{.Name}}/{{.Tag}}/{{.Arch}}.tar", "ghcr.io/org/app:1.0", "ghcr.io%2Forg%2Fapp/1.0/amd64.tar", "ghcr.io/org/app", "1.0"},
+		{"{{.Repository}}/{{.Tag}}.tar", "myorg/app:v1", "myorg%2Fapp/v1.tar", "myorg/app", "v1"},
+		{"{{.Repository}}/{{.Tag}}.tar", "ghcr.io/org/team/app:1.0", "ghcr.io%2Forg%2Fteam%2Fapp/1.0.tar", "ghcr.io/org/team/app", "1.0"},
+		{"{{.Repository}}/{{.Tag}}.tar", "localhost:5000/app:1.0", "localhost%3A5000%2Fapp/1.0.tar", "localhost:5000/app", "1.0"},
+		{"{{.Repository}}-{{.Tag}}.tar", "nginx", "nginx-latest.tar", "nginx", "latest"},
+	}
+	for _, test := range tests {
+		t.Run(test.template+" "+test.image, func(t *testing.T) {
+			useNameTemplate(t, test.template)
+
+			name, err := ArchiveName(test.image, "linux", "amd64", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != test.want {
+				t.Errorf("ArchiveName = %q, want %q", name, test.want)
+			}
+
+			// The name is parsed back wherever the archive is stored
+			fields, ok := ParseArchiveName("/docker-images/2024/" + name)
+			if !ok {
+				t.Fatalf("ParseArchiveName(%q) failed", name)
+			}
+			if fields.Repository != test.repository || fields.Tag != test.tag {
+				t.Errorf("ParseArchiveName(%q) = %q:%q, want %q:%q", name, fields.Repository, fields.Tag, test.repository, test.tag)
+			}
+		})
+	}
+}
+
+func TestArchiveNameDigest(t *testing.T) {
+	useNameTemplate(t, WithDigest(""))
+
+	name, err := ArchiveName("nginx:1.27", "linux", "arm64", "sha256:0123456789abcdef0123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "nginx_1.27_linux_arm64_sha256-0123456789ab.tar"; name != want {
+		t.Errorf("ArchiveName = %q, want %q", name, want)
+	}
+	fields, ok := ParseArchiveName(name + ".gz")
+	if !ok || fields.Digest != "sha256-0123456789ab" || fields.Arch != "arm64" {
+		t.Errorf("ParseArchiveName = %+v, %v", fields, ok)
+	}
+}
+
+func TestArchiveNameInvalid(t *testing.T) {
+	useNameTemplate(t, "../{{.Name}}.tar")
+	if name, err := ArchiveName("nginx", "linux", "amd64", ""); err == nil {
+		t.Errorf("ArchiveName escaped the destination: %q", name)
+	}
+}
+
+func TestSetNameTemplateInvalid(t *testing.T) {
+	for _, text := range []string{"{{.Name", "{{.Unknown}}.tar"} {
+		if err := SetNameTemplate(text); err == nil {
+			SetNameTemplate("")
+			t.Errorf("SetNameTemplate(%q) accepted", text)
+		}
+	}
+}
+
+func TestEncodeName(t *testing.T) {
+	tests := []struct {
+		name, encoded string
+	}{
+		{"nginx", "nginx"},
+		{"my-app.v2", "my-app.v2"},
+		{"mycompany/myapp", "mycompany%2Fmyapp"},
+		{"localhost:5000/my_app", "localhost%3A5000%2Fmy%5Fapp"},
+		{"ghcr.io/my_org/app", "ghcr.io%2Fmy%5Forg%2Fapp"},
+	}
+	for _, test := range tests {
+		if got := EncodeName(test.name); got != test.encoded {
+			t.Errorf("EncodeName(%q) = %q, want %q", test.name, got, test.encoded)
+		}
+		if got := DecodeName(test.encoded); got != test.name {
+			t.Errorf("DecodeName(%q) = %q, want %q", test.encoded, got, test.name)
+		}
+	}
+}
+
+func TestDecodeName(t *testing.T) {
+	tests := []struct {
+		encoded, name string
+	}{
+		// Names of older versions replaced '/' with '·'
+		{"mycompany·myapp", "mycompany/myapp"},
+		{"registry:5000·team·app", "registry:5000/team/app"},
+		// Names that are not valid percent-encoding are kept
+		{"odd%zzname", "odd%zzname"},
+	}
+	for _, test := range tests {
+		if got := DecodeName(test.encoded); got != test.name {
+			t.Errorf("DecodeName(%q) = %q, want %q", test.encoded, got, test.name)
+		}
+	}
+}
+
+func TestParseLegacyArchiveName(t *testing.T) {
+	fields, ok := ParseArchiveName("/docker-images/mycompany·myapp_1.0_linux_amd64.tar")
+	if !ok {
+		t.Fatal("legacy name not parsed")
+	}
+	if fields.Repository != "mycompany/myapp" || fields.Tag != "1.0" || fields.OS != "linux" || fields.Arch != "amd64" {
+		t.Errorf("ParseArchiveName = %+v", fields)
+	}
+}
+
+func TestPortableName(t *testing.T) {
+	tests := []struct {
+		name, portable string
+	}{
+		{"nginx_1.27_linux_amd64.tar", "nginx_1.27_linux_amd64.tar"},
+		{"localhost:5000/app.tar", "localhost%3A5000/app.tar"},
+		{"app/1.0./x.tar", "app/1.0%2E/x.tar"},
+		{"con/nul.tar", "%63on/%6Eul.tar"},
+		{"a|b?.tar", "a%7Cb%3F.tar"},
+	}
+	for _, test := range tests {
+		if got := PortableName(test.name); got != test.portable {
+			t.Errorf("PortableName(%q) = %q, want %q", test.name, got, test.portable)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/baowuhe/go-dkci/state"
+)
+
+// Outdated lists the local images matching filter that the next export would transfer: those whose last export
+// in the state database holds another image than the one their name refers to now, and those never exported.
+// With a location, only the exports to it count, and the archives promoted into it: a local directory, or a cloud
+// folder as named by cloud.Location.
+func Outdated(filter Filter, location string) {
+	cli, err := NewClient("")
+	if err != nil {
+		i18n.Printf("[x] Failed to create Docker client: %v\n", err)
+		os.Exit(1)
+	}
+	defer cli.Close()
+	printOutdated(cli, filter, location)
+}
+
+// printOutdated prints the table of Outdated for the images of cli
+func printOutdated(cli ImageClient, filter Filter, location string) {
+	records, err := state.Records()
+	if err != nil {
+		i18n.Printf("[x] %v\n", err)
+		os.Exit(1)
+	}
+
+	// The last export of every image, by its normalized reference; skipped exports found it up to date then, and
+	// promoted archives hold it in their new folder
+	lastExports := make(map[string]state.Record)
+	for _, record := range records {
+		if record.Operation != state.Export && record.Operation != state.Promote || record.Result == state.Failed {
+			continue
+		}
+		if location != "" && !underLocation(record.Location, location) {
+			continue
+		}
+		name, err := NormalizeReference(record.Image)
+		if err != nil {
+			name = record.Image
+		}
+		lastExports[name] = record
+	}
+
+	entries := ListImageEntries(cli, filter)
+	var stale []ImageEntry
+	var size int64
+	for _, entry := range entries {
+		name, err := NormalizeReference(entry.Name)
+		if err != nil {
+			name = entry.Name
+		}
+		if record, ok := lastExports[name]; ok && record.ImageID == entry.ID {
+			continue
+		}
+		stale = append(stale, entry)
+		size += entry.Size
+	}
+
+	if len(stale) == 0 {
+		i18n.Printf("[√] All %d images are up to date\n", len(entries))
+		return
+	}
+
+	nameWidth := len("IMAGE")
+	for _, entry := range stale {
+		nameWidth = max(nameWidth, len(entry.Name))
+	}
+	fmt.Printf("%-*s  %-14s  %-12s  %-12s  %10s  %s\n", nameWidth, "IMAGE", "STATUS", "ID", "EXPORTED ID", "SIZE", "LAST EXPORT")
+	for _, entry := range stale {
+		name, err := NormalizeReference(entry.Name)
+		if err != nil {
+			name = entry.Name
+		}
+		status, exportedID, lastExport := "never exported", "-", "-"
+		if record, ok := lastExports[name]; ok {
+			status, lastExport = "outdated", record.Time.Local().Format("2006-01-02 15:04")+"  "+record.Location
+			if record.ImageID != "" {
+				exportedID = ShortID(record.ImageID)
+			}
+		}
+		fmt.Printf("%-*s  %-14s  %-12s  %-12s  %10s  %s\n", nameWidth, entry.Name, status, ShortID(entry.ID), exportedID, FormatSize(entry.Size), lastExport)
+	}
+	fmt.Println()
+	i18n.Printf("%d of %d images would be exported (%s)\n", len(stale), len(entries), FormatSize(size))
+}
+
+// underLocation reports whether a location recorded in the state database lies in the directory or cloud folder
+// dir
+func underLocation(recorded, dir string) bool {
+	dir = strings.TrimRight(dir, `/\`)
+	rest, ok := strings.CutPrefix(recorded, dir)
+	return ok && (strings.HasPrefix(rest, "/") || strings.HasPrefix(rest, string(filepath.Separator)))
+}
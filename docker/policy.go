@@ -0,0 +1,268 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/baowuhe/go-dkci/job"
+)
+
+// The decisions of export policies, from the most to the least permissive
+const (
+	PolicyAllow   = "allow"
+	PolicyWarn    = "warn"
+	PolicyConfirm = "confirm"
+	PolicyBlock   = "block"
+)
+
+// policyStrictness orders the decisions, so that the strictest of several applies
+var policyStrictness = map[string]int{PolicyAllow: 0, PolicyWarn: 1, PolicyConfirm: 2, PolicyBlock: 3}
+
+// PolicyInput describes an image about to be exported, as policies see it. It is written to the stdin of the
+// policy command as JSON.
+type PolicyInput struct {
+	Image   string            `json:"image"`
+	ImageID string            `json:"image_id,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Size    int64             `json:"size"`
+	OS      string            `json:"os,omitempty"`
+	Arch    string            `json:"arch,omitempty"`
+	// Destination is the local directory or the cloud location, such as bdfs:/production, the image goes to
+	Destination string `json:"destination"`
+	// Secrets are the files the secret scan found, if enabled
+	Secrets []SecretFinding `json:"secrets,omitempty"`
+}
+
+// PolicyDecision is what a policy decided for an image, and why
+type PolicyDecision struct {
+	Decision string `json:"decision"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// policyRule is a [[policy]] table of the configuration file, compiled
+type policyRule struct {
+	name        string
+	image       *regexp.Regexp
+	destination *regexp.Regexp
+	labels      []string
+	minSize     int64
+	decision    string
+}
+
+var (
+	// policyRules are the rules of the configuration file, in their order
+	policyRules []policyRule
+	// policyCommand is the command deciding on every export, such as an OPA query; empty runs none
+	policyCommand string
+)
+
+// SetPolicy sets the rules and the command export policies consist of, usually from config.GetPolicy, and rejects
+// rules that are not valid
+func SetPolicy(policy config.Policy) error {
+	rules := make([]policyRule, 0, len(policy.Rules))
+	for i, rule := range policy.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("policy %d", i+1)
+		}
+		compiled := policyRule{name: name, labels: rule.Labels, decision: rule.Decision}
+		if _, ok := policyStrictness[rule.Decision]; !ok || rule.Decision == PolicyAllow {
+			return fmt.Errorf("%s: invalid decision %q (expected warn, confirm or block)", name, rule.Decision)
+		}
+		var err error
+		if compiled.image, err = compilePolicyPattern(rule.Image); err != nil {
+			return fmt.Errorf("%s: invalid image pattern %q: %v", name, rule.Image, err)
+		}
+		if compiled.destination, err = compilePolicyPattern(rule.Destination); err != nil {
+			return fmt.Errorf("%s: invalid destination pattern %q: %v", name, rule.Destination, err)
+		}
+		if compiled.minSize, err = ParseSize(rule.MinSize); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		rules = append(rules, compiled)
+	}
+	policyRules, policyCommand = rules, policy.Command
+	return nil
+}
+
+// compilePolicyPattern compiles a pattern of a policy rule to match whole values; an empty pattern matches all
+func compilePolicyPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// matches reports whether an export meets all the conditions of the rule
+func (r policyRule) matches(input PolicyInput) bool {
+	if r.image != nil && !slices.ContainsFunc(referenceForms(input.Image), r.image.MatchString) {
+		return false
+	}
+	if r.destination != nil && !r.destination.MatchString(input.Destination) {
+		return false
+	}
+	for _, label := range r.labels {
+		key, value, hasValue := strings.Cut(label, "=")
+		actual, ok := input.Labels[key]
+		if !ok || hasValue && actual != value {
+			return false
+		}
+	}
+	return input.Size >= r.minSize
+}
+
+// EvaluatePolicy returns the strictest decision of the rules matching an export and of the policy command, or
+// PolicyAllow without any. A command that fails to run or decide blocks the export, so that a broken policy never
+// lets images out.
+func EvaluatePolicy(input PolicyInput) PolicyDecision {
+	decision := PolicyDecision{Decision: PolicyAllow}
+	for _, rule := range policyRules {
+		if rule.matches(input) && policyStrictness[rule.decision] > policyStrictness[decision.Decision] {
+			decision = PolicyDecision{Decision: rule.decision, Reason: rule.name}
+		}
+	}
+	if policyCommand == "" {
+		return decision
+	}
+
+	commandDecision, err := runPolicyCommand(input)
+	if err != nil {
+		commandDecision = PolicyDecision{Decision: PolicyBlock, Reason: err.Error()}
+	}
+	if policyStrictness[commandDecision.Decision] > policyStrictness[decision.Decision] {
+		decision = commandDecision
+	}
+	return decision
+}
+
+// runPolicyCommand runs the policy command through the shell with the input on its stdin. The command prints a
+// decision as JSON, such as {"decision":"block","reason":"dev image"}; printing nothing allows the export.
+func runPolicyCommand(input PolicyInput) (PolicyDecision, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return PolicyDecision{}, err
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", policyCommand)
+	} else {
+		cmd = exec.Command("sh", "-c", policyCommand)
+	}
+	cmd.Env = append(os.Environ(), "DKCI_IMAGE="+input.Image, "DKCI_DESTINATION="+input.Destination)
+	cmd.Stdin = bytes.NewReader(append(data, '\n'))
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return PolicyDecision{}, fmt.Errorf("policy command failed: %v", err)
+	}
+
+	output = bytes.TrimSpace(output)
+	if len(output) == 0 {
+		return PolicyDecision{Decision: PolicyAllow}, nil
+	}
+	var decision PolicyDecision
+	if err := json.Unmarshal(output, &decision); err != nil {
+		return PolicyDecision{}, fmt.Errorf("policy command printed an invalid decision: %v", err)
+	}
+	if _, ok := policyStrictness[decision.Decision]; !ok {
+		return PolicyDecision{}, fmt.Errorf("policy command printed an unknown decision %q", decision.Decision)
+	}
+	return decision, nil
+}
+
+// ApplyPolicies scans every image about to be exported to destination for secrets if enabled, evaluates the
+// export policies for it, and returns the images let through. Blocked images are left out, and so are images in
+// which secrets were found with --block-on-secrets; images needing confirmation are asked about on a terminal and
+// left out without one, as --yes only skips the size confirmation. Resumed and background jobs were confirmed
+// when they started.
+func ApplyPolicies(cli ImageClient, imageNames []string, destination string) []string {
+	if len(policyRules) == 0 && policyCommand == "" && !scanSecrets {
+		return imageNames
+	}
+
+	allowed := make([]string, 0, len(imageNames))
+	for _, imageName := range imageNames {
+		input := PolicyInput{Image: imageName, Destination: destination}
+		ctx, cancel := CallContext()
+		imageInspect, _, err := cli.ImageInspectWithRaw(ctx, imageName)
+		cancel()
+		if err == nil {
+			input.ImageID, input.Size, input.OS, input.Arch = imageInspect.ID, imageInspect.Size, imageInspect.Os, imageInspect.Architecture
+			if imageInspect.Config != nil {
+				input.Labels = imageInspect.Config.Labels
+			}
+		}
+
+		if scanSecrets {
+			fmt.Printf("Scanning %s for secrets...\n", imageName)
+			findings, err := ScanSecrets(cli, imageName)
+			recordSecretScan(imageName, findings, err)
+			if err != nil && blockOnSecrets {
+				i18n.Printf("[x] %v\n", err)
+				continue
+			}
+			if err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+			for _, finding := range findings {
+				fmt.Printf("Warning: %s may contain a secret: %s\n", imageName, finding)
+			}
+			if len(findings) > 0 && blockOnSecrets {
+				i18n.Printf("[x] Not exporting %s, %d file(s) may contain secrets\n", imageName, len(findings))
+				continue
+			}
+			input.Secrets = findings
+		}
+
+		decision := EvaluatePolicy(input)
+		switch decision.Decision {
+		case PolicyBlock:
+			i18n.Printf("[x] Policy blocks the export of %s to %s: %s\n", imageName, destination, decision.Reason)
+			continue
+		case PolicyConfirm:
+			if !confirmPolicy(imageName, destination, decision.Reason) {
+				continue
+			}
+		case PolicyWarn:
+			fmt.Printf("Warning: policy flags the export of %s to %s: %s\n", imageName, destination, decision.Reason)
+		}
+		allowed = append(allowed, imageName)
+	}
+	return allowed
+}
+
+// confirmPolicy asks whether to export an image a policy wants confirmed. Jobs run by the background worker or
+// resumed have nobody who confirmed them, so they are refused like commands without a terminal.
+func confirmPolicy(imageName, destination, reason string) bool {
+	if job.Resuming() {
+		i18n.Printf("[x] Policy requires confirming the export of %s to %s (%s), which a detached or resumed job cannot ask for\n", imageName, destination, reason)
+		return false
+	}
+	if !Interactive() {
+		i18n.Printf("[x] Policy requires confirming the export of %s to %s (%s), which needs a terminal\n", imageName, destination, reason)
+		return false
+	}
+	confirmed := false
+	prompt := &survey.Confirm{
+		Message: i18n.Sprintf("Policy requires confirmation (%s). Export %s to %s?", reason, imageName, destination),
+	}
+	if err := survey.AskOne(prompt, &confirmed); err != nil {
+		i18n.Printf("[x] Failed to get user input: %v\n", err)
+		os.Exit(1)
+	}
+	if !confirmed {
+		i18n.Printf("[x] Export of %s not confirmed\n", imageName)
+	}
+	return confirmed
+}
@@ -0,0 +1,89 @@
+package docker
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/baowuhe/go-dkci/config"
+)
+
+func TestEvaluatePolicy(t *testing.T) {
+	t.Cleanup(func() { SetPolicy(config.Policy{}) })
+	err := SetPolicy(config.Policy{Rules: []config.PolicyRule{
+		{Name: "no dev images in production", Destination: `.*:/production(/.*)?`, Labels: []string{"env=dev"}, Decision: PolicyBlock},
+		{Name: "large images", MinSize: "1GB", Decision: PolicyConfirm},
+		{Name: "latest tags", Image: `.*:latest`, Decision: PolicyWarn},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev := map[string]string{"env": "dev"}
+	tests := []struct {
+		input PolicyInput
+		want  string
+	}{
+		{PolicyInput{Image: "app:1", Labels: dev, Destination: "bdfs:/production"}, PolicyBlock},
+		{PolicyInput{Image: "app:1", Labels: dev, Destination: "bdfs:/staging"}, PolicyAllow},
+		{PolicyInput{Image: "app:1", Labels: map[string]string{"env": "prod"}, Destination: "bdfs:/production/app"}, PolicyAllow},
+		{PolicyInput{Image: "app:1", Size: 2 << 30, Destination: "/tmp/out"}, PolicyConfirm},
+		{PolicyInput{Image: "nginx", Destination: "/tmp/out"}, PolicyWarn},
+		{PolicyInput{Image: "nginx", Labels: dev, Size: 2 << 30, Destination: "bdfs:/production"}, PolicyBlock},
+	}
+	for _, test := range tests {
+		if got := EvaluatePolicy(test.input); got.Decision != test.want {
+			t.Errorf("EvaluatePolicy(%+v) = %+v, want %s", test.input, got, test.want)
+		}
+	}
+
+	for _, rule := range []config.PolicyRule{
+		{Decision: "deny"},
+		{Decision: PolicyAllow},
+		{Image: "app:[", Decision: PolicyBlock},
+		{MinSize: "big", Decision: PolicyBlock},
+	} {
+		if err := SetPolicy(config.Policy{Rules: []config.PolicyRule{rule}}); err == nil {
+			t.Errorf("SetPolicy accepted %+v", rule)
+		}
+	}
+}
+
+func TestPolicyCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test commands need sh")
+	}
+	t.Cleanup(func() { SetPolicy(config.Policy{}) })
+
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{`true`, PolicyAllow},
+		{`grep -q '"image":"app:1"' && echo '{"decision":"confirm","reason":"release"}'`, PolicyConfirm},
+		{`echo '{"decision":"warn"}'`, PolicyWarn},
+		{`echo nonsense`, PolicyBlock},
+		{`echo '{"decision":"maybe"}'`, PolicyBlock},
+		{`exit 1`, PolicyBlock},
+	}
+	for _, test := range tests {
+		if err := SetPolicy(config.Policy{Command: test.command}); err != nil {
+			t.Fatal(err)
+		}
+		if got := EvaluatePolicy(PolicyInput{Image: "app:1", Destination: "/tmp/out"}); got.Decision != test.want {
+			t.Errorf("%s: EvaluatePolicy = %+v, want %s", test.command, got, test.want)
+		}
+	}
+}
+
+func TestConfirmPolicyDetachedJob(t *testing.T) {
+	if err := SetPolicy(config.Policy{Rules: []config.PolicyRule{{Name: "release", Decision: PolicyConfirm}}}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetPolicy(config.Policy{}) })
+
+	// The background worker and resume run the command with the id of its job; nobody confirmed it
+	t.Setenv("DKCI_JOB_ID", "20261016-020000-ab12")
+	if allowed := ApplyPolicies(NewFakeClient(testImages...), []string{"nginx:1.27"}, "/tmp/out"); len(allowed) != 0 {
+		t.Errorf("ApplyPolicies let %q through without a confirmation", allowed)
+	}
+}
@@ -0,0 +1,90 @@
+package docker
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// progressBarWidth is the number of characters of the bar drawn for the layer being loaded
+const progressBarWidth = 30
+
+// loadProgress renders the layer progress messages of docker load. On a terminal the progress of the current layer
+// is redrawn in place on a single line; otherwise, as in logs and concurrent loads, every layer is reported once
+// when it has been loaded.
+type loadProgress struct {
+	out      io.Writer
+	label    string
+	terminal bool
+	// drawn is set while the progress line is on screen without a line break
+	drawn bool
+	// reported holds the layers already reported when not on a terminal
+	reported map[string]bool
+}
+
+// newLoadProgress returns a renderer writing to stdout. label names the archive in the lines when several loads
+// may run at once; the progress is only redrawn in place when loads run one after the other.
+func newLoadProgress(label string) *loadProgress {
+	concurrent := loadConcurrency > 1
+	if !concurrent {
+		label = ""
+	}
+	return &loadProgress{
+		out:      os.Stdout,
+		label:    label,
+		terminal: !concurrent && isTerminal(os.Stdout),
+		reported: make(map[string]bool),
+	}
+}
+
+// update renders a progress message; messages without a layer size are ignored
+func (p *loadProgress) update(message *jsonmessage.JSONMessage) {
+	if message.Progress == nil || message.Progress.Total <= 0 {
+		return
+	}
+	current, total := message.Progress.Current, message.Progress.Total
+
+	if p.terminal {
+		filled := int(float64(min(current, total)) / float64(total) * progressBarWidth)
+		bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+		fmt.Fprintf(p.out, "\r\033[K%s%s %s [%s] %s/%s", p.prefix(), message.Status, ShortID(message.ID), bar, FormatSize(current), FormatSize(total))
+		p.drawn = true
+		return
+	}
+
+	if current >= total && !p.reported[message.ID] {
+		p.reported[message.ID] = true
+		fmt.Fprintf(p.out, "%sLoaded layer %s (%s)\n", p.prefix(), ShortID(message.ID), FormatSize(total))
+	}
+}
+
+// done ends the progress line so that the following output starts on a line of its own
+func (p *loadProgress) done() {
+	if p.drawn {
+		fmt.Fprintln(p.out)
+		p.drawn = false
+	}
+}
+
+// loaded reports an image as soon as the daemon names it, ending the progress line first
+func (p *loadProgress) loaded(ref string) {
+	p.done()
+	fmt.Fprintf(p.out, "%sLoaded %s\n", p.prefix(), ref)
+}
+
+// prefix returns the label of the lines, if any
+func (p *loadProgress) prefix() string {
+	if p.label == "" {
+		return ""
+	}
+	return "[" + p.label + "] "
+}
+
+// isTerminal reports whether a file is a terminal rather than a pipe or a regular file
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,113 @@
+package docker
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/distribution/reference"
+)
+
+// Reference is an image reference split into its parts, as Docker shows them: docker.io/library/nginx is nginx
+type Reference struct {
+	// Repository is the repository with its registry, including any port, such as registry:5000/app
+	Repository string
+	// Tag is the tag, empty if the reference has none
+	Tag string
+	// Digest is the sha256:<hex> content address, empty if the reference has none
+	Digest string
+}
+
+// ParseReference parses and validates an image reference such as nginx, registry:5000/app:1.0 or
+// nginx@sha256:<hex>, normalizing it to the familiar form Docker lists images by
+func ParseReference(name string) (Reference, error) {
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return Reference{}, fmt.Errorf("invalid image reference %s: %v", name, err)
+	}
+	ref := Reference{Repository: reference.FamiliarName(named)}
+	if tagged, ok := named.(reference.Tagged); ok {
+		ref.Tag = tagged.Tag()
+	}
+	if digested, ok := named.(reference.Digested); ok {
+		ref.Digest = digested.Digest().String()
+	}
+	return ref, nil
+}
+
+// String returns the reference in familiar form: repository, then :tag and @digest if set
+func (r Reference) String() string {
+	name := r.Repository
+	if r.Tag != "" {
+		name += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		name += "@" + r.Digest
+	}
+	return name
+}
+
+// NormalizeReference returns a reference in the familiar form Docker lists images by, with ":latest" added if it
+// has neither tag nor digest: docker.io/library/nginx becomes nginx:latest
+func NormalizeReference(name string) (string, error) {
+	ref, err := ParseReference(name)
+	if err != nil {
+		return "", err
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref.String(), nil
+}
+
+// fullReference returns the fully qualified form of a reference, such as docker.io/library/nginx:latest for
+// nginx:latest, or the name itself if it is not a reference
+func fullReference(name string) string {
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return name
+	}
+	return named.String()
+}
+
+// archiveTag returns the tag of the archive name of a reference: its tag, or for a reference pinned by digest the
+// digest with ':' replaced by '-', so that the archive name records exactly which image it holds
+func (r Reference) archiveTag() string {
+	if r.Digest != "" {
+		return strings.ReplaceAll(r.Digest, ":", "-")
+	}
+	if r.Tag != "" {
+		return r.Tag
+	}
+	return "latest"
+}
+
+// SaveReference returns the reference to save an image named by imageName with, given the tags of the image.
+// docker save drops the name of an image saved by digest, so a pinned reference is saved through its own tag or
+// another tag of its repository on the same image, for the archive to restore a name when loaded. Without such a
+// tag the image is saved by digest and loads untagged.
+func SaveReference(tags []string, imageName string) string {
+	ref, err := ParseReference(imageName)
+	if err != nil || ref.Digest == "" {
+		return imageName
+	}
+	if ref.Tag != "" && slices.Contains(tags, ref.Repository+":"+ref.Tag) {
+		return ref.Repository + ":" + ref.Tag
+	}
+	for _, tag := range tags {
+		if tagRef, err := ParseReference(tag); err == nil && tagRef.Repository == ref.Repository {
+			return tag
+		}
+	}
+	return imageName
+}
+
+// pinnedDigest returns the repository digest a reference is pinned to, as Docker lists it (repository@digest,
+// without the tag), or an empty string if it is not pinned
+func pinnedDigest(name string) string {
+	ref, err := ParseReference(name)
+	if err != nil || ref.Digest == "" {
+		return ""
+	}
+	return ref.Repository + "@" + ref.Digest
+}
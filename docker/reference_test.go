@@ -0,0 +1,87 @@
+package docker
+
+import "testing"
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Reference
+		wantErr bool
+	}{
+		{name: "nginx", want: Reference{Repository: "nginx"}},
+		{name: "nginx:1.27", want: Reference{Repository: "nginx", Tag: "1.27"}},
+		{name: "docker.io/library/nginx:1.27", want: Reference{Repository: "nginx", Tag: "1.27"}},
+		{name: "docker.io/myorg/app:v1", want: Reference{Repository: "myorg/app", Tag: "v1"}},
+		{name: "registry:5000/team/app:1.0", want: Reference{Repository: "registry:5000/team/app", Tag: "1.0"}},
+		{name: "nginx@" + testDigest, want: Reference{Repository: "nginx", Digest: testDigest}},
+		{name: "ghcr.io/org/app:1.0@" + testDigest, want: Reference{Repository: "ghcr.io/org/app", Tag: "1.0", Digest: testDigest}},
+		{name: "", wantErr: true},
+		{name: "Nginx:1.27", wantErr: true},
+		{name: "nginx:bad tag", wantErr: true},
+		{name: "nginx@sha256:short", wantErr: true},
+	}
+	for _, test := range tests {
+		ref, err := ParseReference(test.name)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("ParseReference(%q) = %+v, want an error", test.name, ref)
+			}
+			continue
+		}
+		if err != nil || ref != test.want {
+			t.Errorf("ParseReference(%q) = %+v, %v, want %+v", test.name, ref, err, test.want)
+		}
+	}
+}
+
+func TestNormalizeReference(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"nginx", "nginx:latest"},
+		{"docker.io/library/nginx", "nginx:latest"},
+		{"index.docker.io/library/nginx:1.27", "nginx:1.27"},
+		{"registry:5000/app", "registry:5000/app:latest"},
+		{"nginx@" + testDigest, "nginx@" + testDigest},
+		{"nginx:1.27@" + testDigest, "nginx:1.27@" + testDigest},
+	}
+	for _, test := range tests {
+		got, err := NormalizeReference(test.name)
+		if err != nil || got != test.want {
+			t.Errorf("NormalizeReference(%q) = %q, %v, want %q", test.name, got, err, test.want)
+		}
+	}
+	if got, err := NormalizeReference("library/Nginx"); err == nil {
+		t.Errorf("NormalizeReference accepted an invalid reference as %q", got)
+	}
+}
+
+func TestSaveReference(t *testing.T) {
+	tests := []struct {
+		tags      []string
+		imageName string
+		want      string
+	}{
+		{[]string{"nginx:1.27"}, "nginx:1.27", "nginx:1.27"},
+		{[]string{"nginx:1.27", "nginx:latest"}, "nginx:latest@" + testDigest, "nginx:latest"},
+		{[]string{"nginx:1.27"}, "nginx@" + testDigest, "nginx:1.27"},
+		{[]string{"redis:7"}, "nginx@" + testDigest, "nginx@" + testDigest},
+		{nil, "nginx@" + testDigest, "nginx@" + testDigest},
+	}
+	for _, test := range tests {
+		if got := SaveReference(test.tags, test.imageName); got != test.want {
+			t.Errorf("SaveReference(%q, %q) = %q, want %q", test.tags, test.imageName, got, test.want)
+		}
+	}
+}
+
+func TestPinnedDigest(t *testing.T) {
+	if got := pinnedDigest("docker.io/library/nginx:1.27@" + testDigest); got != "nginx@"+testDigest {
+		t.Errorf("pinnedDigest = %q", got)
+	}
+	if got := pinnedDigest("nginx:1.27"); got != "" {
+		t.Errorf("pinnedDigest of a tag = %q", got)
+	}
+}
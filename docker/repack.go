@@ -0,0 +1,200 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/baowuhe/go-dkci/attest"
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/baowuhe/go-dkci/sbom"
+	"github.com/baowuhe/go-dkci/sign"
+)
+
+// RepackLocal recompresses the archives of a local file or directory with the configured compression and level.
+// If fileNames is not empty, exactly those files of the directory are repacked without prompting.
+func RepackLocal(source string, filter Filter, fileNames []string) {
+	fileInfo, err := os.Stat(source)
+	if err != nil {
+		i18n.Printf("[x] Error accessing source: %v\n", err)
+		os.Exit(1)
+	}
+
+	selectedFilePaths := []string{source}
+	if fileInfo.IsDir() {
+		// Files named on the command line are matched without the filter
+		if len(fileNames) > 0 {
+			filter = Filter{}
+		}
+		tarFiles, err := findTarFilesInDirectory(source, filter)
+		if err != nil {
+			i18n.Printf("[x] Error finding .tar files: %v\n", err)
+			os.Exit(1)
+		}
+		if len(tarFiles) == 0 {
+			i18n.Println("[x] No .tar files found in the specified directory")
+			os.Exit(1)
+		}
+
+		if len(fileNames) > 0 {
+			selectedFilePaths = ResolveFiles(tarFiles, fileNames)
+		} else {
+			selectedFilePaths = SelectFiles(tarFiles, "Select .tar files to repack:")
+			if len(selectedFilePaths) == 0 {
+				i18n.Println("[x] No files selected for repacking")
+				os.Exit(1)
+			}
+		}
+	}
+
+	failed := 0
+	for _, filePath := range selectedFilePaths {
+		repackedPath, err := RepackFile(filePath)
+		if err != nil {
+			i18n.Printf("[x] Failed to repack %s: %v\n", filePath, err)
+			failed++
+			continue
+		}
+		if repackedPath != "" {
+			WarnStaleSidecars(filePath, StaleSidecars(filePath))
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// RepackFile rewrites an image archive with the configured compression and level, and returns the path of the
+// result: the archive named with the extension of the new compression, which replaces the original. Archives
+// already in the configured format are left alone, unless a compression level is set; an empty path is returned
+// for them. Password-protected containers are not repacked, as their content could not be sealed again without
+// changing the archive more than the user asked for.
+func RepackFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	if sealedFormat(file) != "" {
+		file.Close()
+		return "", fmt.Errorf("password-protected archives cannot be repacked")
+	}
+	current, err := detectCompression(file)
+	file.Close()
+	if err != nil {
+		return "", err
+	}
+	if current == compression && compressionLevel == 0 {
+		i18n.Printf("[√] %s is already in the requested format\n", filePath)
+		return "", nil
+	}
+
+	repackedPath := CompressedName(PlainArchiveName(filePath))
+	before := fileSize(filePath, current)
+
+	// Write next to the archive, so that the rename replacing it stays on the same filesystem
+	tempPath := repackedPath + ".repack"
+	if err := writeRepacked(filePath, tempPath); err != nil {
+		os.Remove(tempPath)
+		return "", err
+	}
+	if err := os.Rename(tempPath, repackedPath); err != nil {
+		os.Remove(tempPath)
+		return "", err
+	}
+	config.Track(repackedPath)
+	if repackedPath != filePath {
+		if err := os.Remove(filePath); err != nil {
+			return repackedPath, fmt.Errorf("repacked to %s, but failed to remove the original: %v", repackedPath, err)
+		}
+	}
+
+	i18n.Printf("[√] Repacked %s as %s (%s -> %s)\n", filePath, repackedPath, before, fileSize(repackedPath, compression))
+	return repackedPath, nil
+}
+
+// writeRepacked uncompresses an archive and writes it to targetPath with the configured compression
+func writeRepacked(filePath, targetPath string) error {
+	archive, err := OpenArchive(filePath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	outFile, err := os.Create(targetPath)
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+
+	compressor, err := CompressWriter(outFile)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(compressor, archive)
+	// Close the compressor also on failure, so that a compressing process does not wait for more input
+	if closeErr := compressor.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err == nil {
+		err = outFile.Close()
+	}
+	return err
+}
+
+// detectCompression returns the compression of an archive file from its first bytes, "" for plain tar
+func detectCompression(file *os.File) (string, error) {
+	header, err := bufio.NewReader(file).Peek(6)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	for _, m := range compressionMagics {
+		if bytes.HasPrefix(header, m.magic) {
+			return m.compression, nil
+		}
+	}
+	return "", nil
+}
+
+// fileSize describes the size of a file and its compression for the repack summary
+func fileSize(filePath, format string) string {
+	if format == "" {
+		format = "tar"
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return format
+	}
+	return FormatSize(info.Size()) + " " + format
+}
+
+// SidecarFiles returns the names of the signature, attestation, SBOMs and license report that may sit next to
+// an archive
+func SidecarFiles(archivePath string) []string {
+	return []string{sign.SignatureFile(archivePath), attest.File(archivePath), archivePath + ".spdx.json", archivePath + ".cdx.json", sbom.LicenseFile(archivePath)}
+}
+
+// StaleSidecars returns the sidecar files next to a local archive. They were made for its content before it was
+// repacked, and no longer match it.
+func StaleSidecars(archivePath string) []string {
+	var stale []string
+	for _, sidecar := range SidecarFiles(archivePath) {
+		if _, err := os.Stat(sidecar); err == nil {
+			stale = append(stale, sidecar)
+		}
+	}
+	return stale
+}
+
+// WarnStaleSidecars tells the user that the sidecar files of a repacked archive need to be made again
+func WarnStaleSidecars(archivePath string, sidecars []string) {
+	for _, sidecar := range sidecars {
+		fmt.Printf("Warning: %s was made for %s before it was repacked; create it again\n", sidecar, filepath.Base(archivePath))
+	}
+}
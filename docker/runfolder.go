@@ -0,0 +1,92 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/baowuhe/go-dkci/i18n"
+)
+
+// RunFolderLayout names the subfolder of an export run after its start time, such as 2024-06-01T02-00. The names
+// sort in time order and are valid on every file system and in Baidu cloud.
+const RunFolderLayout = "2006-01-02T15-04"
+
+var (
+	// runFolder is the subfolder of the destination or cloud folder exports write to; empty writes to the folder
+	runFolder string
+	// latestRun makes imports from a folder import its latest run folder instead
+	latestRun bool
+)
+
+// RunFolderName returns the name of the run folder of a run started at t
+func RunFolderName(t time.Time) string {
+	return t.Format(RunFolderLayout)
+}
+
+// SetRunFolder makes exports write into the subfolder name of the destination or cloud folder, so that every run
+// of a scheduled export leaves a point-in-time set of its own. An empty name writes to the folder itself.
+func SetRunFolder(name string) error {
+	if name != "" && (name == "." || name == ".." || strings.ContainsAny(name, `/\`)) {
+		return fmt.Errorf("invalid run folder name %q, it must be a single path element", name)
+	}
+	runFolder = name
+	return nil
+}
+
+// RunFolder returns the run folder exports write into, empty for none
+func RunFolder() string {
+	return runFolder
+}
+
+// SetLatestRun makes imports from a folder import the files of its latest run folder
+func SetLatestRun(enabled bool) {
+	latestRun = enabled
+}
+
+// LatestRun reports whether imports from a folder import its latest run folder
+func LatestRun() bool {
+	return latestRun
+}
+
+// LatestRunFolder returns the latest of the folder names that are run folders, those named after their start time
+// with RunFolderLayout, and false if there is none
+func LatestRunFolder(names []string) (string, bool) {
+	var runs []string
+	for _, name := range names {
+		if _, err := time.Parse(RunFolderLayout, name); err == nil {
+			runs = append(runs, name)
+		}
+	}
+	if len(runs) == 0 {
+		return "", false
+	}
+	sort.Strings(runs)
+	return runs[len(runs)-1], true
+}
+
+// latestLocalRun returns the latest run folder of a local directory, exiting if there is none
+func latestLocalRun(dirPath string) string {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		i18n.Printf("[x] Error accessing source: %v\n", err)
+		os.Exit(1)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	name, ok := LatestRunFolder(names)
+	if !ok {
+		i18n.Printf("[x] No run folders found in %s\n", dirPath)
+		os.Exit(1)
+	}
+	runPath := filepath.Join(dirPath, name)
+	fmt.Printf("Importing the latest run %s\n", runPath)
+	return runPath
+}
@@ -0,0 +1,214 @@
+package docker
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// SecretFinding is a file of an image layer that looks like it holds a secret
+type SecretFinding struct {
+	// Layer is the layer archive in the image archive, such as blobs/sha256/4a1c...
+	Layer string `json:"layer"`
+	// Path is the path of the file in the image filesystem
+	Path string `json:"path"`
+	// Kind names what was found, such as "private key"
+	Kind string `json:"kind"`
+}
+
+// String returns the file and what it holds, as shown in warnings
+func (f SecretFinding) String() string {
+	return fmt.Sprintf("/%s (%s)", f.Path, f.Kind)
+}
+
+var (
+	// scanSecrets scans the layers of images for secrets before exporting them
+	scanSecrets bool
+	// blockOnSecrets leaves out the images in which secrets were found instead of warning
+	blockOnSecrets bool
+	// secretScans are the outcomes of the scans of this run by image, for the delivery report
+	secretScans = map[string]string{}
+)
+
+// SetSecretScan makes exports scan the layers of every image for private keys, tokens and credential files
+// before it leaves the host, warning about what is found or, with block set, leaving the image out
+func SetSecretScan(scan, block bool) {
+	scanSecrets, blockOnSecrets = scan || block, block
+}
+
+// recordSecretScan keeps the outcome of the scan of an image for SecretScanStatus
+func recordSecretScan(imageName string, findings []SecretFinding, err error) {
+	switch {
+	case err != nil:
+		secretScans[imageName] = "scan failed"
+	case len(findings) > 0:
+		secretScans[imageName] = fmt.Sprintf("%d possible secret(s)", len(findings))
+	default:
+		secretScans[imageName] = "clean"
+	}
+}
+
+// SecretScanStatus describes the outcome of the secret scan of an image in this run: clean, the number of
+// possible secrets found, scan failed, or not scanned
+func SecretScanStatus(imageName string) string {
+	if status, ok := secretScans[imageName]; ok {
+		return status
+	}
+	return "not scanned"
+}
+
+// maxSecretScanSize is the size up to which the content of files is scanned; larger files are data, not secrets
+const maxSecretScanSize = 1 << 20
+
+// secretFiles name files that hold credentials by their path in the image, matched against its end
+var secretFiles = []struct {
+	re   *regexp.Regexp
+	kind string
+}{
+	{regexp.MustCompile(`(^|/)\.env(\.[^/]+)?$`), "environment file"},
+	{regexp.MustCompile(`(^|/)id_(rsa|dsa|ecdsa|ed25519)$`), "SSH private key"},
+	{regexp.MustCompile(`(^|/)\.aws/credentials$`), "AWS credentials"},
+	{regexp.MustCompile(`(^|/)\.git-credentials$`), "Git credentials"},
+	{regexp.MustCompile(`(^|/)\.netrc$`), "netrc credentials"},
+}
+
+// secretExamples are environment files shipped as templates, without secrets
+var secretExamples = regexp.MustCompile(`\.env\.(example|sample|template|dist)$`)
+
+// secretContents match secrets in the content of files
+var secretContents = []struct {
+	re   *regexp.Regexp
+	kind string
+}{
+	{regexp.MustCompile(`-----BEGIN ((RSA|EC|DSA|OPENSSH|ENCRYPTED|PGP) )?PRIVATE KEY( BLOCK)?-----`), "private key"},
+	{regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`), "AWS access key"},
+	{regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36}\b`), "GitHub token"},
+	{regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20}\b`), "GitLab token"},
+	{regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`), "Slack token"},
+	{regexp.MustCompile(`\bsk_live_[0-9A-Za-z]{24,}`), "Stripe key"},
+	{regexp.MustCompile(`"auths"\s*:\s*\{\s*"[^"]+"\s*:\s*\{\s*"auth"\s*:\s*"[^"]+"`), "registry credentials"},
+	{regexp.MustCompile(`_authToken\s*=\s*\S+`), "npm token"},
+}
+
+// ScanSecrets saves an image from Docker and scans the files of its layers for secrets
+func ScanSecrets(cli ImageClient, imageName string) ([]SecretFinding, error) {
+	ctx, watchdog := StreamContext()
+	defer watchdog.Stop()
+
+	imageReader, err := cli.ImageSave(ctx, []string{imageName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image %s: %v", imageName, watchdog.Err(err))
+	}
+	defer imageReader.Close()
+
+	findings, err := scanArchiveSecrets(watchdog.Reader(imageReader))
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan image %s: %v", imageName, watchdog.Err(err))
+	}
+	return findings, nil
+}
+
+// scanArchiveSecrets scans the layers of an image archive written by docker save. Layers are the entries that
+// are tar archives themselves, plain or gzip-compressed, whatever their names in the legacy or OCI layout.
+func scanArchiveSecrets(r io.Reader) ([]SecretFinding, error) {
+	var findings []SecretFinding
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return findings, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		layer, ok := layerReader(archive)
+		if !ok {
+			continue
+		}
+		layerFindings, err := scanLayerSecrets(header.Name, layer)
+		if err != nil {
+			return nil, fmt.Errorf("layer %s: %v", header.Name, err)
+		}
+		findings = append(findings, layerFindings...)
+	}
+}
+
+// layerReader returns a reader of the tar archive held by an entry of the image archive, and false if the entry
+// is not one, such as the manifest or image configuration
+func layerReader(r io.Reader) (io.Reader, bool) {
+	buffered := bufio.NewReaderSize(r, 4096)
+	magic, _ := buffered.Peek(2)
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		decompressed, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, false
+		}
+		buffered = bufio.NewReaderSize(decompressed, 4096)
+	}
+	header, _ := buffered.Peek(262)
+	if len(header) < 262 || string(header[257:262]) != "ustar" {
+		return nil, false
+	}
+	return buffered, true
+}
+
+// scanLayerSecrets scans the files of a layer archive for secrets, by their path and, up to maxSecretScanSize,
+// their content
+func scanLayerSecrets(layerName string, r io.Reader) ([]SecretFinding, error) {
+	var findings []SecretFinding
+	layer := tar.NewReader(r)
+	for {
+		header, err := layer.Next()
+		if err == io.EOF {
+			return findings, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		filePath := strings.TrimPrefix(path.Clean("/"+header.Name), "/")
+		if header.Typeflag != tar.TypeReg || strings.HasPrefix(path.Base(filePath), ".wh.") {
+			continue
+		}
+
+		if kind := secretFileKind(filePath); kind != "" {
+			findings = append(findings, SecretFinding{Layer: layerName, Path: filePath, Kind: kind})
+			continue
+		}
+		if header.Size > maxSecretScanSize {
+			continue
+		}
+		content, err := io.ReadAll(layer)
+		if err != nil {
+			return nil, err
+		}
+		for _, secret := range secretContents {
+			if secret.re.Match(content) {
+				findings = append(findings, SecretFinding{Layer: layerName, Path: filePath, Kind: secret.kind})
+				break
+			}
+		}
+	}
+}
+
+// secretFileKind returns what a file holds judging by its path, or an empty string for other files
+func secretFileKind(filePath string) string {
+	if secretExamples.MatchString(filePath) {
+		return ""
+	}
+	for _, secret := range secretFiles {
+		if secret.re.MatchString(filePath) {
+			return secret.kind
+		}
+	}
+	return ""
+}
@@ -0,0 +1,444 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/docker/docker/api/types"
+)
+
+// ImageEntry describes a tagged Docker image offered for selection
+type ImageEntry struct {
+	Name    string
+	ID      string
+	Size    int64
+	Created int64
+	// Digests are the repository digests of the image, such as nginx@sha256:<hex>
+	Digests []string
+	// Containers are the containers created from the image, with their state, listed by the delete prompt
+	Containers []string
+}
+
+// FileEntry describes an image archive (local or in the cloud) offered for selection
+type FileEntry struct {
+	Path     string
+	Size     int64
+	Modified int64
+}
+
+// Sort keys accepted by SetSortOrder
+const (
+	SortByName    = "name"
+	SortBySize    = "size"
+	SortByCreated = "created"
+)
+
+var (
+	// sortKey is the key used to order the selection prompts; empty keeps the listing order
+	sortKey string
+	// sortReverse reverses the order of the selection prompts
+	sortReverse bool
+	// selectAll selects every entry of the selection prompts without showing them
+	selectAll bool
+)
+
+// SetSelectAll makes the selection prompts select every listed image or file without asking (--all)
+func SetSelectAll(all bool) {
+	selectAll = all
+}
+
+// Interactive reports whether prompts can be shown, which needs stdin and stdout to be terminals. Under CI or in
+// a pipe, selections must be given on the command line and confirmations are skipped or refused.
+func Interactive() bool {
+	return isTerminal(os.Stdin) && isTerminal(os.Stdout)
+}
+
+// ConfirmDeletion asks the user to type yes before deleting, unless assumeYes is set. Without a terminal there is
+// nobody to ask, so it exits asking for --yes instead.
+func ConfirmDeletion(assumeYes bool) bool {
+	if assumeYes {
+		return true
+	}
+	if !Interactive() {
+		i18n.Println("[x] Cannot ask for confirmation without a terminal, pass --yes to delete")
+		os.Exit(1)
+	}
+	fmt.Print(i18n.T("Type 'yes' to confirm deletion: "))
+	var response string
+	fmt.Scanln(&response)
+	return response == "yes"
+}
+
+// SetSortOrder sets the order in which images and files are listed in the selection prompts
+func SetSortOrder(key string, reverse bool) error {
+	switch key {
+	case "", SortByName, SortBySize, SortByCreated:
+	default:
+		return fmt.Errorf("invalid sort key %q (expected %s, %s or %s)", key, SortByName, SortBySize, SortByCreated)
+	}
+
+	sortKey = key
+	sortReverse = reverse
+	return nil
+}
+
+// ListImageEntries lists the tagged Docker images, keeping only images that match the filter. Untagged images
+// are only listed if the filter asks for them.
+func ListImageEntries(cli ImageClient, filter Filter) []ImageEntry {
+	// List Docker images
+	ctx, cancel := CallContext()
+	images, err := cli.ImageList(ctx, types.ImageListOptions{})
+	cancel()
+	if err != nil {
+		i18n.Printf("[x] Failed to list Docker images: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(images) == 0 {
+		i18n.Println("[x] No Docker images found")
+		os.Exit(1)
+	}
+
+	entries := make([]ImageEntry, 0, len(images))
+	for _, img := range images {
+		// The platform is not part of the image summary, so inspect the image when filtering by it
+		if filter.HasPlatform() {
+			ctx, cancel := CallContext()
+			imageInspect, _, err := cli.ImageInspectWithRaw(ctx, img.ID)
+			cancel()
+			if err != nil {
+				fmt.Printf("Warning: Could not inspect image %s: %v\n", ShortID(img.ID), err)
+				continue
+			}
+			if !filter.MatchPlatform(imageInspect.Os, imageInspect.Architecture) {
+				continue
+			}
+		}
+
+		if !filter.MatchSize(img.Size) || !filter.MatchCreated(img.Created) {
+			continue
+		}
+
+		// Untagged images have no tags, or only <none>:<none>; they are listed by ID if requested
+		tags := slices.DeleteFunc(slices.Clone(img.RepoTags), func(tag string) bool { return tag == "<none>:<none>" })
+		if len(tags) == 0 && filter.Untagged {
+			tags = []string{ShortID(img.ID)}
+		}
+
+		for _, tag := range tags {
+			// If grep patterns are provided, only add images that match them
+			if !filter.Grep.MatchReference(tag) {
+				continue
+			}
+			entries = append(entries, ImageEntry{
+				Name:    tag,
+				ID:      img.ID,
+				Size:    img.Size,
+				Created: img.Created,
+				Digests: img.RepoDigests,
+			})
+		}
+	}
+
+	return entries
+}
+
+// SelectImages shows a multi-select prompt with size, age and short ID columns and returns the selected image names
+func SelectImages(entries []ImageEntry, message string) []string {
+	if sortKey != "" {
+		sort.SliceStable(entries, func(i, j int) bool {
+			switch sortKey {
+			case SortBySize:
+				return entries[i].Size < entries[j].Size
+			case SortByCreated:
+				return entries[i].Created < entries[j].Created
+			default:
+				return entries[i].Name < entries[j].Name
+			}
+		})
+	}
+	if sortReverse {
+		slices.Reverse(entries)
+	}
+
+	nameWidth := 0
+	for _, entry := range entries {
+		if len(entry.Name) > nameWidth {
+			nameWidth = len(entry.Name)
+		}
+	}
+
+	options := make([]string, len(entries))
+	for i, entry := range entries {
+		options[i] = fmt.Sprintf("%-*s  %10s  %-14s  %s", nameWidth, entry.Name,
+			FormatSize(entry.Size), FormatAge(entry.Created), ShortID(entry.ID))
+		if len(entry.Containers) > 0 {
+			options[i] += "  " + i18n.Sprintf("used by %s", strings.Join(entry.Containers, ", "))
+		}
+	}
+
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name
+	}
+
+	indexes := askMultiSelect(message, options, names)
+
+	selected := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		selected = append(selected, entries[i].Name)
+	}
+	return selected
+}
+
+// SelectFiles shows a multi-select prompt with size and upload date columns and returns the selected file paths
+func SelectFiles(entries []FileEntry, message string) []string {
+	if sortKey != "" {
+		sort.SliceStable(entries, func(i, j int) bool {
+			switch sortKey {
+			case SortBySize:
+				return entries[i].Size < entries[j].Size
+			case SortByCreated:
+				return entries[i].Modified < entries[j].Modified
+			default:
+				return filepath.Base(entries[i].Path) < filepath.Base(entries[j].Path)
+			}
+		})
+	}
+	if sortReverse {
+		slices.Reverse(entries)
+	}
+
+	nameWidth := 0
+	for _, entry := range entries {
+		if len(filepath.Base(entry.Path)) > nameWidth {
+			nameWidth = len(filepath.Base(entry.Path))
+		}
+	}
+
+	options := make([]string, len(entries))
+	for i, entry := range entries {
+		modified := "-"
+		if entry.Modified > 0 {
+			modified = time.Unix(entry.Modified, 0).Format("2006-01-02 15:04")
+		}
+		options[i] = fmt.Sprintf("%-*s  %10s  %s", nameWidth, filepath.Base(entry.Path), FormatSize(entry.Size), modified)
+	}
+
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = filepath.Base(entry.Path)
+	}
+
+	indexes := askMultiSelect(message, options, names)
+
+	selected := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		selected = append(selected, entries[i].Path)
+	}
+	return selected
+}
+
+// ResolveImages returns the images named on the command line, exiting with an error if any of them does not exist
+// or is not a valid reference. References are normalized with NormalizeReference, so docker.io/library/nginx
+// names nginx:latest; names listed as they are, such as the short IDs of untagged images, match directly.
+// References pinned by digest, such as nginx@sha256:<hex> or nginx:1.26@sha256:<hex>, match the repository
+// digests of the images.
+func ResolveImages(entries []ImageEntry, names []string) []string {
+	known := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		known[entry.Name] = true
+		for _, digest := range entry.Digests {
+			known[digest] = true
+		}
+	}
+
+	resolved := make([]string, 0, len(names))
+	missing := false
+	for _, name := range names {
+		if !known[name] {
+			normalized, err := NormalizeReference(name)
+			if err != nil {
+				i18n.Printf("[x] %v\n", err)
+				missing = true
+				continue
+			}
+			name = normalized
+		}
+		if !known[name] && !known[pinnedDigest(name)] {
+			i18n.Printf("[x] Docker image not found: %s\n", name)
+			missing = true
+			continue
+		}
+		resolved = append(resolved, name)
+	}
+
+	if missing {
+		os.Exit(1)
+	}
+	return resolved
+}
+
+// ResolveFiles returns the paths of the files named on the command line, matched by file name or full path,
+// exiting with an error if any of them does not exist
+func ResolveFiles(entries []FileEntry, names []string) []string {
+	resolved := make([]string, 0, len(names))
+	missing := false
+	for _, name := range names {
+		found := false
+		for _, entry := range entries {
+			if entry.Path == name || filepath.Base(entry.Path) == name {
+				resolved = append(resolved, entry.Path)
+				found = true
+				break
+			}
+		}
+		if !found {
+			i18n.Printf("[x] File not found: %s\n", name)
+			missing = true
+		}
+	}
+
+	if missing {
+		os.Exit(1)
+	}
+	return resolved
+}
+
+// askMultiSelect shows the options (with an "All" entry when there are several) and returns the selected indexes.
+// Typing narrows the list live by fuzzy matching against names, which holds the name column of each option. With
+// --all every option is selected without asking; without a terminal it exits instead of asking.
+func askMultiSelect(message string, options []string, names []string) []int {
+	if selectAll {
+		indexes := make([]int, len(options))
+		for i := range options {
+			indexes[i] = i
+		}
+		return indexes
+	}
+	if !Interactive() {
+		i18n.Println("[x] Cannot prompt for a selection without a terminal, name the items on the command line or pass --all")
+		os.Exit(1)
+	}
+
+	// Add an "All" option if there are multiple entries
+	selections := options
+	offset := 0
+	if len(options) > 1 {
+		selections = append([]string{i18n.T("All")}, options...)
+		offset = 1
+	}
+
+	// Multi-select prompt
+	prompt := &survey.MultiSelect{
+		Message:  i18n.T(message),
+		Options:  selections,
+		PageSize: 15,
+		Filter: func(filter string, value string, index int) bool {
+			// "All" would select the entries hidden by the filter too, so hide it while filtering
+			if index < offset {
+				return false
+			}
+			return FuzzyMatch(filter, names[index-offset])
+		},
+	}
+
+	answers := []int{}
+	err := survey.AskOne(prompt, &answers)
+	if err != nil {
+		i18n.Printf("[x] Failed to get user selection: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(options) == 1 {
+		return answers
+	}
+
+	indexes := make([]int, 0, len(answers))
+	for _, answer := range answers {
+		// Handle the "All" selection
+		if answer == 0 {
+			indexes = indexes[:0]
+			for i := range options {
+				indexes = append(indexes, i)
+			}
+			return indexes
+		}
+		indexes = append(indexes, answer-1)
+	}
+	return indexes
+}
+
+// FuzzyMatch reports whether all characters of pattern appear in value in the same order, ignoring case
+func FuzzyMatch(pattern string, value string) bool {
+	value = strings.ToLower(value)
+	for _, r := range strings.ToLower(pattern) {
+		i := strings.IndexRune(value, r)
+		if i < 0 {
+			return false
+		}
+		value = value[i+utf8.RuneLen(r):]
+	}
+	return true
+}
+
+// FormatSize converts bytes to a human-readable size (e.g. 12.3 MB)
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// FormatAge converts a Unix timestamp to a relative age (e.g. 3 days ago)
+func FormatAge(unixTime int64) string {
+	if unixTime <= 0 {
+		return "-"
+	}
+
+	elapsed := time.Since(time.Unix(unixTime, 0))
+	switch {
+	case elapsed < time.Minute:
+		return "just now"
+	case elapsed < time.Hour:
+		return plural(int(elapsed.Minutes()), "minute") + " ago"
+	case elapsed < 24*time.Hour:
+		return plural(int(elapsed.Hours()), "hour") + " ago"
+	case elapsed < 30*24*time.Hour:
+		return plural(int(elapsed.Hours()/24), "day") + " ago"
+	case elapsed < 365*24*time.Hour:
+		return plural(int(elapsed.Hours()/(24*30)), "month") + " ago"
+	default:
+		return plural(int(elapsed.Hours()/(24*365)), "year") + " ago"
+	}
+}
+
+// ShortID returns the first 12 hex characters of an image ID
+func ShortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,134 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// socketProbeTimeout bounds the ping of a candidate socket, so that a stale socket of a stopped VM fails fast
+const socketProbeTimeout = 2 * time.Second
+
+// socketLocations are the sockets of the Docker installations probed by name
+var socketLocations = map[string]func() []string{
+	"default": func() []string { return []string{"/var/run/docker.sock"} },
+	"rootless": func() []string {
+		var paths []string
+		if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+			paths = append(paths, filepath.Join(dir, "docker.sock"))
+		}
+		return append(paths, fmt.Sprintf("/run/user/%d/docker.sock", os.Getuid()))
+	},
+	"desktop": func() []string {
+		return homeSockets(filepath.Join(".docker", "run", "docker.sock"), filepath.Join(".docker", "desktop", "docker.sock"))
+	},
+	"colima": func() []string {
+		return homeSockets(filepath.Join(".colima", "default", "docker.sock"), filepath.Join(".colima", "docker.sock"))
+	},
+}
+
+// DefaultSocketOrder is the order in which the sockets are probed unless SetSocketOrder changes it
+var DefaultSocketOrder = []string{"default", "rootless", "desktop", "colima"}
+
+var (
+	// socketOrder are the names of socketLocations and the socket paths probed, in order
+	socketOrder = DefaultSocketOrder
+	// detectOnce probes the sockets once per run, on the first client of the local daemon
+	detectOnce sync.Once
+	// detectedHost is the endpoint of the first live daemon, or empty when none answered
+	detectedHost string
+)
+
+// homeSockets returns the paths of sockets relative to the home directory
+func homeSockets(names ...string) []string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(homeDir, name)
+	}
+	return paths
+}
+
+// SetSocketOrder sets the order in which the sockets of Docker installations are probed, usually from
+// config.GetDockerSockets: the names default, rootless, desktop and colima, absolute socket paths and unix://
+// endpoints. Empty keeps DefaultSocketOrder.
+func SetSocketOrder(order []string) error {
+	if len(order) == 0 {
+		socketOrder = DefaultSocketOrder
+		return nil
+	}
+	for _, entry := range order {
+		if _, ok := socketLocations[entry]; !ok && !filepath.IsAbs(strings.TrimPrefix(entry, "unix://")) {
+			return fmt.Errorf("invalid docker_sockets entry %q (expected default, rootless, desktop, colima or the absolute path of a socket)", entry)
+		}
+	}
+	socketOrder = order
+	return nil
+}
+
+// socketCandidates returns the unix:// endpoints of the sockets in the probe order, without duplicates
+func socketCandidates(order []string) []string {
+	var candidates []string
+	seen := make(map[string]bool)
+	for _, entry := range order {
+		paths := []string{strings.TrimPrefix(entry, "unix://")}
+		if locate, ok := socketLocations[entry]; ok {
+			paths = locate()
+		}
+		for _, socketPath := range paths {
+			if endpoint := "unix://" + socketPath; !seen[endpoint] {
+				seen[endpoint] = true
+				candidates = append(candidates, endpoint)
+			}
+		}
+	}
+	return candidates
+}
+
+// detectSocket returns the endpoint of the first candidate whose socket exists and whose daemon answers a ping,
+// or an empty string when none does
+func detectSocket(candidates []string) string {
+	for _, endpoint := range candidates {
+		if _, err := os.Stat(strings.TrimPrefix(endpoint, "unix://")); err != nil {
+			continue
+		}
+		cli, err := client.NewClientWithOpts(client.WithHost(endpoint), client.WithAPIVersionNegotiation())
+		if err != nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), socketProbeTimeout)
+		_, err = cli.Ping(ctx)
+		cancel()
+		cli.Close()
+		if err == nil {
+			return endpoint
+		}
+	}
+	return ""
+}
+
+// localHost returns the endpoint of the local daemon found by probing the sockets of Docker installations, or an
+// empty string to leave it to the environment: on Windows, which has the named pipe of Docker Desktop, or when no
+// daemon answered. A daemon found elsewhere than at the default endpoint is printed once.
+func localHost() string {
+	if runtime.GOOS == "windows" {
+		return ""
+	}
+	detectOnce.Do(func() {
+		detectedHost = detectSocket(socketCandidates(socketOrder))
+		if detectedHost != "" && detectedHost != client.DefaultDockerHost {
+			fmt.Printf("Using the Docker daemon at %s\n", detectedHost)
+		}
+	})
+	return detectedHost
+}
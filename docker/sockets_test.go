@@ -0,0 +1,85 @@
+package docker
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// serveDaemon answers the pings of Docker clients on a unix socket, and returns its endpoint
+func serveDaemon(t *testing.T, socketPath string) string {
+	t.Helper()
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Api-Version", "1.44")
+		w.Write([]byte("OK"))
+	})}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return "unix://" + socketPath
+}
+
+func TestDetectSocket(t *testing.T) {
+	dir := t.TempDir()
+	missing := "unix://" + filepath.Join(dir, "missing.sock")
+	stale := filepath.Join(dir, "stale.sock")
+	if err := os.WriteFile(stale, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	colima := serveDaemon(t, filepath.Join(dir, "colima.sock"))
+	rootless := serveDaemon(t, filepath.Join(dir, "rootless.sock"))
+
+	tests := []struct {
+		name       string
+		candidates []string
+		want       string
+	}{
+		{"first live", []string{missing, "unix://" + stale, colima, rootless}, colima},
+		{"order", []string{rootless, colima}, rootless},
+		{"none", []string{missing, "unix://" + stale}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectSocket(tt.candidates); got != tt.want {
+				t.Errorf("detectSocket() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSocketCandidates(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+
+	got := socketCandidates([]string{"colima", "/srv/docker.sock", "unix:///srv/docker.sock", "rootless"})
+	want := []string{
+		"unix://" + filepath.Join(home, ".colima", "default", "docker.sock"),
+		"unix://" + filepath.Join(home, ".colima", "docker.sock"),
+		"unix:///srv/docker.sock",
+		"unix:///run/user/1000/docker.sock",
+	}
+	if uid := os.Getuid(); uid != 1000 {
+		want = append(want, fmt.Sprintf("unix:///run/user/%d/docker.sock", uid))
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("socketCandidates() = %q, want %q", got, want)
+	}
+
+	if err := SetSocketOrder([]string{"colima", "/srv/docker.sock", "unix:///srv/docker.sock"}); err != nil {
+		t.Errorf("SetSocketOrder() = %v", err)
+	}
+	for _, entry := range []string{"podman", "docker.sock", "tcp://host:2375"} {
+		if err := SetSocketOrder([]string{entry}); err == nil {
+			t.Errorf("SetSocketOrder(%q) succeeded", entry)
+		}
+	}
+	t.Cleanup(func() { SetSocketOrder(nil) })
+}
@@ -0,0 +1,241 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// squash flattens the layers of images into one before they are saved
+var squash bool
+
+// SetSquash makes exports flatten the layers of every image into one before saving it. The squashed image keeps
+// the configuration of the original (command, entrypoint, environment, working directory, user, ports, labels and
+// volumes) but not its history, and shares no layers with other images.
+func SetSquash(enabled bool) {
+	squash = enabled
+}
+
+// squashMetadataFiles are the files of an image archive naming its tags
+var squashMetadataFiles = []string{"manifest.json", "repositories", "index.json"}
+
+// squashedImage is a flattened copy of an image, created for one save
+type squashedImage struct {
+	// ref is the reference the copy is saved by
+	ref string
+	// suffix was appended to the tag of the original to tag the copy, and is stripped from the saved archive
+	suffix string
+}
+
+// squashImage flattens an image into a new one by exporting the filesystem of a container created from it and
+// importing it again with the configuration of the image. Tagged images are copied to <tag>-dkci-squash-<random>,
+// so that the archive can be renamed to the original tag while saving; others are copied untagged. Remove the copy
+// with removeSquashed.
+func squashImage(cli *client.Client, imageName string) (*squashedImage, error) {
+	ctx, cancel := CallContext()
+	imageInspect, _, err := cli.ImageInspectWithRaw(ctx, imageName)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	squashed := &squashedImage{}
+	tag := ""
+	normalized, _ := NormalizeReference(imageName)
+	for _, candidate := range []string{imageName, normalized} {
+		if slices.Contains(imageInspect.RepoTags, candidate) {
+			tag = candidate
+			break
+		}
+	}
+	if tag != "" {
+		random := make([]byte, 4)
+		rand.Read(random)
+		squashed.suffix = "-dkci-squash-" + hex.EncodeToString(random)
+		squashed.ref = tag + squashed.suffix
+	}
+
+	// The container only provides the filesystem and is never started, so any command will do
+	ctx, cancel = CallContext()
+	created, err := cli.ContainerCreate(ctx, &container.Config{Image: imageInspect.ID, Entrypoint: []string{"true"}}, nil, nil, nil, "")
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a container from %s: %v", imageName, err)
+	}
+	defer func() {
+		ctx, cancel := CallContext()
+		defer cancel()
+		cli.ContainerRemove(ctx, created.ID, container.RemoveOptions{})
+	}()
+
+	streamCtx, watchdog := StreamContext()
+	defer watchdog.Stop()
+
+	filesystem, err := cli.ContainerExport(streamCtx, created.ID)
+	if err != nil {
+		return nil, watchdog.Err(err)
+	}
+	defer filesystem.Close()
+
+	options := types.ImageImportOptions{
+		Message:  "go-dkci squash of " + imageName,
+		Changes:  squashChanges(imageInspect.Config),
+		Platform: imageInspect.Os + "/" + imageInspect.Architecture,
+	}
+	response, err := cli.ImageImport(streamCtx, types.ImageImportSource{Source: watchdog.Reader(filesystem), SourceName: "-"}, squashed.ref, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import the filesystem of %s: %v", imageName, watchdog.Err(err))
+	}
+	defer response.Close()
+
+	// The last status of the import is the ID of the new image
+	decoder := json.NewDecoder(watchdog.Reader(response))
+	for {
+		var message jsonmessage.JSONMessage
+		if err := decoder.Decode(&message); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read import response: %v", err)
+		}
+		if message.Error != nil {
+			return nil, message.Error
+		}
+		if strings.HasPrefix(message.Status, "sha256:") {
+			if squashed.ref == "" {
+				squashed.ref = message.Status
+			}
+			return squashed, nil
+		}
+	}
+	return nil, fmt.Errorf("import of the filesystem of %s returned no image ID", imageName)
+}
+
+// squashChanges returns the Dockerfile instructions restoring the configuration of an image on its squashed copy
+func squashChanges(config *container.Config) []string {
+	if config == nil {
+		return nil
+	}
+	jsonArray := func(values []string) string {
+		encoded, _ := json.Marshal(values)
+		return string(encoded)
+	}
+
+	// Values are quoted, and their $ escaped, as the instructions expand variables
+	quote := func(value string) string {
+		return strings.ReplaceAll(strconv.Quote(value), "$", `\$`)
+	}
+
+	var changes []string
+	for _, env := range config.Env {
+		key, value, _ := strings.Cut(env, "=")
+		changes = append(changes, "ENV "+key+"="+quote(value))
+	}
+	if len(config.Entrypoint) > 0 {
+		changes = append(changes, "ENTRYPOINT "+jsonArray(config.Entrypoint))
+	}
+	if len(config.Cmd) > 0 {
+		changes = append(changes, "CMD "+jsonArray(config.Cmd))
+	}
+	if config.WorkingDir != "" {
+		changes = append(changes, "WORKDIR "+config.WorkingDir)
+	}
+	if config.User != "" {
+		changes = append(changes, "USER "+config.User)
+	}
+	if config.StopSignal != "" {
+		changes = append(changes, "STOPSIGNAL "+config.StopSignal)
+	}
+	// Maps are walked in sorted order, so that squashing the same image twice gives the same configuration
+	for _, port := range slices.Sorted(maps.Keys(config.ExposedPorts)) {
+		changes = append(changes, "EXPOSE "+string(port))
+	}
+	for _, key := range slices.Sorted(maps.Keys(config.Labels)) {
+		changes = append(changes, "LABEL "+quote(key)+"="+quote(config.Labels[key]))
+	}
+	for _, volume := range slices.Sorted(maps.Keys(config.Volumes)) {
+		changes = append(changes, "VOLUME "+jsonArray([]string{volume}))
+	}
+	return changes
+}
+
+// removeSquashed deletes the squashed copy of an image once it is saved
+func removeSquashed(cli *client.Client, squashed *squashedImage) {
+	ctx, cancel := CallContext()
+	defer cancel()
+	if _, err := cli.ImageRemove(ctx, squashed.ref, types.ImageRemoveOptions{PruneChildren: true}); err != nil {
+		fmt.Printf("Warning: Failed to remove squashed image %s: %v\n", squashed.ref, err)
+	}
+}
+
+// unsquashTags copies an image archive, stripping the suffix of the squashed copy from the tags named in its
+// metadata files, so that it loads under the tag of the original image
+func unsquashTags(r io.Reader, suffix string) io.ReadCloser {
+	if suffix == "" {
+		return io.NopCloser(r)
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(rewriteArchive(r, writer, func(name string, data []byte) []byte {
+			if !slices.Contains(squashMetadataFiles, name) {
+				return nil
+			}
+			return bytes.ReplaceAll(data, []byte(suffix), nil)
+		}))
+	}()
+	return reader
+}
+
+// rewriteArchive copies a tar stream, replacing the content of the regular files for which rewrite returns
+// non-nil data. Only small metadata files should be rewritten, as they are read into memory.
+func rewriteArchive(r io.Reader, w io.Writer, rewrite func(name string, data []byte) []byte) error {
+	tarReader := tar.NewReader(r)
+	tarWriter := tar.NewWriter(w)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return tarWriter.Close()
+		}
+		if err != nil {
+			return err
+		}
+
+		// Layers are copied as they are; only files small enough to be metadata are offered for rewriting
+		if header.Typeflag == tar.TypeReg && header.Size <= 1<<20 {
+			data, err := io.ReadAll(tarReader)
+			if err != nil {
+				return err
+			}
+			if rewritten := rewrite(header.Name, data); rewritten != nil {
+				data = rewritten
+			}
+			header.Size = int64(len(data))
+			if err := tarWriter.WriteHeader(header); err != nil {
+				return err
+			}
+			if _, err := tarWriter.Write(data); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tarWriter, tarReader); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/docker/docker/client"
+)
+
+// sshDockerHost is the placeholder host of Docker clients whose connections are tunnelled through SSH
+const sshDockerHost = "http://docker.example.com"
+
+// daemonSchemes are the schemes of Docker daemon endpoints that are connected to directly
+var daemonSchemes = map[string]bool{"npipe": true, "unix": true, "tcp": true}
+
+// apiVersion pins the Docker API version of the clients; empty negotiates it with every daemon
+var apiVersion string
+
+// SetAPIVersion pins the Docker API version of the clients, such as "1.41", or negotiates the highest version
+// supported by both sides with every daemon when version is empty. DOCKER_API_VERSION pins it too.
+func SetAPIVersion(version string) {
+	apiVersion = version
+}
+
+// versionOption returns the client option selecting the API version. A version set by DOCKER_API_VERSION
+// through client.FromEnv is not negotiated away.
+func versionOption() client.Opt {
+	if apiVersion != "" {
+		return client.WithVersion(apiVersion)
+	}
+	return client.WithAPIVersionNegotiation()
+}
+
+// NewClient returns a Docker client for host: the local daemon (configured from the environment, which defaults
+// to the named pipe of Docker Desktop on Windows, or from the current context of the docker CLI unless DOCKER_HOST
+// is set, or else the first live daemon of the sockets probed in the order of SetSocketOrder) if host is empty or
+// "local", a daemon endpoint such as
+// "npipe:////./pipe/docker_engine", "unix:///var/run/docker.sock" or "tcp://host:2375", or the daemon of a remote
+// machine for "ssh://[user@]host[:port]". Remote daemons are reached by running `docker system dial-stdio` over
+// ssh, so only ssh and the docker CLI are needed on the remote side.
+func NewClient(host string) (*client.Client, error) {
+	if host == "" || host == "local" {
+		ctx, err := currentContext()
+		if err != nil {
+			return nil, err
+		}
+		if ctx != nil && strings.HasPrefix(ctx.Host, "ssh://") {
+			return NewClient(ctx.Host)
+		}
+		if ctx != nil {
+			return contextClient(ctx)
+		}
+		if os.Getenv(client.EnvOverrideHost) == "" {
+			if endpoint := localHost(); endpoint != "" {
+				return client.NewClientWithOpts(client.FromEnv, client.WithHost(endpoint), versionOption())
+			}
+		}
+		return client.NewClientWithOpts(client.FromEnv, versionOption())
+	}
+
+	sshURL, err := url.Parse(host)
+	if err == nil && daemonSchemes[sshURL.Scheme] {
+		return client.NewClientWithOpts(client.FromEnv, client.WithHost(host), versionOption())
+	}
+	if err != nil || sshURL.Scheme != "ssh" || sshURL.Hostname() == "" {
+		return nil, fmt.Errorf("invalid Docker host %q (expected local, ssh://[user@]host[:port], npipe://, unix:// or tcp://)", host)
+	}
+	sshPath, err := exec.LookPath("ssh")
+	if err != nil {
+		return nil, fmt.Errorf("ssh executable not found in PATH: %v", err)
+	}
+
+	args := []string{}
+	if sshURL.User != nil {
+		args = append(args, "-l", sshURL.User.Username())
+	}
+	if sshURL.Port() != "" {
+		args = append(args, "-p", sshURL.Port())
+	}
+	args = append(args, "--", sshURL.Hostname(), "docker", "system", "dial-stdio")
+
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialCommand(sshPath, args, host)
+	}
+	return client.NewClientWithOpts(
+		client.WithHTTPClient(&http.Client{Transport: &http.Transport{DialContext: dial}}),
+		client.WithHost(sshDockerHost),
+		client.WithDialContext(dial),
+		versionOption(),
+	)
+}
+
+// CopyImages streams the selected images from the daemon of one host to the daemon of another, see NewClient
+// for the host syntax. The archive goes straight from `docker save` into `docker load` without intermediate files.
+// If imageNames is not empty, exactly those images are copied without prompting.
+func CopyImages(from, to string, filter Filter, imageNames []string) {
+	source, target := OpenHosts(from, to)
+	defer source.Close()
+	defer target.Close()
+
+	selectedImages := HostImages(source, from, filter, imageNames, "Select Docker images to copy:")
+
+	fmt.Printf("Copying %v from %s to %s\n", selectedImages, DisplayHost(from), DisplayHost(to))
+	start := time.Now()
+
+	saveCtx, watchdog := StreamContext()
+	defer watchdog.Stop()
+	imageReader, err := source.ImageSave(saveCtx, selectedImages)
+	if err != nil {
+		i18n.Printf("[x] Failed to save images on %s: %v\n", DisplayHost(from), watchdog.Err(err))
+		os.Exit(1)
+	}
+	defer imageReader.Close()
+
+	counter := NewCountingReader(watchdog.Reader(imageReader))
+	refs, err := LoadStream(target, counter)
+	if err != nil {
+		i18n.Printf("[x] Failed to load images on %s: %v\n", DisplayHost(to), err)
+		os.Exit(1)
+	}
+	if len(refs) == 0 {
+		fmt.Println("The daemon did not report the loaded images")
+	}
+
+	i18n.Printf("[√] Successfully copied %d image(s) (%s in %s)\n", len(selectedImages), FormatSize(counter.Count()), time.Since(start).Round(time.Second))
+}
+
+// OpenHosts returns clients of the daemons of two hosts, see NewClient for the host syntax, exiting when either
+// client cannot be created or the daemon of to cannot be reached
+func OpenHosts(from, to string) (source, target *client.Client) {
+	source, err := NewClient(from)
+	if err != nil {
+		i18n.Printf("[x] Failed to create Docker client for %s: %v\n", DisplayHost(from), err)
+		os.Exit(1)
+	}
+
+	target, err = NewClient(to)
+	if err != nil {
+		i18n.Printf("[x] Failed to create Docker client for %s: %v\n", DisplayHost(to), err)
+		os.Exit(1)
+	}
+
+	// Fail early if the target cannot be reached rather than after selecting images
+	ctx, cancel := CallContext()
+	_, err = target.Ping(ctx)
+	cancel()
+	if err != nil {
+		i18n.Printf("[x] Failed to connect to Docker on %s: %v\n", DisplayHost(to), err)
+		os.Exit(1)
+	}
+	return source, target
+}
+
+// HostImages returns the images of the daemon of cli on host named by imageNames or, without names, those matching
+// filter that are selected at the prompt message. It exits when no image is selected or the image rules forbid one.
+func HostImages(cli ImageClient, host string, filter Filter, imageNames []string, message string) []string {
+	var selectedImages []string
+	if len(imageNames) > 0 {
+		selectedImages = ResolveImages(ListImageEntries(cli, Filter{Untagged: true}), imageNames)
+	} else {
+		imageEntries := ListImageEntries(cli, filter)
+		if len(imageEntries) == 0 {
+			i18n.Printf("[x] No tagged Docker images found on %s\n", DisplayHost(host))
+			os.Exit(1)
+		}
+
+		selectedImages = SelectImages(imageEntries, message)
+		if len(selectedImages) == 0 {
+			i18n.Println("[x] No images selected")
+			os.Exit(1)
+		}
+	}
+
+	if err := CheckImagesAllowed(selectedImages); err != nil {
+		i18n.Printf("[x] %v\n", err)
+		os.Exit(1)
+	}
+	return selectedImages
+}
+
+// DisplayHost names a Docker host in messages
+func DisplayHost(host string) string {
+	if host == "" {
+		return "local"
+	}
+	return host
+}
+
+// dialCommand starts a command and returns a connection reading from its stdout and writing to its stdin.
+// The command's stderr, such as ssh authentication prompts and errors, goes to the terminal.
+func dialCommand(path string, args []string, host string) (net.Conn, error) {
+	cmd := exec.Command(path, args...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %v", strings.Join(append([]string{path}, args...), " "), err)
+	}
+	return &commandConn{cmd: cmd, stdin: stdin, stdout: stdout, host: host}, nil
+}
+
+// commandConn is a net.Conn over the standard input and output of a command
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	host   string
+}
+
+func (c *commandConn) Read(p []byte) (int, error) {
+	return c.stdout.Read(p)
+}
+
+func (c *commandConn) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+// CloseWrite closes the standard input of the command, telling the remote side the request is complete
+func (c *commandConn) CloseWrite() error {
+	return c.stdin.Close()
+}
+
+// Close stops the command
+func (c *commandConn) Close() error {
+	c.stdin.Close()
+	c.stdout.Close()
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr {
+	return commandAddr("local")
+}
+
+func (c *commandConn) RemoteAddr() net.Addr {
+	return commandAddr(c.host)
+}
+
+// Deadlines are not supported by pipes; the HTTP client relies on its context instead
+func (c *commandConn) SetDeadline(t time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// commandAddr is the address of either end of a commandConn
+type commandAddr string
+
+func (a commandAddr) Network() string {
+	return "command"
+}
+
+func (a commandAddr) String() string {
+	return string(a)
+}
@@ -0,0 +1,59 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/baowuhe/go-dkci/job"
+	"github.com/baowuhe/go-dkci/notify"
+	"github.com/baowuhe/go-dkci/state"
+)
+
+// PrintSummary prints the totals of the batch begun by the last job.Start: the items that succeeded, failed or
+// were skipped, the bytes written, uploaded or downloaded, the elapsed time and the average throughput. With
+// --notify the summary is emailed too.
+func PrintSummary() {
+	summary := job.Totals()
+	elapsed := time.Duration(summary.Seconds * float64(time.Second)).Round(time.Second)
+
+	fmt.Println()
+	fmt.Printf("%-9s  %-6s  %-7s  %11s  %8s  %s\n", "SUCCEEDED", "FAILED", "SKIPPED", "TRANSFERRED", "ELAPSED", "THROUGHPUT")
+	fmt.Printf("%-9d  %-6d  %-7d  %11s  %8s  %s/s\n", summary.Succeeded, summary.Failed, summary.Skipped,
+		FormatSize(summary.Bytes), elapsed, FormatSize(int64(summary.BytesPerSecond)))
+	emailSummary(summary, elapsed)
+}
+
+// emailSummary emails the totals of the batch and the outcome of every export and import of this run with the
+// profile of --notify. Failing to send is a warning, as the run itself is done.
+func emailSummary(summary job.Summary, elapsed time.Duration) {
+	if !notify.Enabled() {
+		return
+	}
+
+	host, _ := os.Hostname()
+	totals := fmt.Sprintf("%d succeeded, %d failed, %d skipped", summary.Succeeded, summary.Failed, summary.Skipped)
+	var body strings.Builder
+	fmt.Fprintf(&body, "go-dkci on %s: %s\n", host, totals)
+	fmt.Fprintf(&body, "%s transferred in %s, %s/s\n\n", FormatSize(summary.Bytes), elapsed, FormatSize(int64(summary.BytesPerSecond)))
+	for _, record := range state.Session() {
+		item := record.Image
+		if record.Operation == state.Import {
+			item = strings.Join(record.Images, ", ")
+		}
+		line := fmt.Sprintf("%-7s  %-7s  %s  %s", record.Operation, record.Result, item, record.Location)
+		if record.Error != "" {
+			line += ": " + record.Error
+		}
+		body.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+
+	subject := fmt.Sprintf("go-dkci on %s: %s", host, totals)
+	if err := notify.Email(subject, body.String(), summary.Failed > 0); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return
+	}
+	i18n.Println("[√] Emailed the summary")
+}
@@ -0,0 +1,114 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout bounds Docker API calls unless --docker-timeout or docker_timeout in the configuration says otherwise
+const DefaultTimeout = 2 * time.Minute
+
+// timeout bounds each Docker API call, and how long a stream to or from the daemon may stall; 0 waits forever
+var timeout = DefaultTimeout
+
+// SetTimeout sets how long a Docker API call may take, and how long a save or load stream may pass no data,
+// before it fails; 0 waits forever
+func SetTimeout(d time.Duration) {
+	timeout = d
+}
+
+// CallContext returns the context of a Docker API call, bounded by the timeout
+func CallContext() (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// StreamContext returns the context of a streaming Docker API call, such as saving or loading an image, and a
+// watchdog cancelling it when the stream stalls for longer than the timeout. The stream may take as long as it
+// needs while data flows through the readers of the watchdog. Stop the watchdog once the stream is done.
+func StreamContext() (context.Context, *Watchdog) {
+	ctx, cancel := context.WithCancel(context.Background())
+	watchdog := NewWatchdog(timeout, "Docker", cancel)
+	return ctx, watchdog
+}
+
+// Watchdog calls a function when no data passed through its readers for a while, so that stalled transfers fail
+// instead of hanging
+type Watchdog struct {
+	timeout time.Duration
+	peer    string
+	timer   *time.Timer
+	stop    func()
+	stalled atomic.Bool
+}
+
+// NewWatchdog starts a watchdog calling onStall when its readers pass no data for timeout; peer names the other
+// side in the error. A timeout of 0 never calls onStall.
+func NewWatchdog(timeout time.Duration, peer string, onStall func()) *Watchdog {
+	w := &Watchdog{timeout: timeout, peer: peer, stop: onStall}
+	if timeout > 0 {
+		w.timer = time.AfterFunc(timeout, func() {
+			w.stalled.Store(true)
+			onStall()
+		})
+	}
+	return w
+}
+
+// Reader returns a reader resetting the watchdog whenever data is read from r. Once the watchdog fired, its
+// errors are replaced as by Err.
+func (w *Watchdog) Reader(r io.Reader) io.Reader {
+	return &watchedReader{reader: r, watchdog: w}
+}
+
+// ReadCloser returns a reader resetting the watchdog whenever data is read from rc, and stopping it when closed
+func (w *Watchdog) ReadCloser(rc io.ReadCloser) io.ReadCloser {
+	return &watchedReadCloser{watchedReader: watchedReader{reader: rc, watchdog: w}, closer: rc}
+}
+
+// Stop stops the watchdog and releases what onStall would have released
+func (w *Watchdog) Stop() {
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.stop()
+}
+
+// Err replaces the error of an operation cut short by the watchdog with one saying that the transfer stalled
+func (w *Watchdog) Err(err error) error {
+	if err != nil && w.stalled.Load() {
+		return fmt.Errorf("no data from %s for %s, giving up (raise the timeout if it is just slow)", w.peer, w.timeout)
+	}
+	return err
+}
+
+// watchedReader resets its watchdog on every read returning data
+type watchedReader struct {
+	reader   io.Reader
+	watchdog *Watchdog
+}
+
+func (r *watchedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 && r.watchdog.timer != nil {
+		r.watchdog.timer.Reset(r.watchdog.timeout)
+	}
+	return n, r.watchdog.Err(err)
+}
+
+// watchedReadCloser is a watchedReader stopping its watchdog when closed
+type watchedReadCloser struct {
+	watchedReader
+	closer io.Closer
+}
+
+func (r *watchedReadCloser) Close() error {
+	err := r.closer.Close()
+	r.watchdog.Stop()
+	return err
+}
@@ -0,0 +1,426 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// transferPath is the URL path the sender streams the images on
+const transferPath = "/images"
+
+// checksumTrailer carries the SHA-256 of the streamed archive, sent after the body
+const checksumTrailer = "X-Dkci-Sha256"
+
+// TransferTokenEnv names the environment variable holding the shared token of send and receive, so that it need
+// not appear on the command line
+const TransferTokenEnv = "DKCI_TRANSFER_TOKEN"
+
+var (
+	// transferCert and transferKey enable TLS on the sending side
+	transferCert, transferKey string
+	// transferTLS makes the receiver connect over TLS
+	transferTLS bool
+	// transferCA is the certificate the receiver trusts in addition to the system roots
+	transferCA string
+	// transferInsecure makes the receiver skip the verification of the sender certificate
+	transferInsecure bool
+	// transferToken is the secret the receiver presents and the sender checks before sending anything
+	transferToken string
+)
+
+// SetTransferToken sets the shared token of SendImages and ReceiveImages; empty takes it from TransferTokenEnv.
+// The sender generates one when neither is set.
+func SetTransferToken(token string) {
+	if token == "" {
+		token = os.Getenv(TransferTokenEnv)
+	}
+	transferToken = token
+}
+
+// SetSendTLS makes SendImages serve the images over TLS with the given certificate and key
+func SetSendTLS(certFile, keyFile string) {
+	transferCert = certFile
+	transferKey = keyFile
+}
+
+// SetReceiveTLS makes ReceiveImages connect over TLS, trusting caFile (if not empty) or skipping the
+// verification of the sender certificate if insecure is set. A CA file or insecure imply TLS.
+func SetReceiveTLS(enabled bool, caFile string, insecure bool) {
+	transferTLS = enabled || caFile != "" || insecure
+	transferCA = caFile
+	transferInsecure = insecure
+}
+
+// SendImages waits for a receiver on listen and streams the `docker save` archive of the selected images to it.
+// Receivers must present the transfer token, which is generated and printed unless SetTransferToken set one. The
+// SHA-256 of the archive follows the stream so the receiver can check it. Returns after the first transfer.
+func SendImages(listen string, filter Filter, imageNames []string) {
+	cli, err := NewClient("")
+	if err != nil {
+		i18n.Printf("[x] Failed to create Docker client: %v\n", err)
+		os.Exit(1)
+	}
+	defer cli.Close()
+
+	var selectedImages []string
+	if len(imageNames) > 0 {
+		selectedImages = ResolveImages(ListImageEntries(cli, Filter{Untagged: true}), imageNames)
+	} else {
+		imageEntries := ListImageEntries(cli, filter)
+		if len(imageEntries) == 0 {
+			i18n.Println("[x] No tagged Docker images found")
+			os.Exit(1)
+		}
+
+		selectedImages = SelectImages(imageEntries, "Select Docker images to send:")
+		if len(selectedImages) == 0 {
+			i18n.Println("[x] No images selected")
+			os.Exit(1)
+		}
+	}
+	if err := CheckImagesAllowed(selectedImages); err != nil {
+		i18n.Printf("[x] %v\n", err)
+		os.Exit(1)
+	}
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		i18n.Printf("[x] Failed to listen on %s: %v\n", listen, err)
+		os.Exit(1)
+	}
+
+	scheme := "plain TCP"
+	if transferCert != "" {
+		scheme = "TLS"
+	}
+	token := transferToken
+	if token == "" {
+		random := make([]byte, 16)
+		if _, err := rand.Read(random); err != nil {
+			i18n.Printf("[x] Failed to generate a transfer token: %v\n", err)
+			os.Exit(1)
+		}
+		token = hex.EncodeToString(random)
+		fmt.Printf("Waiting for a receiver on %s (%s), run: %s=%s go-dkci receive --from <this-host>%s\n", listener.Addr(), scheme, TransferTokenEnv, token, portOf(listen))
+	} else {
+		fmt.Printf("Waiting for a receiver on %s (%s), run: go-dkci receive --from <this-host>%s with the same token\n", listener.Addr(), scheme, portOf(listen))
+	}
+
+	done := make(chan error, 1)
+	var busy atomic.Bool
+	server := &http.Server{}
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != transferPath || r.Method != http.MethodGet {
+			http.NotFound(w, r)
+			return
+		}
+		if !validToken(r, token) {
+			fmt.Printf("Refused %s: wrong transfer token\n", r.RemoteAddr)
+			http.Error(w, "wrong transfer token", http.StatusUnauthorized)
+			return
+		}
+		// Only the first receiver gets the images
+		if !busy.CompareAndSwap(false, true) {
+			http.Error(w, "another receiver is already connected", http.StatusConflict)
+			return
+		}
+		fmt.Printf("Sending %v to %s\n", selectedImages, r.RemoteAddr)
+
+		imageReader, err := cli.ImageSave(r.Context(), selectedImages)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			done <- fmt.Errorf("failed to save images: %v", err)
+			return
+		}
+		defer imageReader.Close()
+
+		w.Header().Set("Trailer", checksumTrailer)
+		w.Header().Set("Content-Type", "application/x-tar")
+
+		hash := sha256.New()
+		written, err := io.Copy(io.MultiWriter(w, hash), imageReader)
+		if err != nil {
+			done <- fmt.Errorf("transfer to %s interrupted after %s: %v", r.RemoteAddr, FormatSize(written), err)
+			return
+		}
+		w.Header().Set(checksumTrailer, hex.EncodeToString(hash.Sum(nil)))
+		fmt.Printf("Sent %s\n", FormatSize(written))
+		done <- nil
+	})
+
+	go func() {
+		var err error
+		if transferCert != "" {
+			err = server.ServeTLS(listener, transferCert, transferKey)
+		} else {
+			err = server.Serve(listener)
+		}
+		if err != http.ErrServerClosed && busy.CompareAndSwap(false, true) {
+			done <- err
+		}
+	}()
+
+	err = <-done
+	// Let the handler finish writing the trailer before closing the connection
+	server.Shutdown(context.Background())
+	if err != nil {
+		i18n.Printf("[x] Failed to send images: %v\n", err)
+		os.Exit(1)
+	}
+	i18n.Printf("[√] Successfully sent %d image(s)\n", len(selectedImages))
+}
+
+// ReceiveImages connects to a sender started with SendImages, presenting the transfer token, and loads the streamed
+// images into Docker, or into the kind cluster set with SetKindCluster. The stream is written to the directory of the
+// run while it is hashed, and only loaded once it matches the checksum sent by the sender, so that a corrupted or
+// tampered transfer never reaches the daemon.
+func ReceiveImages(from string) {
+	if transferToken == "" {
+		i18n.Printf("[x] Error: receive needs the token printed by send, in --token or %s\n", TransferTokenEnv)
+		os.Exit(1)
+	}
+	cli, err := NewClient("")
+	if err != nil {
+		i18n.Printf("[x] Failed to create Docker client: %v\n", err)
+		os.Exit(1)
+	}
+	defer cli.Close()
+
+	httpClient, scheme, err := transferClient()
+	if err != nil {
+		i18n.Printf("[x] Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Receiving images from %s\n", from)
+	archivePath, size, sum, err := receiveArchive(httpClient, scheme+"://"+from+transferPath)
+	if err != nil {
+		if archivePath != "" {
+			os.Remove(archivePath)
+		}
+		i18n.Printf("[x] Failed to receive images from %s: %v\n", from, err)
+		os.Exit(1)
+	}
+
+	archive, err := os.Open(archivePath)
+	if err == nil {
+		if kindCluster != "" {
+			err = loadIntoKind(cli, kindCluster, archive)
+		} else {
+			_, err = LoadStream(cli, archive)
+		}
+		archive.Close()
+	}
+	os.Remove(archivePath)
+	if err != nil {
+		i18n.Printf("[x] Failed to load received images: %v\n", err)
+		os.Exit(1)
+	}
+
+	i18n.Printf("[√] Successfully received %s from %s (sha256 %s)\n", FormatSize(size), from, ShortID(sum))
+}
+
+// receiveArchive downloads the archive streamed at url to a file of the run directory, hashing it on the way, and
+// checks it against the checksum trailer of the sender. It returns the path of the file, which the caller removes,
+// also on error, with its size and SHA-256.
+func receiveArchive(httpClient *http.Client, url string) (string, int64, string, error) {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", 0, "", err
+	}
+	request.Header.Set("Authorization", "Bearer "+transferToken)
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		return "", 0, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(resp.Body)
+		return "", 0, "", fmt.Errorf("sender refused the transfer: %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	workDir, err := config.RunDir()
+	if err != nil {
+		return "", 0, "", err
+	}
+	file, err := os.CreateTemp(workDir, "received-*.tar")
+	if err != nil {
+		return "", 0, "", err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return file.Name(), 0, "", fmt.Errorf("transfer interrupted after %s: %v", FormatSize(size), err)
+	}
+
+	expected := resp.Trailer.Get(checksumTrailer)
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if expected == "" {
+		return file.Name(), 0, "", errors.New("sender did not complete the transfer, nothing was loaded")
+	}
+	if expected != actual {
+		return file.Name(), 0, "", fmt.Errorf("checksum mismatch (expected %s, got %s), nothing was loaded", expected, actual)
+	}
+	return file.Name(), size, actual, nil
+}
+
+// validToken reports whether a request carries the transfer token, comparing it in constant time
+func validToken(r *http.Request, token string) bool {
+	presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+}
+
+// LoadStream loads an image archive stream (docker save output, plain or compressed) into the Docker daemon of cli,
+// showing the progress of its layers, and returns the references of the loaded images
+func LoadStream(cli ImageClient, archive io.Reader) ([]string, error) {
+	ctx, watchdog := StreamContext()
+	defer watchdog.Stop()
+	response, err := cli.ImageLoad(ctx, watchdog.Reader(archive), false)
+	if err != nil {
+		return nil, watchdog.Err(err)
+	}
+	response.Body = watchdog.ReadCloser(response.Body)
+	defer response.Body.Close()
+
+	refs, err := ReadLoadResponse(response, "")
+	return refs, watchdog.Err(err)
+}
+
+// Messages of docker load naming a loaded image: its repo:tag, or its ID if the archive has no tags for it
+const (
+	loadedImagePrefix   = "Loaded image: "
+	loadedImageIDPrefix = "Loaded image ID: "
+)
+
+// ReadLoadResponse reads the response of docker load to the end and returns the references of the loaded images,
+// in the order the daemon reported them. An error reported by the daemon in the response is returned as an error.
+// The response is decoded message by message as the daemon sends it, so that a verbose response is never held in
+// memory as a whole. The progress of the layers, sent unless the load was quiet, and every loaded image are shown
+// on stdout as they arrive; label names the archive in those lines.
+func ReadLoadResponse(response types.ImageLoadResponse, label string) ([]string, error) {
+	progress := newLoadProgress(label)
+	defer progress.done()
+
+	var refs []string
+	addRef := func(line string) {
+		line = strings.TrimSpace(line)
+		for _, prefix := range []string{loadedImagePrefix, loadedImageIDPrefix} {
+			if ref, ok := strings.CutPrefix(line, prefix); ok {
+				refs = append(refs, ref)
+				progress.loaded(ref)
+			}
+		}
+	}
+
+	// Old daemons answer in plain text
+	if !response.JSON {
+		reader := bufio.NewReader(response.Body)
+		for {
+			line, err := reader.ReadString('\n')
+			addRef(line)
+			if err == io.EOF {
+				return refs, nil
+			} else if err != nil {
+				return refs, fmt.Errorf("failed to read load response: %v", err)
+			}
+		}
+	}
+
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var message jsonmessage.JSONMessage
+		if err := decoder.Decode(&message); err == io.EOF {
+			return refs, nil
+		} else if err != nil {
+			return refs, fmt.Errorf("failed to read load response: %v", err)
+		}
+		if message.Error != nil {
+			return refs, message.Error
+		}
+		progress.update(&message)
+		for _, line := range strings.Split(message.Stream, "\n") {
+			addRef(line)
+		}
+	}
+}
+
+// transferClient returns the HTTP client and URL scheme used to connect to a sender
+func transferClient() (*http.Client, string, error) {
+	if !transferTLS {
+		return http.DefaultClient, "http", nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: transferInsecure}
+	if transferCA != "" {
+		pem, err := os.ReadFile(transferCA)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read CA certificate: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, "", fmt.Errorf("no certificate found in %s", transferCA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, "https", nil
+}
+
+// CountingReader counts the bytes read through it, for the totals of copies that stream images
+type CountingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+// NewCountingReader returns a CountingReader reading from reader
+func NewCountingReader(reader io.Reader) *CountingReader {
+	return &CountingReader{reader: reader}
+}
+
+func (c *CountingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// Count returns the number of bytes read so far
+func (c *CountingReader) Count() int64 {
+	return c.count
+}
+
+// portOf returns the ":port" part of a listen address
+func portOf(listen string) string {
+	if i := strings.LastIndex(listen, ":"); i >= 0 {
+		return listen[i:]
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/baowuhe/go-dkci/config"
+)
+
+// fakeSender serves archive like SendImages does, with checksum as the trailer
+func fakeSender(t *testing.T, token, archive, checksum string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !validToken(r, token) {
+			http.Error(w, "wrong transfer token", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Trailer", checksumTrailer)
+		io.WriteString(w, archive)
+		w.Header().Set(checksumTrailer, checksum)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestReceiveArchive(t *testing.T) {
+	t.Setenv("DKCI_TEMP_DIR", t.TempDir())
+	t.Cleanup(config.RemoveRunDir)
+	t.Cleanup(func() { SetTransferToken("") })
+
+	archive := strings.Repeat("layer", 1000)
+	sum := sha256.Sum256([]byte(archive))
+	checksum := hex.EncodeToString(sum[:])
+
+	SetTransferToken("secret")
+	server := fakeSender(t, "secret", archive, checksum)
+	archivePath, size, actual, err := receiveArchive(server.Client(), server.URL+transferPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(archivePath)
+	if size != int64(len(archive)) || actual != checksum {
+		t.Errorf("receiveArchive = %d, %s, want %d, %s", size, actual, len(archive), checksum)
+	}
+	if received, _ := os.ReadFile(archivePath); string(received) != archive {
+		t.Errorf("received %d bytes, want %d", len(received), len(archive))
+	}
+
+	// A tampered stream is detected before anything is loaded
+	server = fakeSender(t, "secret", archive+"x", checksum)
+	archivePath, _, _, err = receiveArchive(server.Client(), server.URL+transferPath)
+	if archivePath != "" {
+		os.Remove(archivePath)
+	}
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("tampered stream: %v", err)
+	}
+
+	// A receiver with the wrong token is refused
+	SetTransferToken("guess")
+	archivePath, _, _, err = receiveArchive(server.Client(), server.URL+transferPath)
+	if archivePath != "" || err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("wrong token: %q, %v", archivePath, err)
+	}
+}
+
+func TestSetTransferTokenEnvironment(t *testing.T) {
+	t.Cleanup(func() { SetTransferToken("") })
+	t.Setenv(TransferTokenEnv, "from-env")
+	SetTransferToken("")
+	if transferToken != "from-env" {
+		t.Errorf("token %q, want from-env", transferToken)
+	}
+	SetTransferToken("from-flag")
+	if transferToken != "from-flag" {
+		t.Errorf("token %q, want from-flag", transferToken)
+	}
+}
@@ -0,0 +1,220 @@
+package docker
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+)
+
+// VolumeSuffix ends the file names of volume exports
+const VolumeSuffix = ".volume.tar"
+
+// volumeMountPath is where the helper container mounts the volume. Archives of the volume contain a
+// single top-level directory of the same name.
+const volumeMountPath = "/volume"
+
+// helperImage is the image of the helper container the volume is mounted in. The container is created
+// but never started, so any local image will do.
+var helperImage = "busybox:latest"
+
+// SetHelperImage sets the image used to create the helper container volumes are copied through
+func SetHelperImage(image string) {
+	if image != "" {
+		helperImage = image
+	}
+}
+
+// IsFilesystemArchive reports whether a file is a container or volume export rather than an image archive
+func IsFilesystemArchive(filePath string) bool {
+	lowerPath := strings.ToLower(filePath)
+	return strings.HasSuffix(lowerPath, ContainerSuffix) || strings.HasSuffix(lowerPath, VolumeSuffix)
+}
+
+// VolumeNameFromFile recovers the volume name from the name of a volume export
+func VolumeNameFromFile(filePath string) string {
+	return DecodeName(strings.TrimSuffix(filepath.Base(filePath), VolumeSuffix))
+}
+
+// ExportVolumes exports the contents of the given volumes to a local destination
+func ExportVolumes(destination string, volumeNames []string) {
+	// Initialize Docker client
+	cli, err := NewClient("")
+	if err != nil {
+		i18n.Printf("[x] Failed to create Docker client: %v\n", err)
+		os.Exit(1)
+	}
+	defer cli.Close()
+
+	// Create destination directory if it doesn't exist
+	if err := os.MkdirAll(destination, 0755); err != nil {
+		i18n.Printf("[x] Failed to create destination directory %s: %v\n", destination, err)
+		os.Exit(1)
+	}
+
+	for _, volumeName := range volumeNames {
+		tarFilePath, err := SaveVolume(cli, volumeName, destination)
+		if err != nil {
+			i18n.Printf("[x] Failed to export volume %s: %v\n", volumeName, err)
+			continue
+		}
+		i18n.Printf("[√] Successfully exported volume %s to %s\n", volumeName, tarFilePath)
+	}
+}
+
+// ImportVolume restores a volume export into the named volume, creating the volume if needed.
+// An empty volumeName restores into the volume the file was exported from.
+func ImportVolume(filePath, volumeName string) {
+	// Initialize Docker client
+	cli, err := NewClient("")
+	if err != nil {
+		i18n.Printf("[x] Failed to create Docker client: %v\n", err)
+		os.Exit(1)
+	}
+	defer cli.Close()
+
+	if volumeName == "" {
+		volumeName = VolumeNameFromFile(filePath)
+	}
+
+	if err := RestoreVolume(cli, volumeName, filePath); err != nil {
+		i18n.Printf("[x] Failed to import %s into volume %s: %v\n", filePath, volumeName, err)
+		os.Exit(1)
+	}
+	i18n.Printf("[√] Successfully imported %s into volume %s\n", filePath, volumeName)
+}
+
+// SaveVolume copies the contents of a volume out of a helper container to <name>.volume.tar in dir
+// and returns the file path
+func SaveVolume(cli *client.Client, volumeName, dir string) (string, error) {
+	ctx, cancel := CallContext()
+	_, err := cli.VolumeInspect(ctx, volumeName)
+	cancel()
+	if err != nil {
+		return "", err
+	}
+
+	containerID, err := createVolumeHelper(cli, volumeName)
+	if err != nil {
+		return "", err
+	}
+	defer removeVolumeHelper(cli, containerID)
+
+	tarFilePath := filepath.Join(dir, EncodeName(volumeName)+VolumeSuffix)
+	fmt.Printf("Exporting volume %s to %s...\n", volumeName, tarFilePath)
+
+	streamCtx, watchdog := StreamContext()
+	defer watchdog.Stop()
+
+	volumeReader, _, err := cli.CopyFromContainer(streamCtx, containerID, volumeMountPath)
+	if err != nil {
+		return "", watchdog.Err(err)
+	}
+	defer volumeReader.Close()
+
+	outFile, err := os.Create(tarFilePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create output file %s: %v", tarFilePath, err)
+	}
+	defer outFile.Close()
+	config.Track(tarFilePath)
+
+	if _, err := io.Copy(outFile, watchdog.Reader(volumeReader)); err != nil {
+		os.Remove(tarFilePath)
+		return "", fmt.Errorf("failed to write file %s: %v", tarFilePath, watchdog.Err(err))
+	}
+	return tarFilePath, nil
+}
+
+// RestoreVolume copies a volume export into a volume through a helper container, creating the volume if
+// it does not exist. Existing files of the volume are overwritten, others are kept.
+func RestoreVolume(cli *client.Client, volumeName, filePath string) error {
+	ctx, cancel := CallContext()
+	defer cancel()
+	if _, err := cli.VolumeInspect(ctx, volumeName); err != nil {
+		fmt.Printf("Creating volume %s...\n", volumeName)
+		if _, err := cli.VolumeCreate(ctx, volume.CreateOptions{Name: volumeName}); err != nil {
+			return fmt.Errorf("failed to create volume: %v", err)
+		}
+	}
+
+	containerID, err := createVolumeHelper(cli, volumeName)
+	if err != nil {
+		return err
+	}
+	defer removeVolumeHelper(cli, containerID)
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	streamCtx, watchdog := StreamContext()
+	defer watchdog.Stop()
+
+	// The archive holds the mount directory itself, so it is extracted at the root
+	err = cli.CopyToContainer(streamCtx, containerID, "/", watchdog.Reader(file), types.CopyToContainerOptions{CopyUIDGID: true})
+	return watchdog.Err(err)
+}
+
+// createVolumeHelper creates (without starting) a container with the volume mounted at volumeMountPath
+func createVolumeHelper(cli *client.Client, volumeName string) (string, error) {
+	ctx, cancel := CallContext()
+	defer cancel()
+	response, err := cli.ContainerCreate(ctx,
+		&container.Config{Image: helperImage, Cmd: []string{"true"}},
+		&container.HostConfig{Mounts: []mount.Mount{{Type: mount.TypeVolume, Source: volumeName, Target: volumeMountPath}}},
+		nil, nil, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to create helper container from %s (use --helper-image to pick a local image): %v", helperImage, err)
+	}
+	return response.ID, nil
+}
+
+// removeVolumeHelper removes a helper container, leaving the volume in place
+func removeVolumeHelper(cli *client.Client, containerID string) {
+	ctx, cancel := CallContext()
+	defer cancel()
+	if err := cli.ContainerRemove(ctx, containerID, container.RemoveOptions{}); err != nil {
+		fmt.Printf("Warning: Failed to remove helper container %s: %v\n", ShortID(containerID), err)
+	}
+}
+
+// ListVolumes returns the names of the Docker volumes, sorted
+func ListVolumes(cli *client.Client) ([]string, error) {
+	ctx, cancel := CallContext()
+	defer cancel()
+	response, err := cli.VolumeList(ctx, volume.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(response.Volumes))
+	for _, v := range response.Volumes {
+		names = append(names, v.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SelectVolumes shows a multi-select prompt of volume names and returns the selected ones
+func SelectVolumes(names []string, message string) []string {
+	indexes := askMultiSelect(message, names, names)
+
+	selected := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		selected = append(selected, names[i])
+	}
+	return selected
+}
@@ -0,0 +1,243 @@
+// Package docs generates the manual pages and the machine-readable command reference of go-dkci from the flag sets
+// of its commands, so that packages ship documentation matching the binary. go-dkci has no cobra command tree; the
+// commands are the pflag flag sets main dispatches on, listed by it as Commands.
+package docs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// Command is a command of go-dkci as given on the command line, such as "export" or "volume import"
+type Command struct {
+	Name    string
+	Summary string
+	Usage   string
+	Flags   *pflag.FlagSet
+}
+
+// Reference describes the commands of a build of go-dkci
+type Reference struct {
+	Version string
+	// Date is the date printed on the manual pages
+	Date time.Time
+	// TempDir is replaced by ~/.cache/go-dkci in flag defaults, so that the pages do not depend on who built them
+	TempDir string
+	// Global holds the flags accepted by every command
+	Global   *pflag.FlagSet
+	Commands []Command
+	// Environment maps the environment variables go-dkci reads to their description
+	Environment [][2]string
+}
+
+// jsonReference is the machine-readable form of a Reference
+type jsonReference struct {
+	Version     string            `json:"version"`
+	Global      []jsonFlag        `json:"global_flags"`
+	Commands    []jsonCommand     `json:"commands"`
+	Environment map[string]string `json:"environment"`
+}
+
+type jsonCommand struct {
+	Name    string     `json:"name"`
+	Summary string     `json:"summary"`
+	Usage   string     `json:"usage"`
+	Flags   []jsonFlag `json:"flags"`
+}
+
+type jsonFlag struct {
+	Name      string `json:"name"`
+	Shorthand string `json:"shorthand,omitempty"`
+	Type      string `json:"type"`
+	Default   string `json:"default,omitempty"`
+	Usage     string `json:"usage"`
+}
+
+// BuildDate returns the date of reproducible builds from SOURCE_DATE_EPOCH, or the current date
+func BuildDate() time.Time {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		var seconds int64
+		if _, err := fmt.Sscan(epoch, &seconds); err == nil {
+			return time.Unix(seconds, 0).UTC()
+		}
+	}
+	return time.Now().UTC()
+}
+
+// WriteJSON writes the command and flag reference as JSON
+func (r *Reference) WriteJSON(w io.Writer) error {
+	out := jsonReference{
+		Version:     r.Version,
+		Global:      r.flags(r.Global),
+		Commands:    []jsonCommand{},
+		Environment: map[string]string{},
+	}
+	for _, command := range r.Commands {
+		out.Commands = append(out.Commands, jsonCommand{
+			Name:    command.Name,
+			Summary: command.Summary,
+			Usage:   r.usage(command),
+			Flags:   r.flags(command.Flags),
+		})
+	}
+	for _, variable := range r.Environment {
+		out.Environment[variable[0]] = variable[1]
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+// WriteManPages writes go-dkci.1 and a go-dkci-<command>.1 page for every command to dir and returns their paths
+func (r *Reference) WriteManPages(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var written []string
+	write := func(name, page string) error {
+		filePath := filepath.Join(dir, name+".1")
+		if err := os.WriteFile(filePath, []byte(page), 0644); err != nil {
+			return err
+		}
+		written = append(written, filePath)
+		return nil
+	}
+
+	if err := write("go-dkci", r.mainPage()); err != nil {
+		return written, err
+	}
+	for _, command := range r.Commands {
+		if err := write(pageName(command), r.commandPage(command)); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// mainPage renders go-dkci(1), listing the commands, global flags and environment
+func (r *Reference) mainPage() string {
+	var b strings.Builder
+	r.header(&b, "go-dkci")
+	b.WriteString(".SH NAME\ngo\\-dkci \\- manage Docker images with local archives and Baidu cloud\n")
+	b.WriteString(".SH SYNOPSIS\n.B go\\-dkci\n.I command\n[flags] [image or file names...]\n")
+	b.WriteString(".SH COMMANDS\n")
+	for _, command := range r.Commands {
+		fmt.Fprintf(&b, ".TP\n.B %s\n%s\nSee \\fB%s\\fR(1).\n", escape(command.Name), escape(command.Summary), escape(pageName(command)))
+	}
+	if r.Global != nil && r.Global.HasFlags() {
+		b.WriteString(".SH GLOBAL OPTIONS\n")
+		r.options(&b, r.Global)
+	}
+	if len(r.Environment) > 0 {
+		b.WriteString(".SH ENVIRONMENT\n")
+		for _, variable := range r.Environment {
+			fmt.Fprintf(&b, ".TP\n.B %s\n%s\n", escape(variable[0]), escape(variable[1]))
+		}
+	}
+	return b.String()
+}
+
+// commandPage renders the page of a command
+func (r *Reference) commandPage(command Command) string {
+	var b strings.Builder
+	r.header(&b, pageName(command))
+	fmt.Fprintf(&b, ".SH NAME\n%s \\- %s\n", escape(pageName(command)), escape(command.Summary))
+	fmt.Fprintf(&b, ".SH SYNOPSIS\n.B %s\n", escape(r.usage(command)))
+	if command.Flags != nil && command.Flags.HasAvailableFlags() {
+		b.WriteString(".SH OPTIONS\n")
+		r.options(&b, command.Flags)
+	}
+	b.WriteString(".SH SEE ALSO\n.BR go\\-dkci (1)\n")
+	return b.String()
+}
+
+// header writes the title line of a page
+func (r *Reference) header(b *strings.Builder, name string) {
+	fmt.Fprintf(b, ".TH %q \"1\" %q \"go-dkci %s\" \"go-dkci Manual\"\n",
+		strings.ToUpper(name), r.Date.Format("2006-01-02"), r.Version)
+}
+
+// options writes the visible flags of a flag set as tagged paragraphs
+func (r *Reference) options(b *strings.Builder, flagSet *pflag.FlagSet) {
+	for _, flag := range r.flags(flagSet) {
+		names := "\\fB\\-\\-" + escape(flag.Name) + "\\fR"
+		if flag.Shorthand != "" {
+			names = "\\fB\\-" + escape(flag.Shorthand) + "\\fR, " + names
+		}
+		if flag.Type != "bool" {
+			names += " \\fI" + escape(flag.Type) + "\\fR"
+		}
+		usage := flag.Usage
+		if flag.Default != "" {
+			usage += fmt.Sprintf(" (default %s)", flag.Default)
+		}
+		fmt.Fprintf(b, ".TP\n%s\n%s\n", names, escape(usage))
+	}
+}
+
+// flags lists the visible flags of a flag set in the order they were defined
+func (r *Reference) flags(flagSet *pflag.FlagSet) []jsonFlag {
+	flags := []jsonFlag{}
+	if flagSet == nil {
+		return flags
+	}
+	sorted := flagSet.SortFlags
+	flagSet.SortFlags = false
+	defer func() { flagSet.SortFlags = sorted }()
+
+	flagSet.VisitAll(func(flag *pflag.Flag) {
+		if flag.Hidden {
+			return
+		}
+		value, usage := flag.DefValue, flag.Usage
+		if r.TempDir != "" {
+			value = strings.ReplaceAll(value, r.TempDir, "~/.cache/go-dkci")
+			usage = strings.ReplaceAll(usage, r.TempDir, "~/.cache/go-dkci")
+		}
+		// Zero values are not worth printing as defaults
+		switch value {
+		case "false", "0", "[]", "":
+			value = ""
+		}
+		flags = append(flags, jsonFlag{
+			Name:      flag.Name,
+			Shorthand: flag.Shorthand,
+			Type:      flag.Value.Type(),
+			Default:   value,
+			Usage:     usage,
+		})
+	})
+	return flags
+}
+
+// usage returns the synopsis of a command
+func (r *Reference) usage(command Command) string {
+	if command.Usage != "" {
+		return "go-dkci " + command.Name + " " + command.Usage
+	}
+	return "go-dkci " + command.Name + " [flags]"
+}
+
+// pageName returns the name of the page of a command, such as go-dkci-volume-import
+func pageName(command Command) string {
+	return "go-dkci-" + strings.ReplaceAll(command.Name, " ", "-")
+}
+
+// escape quotes text for roff: backslashes, hyphens and lines that would start with a control character
+func escape(text string) string {
+	text = strings.ReplaceAll(text, "\\", "\\e")
+	text = strings.ReplaceAll(text, "-", "\\-")
+	if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "'") {
+		text = "\\&" + text
+	}
+	return text
+}
@@ -0,0 +1,163 @@
+package fileserver
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/docker"
+	"github.com/baowuhe/go-dkci/health"
+)
+
+// entry is a line of a directory index page
+type entry struct {
+	Name     string
+	Href     string
+	Size     string
+	Modified string
+	IsDir    bool
+}
+
+// indexPage lists a directory with the command to fetch each archive
+var indexPage = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>go-dkci: {{.Path}}</title></head>
+<body>
+<h1>{{.Path}}</h1>
+<p>Download an archive with <code>curl -fO &lt;link&gt;</code> (add <code>-C -</code> to resume) and load it with <code>go-dkci import --source &lt;file&gt;</code>.</p>
+<table>
+<tr><th align="left">Name</th><th align="right">Size</th><th align="left">Modified</th></tr>
+{{if ne .Path "/"}}<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{end}}{{range .Entries}}<tr><td><a href="{{.Href}}">{{.Name}}</a></td><td align="right">{{.Size}}</td><td>{{.Modified}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// Serve shares dir over HTTP on listen. Directories are shown as an index page and files are served
+// with range support, so interrupted downloads can be resumed with `curl -C -`. Hidden files and, when dir is the
+// working directory of go-dkci, its job state, caches and run directories are not served. /healthz and /readyz
+// report whether the server runs and whether dir can be read, along with the extra checks.
+func Serve(listen, dir string, checks ...health.Check) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	fmt.Printf("Serving %s on %s\n", dir, listen)
+	checks = append([]health.Check{health.Readable("directory", dir)}, checks...)
+	return http.ListenAndServe(listen, health.Handler(newHandler(dir), checks...))
+}
+
+// newHandler serves the index pages and files of dir, refusing the paths hidden by isHidden
+func newHandler(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	workDir := isWorkDir(dir)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Printf("%s %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+
+		if isHidden(path.Clean("/"+r.URL.Path), workDir) {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/") {
+			serveIndex(w, r, dir, workDir)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// isWorkDir reports whether dir is the working directory of go-dkci, which holds internal entries next to the
+// exported files
+func isWorkDir(dir string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	workDir, err := filepath.Abs(config.TempDir())
+	return err == nil && absDir == workDir
+}
+
+// isHidden reports whether the cleaned URL path names a hidden file or lies in one, such as the track file of
+// the working directory or the blob store of delta exports. In the working directory, the job state (which records
+// whole command lines), the registry and blob caches and the directories of runs are hidden as well.
+func isHidden(urlPath string, workDir bool) bool {
+	elements := strings.Split(strings.Trim(urlPath, "/"), "/")
+	for _, element := range elements {
+		if strings.HasPrefix(element, ".") {
+			return true
+		}
+	}
+	if !workDir {
+		return false
+	}
+	switch elements[0] {
+	case config.JobsDir, config.RegistryDir, config.BlobsDir:
+		return true
+	}
+	_, isRunDir := config.RunDirPID(elements[0])
+	return isRunDir
+}
+
+// serveIndex renders the index page of the directory requested by r
+func serveIndex(w http.ResponseWriter, r *http.Request, dir string, workDir bool) {
+	urlPath := path.Clean("/" + r.URL.Path)
+	localPath := filepath.Join(dir, filepath.FromSlash(urlPath))
+
+	dirEntries, err := os.ReadDir(localPath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var entries []entry
+	for _, dirEntry := range dirEntries {
+		// Skip the entries that are not served
+		if isHidden(path.Join(urlPath, dirEntry.Name()), workDir) {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+
+		e := entry{
+			Name:     dirEntry.Name(),
+			Href:     url.PathEscape(dirEntry.Name()),
+			Modified: info.ModTime().Format("2006-01-02 15:04:05"),
+			IsDir:    info.IsDir(),
+		}
+		if e.IsDir {
+			e.Name += "/"
+			e.Href += "/"
+		} else {
+			e.Size = docker.FormatSize(info.Size())
+		}
+		entries = append(entries, e)
+	}
+
+	// Directories first, then files by name
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
+		}
+		return entries[i].Name < entries[j].Name
+	})
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	indexPage.Execute(w, map[string]any{
+		"Path":    urlPath,
+		"Entries": entries,
+	})
+}
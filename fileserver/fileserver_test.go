@@ -0,0 +1,82 @@
+package fileserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandlerHidesInternalFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DKCI_TEMP_DIR", dir)
+	for _, name := range []string{
+		"nginx_1.26_linux_amd64.tar",
+		"team/app_1.0_linux_amd64.tar",
+		".go-dkci-files",
+		"jobs/1234.json",
+		"registry/blobs/abc",
+		"blobs/def",
+		"run-42-x/tmp.tar",
+		"team/.dkci-blobs/ghi",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	handler := newHandler(dir)
+	get := func(urlPath string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, urlPath, nil))
+		return recorder
+	}
+
+	for _, urlPath := range []string{"/nginx_1.26_linux_amd64.tar", "/team/app_1.0_linux_amd64.tar"} {
+		if code := get(urlPath).Code; code != http.StatusOK {
+			t.Errorf("GET %s = %d, want 200", urlPath, code)
+		}
+	}
+	for _, urlPath := range []string{
+		"/.go-dkci-files", "/jobs/1234.json", "/jobs/", "/registry/blobs/abc", "/blobs/def",
+		"/run-42-x/tmp.tar", "/team/.dkci-blobs/ghi", "/team/../jobs/1234.json",
+	} {
+		if code := get(urlPath).Code; code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", urlPath, code)
+		}
+	}
+
+	index := get("/").Body.String()
+	for _, name := range []string{"jobs", "registry", "blobs", "run-42-x", ".go-dkci-files"} {
+		if strings.Contains(index, ">"+name) {
+			t.Errorf("index lists %s:\n%s", name, index)
+		}
+	}
+	if !strings.Contains(index, "nginx_1.26_linux_amd64.tar") {
+		t.Errorf("index lacks the archive:\n%s", index)
+	}
+}
+
+func TestHandlerOutsideWorkDir(t *testing.T) {
+	t.Setenv("DKCI_TEMP_DIR", t.TempDir())
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "jobs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "jobs", "app.tar"), []byte("archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Other directories may use the names of the working directory for their own folders
+	recorder := httptest.NewRecorder()
+	newHandler(dir).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/jobs/app.tar", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("GET /jobs/app.tar = %d, want 200", recorder.Code)
+	}
+}
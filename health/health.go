@@ -0,0 +1,136 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/baowuhe/go-dkci/cloud"
+	"github.com/baowuhe/go-dkci/docker"
+)
+
+// Check is a condition a serving process needs to do its work, such as a reachable Docker daemon
+type Check struct {
+	Name string
+	// Run returns an error while the condition is not met
+	Run func() error
+}
+
+// checkTimeout bounds a readiness check, so that a hanging daemon or cloud answers /readyz with 503 in time
+const checkTimeout = 10 * time.Second
+
+// result is the outcome of a check as /readyz reports it
+type result struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Handler serves /healthz and /readyz for orchestrators and monitoring and passes the other requests on to next.
+// /healthz answers 200 while the process serves. /readyz runs the checks and answers 200 when all pass or 503 when
+// one fails, with the outcome of every check as JSON.
+func Handler(next http.Handler, checks ...Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte("ok\n"))
+		case "/readyz":
+			serveReady(w, checks)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// serveReady runs the checks concurrently and writes their outcome
+func serveReady(w http.ResponseWriter, checks []Check) {
+	results := make(map[string]result, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			outcome := result{Status: "ok"}
+			if err := run(check); err != nil {
+				outcome = result{Status: "failed", Error: err.Error()}
+			}
+			mu.Lock()
+			results[check.Name] = outcome
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	for _, outcome := range results {
+		if outcome.Status != "ok" {
+			status = http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(results)
+}
+
+// run runs a check, failing it when it takes longer than checkTimeout
+func run(check Check) error {
+	done := make(chan error, 1)
+	go func() { done <- check.Run() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(checkTimeout):
+		return fmt.Errorf("timed out after %s", checkTimeout)
+	}
+}
+
+// Docker checks that the Docker daemon of host, local or as accepted by docker.NewClient, answers a ping
+func Docker(host string) Check {
+	return Check{Name: "docker", Run: func() error {
+		cli, err := docker.NewClient(host)
+		if err != nil {
+			return err
+		}
+		defer cli.Close()
+		ctx, cancel := docker.CallContext()
+		defer cancel()
+		_, err = cli.Ping(ctx)
+		return err
+	}}
+}
+
+// Cloud checks that the folder dirPath of the storage can be listed, which fails once the Baidu access token has
+// expired and cannot be refreshed
+func Cloud(storage cloud.Storage, dirPath string) Check {
+	return Check{Name: "cloud", Run: func() error {
+		_, err := storage.ListFiles(dirPath)
+		return err
+	}}
+}
+
+// Writable checks that files can be created in dir, such as the cache of downloaded archives
+func Writable(name, dir string) Check {
+	return Check{Name: name, Run: func() error {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		file, err := os.CreateTemp(dir, ".readyz-*")
+		if err != nil {
+			return err
+		}
+		file.Close()
+		return os.Remove(file.Name())
+	}}
+}
+
+// Readable checks that the directory dir can be listed, such as the directory of a file server
+func Readable(name, dir string) Check {
+	return Check{Name: name, Run: func() error {
+		_, err := os.ReadDir(dir)
+		return err
+	}}
+}
@@ -0,0 +1,79 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	passing := Check{Name: "cache", Run: func() error { return nil }}
+	failing := Check{Name: "cloud", Run: func() error { return errors.New("token expired") }}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		checks []Check
+		status int
+		failed string
+	}{
+		{"liveness ignores checks", "/healthz", []Check{failing}, http.StatusOK, ""},
+		{"ready without checks", "/readyz", nil, http.StatusOK, ""},
+		{"ready", "/readyz", []Check{passing}, http.StatusOK, ""},
+		{"not ready", "/readyz", []Check{passing, failing}, http.StatusServiceUnavailable, "cloud"},
+		{"other paths pass through", "/v2/", []Check{failing}, http.StatusTeapot, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			Handler(next, tt.checks...).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if recorder.Code != tt.status {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.status)
+			}
+			if tt.path != "/readyz" {
+				return
+			}
+			var results map[string]result
+			if err := json.Unmarshal(recorder.Body.Bytes(), &results); err != nil {
+				t.Fatalf("invalid body %q: %v", recorder.Body.String(), err)
+			}
+			if len(results) != len(tt.checks) {
+				t.Fatalf("got %d results, want %d", len(results), len(tt.checks))
+			}
+			for name, outcome := range results {
+				if failed := outcome.Status != "ok"; failed != (name == tt.failed) {
+					t.Errorf("check %s: status %q, error %q", name, outcome.Status, outcome.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestWritable(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	if err := Writable("cache", dir).Run(); err != nil {
+		t.Fatalf("Writable() = %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Writable() left %v, %v", entries, err)
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Writable("cache", file).Run(); err == nil {
+		t.Error("Writable() of a file succeeded")
+	}
+	if err := Readable("directory", filepath.Join(dir, "missing")).Run(); err == nil {
+		t.Error("Readable() of a missing directory succeeded")
+	}
+}
@@ -0,0 +1,93 @@
+package hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/baowuhe/go-dkci/config"
+)
+
+// The hooks, named as the keys of the configuration file setting them
+const (
+	PreExport  = "pre_export"
+	PostExport = "post_export"
+	PreImport  = "pre_import"
+	PostImport = "post_import"
+)
+
+var (
+	// commands maps the hooks to their shell commands; hooks without a command are not run
+	commands = map[string]string{}
+	// disabled skips every hook, for runs that must not trigger them
+	disabled bool
+)
+
+// Context describes the operation a hook runs around. It is written to the stdin of the hook as JSON and set
+// in its environment as DKCI_HOOK, DKCI_IMAGE, DKCI_ARCHIVE, DKCI_CLOUD_PATH and DKCI_IMAGES (comma-separated).
+type Context struct {
+	Hook string `json:"hook"`
+	// Image is the exported image
+	Image string `json:"image,omitempty"`
+	// Archive is the local archive being exported or imported; before an export it does not exist yet
+	Archive string `json:"archive,omitempty"`
+	// CloudPath is where a cloud export uploads the archive
+	CloudPath string `json:"cloud_path,omitempty"`
+	// Images are the images an import loaded
+	Images []string `json:"images,omitempty"`
+}
+
+// SetHooks sets the commands of the hooks, usually from config.GetHooks
+func SetHooks(hooks config.Hooks) {
+	commands = map[string]string{
+		PreExport:  hooks.PreExport,
+		PostExport: hooks.PostExport,
+		PreImport:  hooks.PreImport,
+		PostImport: hooks.PostImport,
+	}
+}
+
+// Disable keeps the hooks from running
+func Disable() {
+	disabled = true
+}
+
+// Run runs the command of a hook, if it has one, through the shell. The output of the command passes through, and
+// the command failing is an error, so that a pre hook can stop the export or import.
+func Run(context Context) error {
+	command := commands[context.Hook]
+	if disabled || command == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(context)
+	if err != nil {
+		return err
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(),
+		"DKCI_HOOK="+context.Hook,
+		"DKCI_IMAGE="+context.Image,
+		"DKCI_ARCHIVE="+context.Archive,
+		"DKCI_CLOUD_PATH="+context.CloudPath,
+		"DKCI_IMAGES="+strings.Join(context.Images, ","),
+	)
+	cmd.Stdin = strings.NewReader(string(data) + "\n")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	fmt.Printf("Running %s hook: %s\n", context.Hook, command)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook failed: %v", context.Hook, err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Package i18n translates the prompts, errors and summaries of go-dkci. Messages are written in English in the code
+// and looked up by their format string in the catalog of the selected language, so a message without a translation
+// is printed in English.
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Supported languages
+const (
+	English = "en"
+	Chinese = "zh-CN"
+)
+
+// catalogs maps a language to its translations, keyed by the English format string
+var catalogs = map[string]map[string]string{
+	Chinese: zhCN,
+}
+
+// language is the language messages are printed in
+var language = English
+
+func init() {
+	language = FromEnvironment()
+}
+
+// FromEnvironment returns the language of the locale set in LC_ALL, LC_MESSAGES or LANG, in that order, and English
+// when none of them names a supported language
+func FromEnvironment() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale := os.Getenv(name); locale != "" {
+			if lang, err := Parse(locale); err == nil {
+				return lang
+			}
+			return English
+		}
+	}
+	return English
+}
+
+// Parse returns the supported language of a language name or locale such as en, zh, zh-CN or zh_CN.UTF-8
+func Parse(name string) (string, error) {
+	// Drop the encoding and modifier of locales such as zh_CN.UTF-8@pinyin
+	locale := strings.ToLower(name)
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	locale = strings.ReplaceAll(locale, "_", "-")
+
+	switch {
+	case locale == "c" || locale == "posix" || locale == "en" || strings.HasPrefix(locale, "en-"):
+		return English, nil
+	case locale == "zh" || locale == "zh-cn" || locale == "zh-hans" || locale == "zh-sg":
+		return Chinese, nil
+	}
+	return "", fmt.Errorf("unsupported language %q (expected %s or %s)", name, English, Chinese)
+}
+
+// SetLanguage selects the language messages are printed in
+func SetLanguage(name string) error {
+	lang, err := Parse(name)
+	if err != nil {
+		return err
+	}
+	language = lang
+	return nil
+}
+
+// Language returns the language messages are printed in
+func Language() string {
+	return language
+}
+
+// T returns the translation of an English message or format string, or the message itself if it has none
+func T(message string) string {
+	if translated, ok := catalogs[language][message]; ok {
+		return translated
+	}
+	return message
+}
+
+// Sprintf formats a translated format string
+func Sprintf(format string, args ...any) string {
+	return fmt.Sprintf(T(format), args...)
+}
+
+// Printf prints a translated format string to stdout
+func Printf(format string, args ...any) {
+	fmt.Printf(T(format), args...)
+}
+
+// Println prints a translated message to stdout
+func Println(message string) {
+	fmt.Println(T(message))
+}
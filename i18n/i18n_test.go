@@ -0,0 +1,124 @@
+package i18n
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "en", want: English},
+		{name: "en_US.UTF-8", want: English},
+		{name: "en-GB", want: English},
+		{name: "C", want: English},
+		{name: "POSIX", want: English},
+		{name: "zh", want: Chinese},
+		{name: "zh-CN", want: Chinese},
+		{name: "zh_CN.UTF-8", want: Chinese},
+		{name: "zh_CN.UTF-8@pinyin", want: Chinese},
+		{name: "zh-Hans", want: Chinese},
+		{name: "zh_SG", want: Chinese},
+		{name: "zh_TW.UTF-8", wantErr: true},
+		{name: "fr_FR.UTF-8", wantErr: true},
+		{name: "english", wantErr: true},
+		{name: "", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := Parse(test.name)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q) = %q, want an error", test.name, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("Parse(%q) = %q, %v, want %q", test.name, got, err, test.want)
+		}
+	}
+}
+
+func TestFromEnvironment(t *testing.T) {
+	tests := []struct {
+		lcAll, lcMessages, lang string
+		want                    string
+	}{
+		{"", "", "", English},
+		{"", "", "zh_CN.UTF-8", Chinese},
+		{"", "zh_CN.UTF-8", "en_US.UTF-8", Chinese},
+		{"en_US.UTF-8", "zh_CN.UTF-8", "zh_CN.UTF-8", English},
+		// An unsupported locale selects English rather than falling through to the next variable
+		{"fr_FR.UTF-8", "", "zh_CN.UTF-8", English},
+	}
+	for _, test := range tests {
+		t.Setenv("LC_ALL", test.lcAll)
+		t.Setenv("LC_MESSAGES", test.lcMessages)
+		t.Setenv("LANG", test.lang)
+		if got := FromEnvironment(); got != test.want {
+			t.Errorf("LC_ALL=%q LC_MESSAGES=%q LANG=%q: FromEnvironment = %q, want %q", test.lcAll, test.lcMessages, test.lang, got, test.want)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	t.Cleanup(func() { language = English })
+
+	message := "[√] All items of job %s are done\n"
+	if err := SetLanguage("en"); err != nil {
+		t.Fatal(err)
+	}
+	if got := T(message); got != message {
+		t.Errorf("English T = %q", got)
+	}
+	if err := SetLanguage("zh_CN.UTF-8"); err != nil {
+		t.Fatal(err)
+	}
+	if got := T(message); got == message {
+		t.Errorf("no Chinese translation of %q", message)
+	}
+	if got := T("untranslated message"); got != "untranslated message" {
+		t.Errorf("untranslated T = %q", got)
+	}
+	if err := SetLanguage("fr"); err == nil || Language() != Chinese {
+		t.Errorf("SetLanguage(fr) = %v, language %s", err, Language())
+	}
+}
+
+// arguments returns the verb taking each argument of a format string, resolving explicit indexes such as %[2]s
+func arguments(format string) []string {
+	var args []string
+	next := 0
+	for _, match := range verbPattern.FindAllStringSubmatch(format, -1) {
+		if match[2] == "%" {
+			continue
+		}
+		if match[1] != "" {
+			next, _ = strconv.Atoi(match[1])
+			next--
+		}
+		for len(args) <= next {
+			args = append(args, "")
+		}
+		args[next] = match[2]
+		next++
+	}
+	return args
+}
+
+var verbPattern = regexp.MustCompile(`%(?:\[(\d+)\])?[-+# 0-9.]*([a-zA-Z%])`)
+
+func TestCatalogVerbs(t *testing.T) {
+	// A translation must take the same arguments as its English format string, in any order
+	for lang, catalog := range catalogs {
+		for message, translated := range catalog {
+			if want, got := arguments(message), arguments(translated); !slices.Equal(got, want) {
+				t.Errorf("%s translation of %q takes %q, want %q", lang, message, got, want)
+			}
+		}
+	}
+}
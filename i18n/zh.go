@@ -0,0 +1,273 @@
+package i18n
+
+// zhCN holds the Simplified Chinese translations. The verbs of a translation must take the arguments in the order of
+// the English format string.
+var zhCN = map[string]string{
+	// Prompts
+	"All":                             "全部",
+	"Select Docker images to export:": "选择要导出的 Docker 镜像：",
+	"Select Docker images to export to cloud:":                   "选择要导出到百度网盘的 Docker 镜像：",
+	"Select Docker images to delete:":                            "选择要删除的 Docker 镜像：",
+	"Select Docker images to copy:":                              "选择要复制的 Docker 镜像：",
+	"Select Docker images to send:":                              "选择要发送的 Docker 镜像：",
+	"Select additional images to back up:":                       "选择要额外备份的镜像：",
+	"Select .tar files to import as Docker images:":              "选择要导入为 Docker 镜像的 .tar 文件：",
+	"Select .tar files to download and import as Docker images:": "选择要下载并导入为 Docker 镜像的 .tar 文件：",
+	"Select .tar files to repack:":                               "选择要重新打包的 .tar 文件：",
+	"Select additional volumes to back up:":                      "选择要额外备份的数据卷：",
+	"Type 'yes' to confirm deletion: ":                           "输入 'yes' 确认删除：",
+	"used by %s":                                                 "被 %s 使用",
+	"Export %d image(s)?":                                        "导出 %d 个镜像？",
+	"Policy requires confirmation (%s). Export %s to %s?":        "策略要求确认（%s）。将 %s 导出到 %s？",
+	"\nFound %d file(s) created by go-dkci in cache directory. Are you sure you want to delete them?\n": "\n缓存目录中有 %d 个由 go-dkci 创建的文件。确定要删除吗？\n",
+
+	// Interactive browser
+	"Browse local images":              "浏览本地镜像",
+	"Browse cloud folder":              "浏览网盘目录",
+	"Search":                           "搜索",
+	"Quit":                             "退出",
+	"Export to local directory":        "导出到本地目录",
+	"Export to Baidu cloud":            "导出到百度网盘",
+	"Delete":                           "删除",
+	"Import into Docker":               "导入到 Docker",
+	"Back":                             "返回",
+	"%s (current: %s)":                 "%s（当前：%s）",
+	"Local images (%d):":               "本地镜像（%d）：",
+	"Cloud folder %s:":                 "网盘目录 %s：",
+	"Search pattern (empty to clear):": "搜索关键字（留空清除）：",
+	"Export directory:":                "导出目录：",
+	"Baidu cloud folder:":              "百度网盘目录：",
+	"Delete image %s?":                 "删除镜像 %s？",
+
+	// Progress and summaries
+	"%d delta recipe(s) use %d layer(s); %d unreferenced layer(s) hold %s\n":              "%d 个增量配方使用 %d 个层；%d 个未引用的层占用 %s\n",
+	"%d file(s) to delete, %s in total\n":                                                 "将删除 %d 个文件，共 %s\n",
+	"%d file(s) to promote to %s, %s in total\n":                                          "将推送 %[1]d 个文件到 %[2]s，共 %[3]s\n",
+	"[√] Promoted %s to %s\n":                                                             "[√] 已将 %s 推送到 %s\n",
+	"[√] Moved %s to %s\n":                                                                "[√] 已将 %s 移动到 %s\n",
+	"[x] Failed to move %s: %v\n":                                                         "[x] 移动 %s 失败：%v\n",
+	"[x] %s already exists\n":                                                             "[x] %s 已存在\n",
+	"[x] Cannot move %s into itself\n":                                                    "[x] 不能将 %s 移动到其自身中\n",
+	"[x] Failed to promote %s: %v\n":                                                      "[x] 推送 %s 失败：%v\n",
+	"[x] %d of %d archive(s) could not be promoted\n":                                     "[x] %[2]d 个文件中有 %[1]d 个推送失败\n",
+	"[x] %s is already in %s\n":                                                           "[x] %s 已在 %s 中\n",
+	"[x] No archives match the source patterns":                                           "[x] 没有与源路径匹配的文件",
+	"Selected images: %v\n":                                                               "已选择镜像：%v\n",
+	"Total: %s, about %s once compressed with %s\n":                                       "合计：%s，使用 %[3]s 压缩后约 %[2]s\n",
+	"Total: %s (about %s if compressed with %s)\n":                                        "合计：%s（若使用 %[3]s 压缩约 %[2]s）\n",
+	"Importing %d files, %d at a time\n":                                                  "正在导入 %d 个文件，每次 %d 个\n",
+	"%d of %d images would be exported (%s)\n":                                            "%d/%d 个镜像将被导出（%s）\n",
+	"Importing image from file: %s\n":                                                     "正在从文件导入镜像：%s\n",
+	"No delta export layers found in %s\n":                                                "%s 中没有增量导出的层\n",
+	"No matching files found in %s\n":                                                     "%s 中没有匹配的文件\n",
+	"Leaving %d file(s) in %s that were not created by go-dkci\n":                         "保留 %[2]s 中 %[1]d 个非 go-dkci 创建的文件\n",
+	"No files created by go-dkci found in cache directory: %s\n":                          "缓存目录中没有 go-dkci 创建的文件：%s\n",
+	"Keeping the job state in %s\n":                                                       "保留任务状态：%s\n",
+	"Keeping %s, in use by running process %d\n":                                          "保留 %s，正被运行中的进程 %d 使用\n",
+	"[√] %s is already up to date at %s\n":                                                "[√] %s 已是最新：%s\n",
+	"[√] Skipped %s, %s already exists\n":                                                 "[√] 已跳过 %s，%s 已存在\n",
+	"[√] %s is already in the requested format\n":                                         "[√] %s 已是要求的格式\n",
+	"[√] All items of job %s are done\n":                                                  "[√] 任务 %s 的所有条目均已完成\n",
+	"[√] All %d images are up to date\n":                                                  "[√] 全部 %d 个镜像均已是最新\n",
+	"[√] Attestation signature verified":                                                  "[√] 证明签名验证通过",
+	"[√] Backed up %d image(s), %d volume(s) and %d compose file(s) to %s\n":              "[√] 已备份 %d 个镜像、%d 个数据卷和 %d 个 compose 文件到 %s\n",
+	"[√] Bundle %s is up to date: %d image(s), %d file(s) removed\n":                      "[√] 清单 %s 已是最新：%d 个镜像，已删除 %d 个文件\n",
+	"[√] Committed container %s as %s (%s)\n":                                             "[√] 已将容器 %s 提交为 %s（%s）\n",
+	"[√] Dry run: %d image(s) to export, %d file(s) to remove\n":                          "[√] 演练：将导出 %d 个镜像，删除 %d 个文件\n",
+	"[√] Materialized %s as %s\n":                                                         "[√] 已将 %s 还原为 %s\n",
+	"[√] Preload DaemonSet %s/%s applied for %d file(s)\n":                                "[√] 已为 %[3]d 个文件应用预加载 DaemonSet %[1]s/%[2]s\n",
+	"[√] Queued job %s; follow it with: go-dkci status %s\n":                              "[√] 任务 %s 已加入队列，查看进度：go-dkci status %s\n",
+	"[√] Repacked %s as %s (%s -> %s)\n":                                                  "[√] 已将 %s 重新打包为 %s（%s -> %s）\n",
+	"[√] Removed %d file(s) from %s, reclaiming %s\n":                                     "[√] 已从 %[2]s 删除 %[1]d 个文件，释放 %[3]s\n",
+	"[√] Removed %d unreferenced layer(s), reclaiming %s in Baidu cloud and %s locally\n": "[√] 已删除 %d 个未引用的层，释放百度网盘 %s、本地 %s\n",
+	"[√] Restored %d image(s), %d volume(s) and %d compose file(s)\n":                     "[√] 已恢复 %d 个镜像、%d 个数据卷和 %d 个 compose 文件\n",
+	"[√] Successfully cleaned cache directory. Deleted %d file(s)\n":                      "[√] 缓存目录清理完成，已删除 %d 个文件\n",
+	"[√] Successfully copied %d image(s) (%s in %s)\n":                                    "[√] 已复制 %d 个镜像（%s，用时 %s）\n",
+	"[√] Successfully copied %s to %s (%s)\n":                                             "[√] 已将 %s 复制到 %s（%s）\n",
+	"[√] Successfully deleted image %s\n":                                                 "[√] 已删除镜像 %s\n",
+	"[√] Successfully exported and uploaded container %s to %s\n":                         "[√] 已导出容器 %s 并上传到 %s\n",
+	"[√] Successfully exported and uploaded image %s to %s\n":                             "[√] 已导出镜像 %s 并上传到 %s\n",
+	"[√] Successfully exported and uploaded volume %s to %s\n":                            "[√] 已导出数据卷 %s 并上传到 %s\n",
+	"[√] Successfully exported container %s to %s\n":                                      "[√] 已将容器 %s 导出到 %s\n",
+	"[√] Successfully exported image %s to %s\n":                                          "[√] 已将镜像 %s 导出到 %s\n",
+	"[√] Successfully exported volume %s to %s\n":                                         "[√] 已将数据卷 %s 导出到 %s\n",
+	"[√] Successfully imported %s from %s\n":                                              "[√] 已导入 %s（来自 %s）\n",
+	"[√] Successfully imported %s into volume %s\n":                                       "[√] 已将 %s 导入数据卷 %s\n",
+	"[√] Successfully imported image from %s\n":                                           "[√] 已从 %s 导入镜像\n",
+	"[√] Successfully loaded %s into kind cluster %s from %s\n":                           "[√] 已将 %s 加载到 kind 集群 %s（来自 %s）\n",
+	"[√] Successfully loaded %s into kind cluster %s\n":                                   "[√] 已将 %s 加载到 kind 集群 %s\n",
+	"[√] Successfully logged in to Baidu cloud":                                           "[√] 百度网盘登录成功",
+	"[√] Successfully received %s from %s (sha256 %s)\n":                                  "[√] 已接收 %s（来自 %s，sha256 %s）\n",
+	"[√] Successfully sent %d image(s)\n":                                                 "[√] 已发送 %d 个镜像\n",
+	"[√] Tagged %s as %s\n":                                                               "[√] 已将 %s 标记为 %s\n",
+	"[√] Uploaded %s to Baidu cloud at %s\n":                                              "[√] 已将 %s 上传到百度网盘 %s\n",
+	"[√] Using directory %s as cloud storage\n":                                           "[√] 使用目录 %s 作为网盘存储\n",
+	"[√] Using storage plugin %s\n":                                                       "[√] 使用存储插件 %s\n",
+
+	// Errors
+	"[x] %d of %d file(s) failed to import\n":                                                                            "[x] %d 个文件导入失败（共 %d 个）\n",
+	"[x] %d of %d file(s) could not be removed\n":                                                                        "[x] %[2]d 个文件中有 %[1]d 个无法删除\n",
+	"[x] %d of %d item(s) of job %s did not complete; continue with: go-dkci resume %s\n":                                "[x] 任务 %[3]s 有 %[1]d 个条目未完成（共 %[2]d 个），继续执行：go-dkci resume %[4]s\n",
+	"[x] Attestation signature verification failed: %v\n":                                                                "[x] 证明签名验证失败：%v\n",
+	"[x] Backup failed: %v\n":                                                                                            "[x] 备份失败：%v\n",
+	"[x] Backup upload failed: %v\n":                                                                                     "[x] 备份上传失败：%v\n",
+	"[x] Cache cleanup cancelled by user":                                                                                "[x] 用户取消了缓存清理",
+	"[x] Export cancelled by user":                                                                                       "[x] 用户取消了导出",
+	"[x] Cannot ask for confirmation without a terminal, pass --yes to delete":                                           "[x] 没有终端，无法请求确认，请使用 --yes 进行删除",
+	"[x] Cannot prompt for a selection without a terminal, name the items on the command line or pass --all":             "[x] 没有终端，无法显示选择列表，请在命令行中指定条目或使用 --all",
+	"[x] Cache directory does not exist: %s\n":                                                                           "[x] 缓存目录不存在：%s\n",
+	"[x] Docker image not found: %s\n":                                                                                   "[x] 找不到 Docker 镜像：%s\n",
+	"[x] Error accessing cloud file %s: %v\n":                                                                            "[x] 访问网盘文件 %s 出错：%v\n",
+	"[x] Error accessing source: %v\n":                                                                                   "[x] 访问来源出错：%v\n",
+	"[x] Error accessing cloud folder %s: %v\n":                                                                          "[x] 访问网盘文件夹 %s 出错：%v\n",
+	"[x] No run folders found in %s\n":                                                                                   "[x] %s 中没有找到运行文件夹\n",
+	"[x] Error: --run-folder-name requires --run-folder":                                                                 "[x] 错误：--run-folder-name 需要 --run-folder",
+	"[x] Error finding .tar files: %v\n":                                                                                 "[x] 查找 .tar 文件出错：%v\n",
+	"[x] Error getting BDFS configuration: %v\n":                                                                         "[x] 获取百度网盘配置出错：%v\n",
+	"[x] Error reading Kubernetes manifests: %v\n":                                                                       "[x] 读取 Kubernetes 清单出错：%v\n",
+	"[x] Error rendering Helm chart: %v\n":                                                                               "[x] 渲染 Helm chart 出错：%v\n",
+	"[x] Error: %v\n":                                                                                                    "[x] 错误：%v\n",
+	"[x] Error: --also-tag cannot be combined with --kind":                                                               "[x] 错误：--also-tag 不能与 --kind 同时使用",
+	"[x] Error: --archive cannot be combined with --delta or --sbom":                                                     "[x] 错误：--archive 不能与 --delta 或 --sbom 同时使用",
+	"[x] Error: --delta cannot be combined with --compress":                                                              "[x] 错误：--delta 不能与 --compress 同时使用",
+	"[x] Error: --delta cannot be combined with --sign":                                                                  "[x] 错误：--delta 不能与 --sign 同时使用",
+	"[x] Error: --from and --to must name different Docker hosts":                                                        "[x] 错误：--from 和 --to 必须是不同的 Docker 主机",
+	"[x] Error: --from flag is required for k8s preload command":                                                         "[x] 错误：k8s preload 命令需要 --from 参数",
+	"[x] Error: --from is required for receive command":                                                                  "[x] 错误：receive 命令需要 --from 参数",
+	"[x] Error: --key is required with --sign":                                                                           "[x] 错误：使用 --sign 时需要 --key",
+	"[x] Error: --key is required with --verify-signature":                                                               "[x] 错误：使用 --verify-signature 时需要 --key",
+	"[x] Error: --level requires --to gzip or zstd":                                                                      "[x] 错误：--level 需要 --to gzip 或 zstd",
+	"[x] Error: --output json and --progress jsonl cannot both write to stdout, set --progress-fd":                       "[x] 错误：--output json 和 --progress jsonl 不能同时写入标准输出，请设置 --progress-fd",
+	"[x] Error: --squash cannot be combined with --with-all-tags":                                                        "[x] 错误：--squash 不能与 --with-all-tags 同时使用",
+	"[x] Error: --stream requires --cloud":                                                                               "[x] 错误：--stream 需要 --cloud",
+	"[x] Error: --stream cannot be combined with --delta, --archive, --sbom, --attest or --sign":                         "[x] 错误：--stream 不能与 --delta、--archive、--sbom、--attest 或 --sign 同时使用",
+	"[x] Error: --stream requires a dir:// backend, Baidu cloud and storage plugins only upload local files":             "[x] 错误：--stream 需要 dir:// 后端，百度网盘和存储插件只能上传本地文件",
+	"[x] Error: --tls-cert and --tls-key must be used together":                                                          "[x] 错误：--tls-cert 和 --tls-key 必须同时使用",
+	"[x] Error: --to is required for repack command":                                                                     "[x] 错误：repack 命令需要 --to 参数",
+	"[x] Error: --upload-retries must not be negative":                                                                   "[x] 错误：--upload-retries 不能为负数",
+	"[x] Error: -d and -c flags are mutually exclusive":                                                                  "[x] 错误：-d 和 -c 参数不能同时使用",
+	"[x] Error: -f/--file flag is required for apply command":                                                            "[x] 错误：apply 命令需要 -f/--file 参数",
+	"[x] Error: -s and -c flags are mutually exclusive":                                                                  "[x] 错误：-s 和 -c 参数不能同时使用",
+	"[x] Error: -s/--source and -c/--cloud flags are mutually exclusive":                                                 "[x] 错误：-s/--source 和 -c/--cloud 参数不能同时使用",
+	"[x] Error: cloud prune requires --grep, --older-than, --newer-than or --since":                                      "[x] 错误：cloud prune 需要 --grep、--older-than、--newer-than 或 --since 参数",
+	"[x] Error: cloud prune requires a folder, or default_cloud_dir in the configuration file":                           "[x] 错误：cloud prune 需要指定目录，或在配置文件中设置 default_cloud_dir",
+	"[x] Error: promote requires the files to promote and a target folder":                                               "[x] 错误：promote 需要指定要推送的文件和目标目录",
+	"[x] Error: cloud prune takes at most one folder":                                                                    "[x] 错误：cloud prune 最多接受一个目录",
+	"[x] Error: either -s/--source or -c/--cloud flag is required for import command":                                    "[x] 错误：import 命令需要 -s/--source 或 -c/--cloud 参数",
+	"[x] Error: either -s/--source or -c/--cloud flag is required for repack command":                                    "[x] 错误：repack 命令需要 -s/--source 或 -c/--cloud 参数",
+	"[x] Error: either -s/--source or -c/--cloud flag is required for volume import command":                             "[x] 错误：volume import 命令需要 -s/--source 或 -c/--cloud 参数",
+	"[x] Error: exactly one of -s/--source or -c/--cloud is required for restore command":                                "[x] 错误：restore 命令需要且只能使用 -s/--source 或 -c/--cloud 之一",
+	"[x] Error: export-container requires at least one container name or ID":                                             "[x] 错误：export-container 至少需要一个容器名称或 ID",
+	"[x] Error: gc requires a folder, or default_cloud_dir in the configuration file":                                    "[x] 错误：gc 需要指定目录，或在配置文件中设置 default_cloud_dir",
+	"[x] Error: gc takes at most one folder":                                                                             "[x] 错误：gc 最多接受一个目录",
+	"[x] Error: inspect requires exactly one archive path":                                                               "[x] 错误：inspect 需要且只能指定一个归档文件路径",
+	"[x] Error: resume takes a single job id":                                                                            "[x] 错误：resume 只接受一个任务 ID",
+	"[x] Error: snapshot requires exactly one container name or ID":                                                      "[x] 错误：snapshot 需要且只能指定一个容器名称或 ID",
+	"[x] Error: status takes at most one job id":                                                                         "[x] 错误：status 最多接受一个任务 ID",
+	"[x] Error: log takes no arguments":                                                                                  "[x] 错误：log 不接受参数",
+	"[x] Error: outdated takes no arguments":                                                                             "[x] 错误：outdated 不接受参数",
+	"[x] Error: the ui command needs a terminal, use export, import or delete in scripts":                                "[x] 错误：ui 命令需要终端，脚本中请使用 export、import 或 delete",
+	"[x] Error: unknown cloud subcommand (expected prune or mv)":                                                         "[x] 错误：未知的 cloud 子命令（应为 prune 或 mv）",
+	"[x] Error: cloud mv requires a source and a target path":                                                            "[x] 错误：cloud mv 需要指定源路径和目标路径",
+	"[x] Error: unknown k8s subcommand (expected preload)":                                                               "[x] 错误：未知的 k8s 子命令（应为 preload）",
+	"[x] Error: unknown volume subcommand (expected export or import)":                                                   "[x] 错误：未知的 volume 子命令（应为 export 或 import）",
+	"[x] Error: unsupported output format %q (expected text or json)\n":                                                  "[x] 错误：不支持的输出格式 %q（应为 text 或 json）\n",
+	"[x] Error: volume export requires at least one volume name":                                                         "[x] 错误：volume export 至少需要一个数据卷名称",
+	"[x] Failed to apply preload DaemonSet: %v\n":                                                                        "[x] 应用预加载 DaemonSet 失败：%v\n",
+	"[x] Failed to commit container %s: %v\n":                                                                            "[x] 提交容器 %s 失败：%v\n",
+	"[x] Failed to connect to Docker on %s: %v\n":                                                                        "[x] 连接 %s 上的 Docker 失败：%v\n",
+	"[x] Failed to copy %s to %s: %v\n":                                                                                  "[x] 将 %s 复制到 %s 失败：%v\n",
+	"[x] Failed to create Docker client for %s: %v\n":                                                                    "[x] 为 %s 创建 Docker 客户端失败：%v\n",
+	"[x] Failed to create Docker client: %v\n":                                                                           "[x] 创建 Docker 客户端失败：%v\n",
+	"[x] Failed to create destination directory %s: %v\n":                                                                "[x] 创建目标目录 %s 失败：%v\n",
+	"[x] Failed to create directory %s: %v\n":                                                                            "[x] 创建目录 %s 失败：%v\n",
+	"[x] Failed to create temp directory %s: %v\n":                                                                       "[x] 创建临时目录 %s 失败：%v\n",
+	"[x] Failed to delete %s: %v\n":                                                                                      "[x] 删除 %s 失败：%v\n",
+	"[x] Failed to delete image %s: %v\n":                                                                                "[x] 删除镜像 %s 失败：%v\n",
+	"[x] Failed to download %s from Baidu cloud: %v\n":                                                                   "[x] 从百度网盘下载 %s 失败：%v\n",
+	"[x] Failed to download attestation %s from Baidu cloud: %v\n":                                                       "[x] 从百度网盘下载证明 %s 失败：%v\n",
+	"[x] Failed to encrypt %s: %v\n":                                                                                     "[x] 加密 %s 失败：%v\n",
+	"[x] Failed to export %s, nothing was pruned\n":                                                                      "[x] 导出 %s 失败，未删除任何文件\n",
+	"[x] Failed to export container %s: %v\n":                                                                            "[x] 导出容器 %s 失败：%v\n",
+	"[x] Failed to export volume %s: %v\n":                                                                               "[x] 导出数据卷 %s 失败：%v\n",
+	"[x] Failed to generate SBOM for %s: %v\n":                                                                           "[x] 为 %s 生成 SBOM 失败：%v\n",
+	"[x] Failed to generate preload DaemonSet: %v\n":                                                                     "[x] 生成预加载 DaemonSet 失败：%v\n",
+	"[x] Failed to get user input: %v\n":                                                                                 "[x] 获取用户输入失败：%v\n",
+	"[x] Failed to get user selection: %v\n":                                                                             "[x] 获取用户选择失败：%v\n",
+	"[x] Failed to import %s into volume %s: %v\n":                                                                       "[x] 将 %s 导入数据卷 %s 失败：%v\n",
+	"[x] Failed to inspect image %s: %v\n":                                                                               "[x] 检查镜像 %s 失败：%v\n",
+	"[x] Failed to list %s: %v\n":                                                                                        "[x] 列出 %s 失败：%v\n",
+	"[x] Failed to list Docker images: %v\n":                                                                             "[x] 列出 Docker 镜像失败：%v\n",
+	"[x] Failed to list cloud folder %s: %v\n":                                                                           "[x] 列出网盘目录 %s 失败：%v\n",
+	"[x] Failed to list containers: %v\n":                                                                                "[x] 列出容器失败：%v\n",
+	"[x] Failed to list jobs: %v\n":                                                                                      "[x] 列出任务失败：%v\n",
+	"[x] Failed to list volumes: %v\n":                                                                                   "[x] 列出数据卷失败：%v\n",
+	"[x] Failed to listen on %s: %v\n":                                                                                   "[x] 监听 %s 失败：%v\n",
+	"[x] Failed to load images on %s: %v\n":                                                                              "[x] 在 %s 上加载镜像失败：%v\n",
+	"[x] Failed to load received images: %v\n":                                                                           "[x] 加载接收的镜像失败：%v\n",
+	"[x] Failed to receive images from %s: %v\n":                                                                         "[x] 从 %s 接收镜像失败：%v\n",
+	"[x] Failed to generate a transfer token: %v\n":                                                                      "[x] 生成传输令牌失败：%v\n",
+	"[x] Error: receive needs the token printed by send, in --token or %s\n":                                             "[x] 错误：receive 需要 send 打印的令牌，请通过 --token 或 %s 提供\n",
+	"[x] Failed to locate the go-dkci executable: %v\n":                                                                  "[x] 找不到 go-dkci 可执行文件：%v\n",
+	"[x] Failed to login to Baidu cloud: %v\n":                                                                           "[x] 登录百度网盘失败：%v\n",
+	"[x] Failed to materialize %s: %v\n":                                                                                 "[x] 还原 %s 失败：%v\n",
+	"[x] Failed to name the archive of image %s: %v\n":                                                                   "[x] 为镜像 %s 的归档文件命名失败：%v\n",
+	"[x] Failed to open backend directory %s: %v\n":                                                                      "[x] 打开后端目录 %s 失败：%v\n",
+	"[x] Failed to queue job: %v\n":                                                                                      "[x] 任务加入队列失败：%v\n",
+	"[x] Failed to read %s: %v\n":                                                                                        "[x] 读取 %s 失败：%v\n",
+	"[x] Failed to read delta recipe %s, nothing was deleted: %v\n":                                                      "[x] 读取增量配方 %s 失败，未删除任何文件：%v\n",
+	"[x] Failed to read attestation: %v\n":                                                                               "[x] 读取证明失败：%v\n",
+	"[x] Failed to read backup: %v\n":                                                                                    "[x] 读取备份失败：%v\n",
+	"[x] Failed to read cache directory %s: %v\n":                                                                        "[x] 读取缓存目录 %s 失败：%v\n",
+	"[x] Failed to read the list of files created by go-dkci: %v\n":                                                      "[x] 读取 go-dkci 创建的文件列表失败：%v\n",
+	"[x] Failed to remove %s: %v\n":                                                                                      "[x] 删除 %s 失败：%v\n",
+	"[x] Failed to repack %s: %v\n":                                                                                      "[x] 重新打包 %s 失败：%v\n",
+	"[x] Failed to resume job %s: %v\n":                                                                                  "[x] 继续任务 %s 失败：%v\n",
+	"[x] Failed to save images on %s: %v\n":                                                                              "[x] 在 %s 上保存镜像失败：%v\n",
+	"[x] Failed to send images: %v\n":                                                                                    "[x] 发送镜像失败：%v\n",
+	"[x] Failed to sign %s: %v\n":                                                                                        "[x] 签名 %s 失败：%v\n",
+	"[x] Failed to upload %s to Baidu cloud: %v\n":                                                                       "[x] 上传 %s 到百度网盘失败：%v\n",
+	"[x] Failed to write attestation for %s: %v\n":                                                                       "[x] 写入 %s 的证明失败：%v\n",
+	"[x] Garbage collection cancelled by user":                                                                           "[x] 用户已取消垃圾回收",
+	"[x] File not found: %s\n":                                                                                           "[x] 找不到文件：%s\n",
+	"[x] File server stopped: %v\n":                                                                                      "[x] 文件服务器已停止：%v\n",
+	"[x] Images not found locally: %s\n":                                                                                 "[x] 本地找不到镜像：%s\n",
+	"[x] Job %s is still %s; follow it with: go-dkci status %s\n":                                                        "[x] 任务 %s 仍处于 %s 状态，查看进度：go-dkci status %s\n",
+	"[x] No .tar files found in the specified cloud directory":                                                           "[x] 指定的网盘目录中没有 .tar 文件",
+	"[x] No .tar files found in the specified directory":                                                                 "[x] 指定的目录中没有 .tar 文件",
+	"[x] No Docker images found":                                                                                         "[x] 没有找到 Docker 镜像",
+	"[x] No files selected for import":                                                                                   "[x] 未选择要导入的文件",
+	"[x] No files selected for repacking":                                                                                "[x] 未选择要重新打包的文件",
+	"[x] Prune cancelled by user":                                                                                        "[x] 用户已取消清理",
+	"[x] No images found in Helm chart %s\n":                                                                             "[x] Helm chart %s 中没有镜像\n",
+	"[x] No images found in Kubernetes manifests %s\n":                                                                   "[x] Kubernetes 清单 %s 中没有镜像\n",
+	"[x] No images selected":                                                                                             "[x] 未选择镜像",
+	"[x] No tagged Docker images found on %s\n":                                                                          "[x] %s 上没有带标签的 Docker 镜像\n",
+	"[x] No tagged Docker images found":                                                                                  "[x] 没有带标签的 Docker 镜像",
+	"[x] Nothing selected to back up":                                                                                    "[x] 未选择要备份的内容",
+	"[x] Registry stopped: %v\n":                                                                                         "[x] 镜像仓库服务已停止：%v\n",
+	"[x] Restore failed: %v\n":                                                                                           "[x] 恢复失败：%v\n",
+	"[x] The specified file %s is not a .tar file\n":                                                                     "[x] 指定的文件 %s 不是 .tar 文件\n",
+	"[x] No images left to export":                                                                                       "[x] 没有剩余可导出的镜像",
+	"[x] Policy blocks the export of %s to %s: %s\n":                                                                     "[x] 策略禁止将 %s 导出到 %s：%s\n",
+	"[x] Export of %s not confirmed\n":                                                                                   "[x] 未确认导出 %s\n",
+	"[x] Policy requires confirming the export of %s to %s (%s), which needs a terminal\n":                               "[x] 策略要求确认将 %s 导出到 %s（%s），这需要终端\n",
+	"[x] Policy requires confirming the export of %s to %s (%s), which a detached or resumed job cannot ask for\n":       "[x] 策略要求确认将 %s 导出到 %s（%s），后台或恢复的任务无法询问确认\n",
+	"[x] Not exporting %s, %d file(s) may contain secrets\n":                                                             "[x] 不导出 %s，%d 个文件可能包含机密\n",
+	"[x] Failed to write license report for %s: %v\n":                                                                    "[x] 为 %s 写入许可证报告失败：%v\n",
+	"[x] Failed to write delivery report %s: %v\n":                                                                       "[x] 写入交付报告 %s 失败：%v\n",
+	"[√] Wrote delivery report to %s\n":                                                                                  "[√] 已写入交付报告：%s\n",
+	"[√] Emailed the summary":                                                                                            "[√] 已通过邮件发送摘要",
+	"[x] Error: install-service needs systemd or cron, use the Task Scheduler on Windows":                                "[x] 错误：install-service 需要 systemd 或 cron，在 Windows 上请使用任务计划程序",
+	"[x] Error: a go-dkci command to install is required, e.g. install-service --schedule \"0 2 * * *\" -- export --all": "[x] 错误：需要指定要安装的 go-dkci 命令，例如 install-service --schedule \"0 2 * * *\" -- export --all",
+	"[x] Error: put -- before the command to install, e.g. install-service --schedule \"0 2 * * *\" -- export --all":     "[x] 错误：请在要安装的命令前加上 --，例如 install-service --schedule \"0 2 * * *\" -- export --all",
+	"[x] Error: installing systemd system units needs root, run it with sudo or add --user":                              "[x] 错误：安装 systemd 系统单元需要 root 权限，请使用 sudo 运行或添加 --user",
+	"[x] Failed to install service %s: %v\n":                                                                             "[x] 安装服务 %s 失败：%v\n",
+	"[√] Installed service %s\n":                                                                                         "[√] 已安装服务 %s\n",
+	"[x] Error: --cloud requires --via cloud":                                                                            "[x] 错误：--cloud 需要与 --via cloud 一起使用",
+	"[x] Error: --via cloud requires --cloud, or default_cloud_dir in the configuration file":                            "[x] 错误：--via cloud 需要 --cloud，或在配置文件中设置 default_cloud_dir",
+	"[x] Failed to migrate image %s: %v\n":                                                                               "[x] 迁移镜像 %s 失败：%v\n",
+	"[x] Failed to migrate %d of %d image(s): %s\n":                                                                      "[x] %d/%d 个镜像迁移失败：%s\n",
+	"[√] Migrated %s (%s in %s)\n":                                                                                       "[√] 已迁移 %s（%s，用时 %s）\n",
+	"[√] Successfully migrated %d image(s) from %s to %s (%s in %s)\n":                                                   "[√] 已将 %d 个镜像从 %s 迁移到 %s（%s，用时 %s）\n",
+}
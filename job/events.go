@@ -0,0 +1,68 @@
+package job
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Progress events, one per state change of an item or uploaded chunk; items end with a Completed or Failed event
+const (
+	Started       = "started"
+	ChunkUploaded = "chunk-uploaded"
+)
+
+// Event is a progress event, written as one line of JSON. Item names the image or file of started, completed
+// and failed events; File, Bytes, Uploaded and Total describe an uploaded chunk.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	Item    string    `json:"item,omitempty"`
+	Skipped bool      `json:"skipped,omitempty"`
+	File    string    `json:"file,omitempty"`
+	// Bytes is the size of the chunk, Uploaded the bytes of the file uploaded so far and Total its size
+	Bytes    int64 `json:"bytes,omitempty"`
+	Uploaded int64 `json:"uploaded,omitempty"`
+	Total    int64 `json:"total,omitempty"`
+}
+
+var (
+	// events receives the progress events; nil writes none
+	events io.Writer
+	// eventsMu keeps the lines of events emitted at once from interleaving
+	eventsMu sync.Mutex
+)
+
+// SetEvents makes batches write their progress events to w as JSON lines
+func SetEvents(w io.Writer) {
+	events = w
+}
+
+// Emit writes a progress event if requested, stamping it with the current time
+func Emit(event Event) {
+	if events == nil {
+		return
+	}
+	event.Time = time.Now().UTC()
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	events.Write(append(data, '\n'))
+}
+
+// itemEvent returns the event of an item reaching a status, if the status has one
+func itemEvent(item, status string) (Event, bool) {
+	switch status {
+	case Running:
+		return Event{Event: Started, Item: item}, true
+	case Done:
+		return Event{Event: Completed, Item: item}, true
+	case Failed:
+		return Event{Event: Failed, Item: item}, true
+	}
+	return Event{}, false
+}
@@ -0,0 +1,397 @@
+package job
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/spf13/pflag"
+)
+
+// Dir holds the state files of the jobs, one <job-id>.json per job
+var Dir = filepath.Join(config.TempDir(), config.JobsDir)
+
+// idEnv passes the job being resumed to the re-run command
+const idEnv = "DKCI_JOB_ID"
+
+// Job states; Running and Failed are also item statuses
+const (
+	Queued    = "queued"
+	Running   = "running"
+	Completed = "completed"
+	Failed    = "failed"
+)
+
+// Item statuses
+const (
+	Pending = "pending"
+	Done    = "done"
+)
+
+// Item is an image or file processed by a job
+type Item struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// Job is a batch export or import. Its command line and items are persisted so that the items not done when it
+// was interrupted can be processed again with `go-dkci resume <job-id>`, and so that `go-dkci status` can show it.
+type Job struct {
+	ID      string    `json:"id"`
+	Command string    `json:"command"`
+	Flags   []string  `json:"flags"`
+	Items   []Item    `json:"items"`
+	State   string    `json:"state"`
+	PID     int       `json:"pid,omitempty"`
+	Log     string    `json:"log,omitempty"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+var (
+	// command and flags describe the running command until Start creates its job
+	command string
+	flags   []string
+	// globalFlags holds the global flags of the command line, which apply to every job it prepares
+	globalFlags []string
+	// current is the job of the running command, if any
+	current *Job
+	// mu guards current against items processed concurrently
+	mu sync.Mutex
+)
+
+// Prepare records the command line of a batch command so that Start can persist it. Flags named in skip,
+// such as those selecting the items, are left out; so are secrets, which are never written to disk.
+func Prepare(name string, flagSet *pflag.FlagSet, skip ...string) {
+	command = name
+	flags = append(changedFlags(flagSet, skip), globalFlags...)
+}
+
+// SetGlobalFlags records the global flags given on the command line, such as --docker-api-version, so that
+// resumed and background jobs run with them too
+func SetGlobalFlags(flagSet *pflag.FlagSet) {
+	globalFlags = changedFlags(flagSet, nil)
+}
+
+// changedFlags returns the flags set on the command line as --name=value, except those named in skip
+func changedFlags(flagSet *pflag.FlagSet, skip []string) []string {
+	var changed []string
+	flagSet.Visit(func(flag *pflag.Flag) {
+		for _, skipped := range skip {
+			if flag.Name == skipped {
+				return
+			}
+		}
+		if sliceValue, ok := flag.Value.(pflag.SliceValue); ok {
+			for _, value := range sliceValue.GetSlice() {
+				changed = append(changed, "--"+flag.Name+"="+value)
+			}
+			return
+		}
+		changed = append(changed, "--"+flag.Name+"="+flag.Value.String())
+	})
+	return changed
+}
+
+// Resuming reports whether this process runs a job again, resumed or by the background worker, rather than a
+// command typed by the user
+func Resuming() bool {
+	return os.Getenv(idEnv) != ""
+}
+
+// Start persists the job of the prepared command with the selected items, or continues the job being resumed.
+// It only begins the totals of the batch unless Prepare was called.
+func Start(items []string) {
+	StartSummary(items)
+	if command == "" {
+		return
+	}
+
+	if id := os.Getenv(idEnv); id != "" {
+		if job, err := Load(id); err == nil {
+			current = job
+			current.adopt(items)
+			current.State = Running
+			current.PID = os.Getpid()
+			current.update()
+			return
+		}
+	}
+
+	job, err := newJob(items, Running)
+	if err != nil {
+		fmt.Printf("Warning: Failed to create job: %v\n", err)
+		return
+	}
+	current = job
+	fmt.Printf("Started job %s; if it is interrupted, continue with: go-dkci resume %s\n", job.ID, job.ID)
+}
+
+// Enqueue persists the prepared command with its items as a queued job and makes sure a background worker
+// runs it. The items must be named, since nobody is there to answer a selection prompt.
+func Enqueue(items []string) (*Job, error) {
+	if command == "" {
+		return nil, fmt.Errorf("no command to queue")
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("background jobs need the images or files to be named on the command line")
+	}
+	job, err := newJob(items, Queued)
+	if err != nil {
+		return nil, err
+	}
+	if err := startWorker(); err != nil {
+		return nil, fmt.Errorf("failed to start the background worker: %v", err)
+	}
+	return job, nil
+}
+
+// MarkRunning records that an item of the running job is being processed
+func MarkRunning(item string) {
+	setStatus(item, Running)
+}
+
+// MarkDone records that an item of the running job completed
+func MarkDone(item string) {
+	setStatus(item, Done)
+}
+
+// MarkFailed records that an item of the running job failed, unless it was marked done
+func MarkFailed(item string) {
+	setStatus(item, Failed)
+}
+
+// setStatus sets the status of an item of the running job; done items stay done
+func setStatus(item, status string) {
+	mu.Lock()
+	defer mu.Unlock()
+	recordOutcome(item, status)
+	if current == nil {
+		return
+	}
+	for i := range current.Items {
+		if current.Items[i].Name == item && current.Items[i].Status != Done {
+			current.Items[i].Status = status
+			current.update()
+			return
+		}
+	}
+}
+
+// Finish records whether all items of the running job are done, and tells how to resume it otherwise
+func Finish() {
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		return
+	}
+	if unfinished := current.Unfinished(); len(unfinished) > 0 {
+		current.State = Failed
+		current.update()
+		i18n.Printf("[x] %d of %d item(s) of job %s did not complete; continue with: go-dkci resume %s\n", len(unfinished), len(current.Items), current.ID, current.ID)
+		return
+	}
+	current.State = Completed
+	current.update()
+}
+
+// adopt renames the unfinished items to the names the re-run command resolved them to, such as the full path
+// of a file or an image name with its tag; the command resolves the names in the order it received them
+func (j *Job) adopt(items []string) {
+	if len(items) != len(j.Unfinished()) {
+		return
+	}
+	next := 0
+	for i := range j.Items {
+		if j.Items[i].Status != Done {
+			j.Items[i].Name = items[next]
+			next++
+		}
+	}
+}
+
+// Unfinished returns the names of the items not done yet
+func (j *Job) Unfinished() []string {
+	var names []string
+	for _, item := range j.Items {
+		if item.Status != Done {
+			names = append(names, item.Name)
+		}
+	}
+	return names
+}
+
+// Active reports whether the job is queued or processed by a live process. A running job whose process is
+// gone was interrupted.
+func (j *Job) Active() bool {
+	switch j.State {
+	case Queued:
+		return true
+	case Running:
+		return ProcessAlive(j.PID)
+	}
+	return false
+}
+
+// Load reads the state of a job
+func Load(id string) (*Job, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return nil, fmt.Errorf("invalid job id %q", id)
+	}
+	data, err := os.ReadFile(filepath.Join(Dir, id+".json"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("job %s not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to parse job %s: %v", id, err)
+	}
+	return &job, nil
+}
+
+// List returns the persisted jobs, oldest first
+func List() ([]*Job, error) {
+	entries, err := os.ReadDir(Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*Job
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		job, err := Load(id)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].Created.Before(jobs[k].Created) })
+	return jobs, nil
+}
+
+// Resume runs the command of a job again for the items not done yet, updating the same job
+func Resume(id string) {
+	job, err := Load(id)
+	if err != nil {
+		i18n.Printf("[x] Error: %v\n", err)
+		os.Exit(1)
+	}
+	if job.Active() {
+		i18n.Printf("[x] Job %s is still %s; follow it with: go-dkci status %s\n", id, job.State, id)
+		os.Exit(1)
+	}
+	unfinished := job.Unfinished()
+	if len(unfinished) == 0 {
+		i18n.Printf("[√] All items of job %s are done\n", id)
+		return
+	}
+
+	cmd, err := job.rerun()
+	if err != nil {
+		i18n.Printf("[x] Failed to locate the go-dkci executable: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Resuming job %s: %d of %d item(s) left\n", id, len(unfinished), len(job.Items))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitErr.ExitCode())
+		}
+		i18n.Printf("[x] Failed to resume job %s: %v\n", id, err)
+		os.Exit(1)
+	}
+}
+
+// rerun returns the command running go-dkci again for the unfinished items of the job, updating the same job
+func (j *Job) rerun() (*exec.Cmd, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	args := append(append([]string{j.Command}, j.Flags...), j.Unfinished()...)
+	cmd := exec.Command(executable, args...)
+	cmd.Env = append(os.Environ(), idEnv+"="+j.ID)
+	return cmd, nil
+}
+
+// newJob creates and persists a job of the prepared command
+func newJob(items []string, state string) (*Job, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	job := &Job{ID: id, Command: command, Flags: flags, State: state, Created: now, Updated: now}
+	if state == Running {
+		job.PID = os.Getpid()
+	}
+	for _, item := range items {
+		job.Items = append(job.Items, Item{Name: item, Status: Pending})
+	}
+	if err := job.save(); err != nil {
+		return nil, fmt.Errorf("failed to save job %s: %v", id, err)
+	}
+	return job, nil
+}
+
+// update saves the state of the job, warning if that fails
+func (j *Job) update() {
+	j.Updated = time.Now()
+	if err := j.save(); err != nil {
+		fmt.Printf("Warning: Failed to save job %s: %v\n", j.ID, err)
+	}
+}
+
+// save writes the state of the job
+func (j *Job) save() error {
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return err
+	}
+	config.Track(Dir)
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write a temporary file first so an interruption never leaves a truncated state behind
+	tempFile := j.file() + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, j.file())
+}
+
+// file returns the path of the state file of the job
+func (j *Job) file() string {
+	return filepath.Join(Dir, j.ID+".json")
+}
+
+// newID returns a job id made of the start time and a random suffix
+func newID() (string, error) {
+	suffix := make([]byte, 2)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix), nil
+}
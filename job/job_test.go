@@ -0,0 +1,159 @@
+package job
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// useTempDir keeps the state files of the jobs of a test in a temporary directory and resets the prepared command
+func useTempDir(t *testing.T) {
+	t.Helper()
+	t.Setenv("DKCI_TEMP_DIR", t.TempDir())
+	t.Setenv(idEnv, "")
+	saved := Dir
+	Dir = t.TempDir()
+	t.Cleanup(func() {
+		Dir = saved
+		command, flags, globalFlags, current = "", nil, nil, nil
+	})
+}
+
+func TestChangedFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		skip []string
+		want []string
+	}{
+		{name: "none"},
+		{name: "string", args: []string{"--platform", "linux/arm64"}, want: []string{"--platform=linux/arm64"}},
+		{name: "bool", args: []string{"--compress"}, want: []string{"--compress=true"}},
+		{name: "slice", args: []string{"--exclude", "a,b", "--exclude", "c"}, want: []string{"--exclude=a", "--exclude=b", "--exclude=c"}},
+		{name: "skipped", args: []string{"--all", "--compress"}, skip: []string{"all"}, want: []string{"--compress=true"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flagSet := pflag.NewFlagSet("export", pflag.ContinueOnError)
+			flagSet.String("platform", "", "")
+			flagSet.Bool("compress", false, "")
+			flagSet.Bool("all", false, "")
+			flagSet.StringSlice("exclude", nil, "")
+			if err := flagSet.Parse(test.args); err != nil {
+				t.Fatal(err)
+			}
+			if got := changedFlags(flagSet, test.skip); !slices.Equal(got, test.want) {
+				t.Errorf("changedFlags = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPrepareAppendsGlobalFlags(t *testing.T) {
+	useTempDir(t)
+
+	global := pflag.NewFlagSet("go-dkci", pflag.ContinueOnError)
+	global.String("docker-api-version", "", "")
+	if err := global.Parse([]string{"--docker-api-version", "1.43"}); err != nil {
+		t.Fatal(err)
+	}
+	SetGlobalFlags(global)
+
+	flagSet := pflag.NewFlagSet("export", pflag.ContinueOnError)
+	flagSet.Bool("compress", false, "")
+	flagSet.Bool("all", false, "")
+	if err := flagSet.Parse([]string{"--all", "--compress"}); err != nil {
+		t.Fatal(err)
+	}
+	Prepare("export", flagSet, "all")
+
+	if want := []string{"--compress=true", "--docker-api-version=1.43"}; !slices.Equal(flags, want) {
+		t.Errorf("flags = %q, want %q", flags, want)
+	}
+}
+
+func TestStartPersistsProgress(t *testing.T) {
+	useTempDir(t)
+	Prepare("export", pflag.NewFlagSet("export", pflag.ContinueOnError))
+
+	Start([]string{"nginx:1.27", "redis:7", "alpine:3"})
+	if current == nil {
+		t.Fatal("no job started")
+	}
+	id := current.ID
+	MarkDone("nginx:1.27")
+	MarkFailed("redis:7")
+	MarkFailed("nginx:1.27")
+	Finish()
+
+	job, err := Load(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != Failed || job.Command != "export" {
+		t.Errorf("job %s = %s %s, want failed export", id, job.State, job.Command)
+	}
+	want := []Item{{"nginx:1.27", Done}, {"redis:7", Failed}, {"alpine:3", Pending}}
+	if !slices.Equal(job.Items, want) {
+		t.Errorf("items = %+v, want %+v", job.Items, want)
+	}
+	if unfinished := job.Unfinished(); !slices.Equal(unfinished, []string{"redis:7", "alpine:3"}) {
+		t.Errorf("Unfinished = %q", unfinished)
+	}
+	if job.Active() {
+		t.Error("failed job reported active")
+	}
+
+	jobs, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != id {
+		t.Errorf("List = %+v", jobs)
+	}
+}
+
+func TestStartResumesJob(t *testing.T) {
+	useTempDir(t)
+	Prepare("import", pflag.NewFlagSet("import", pflag.ContinueOnError))
+	Start([]string{"a.tar", "b.tar", "c.tar"})
+	id := current.ID
+	MarkDone("b.tar")
+	Finish()
+
+	// The resumed command resolves the unfinished items to full paths, in the order it received them
+	t.Setenv(idEnv, id)
+	if !Resuming() {
+		t.Fatal("Resuming = false with a job id set")
+	}
+	current = nil
+	Start([]string{"/data/a.tar", "/data/c.tar"})
+	if current == nil || current.ID != id {
+		t.Fatalf("resumed into a new job instead of %s", id)
+	}
+	MarkDone("/data/a.tar")
+	MarkDone("/data/c.tar")
+	Finish()
+
+	job, err := Load(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Item{{"/data/a.tar", Done}, {"b.tar", Done}, {"/data/c.tar", Done}}
+	if job.State != Completed || !slices.Equal(job.Items, want) {
+		t.Errorf("job = %s %+v, want completed %+v", job.State, job.Items, want)
+	}
+	if jobs, _ := List(); len(jobs) != 1 {
+		t.Errorf("resuming created another job: %d jobs", len(jobs))
+	}
+}
+
+func TestLoadInvalidID(t *testing.T) {
+	useTempDir(t)
+	for _, id := range []string{"", "../jobs", `a\b`, "missing"} {
+		if _, err := Load(id); err == nil {
+			t.Errorf("Load(%q) succeeded", id)
+		}
+	}
+}
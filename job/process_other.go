@@ -0,0 +1,30 @@
+//go:build !windows
+
+package job
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// ProcessAlive reports whether a process with the given id exists
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// detach does nothing; the worker ignores the hangup of the terminal it was started from instead
+func detach(cmd *exec.Cmd) {}
+
+// ignoreHangup keeps the worker running when the terminal that queued the job is closed
+func ignoreHangup() {
+	signal.Ignore(syscall.SIGHUP)
+}
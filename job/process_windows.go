@@ -0,0 +1,44 @@
+package job
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Access right and exit code used to check a process on Windows, which has no signal 0
+const (
+	processQueryLimitedInformation = 0x1000
+	stillActive                    = 259
+)
+
+// Process creation flags giving the worker no console, so that closing the console does not end it
+const (
+	createNewProcessGroup = 0x00000200
+	detachedProcess       = 0x00000008
+)
+
+// ProcessAlive reports whether a process with the given id is still running
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(handle)
+
+	var exitCode uint32
+	if err := syscall.GetExitCodeProcess(handle, &exitCode); err != nil {
+		return false
+	}
+	return exitCode == stillActive
+}
+
+// detach makes a command outlive the console it was started from
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: createNewProcessGroup | detachedProcess}
+}
+
+// ignoreHangup does nothing; Windows has no hangup signal, detach keeps the worker running instead
+func ignoreHangup() {}
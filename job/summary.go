@@ -0,0 +1,99 @@
+package job
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Skipped is the summary status of an item that needed no work, such as an image already exported
+const Skipped = "skipped"
+
+// Summary totals the items of a batch for the summary printed when it ends
+type Summary struct {
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	// Skipped counts the items that needed no work or were not reached
+	Skipped int   `json:"skipped"`
+	Bytes   int64 `json:"bytes"`
+	// Seconds is the time since the batch started, and BytesPerSecond the average throughput over it
+	Seconds        float64 `json:"seconds"`
+	BytesPerSecond float64 `json:"bytesPerSecond"`
+}
+
+var (
+	// started is when the items of the batch were selected, and outcomes the latest status of each of them; both
+	// are kept whether or not the batch is persisted as a job
+	started  time.Time
+	outcomes map[string]string
+	// transferred counts the bytes written, uploaded or downloaded by the batch
+	transferred atomic.Int64
+)
+
+// StartSummary begins the totals of a batch of items without persisting it as a job; Start does so too
+func StartSummary(items []string) {
+	mu.Lock()
+	defer mu.Unlock()
+	started = time.Now()
+	outcomes = make(map[string]string, len(items))
+	for _, item := range items {
+		outcomes[item] = Pending
+	}
+	transferred.Store(0)
+}
+
+// recordOutcome updates the status of an item in the totals and emits its progress event; done and skipped
+// items keep their status. The caller holds mu.
+func recordOutcome(item, status string) {
+	if outcomes == nil || outcomes[item] == Done || outcomes[item] == Skipped || outcomes[item] == status {
+		return
+	}
+	outcomes[item] = status
+	if event, ok := itemEvent(item, status); ok {
+		Emit(event)
+	}
+}
+
+// MarkSkipped records that an item of the running job needed no work, such as an image already exported. It
+// counts as done for the job.
+func MarkSkipped(item string) {
+	mu.Lock()
+	if outcomes != nil && outcomes[item] != Done && outcomes[item] != Skipped {
+		outcomes[item] = Skipped
+		Emit(Event{Event: Completed, Item: item, Skipped: true})
+	}
+	mu.Unlock()
+	setStatus(item, Done)
+}
+
+// AddBytes counts bytes written, uploaded or downloaded for the summary of the batch
+func AddBytes(n int64) {
+	transferred.Add(n)
+}
+
+// Totals returns the summary of the batch begun by the last Start. Items still pending or running count as
+// skipped and failed respectively.
+func Totals() Summary {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var summary Summary
+	for _, status := range outcomes {
+		switch status {
+		case Done:
+			summary.Succeeded++
+		case Failed, Running:
+			summary.Failed++
+		default:
+			summary.Skipped++
+		}
+	}
+	summary.Bytes = transferred.Load()
+	if !started.IsZero() {
+		elapsed := time.Since(started)
+		summary.Seconds = elapsed.Round(time.Millisecond).Seconds()
+		if elapsed > 0 {
+			summary.BytesPerSecond = float64(summary.Bytes) / elapsed.Seconds()
+		}
+	}
+	return summary
+}
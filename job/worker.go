@@ -0,0 +1,209 @@
+package job
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/i18n"
+)
+
+// workerLock holds the process id of the background worker, so that a single worker runs the queue
+var workerLock = filepath.Join(Dir, "worker.pid")
+
+// startWorker starts a background worker unless one is running already. The worker outlives this process.
+func startWorker() error {
+	if pid, err := readLock(); err == nil && ProcessAlive(pid) {
+		return nil
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(executable, "worker")
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// RunWorker runs the queued jobs one after the other, oldest first, until the queue is empty. The output of
+// every job goes to <Dir>/<job-id>.log.
+func RunWorker() {
+	ignoreHangup()
+
+	for acquireLock() {
+		for {
+			job := nextQueued()
+			if job == nil {
+				break
+			}
+			runQueued(job)
+		}
+		os.Remove(workerLock)
+
+		// A job queued while the lock was released would otherwise wait for the next worker
+		if nextQueued() == nil {
+			return
+		}
+	}
+}
+
+// runQueued runs a queued job and records its outcome
+func runQueued(job *Job) {
+	job.Log = filepath.Join(Dir, job.ID+".log")
+	logFile, err := os.Create(job.Log)
+	if err != nil {
+		job.State = Failed
+		job.update()
+		return
+	}
+	defer logFile.Close()
+
+	// The worker stands in for the command until it records its own process id
+	job.State = Running
+	job.PID = os.Getpid()
+	job.update()
+
+	cmd, err := job.rerun()
+	if err == nil {
+		cmd.Stdout = logFile
+		cmd.Stderr = logFile
+		err = cmd.Run()
+	}
+	if err != nil {
+		fmt.Fprintf(logFile, "[x] Job %s failed: %v\n", job.ID, err)
+	}
+
+	// The command updated the items; its exit status decides about items it never reached
+	if updated, err := Load(job.ID); err == nil {
+		job = updated
+	}
+	for i := range job.Items {
+		if job.Items[i].Status == Running {
+			job.Items[i].Status = Failed
+		}
+	}
+	job.State = Completed
+	if err != nil || len(job.Unfinished()) > 0 {
+		job.State = Failed
+	}
+	job.update()
+}
+
+// nextQueued returns the oldest queued job, or nil if the queue is empty
+func nextQueued() *Job {
+	jobs, err := List()
+	if err != nil {
+		return nil
+	}
+	for _, job := range jobs {
+		if job.State == Queued {
+			return job
+		}
+	}
+	return nil
+}
+
+// acquireLock makes this process the worker, replacing the lock of a worker that is gone
+func acquireLock() bool {
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return false
+	}
+	config.Track(Dir)
+	for attempt := 0; attempt < 2; attempt++ {
+		lockFile, err := os.OpenFile(workerLock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			fmt.Fprintf(lockFile, "%d\n", os.Getpid())
+			lockFile.Close()
+			return true
+		}
+		if pid, err := readLock(); err == nil && ProcessAlive(pid) {
+			return false
+		}
+		os.Remove(workerLock)
+	}
+	return false
+}
+
+// readLock returns the process id of the worker holding the lock
+func readLock() (int, error) {
+	data, err := os.ReadFile(workerLock)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// Status prints the jobs in progress and those that can be resumed, or every job with all, or the items of a
+// single job
+func Status(id string, all bool) {
+	if id != "" {
+		job, err := Load(id)
+		if err != nil {
+			i18n.Printf("[x] Error: %v\n", err)
+			os.Exit(1)
+		}
+		job.print()
+		return
+	}
+
+	jobs, err := List()
+	if err != nil {
+		i18n.Printf("[x] Failed to list jobs: %v\n", err)
+		os.Exit(1)
+	}
+	if !all {
+		// Completed jobs are left out; failed and interrupted ones remain until their items are done
+		var pending []*Job
+		for _, job := range jobs {
+			if job.Active() || len(job.Unfinished()) > 0 {
+				pending = append(pending, job)
+			}
+		}
+		if len(pending) == 0 && len(jobs) > 0 {
+			fmt.Println("No jobs in progress or to resume (see go-dkci status --all, and go-dkci log for the history)")
+			return
+		}
+		jobs = pending
+	}
+	if len(jobs) == 0 {
+		fmt.Println("No jobs")
+		return
+	}
+	fmt.Printf("%-20s  %-7s  %-11s  %-9s  %s\n", "JOB", "COMMAND", "STATE", "PROGRESS", "CREATED")
+	for _, job := range jobs {
+		done := len(job.Items) - len(job.Unfinished())
+		fmt.Printf("%-20s  %-7s  %-11s  %-9s  %s\n", job.ID, job.Command, job.displayState(), fmt.Sprintf("%d/%d", done, len(job.Items)), job.Created.Format("2006-01-02 15:04"))
+	}
+}
+
+// print shows the command line, state and items of the job
+func (j *Job) print() {
+	fmt.Printf("Job:     %s\n", j.ID)
+	fmt.Printf("Command: go-dkci %s\n", strings.Join(append([]string{j.Command}, j.Flags...), " "))
+	fmt.Printf("State:   %s\n", j.displayState())
+	fmt.Printf("Created: %s\n", j.Created.Format(time.DateTime))
+	fmt.Printf("Updated: %s\n", j.Updated.Format(time.DateTime))
+	if j.Log != "" {
+		fmt.Printf("Log:     %s\n", j.Log)
+	}
+	fmt.Println("Items:")
+	for _, item := range j.Items {
+		fmt.Printf("  %-8s %s\n", item.Status, item.Name)
+	}
+}
+
+// displayState returns the state of the job, telling running jobs whose process is gone apart
+func (j *Job) displayState() string {
+	if j.State == Running && !ProcessAlive(j.PID) {
+		return "interrupted"
+	}
+	return j.State
+}
@@ -0,0 +1,37 @@
+package k8s
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ImagesFromHelmChart renders a Helm chart with `helm template` and collects the image references of the
+// rendered workloads. valuesFiles are passed to helm with -f in the given order.
+func ImagesFromHelmChart(chart string, valuesFiles []string) ([]string, error) {
+	helmPath, err := exec.LookPath("helm")
+	if err != nil {
+		return nil, fmt.Errorf("helm executable not found in PATH: %v", err)
+	}
+
+	args := []string{"template", chart}
+	for _, valuesFile := range valuesFiles {
+		args = append(args, "-f", valuesFile)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(helmPath, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("helm template failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	images, err := imagesFromReader(&stdout)
+	if err != nil {
+		return nil, err
+	}
+
+	return uniqueImages(images), nil
+}
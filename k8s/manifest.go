@@ -0,0 +1,196 @@
+package k8s
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// workloadKinds are the resource kinds whose pod templates (or pod specs) are scanned for images
+var workloadKinds = map[string]bool{
+	"Pod":                   true,
+	"Deployment":            true,
+	"StatefulSet":           true,
+	"DaemonSet":             true,
+	"ReplicaSet":            true,
+	"ReplicationController": true,
+	"Job":                   true,
+	"CronJob":               true,
+}
+
+// ImagesFromManifests collects the image references used by the workloads in a manifest file or in all
+// .yaml/.yml files of a directory. Both containers and initContainers are included; duplicates are removed
+// and the order of first appearance is kept.
+func ImagesFromManifests(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	if info.IsDir() {
+		err = filepath.Walk(path, func(filePath string, fileInfo os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			lowerName := strings.ToLower(fileInfo.Name())
+			if !fileInfo.IsDir() && (strings.HasSuffix(lowerName, ".yaml") || strings.HasSuffix(lowerName, ".yml")) {
+				files = append(files, filePath)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		files = []string{path}
+	}
+
+	var images []string
+	for _, file := range files {
+		fileImages, err := imagesFromFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest %s: %v", file, err)
+		}
+		images = append(images, fileImages...)
+	}
+
+	return uniqueImages(images), nil
+}
+
+// imagesFromFile scans each YAML document of a file and returns the images of workload resources
+func imagesFromFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return imagesFromReader(file)
+}
+
+// listItem is an item of a List document: its kind, empty for the items of typed lists such as DeploymentList, and
+// the images found in it
+type listItem struct {
+	kind   string
+	images []string
+}
+
+// imagesFromReader scans each YAML document of a stream and returns the images of workload resources. The items of
+// List documents, as written by kubectl get -o yaml, are scanned like documents of their own.
+func imagesFromReader(reader io.Reader) ([]string, error) {
+	var images, documentImages []string
+	var items []listItem
+	kind := ""
+	// inItems is set within the top-level items key, whose entries start with a "- " indented by itemIndent
+	inItems, itemIndent := false, -1
+
+	// flush keeps the images of the finished document if it describes a workload, or those of its workload items
+	// if it is a List
+	flush := func() {
+		switch {
+		case workloadKinds[kind]:
+			images = append(images, documentImages...)
+		case strings.HasSuffix(kind, "List"):
+			for _, item := range items {
+				itemKind := item.kind
+				if itemKind == "" {
+					itemKind = strings.TrimSuffix(kind, "List")
+				}
+				if workloadKinds[itemKind] {
+					images = append(images, item.images...)
+				}
+			}
+		}
+		documentImages, items, kind = nil, nil, ""
+		inItems, itemIndent = false, -1
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// A new YAML document starts
+		if strings.HasPrefix(line, "---") {
+			flush()
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		// The resource kind and the items of a List are top-level keys
+		if indent == 0 && !strings.HasPrefix(trimmed, "-") {
+			key, value, _ := strings.Cut(line, ":")
+			if key == "kind" {
+				kind = cleanValue(value)
+			}
+			inItems, itemIndent = key == "items", -1
+			continue
+		}
+
+		// An entry of items starts an item, whose kind is one of its keys
+		if inItems {
+			fields, fieldIndent := trimmed, indent
+			if trimmed == "-" || strings.HasPrefix(trimmed, "- ") {
+				if itemIndent < 0 {
+					itemIndent = indent
+				}
+				if indent == itemIndent {
+					items = append(items, listItem{})
+					fields, fieldIndent = strings.TrimSpace(trimmed[1:]), indent+2
+				}
+			}
+			if value, ok := strings.CutPrefix(fields, "kind:"); ok && len(items) > 0 && fieldIndent == itemIndent+2 {
+				items[len(items)-1].kind = cleanValue(value)
+				continue
+			}
+		}
+
+		// Container images appear as "image: <ref>" (optionally as the first key of a list item)
+		if value, ok := strings.CutPrefix(strings.TrimLeft(trimmed, "- "), "image:"); ok {
+			image := cleanValue(value)
+			switch {
+			case image == "":
+			case inItems && len(items) > 0:
+				items[len(items)-1].images = append(items[len(items)-1].images, image)
+			default:
+				documentImages = append(documentImages, image)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	return images, nil
+}
+
+// cleanValue strips comments, whitespace and quotes from a scalar YAML value
+func cleanValue(value string) string {
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	value = strings.TrimSpace(value)
+	return strings.Trim(value, `"'`)
+}
+
+// uniqueImages removes duplicate references while keeping the order of first appearance
+func uniqueImages(images []string) []string {
+	var unique []string
+	seen := make(map[string]bool)
+	for _, image := range images {
+		if !seen[image] {
+			seen[image] = true
+			unique = append(unique, image)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,213 @@
+package k8s
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestImagesFromReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     []string
+	}{
+		{
+			name: "deployment",
+			manifest: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.27
+`,
+			want: []string{"nginx:1.27"},
+		},
+		{
+			name: "image as list item",
+			manifest: `kind: Pod
+spec:
+  containers:
+    - image: redis:7
+      name: cache
+    -   image: alpine:3.20
+`,
+			want: []string{"redis:7", "alpine:3.20"},
+		},
+		{
+			name: "quoted values with comments",
+			manifest: `kind: "Deployment" # the app
+spec:
+  template:
+    spec:
+      containers:
+      - image: "registry.local:5000/app:1.2" # pinned for the release
+      - image: 'busybox:1.36'
+      # - image: commented/out:1
+`,
+			want: []string{"registry.local:5000/app:1.2", "busybox:1.36"},
+		},
+		{
+			name: "init containers",
+			manifest: `kind: StatefulSet
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: migrate
+        image: app-migrations:1.2
+      containers:
+      - name: app
+        image: app:1.2
+`,
+			want: []string{"app-migrations:1.2", "app:1.2"},
+		},
+		{
+			name: "multiple documents",
+			manifest: `kind: Job
+spec:
+  template:
+    spec:
+      containers:
+      - image: job:1
+---
+kind: CronJob
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - image: cron:1
+---
+# a document starting with a comment
+kind: DaemonSet
+spec:
+  template:
+    spec:
+      containers:
+      - image: agent:1
+`,
+			want: []string{"job:1", "cron:1", "agent:1"},
+		},
+		{
+			name: "non-workload kinds ignored",
+			manifest: `kind: ConfigMap
+data:
+  image: not-an-image:1
+---
+kind: Service
+metadata:
+  annotations:
+    image: not-an-image:2
+---
+apiVersion: example.com/v1
+kind: Widget
+spec:
+  image: custom-resource:1
+---
+kind: Pod
+spec:
+  containers:
+  - image: kept:1
+`,
+			want: []string{"kept:1"},
+		},
+		{
+			name: "list items",
+			manifest: `apiVersion: v1
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  spec:
+    template:
+      spec:
+        containers:
+        - image: listed-deployment:1
+- apiVersion: v1
+  kind: ConfigMap
+  data:
+    image: not-an-image:3
+- kind: Pod
+  spec:
+    containers:
+    - image: listed-pod:1
+kind: List
+metadata: {}
+`,
+			want: []string{"listed-deployment:1", "listed-pod:1"},
+		},
+		{
+			name: "typed list",
+			manifest: `kind: DeploymentList
+items:
+  - metadata:
+      name: web
+    spec:
+      template:
+        spec:
+          containers:
+            - image: typed-list:1
+`,
+			want: []string{"typed-list:1"},
+		},
+		{
+			name: "items of a non-list kind",
+			manifest: `kind: ConfigMap
+items:
+- kind: Pod
+  image: not-an-image:4
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			images, err := imagesFromReader(strings.NewReader(test.manifest))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(images, test.want) {
+				t.Errorf("imagesFromReader = %q, want %q", images, test.want)
+			}
+		})
+	}
+}
+
+func TestImagesFromManifests(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"web.yaml":            "kind: Deployment\nspec:\n  template:\n    spec:\n      containers:\n      - image: nginx:1.27\n",
+		"nested/worker.YML":   "kind: Deployment\nspec:\n  template:\n    spec:\n      containers:\n      - image: nginx:1.27\n      - image: worker:2\n",
+		"notes.txt":           "kind: Pod\nimage: ignored:1\n",
+		"nested/values.json":  "{}",
+		"nested/service.yaml": "kind: Service\n",
+	}
+	for name, content := range files {
+		filePath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	images, err := ImagesFromManifests(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(images)
+	if want := []string{"nginx:1.27", "worker:2"}; !slices.Equal(images, want) {
+		t.Errorf("ImagesFromManifests = %q, want %q", images, want)
+	}
+
+	if _, err := ImagesFromManifests(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("a missing manifest was read")
+	}
+}
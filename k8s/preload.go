@@ -0,0 +1,140 @@
+package k8s
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/baowuhe/go-dkci/docker"
+)
+
+// PreloadOptions describes the DaemonSet that imports image archives on every node of a cluster
+type PreloadOptions struct {
+	// Name and Namespace of the DaemonSet
+	Name      string
+	Namespace string
+	// Image is the container image providing the go-dkci binary
+	Image string
+	// Source is the directory holding the archives on every node (e.g. an NFS mount)
+	Source string
+	// Files are the archive names to import; empty means every archive found in Source
+	Files []string
+	// DockerSocket is the path of the node's Docker socket the images are imported into
+	DockerSocket string
+}
+
+// preloadTemplate imports the archives in an init container and then keeps the pod alive with pause,
+// so the DaemonSet reports every node as ready once its images are loaded
+var preloadTemplate = template.Must(template.New("preload").Parse(`apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: {{ .Name }}
+  namespace: {{ .Namespace }}
+  labels:
+    app.kubernetes.io/name: {{ .Name }}
+    app.kubernetes.io/managed-by: go-dkci
+spec:
+  selector:
+    matchLabels:
+      app.kubernetes.io/name: {{ .Name }}
+  template:
+    metadata:
+      labels:
+        app.kubernetes.io/name: {{ .Name }}
+    spec:
+      initContainers:
+        - name: import
+          image: {{ .Image }}
+          command:
+            - go-dkci
+            - import
+            - --source
+            - /images
+{{- range .Files }}
+            - {{ printf "%q" . }}
+{{- end }}
+          env:
+            - name: DOCKER_HOST
+              value: unix:///var/run/docker.sock
+          volumeMounts:
+            - name: images
+              mountPath: /images
+              readOnly: true
+            - name: docker-socket
+              mountPath: /var/run/docker.sock
+      containers:
+        - name: pause
+          image: registry.k8s.io/pause:3.9
+      volumes:
+        - name: images
+          hostPath:
+            path: {{ .Source }}
+            type: Directory
+        - name: docker-socket
+          hostPath:
+            path: {{ .DockerSocket }}
+            type: Socket
+`))
+
+// PreloadDaemonSet renders a DaemonSet manifest that runs go-dkci on every node to import the archives of
+// opts.Source into the node's Docker daemon. When no files are given, Source must also be readable locally
+// so the archives can be listed; the import runs unattended, so every file has to be named up front.
+func PreloadDaemonSet(opts PreloadOptions) (string, error) {
+	if opts.Source == "" {
+		return "", fmt.Errorf("source directory is required")
+	}
+
+	if len(opts.Files) == 0 {
+		files, err := archivesInDirectory(opts.Source)
+		if err != nil {
+			return "", fmt.Errorf("failed to list archives in %s (name the files explicitly if it is only mounted on the nodes): %v", opts.Source, err)
+		}
+		if len(files) == 0 {
+			return "", fmt.Errorf("no .tar files found in %s", opts.Source)
+		}
+		opts.Files = files
+	}
+
+	var manifest bytes.Buffer
+	if err := preloadTemplate.Execute(&manifest, opts); err != nil {
+		return "", err
+	}
+	return manifest.String(), nil
+}
+
+// ApplyManifest applies a manifest to the current kubectl context with `kubectl apply -f -`
+func ApplyManifest(manifest string) error {
+	kubectlPath, err := exec.LookPath("kubectl")
+	if err != nil {
+		return fmt.Errorf("kubectl executable not found in PATH: %v", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(kubectlPath, "apply", "-f", "-")
+	cmd.Stdin = strings.NewReader(manifest)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("kubectl apply failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// archivesInDirectory returns the names of the image archives found in a directory tree
+func archivesInDirectory(dirPath string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && docker.IsArchiveName(info.Name()) {
+			files = append(files, info.Name())
+		}
+		return nil
+	})
+	return files, err
+}
@@ -1,49 +1,710 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/baowuhe/go-dkci/attest"
+	"github.com/baowuhe/go-dkci/backend"
+	"github.com/baowuhe/go-dkci/backup"
+	"github.com/baowuhe/go-dkci/bundle"
 	"github.com/baowuhe/go-dkci/cloud"
 	"github.com/baowuhe/go-dkci/config"
 	"github.com/baowuhe/go-dkci/docker"
+	"github.com/baowuhe/go-dkci/docs"
+	"github.com/baowuhe/go-dkci/fileserver"
+	"github.com/baowuhe/go-dkci/health"
+	"github.com/baowuhe/go-dkci/hook"
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/baowuhe/go-dkci/job"
+	"github.com/baowuhe/go-dkci/k8s"
+	"github.com/baowuhe/go-dkci/migrate"
+	"github.com/baowuhe/go-dkci/notify"
+	"github.com/baowuhe/go-dkci/registry"
+	"github.com/baowuhe/go-dkci/sbom"
+	"github.com/baowuhe/go-dkci/service"
+	"github.com/baowuhe/go-dkci/sign"
+	"github.com/baowuhe/go-dkci/ui"
 	"github.com/spf13/pflag"
 )
 
 var (
 	destination     string
+	outputFormat    string
 	cloudPath       string
-	grepPattern     string
+	grepPatterns    []string
+	matchAll        bool
+	filterOS        string
+	filterArch      string
+	minSize         string
+	maxSize         string
+	since           string
+	newerThan       string
+	olderThan       string
+	k8sManifests    string
+	helmChart       string
+	helmValues      []string
 	source          string
 	cloudImportPath string
+	kindCluster     string
+	preloadOptions  k8s.PreloadOptions
+	serviceOptions  service.Options
+	migrateOptions  migrate.Options
+	signExports     bool
+	sbomFormat      string
+	attestExports   bool
+	annotations     []string
+	inspectCloud    bool
+	uploadRetries   int
+	noCache         bool
+	keepTempFiles   bool
+	nameTemplate    string
+	withDigest      bool
+	keyPath         string
+	verifySignature bool
+	rekorLog        bool
+	skipVerify      bool
+	applyManifest   bool
+	listenAddress   string
+	registryCloud   string
+	serveDir        string
+	serveListen     string
+	checkDocker     bool
+	sendListen      string
+	receiveFrom     string
+	tlsCert         string
+	tlsKey          string
+	useTLS          bool
+	caCert          string
+	insecureTLS     bool
+	transferToken   string
+	deltaExport     bool
+	streamExport    bool
+	noCreateFolders bool
+	runFolder       bool
+	runFolderName   string
+	latestRun       bool
+	latestOnly      bool
+	containerCloud  string
+	snapshotTag     string
+	noPause         bool
+	volumeName      string
+	helperImage     string
+	composeFiles    []string
+	backupAll       bool
+	composeDir      string
+	backupDir       string
+	compressFormat  string
+	compressLevel   int
+	squashImages    bool
+	withAllTags     bool
+	scanSecrets     bool
+	licenseReport   bool
+	deliveryReport  string
+	blockOnSecrets  bool
+	onConflict      string
+	alsoTags        []string
+	untaggedImages  bool
+	selectAll       bool
+	archiveFormat   string
+	archivePassword string
+	detachJob       bool
+	assumeYes       bool
+	saveConcurrency int
+	transferLimit   int
+	loadConcurrency int
+	bundleFile      string
+	pruneBundle     bool
+	dryRun          bool
+	moveFiles       bool
+	copyFrom        string
+	copyTo          string
+	sortKey         string
+	sortReverse     bool
+	docsFormat      string
+	docsDir         string
+	language        string
+	apiVersion      string
+	dockerTimeout   time.Duration
+	cloudTimeout    time.Duration
+	cloudQPS        float64
+	accountLevel    string
+	progressFormat  string
+	progressFD      int
+	backendSpec     string
+	noHooks         bool
+	notifyProfile   string
+	allJobs         bool
+	logLast         int
 )
 
-// Define the version here - could be set during build time in a real application
-var version = "v0.1.0"
+// Build information, set at build time with
+// -ldflags "-X main.version=v1.2.3 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)".
+// Without them, go-dkci reports the module version, commit and commit time embedded by go build.
+var (
+	version   = defaultVersion
+	commit    = ""
+	buildDate = ""
+)
+
+// environment lists the environment variables go-dkci reads, for the manual pages
+var environment = [][2]string{
+	{"BDFS_CONFIG_FILE", "Path of the TOML configuration file (default ~/.local/app/dkci/config.toml)"},
+	{"BDFS_CLIENT_ID", "Baidu cloud client ID, used together with BDFS_CLIENT_SECRET and BDFS_TOKEN_PATH instead of the configuration file"},
+	{"BDFS_CLIENT_SECRET", "Baidu cloud client secret"},
+	{"BDFS_TOKEN_PATH", "Path of the Baidu cloud access token"},
+	{"DKCI_NAME_TEMPLATE", "Go template for exported file names"},
+	{"DKCI_ARCHIVE_PASSWORD", "Password of the --archive containers"},
+	{"DKCI_TEMP_DIR", "Working directory instead of ~/.cache/go-dkci"},
+	{"DOCKER_HOST", "Docker daemon to connect to"},
+	{"DOCKER_CONTEXT", "Docker context to connect to instead of the current context of the docker CLI, unless DOCKER_HOST is set"},
+	{"DOCKER_CONFIG", "Configuration directory of the docker CLI holding its contexts (default ~/.docker)"},
+	{"DOCKER_API_VERSION", "Docker API version to use instead of negotiating it, unless --docker-api-version is given"},
+	{"LC_ALL, LC_MESSAGES, LANG", "Language of prompts, errors and summaries, unless --lang is given"},
+}
+
+// defaultVersion is the version of builds that do not set one with ldflags
+const defaultVersion = "v0.1.0"
+
+// features lists the optional capabilities of this build, reported by the version command
+var features = []string{
+	"compress:gzip",
+	"compress:zstd",
+	"archive:zip,7z",
+	"sbom:" + sbom.FormatSPDX + "," + sbom.FormatCycloneDX,
+	"sign:cosign",
+	"attest",
+	"delta",
+	"registry",
+	"kind",
+	"lang:" + i18n.English + "," + i18n.Chinese,
+}
+
+// pluginName is the name of go-dkci as a Docker CLI plugin, installed as docker-dkci in ~/.docker/cli-plugins
+const pluginName = "dkci"
+
+// pluginMetadataCommand is the command the Docker CLI runs plugins with to read their metadata
+const pluginMetadataCommand = "docker-cli-plugin-metadata"
+
+// pluginMetadata describes go-dkci to the Docker CLI
+type pluginMetadata struct {
+	SchemaVersion    string
+	Vendor           string
+	Version          string
+	ShortDescription string
+	URL              string
+}
+
+// dockerFlags are the global flags of the docker CLI taking a value, which it passes on to plugins, with the
+// environment variable applying them to go-dkci, if any
+var dockerFlags = map[string]string{
+	"--context":   "DOCKER_CONTEXT",
+	"-c":          "DOCKER_CONTEXT",
+	"--host":      "DOCKER_HOST",
+	"-H":          "DOCKER_HOST",
+	"--config":    "DOCKER_CONFIG",
+	"--log-level": "",
+	"-l":          "",
+	"--tlscacert": "",
+	"--tlscert":   "",
+	"--tlskey":    "",
+}
 
 func main() {
+	// Run as a Docker CLI plugin: describe go-dkci to the docker CLI, and run `docker [flags] dkci args...`, which
+	// the docker CLI runs as `docker-dkci [flags] dkci args...`, as `go-dkci args...`
+	if len(os.Args) > 1 && os.Args[1] == pluginMetadataCommand {
+		json.NewEncoder(os.Stdout).Encode(pluginMetadata{
+			SchemaVersion:    "0.1.0",
+			Vendor:           "baowuhe",
+			Version:          currentBuild().Version,
+			ShortDescription: "Export and import Docker images via local files or Baidu Cloud",
+			URL:              "https://github.com/baowuhe/go-dkci",
+		})
+		return
+	}
+	if args, env, ok := pluginArgs(os.Args); ok {
+		for name, value := range env {
+			os.Setenv(name, value)
+		}
+		os.Args = args
+	}
+
+	// Set up the global flags, which may be given anywhere on the command line
+	globalFlags := pflag.NewFlagSet("go-dkci", pflag.ExitOnError)
+	globalFlags.StringVar(&language, "lang", "", "Language of prompts, errors and summaries: en or zh-CN (default from LC_ALL, LC_MESSAGES or LANG)")
+	globalFlags.StringVar(&apiVersion, "docker-api-version", "", "Docker API version to use (e.g. 1.41) instead of negotiating it with the daemon")
+	globalFlags.DurationVar(&dockerTimeout, "docker-timeout", docker.DefaultTimeout, "Fail Docker calls taking longer, and saves or loads passing no data for as long; 0 waits forever")
+	globalFlags.DurationVar(&cloudTimeout, "cloud-timeout", cloud.DefaultTimeout, "Fail Baidu cloud downloads passing no data for longer; 0 waits forever")
+	globalFlags.Float64Var(&cloudQPS, "cloud-qps", cloud.DefaultQPS, "Baidu cloud API requests sent per second at most, so that bulk operations are not throttled; 0 sends them unthrottled")
+	globalFlags.StringVar(&accountLevel, "account-level", "free", "Declared membership level of the Baidu account, free, VIP or SVIP, which sets the per-file size limit uploads are split at")
+	globalFlags.StringVar(&backendSpec, "backend", "bdfs", "Storage of the cloud commands: bdfs (Baidu cloud), dir://<path> for a local directory such as a USB drive, or plugin:<name> for a storage plugin")
+	globalFlags.BoolVar(&noHooks, "no-hooks", false, "Do not run the pre_export, post_export, pre_import and post_import hooks of the configuration file")
+	globalFlags.StringVar(&notifyProfile, "notify", "", "Email the summary of exports and imports with this [email.<profile>] of the configuration file")
+	globalFlags.StringVar(&progressFormat, "progress", "text", "Progress format: text, or jsonl to also write one JSON event per started, completed or failed item and uploaded chunk")
+	globalFlags.IntVar(&progressFD, "progress-fd", 1, "File descriptor the jsonl progress events are written to; with 1 (stdout) the other output goes to stderr")
+
+	if err := parseGlobalFlags(globalFlags); err != nil {
+		fmt.Printf("[x] Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Print prompts, errors and summaries in the language of --lang or of the locale
+	if language != "" {
+		if err := i18n.SetLanguage(language); err != nil {
+			fmt.Printf("[x] Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Talk to Docker daemons in the pinned API version, or the highest version both sides support
+	docker.SetAPIVersion(apiVersion)
+
+	// Find the local daemon among the sockets of rootless Docker, Docker Desktop and Colima in the configured order
+	sockets, err := config.GetDockerSockets()
+	if err == nil {
+		err = docker.SetSocketOrder(sockets)
+	}
+	if err != nil {
+		fmt.Printf("[x] Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Fail fast on a hung daemon or a stalled download, with the timeouts of the flags or the configuration file
+	if err := configureTimeouts(globalFlags); err != nil {
+		fmt.Printf("[x] Error: %v\n", err)
+		os.Exit(1)
+	}
+	docker.SetTimeout(dockerTimeout)
+	cloud.SetTimeout(cloudTimeout)
+
+	// Stay under the rate limits of the Baidu cloud API, with the limit of the flag or the configuration file
+	if qps, ok := config.GetCloudQPS(); ok && !globalFlags.Changed("cloud-qps") {
+		cloudQPS = qps
+	}
+	cloud.SetQPS(cloudQPS)
+
+	// Size uploads for the membership level of the Baidu account given by the flag or the configuration file
+	if !globalFlags.Changed("account-level") {
+		level, err := config.GetAccountLevel()
+		if err != nil {
+			fmt.Printf("[x] Error: %v\n", err)
+			os.Exit(1)
+		}
+		if level != "" {
+			accountLevel = level
+		}
+	}
+	if err := cloud.SetAccountLevel(accountLevel); err != nil {
+		fmt.Printf("[x] Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Keep the cloud files in a local directory instead of Baidu cloud if requested
+	if err := cloud.SetBackend(backendSpec); err != nil {
+		fmt.Printf("[x] Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Refuse to export the images ruled out by allow_images and deny_images in the configuration file
+	rules, err := config.GetImageRules()
+	if err == nil {
+		err = docker.SetImageRules(rules.Allow, rules.Deny)
+	}
+	if err != nil {
+		fmt.Printf("[x] Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Check every export against the policy rules and command of the configuration file
+	policy, err := config.GetPolicy()
+	if err == nil {
+		err = docker.SetPolicy(policy)
+	}
+	if err != nil {
+		fmt.Printf("[x] Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Email the summaries of the runs with an email profile of the configuration file if requested
+	if notifyProfile != "" {
+		profile, err := config.GetEmailProfile(notifyProfile)
+		if err == nil {
+			err = notify.SetEmail(notifyProfile, profile)
+		}
+		if err != nil {
+			fmt.Printf("[x] Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Run the hook commands of the configuration file around exports and imports unless disabled
+	hook.SetHooks(config.GetHooks())
+	if noHooks {
+		hook.Disable()
+	}
+
+	// Stream progress events for CI systems if requested
+	if err := configureProgress(); err != nil {
+		fmt.Printf("[x] Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Resumed and background jobs run with the same global flags
+	job.SetGlobalFlags(globalFlags)
+
+	// Delete the intermediate files of this run when it ends, also when it is interrupted
+	defer config.RemoveRunDir()
+	removeRunDirOnSignal()
+
+	// Exports, downloads and caches go to go-dkci in the temporary directory of the system
+	tempDir := config.TempDir()
+
 	// Set up the version command
 	versionCmd := pflag.NewFlagSet("version", pflag.ExitOnError)
+	versionCmd.StringVar(&outputFormat, "output", "text", "Output format: text, or json for inventories and bug reports")
 
 	// Set up the export command
 	exportCmd := pflag.NewFlagSet("export", pflag.ExitOnError)
-	exportCmd.StringVarP(&destination, "destination", "d", "/tmp/go-dkci", "Specify the export directory")
+	exportCmd.StringVarP(&destination, "destination", "d", tempDir, "Specify the export directory")
 	exportCmd.StringVarP(&cloudPath, "cloud", "c", "", "Specify the Baidu cloud folder path for export (mutually exclusive with -d)")
-	exportCmd.StringVarP(&grepPattern, "grep", "g", "", "Filter images by pattern")
+	exportCmd.StringArrayVarP(&grepPatterns, "grep", "g", nil, "Filter images by pattern (repeatable, any pattern matches)")
+	exportCmd.BoolVar(&matchAll, "match-all", false, "Require all --grep patterns to match")
+	exportCmd.StringVar(&filterOS, "os", "", "Only include images for this operating system (e.g. linux)")
+	exportCmd.StringVar(&filterArch, "arch", "", "Only include images for this architecture (e.g. arm64)")
+	exportCmd.StringVar(&minSize, "min-size", "", "Only include images of at least this size (e.g. 100MB)")
+	exportCmd.StringVar(&maxSize, "max-size", "", "Only include images of at most this size (e.g. 2GB)")
+	exportCmd.StringVar(&since, "since", "", "Only include images created on or after this date (YYYY-MM-DD)")
+	exportCmd.StringVar(&newerThan, "newer-than", "", "Only include images created within this age (e.g. 7d)")
+	exportCmd.StringVar(&olderThan, "older-than", "", "Only include images older than this age (e.g. 90d)")
+	exportCmd.StringVar(&k8sManifests, "k8s-manifests", "", "Export the images referenced by Kubernetes manifests in this file or directory")
+	exportCmd.StringVar(&helmChart, "helm-chart", "", "Export the images referenced by the rendered Helm chart")
+	exportCmd.StringArrayVar(&helmValues, "values", nil, "Values file passed to helm template (repeatable, used with --helm-chart)")
+	exportCmd.StringVar(&sortKey, "sort", "", "Sort the selection list by name, size or created")
+	exportCmd.BoolVar(&sortReverse, "reverse", false, "Reverse the sort order of the selection list")
+	exportCmd.BoolVar(&selectAll, "all", false, "Export all matching images without prompting")
+	exportCmd.StringVar(&nameTemplate, "name-template", "", "Go template for exported file names (e.g. \"{{.Name}}/{{.Tag}}/{{.Arch}}.tar\")")
+	exportCmd.BoolVar(&withDigest, "with-digest", false, "Append the short image digest to exported file names and skip files that already exist")
+	exportCmd.StringVar(&onConflict, "on-conflict", docker.ConflictOverwrite, "What to do when the file name of an image is taken: overwrite, skip, number or timestamp")
+	exportCmd.BoolVar(&keepTempFiles, "keep", false, "Keep the temporary files of a cloud export in "+tempDir)
+	exportCmd.BoolVar(&noCreateFolders, "no-create-folders", false, "Fail instead of creating the cloud folder if it does not exist")
+	exportCmd.BoolVar(&runFolder, "run-folder", false, "Write the export into a subfolder named after its start time (e.g. 2024-06-01T02-00)")
+	exportCmd.StringVar(&runFolderName, "run-folder-name", "", "Name the --run-folder subfolder instead of using the start time")
+	exportCmd.BoolVar(&squashImages, "squash", false, "Flatten the layers of each image into one before saving it")
+	exportCmd.BoolVar(&withAllTags, "with-all-tags", false, "Save each image with all of its tags in one archive, restoring every tag on import")
+	exportCmd.BoolVar(&streamExport, "stream", false, "Stream each image from Docker into a dir:// backend, compressing it on the way, without a temporary file; other backends reject it")
+	exportCmd.BoolVar(&deltaExport, "delta", false, "Upload only the layers not yet in the cloud folder, plus a recipe to rebuild the file")
+	exportCmd.StringVar(&compressFormat, "compress", "", "Compress the exported files (gzip, zstd or none)")
+	exportCmd.IntVar(&compressLevel, "compress-level", 0, "Compression level of --compress (1-9 for gzip, 1-19 for zstd)")
+	exportCmd.StringVar(&archiveFormat, "archive", "", "Wrap the exported files in a password-protected container (zip, 7z or none)")
+	exportCmd.StringVar(&archivePassword, "password", "", "Password of the --archive containers (default $DKCI_ARCHIVE_PASSWORD)")
+	exportCmd.BoolVar(&detachJob, "detach", false, "Queue the export as a background job and return (see go-dkci status)")
+	exportCmd.BoolVarP(&assumeYes, "yes", "y", false, "Start the export without confirming the size of the selected images")
+	exportCmd.IntVar(&saveConcurrency, "save-concurrency", 1, "Save this many images from Docker at once")
+	exportCmd.IntVar(&transferLimit, "transfer-concurrency", 1, "Upload this many files to Baidu cloud at once")
+	exportCmd.IntVar(&uploadRetries, "upload-retries", 0, "Retry a cloud upload this many times if its checksum does not match")
+	exportCmd.StringVar(&sbomFormat, "sbom", "", "Write an SBOM (spdx or cyclonedx) next to each exported file")
+	exportCmd.StringVar(&deliveryReport, "delivery-report", "", "Write a delivery report of the export to this file, as HTML if it ends in .html and as Markdown otherwise")
+	exportCmd.BoolVar(&licenseReport, "license-report", false, "Write a Markdown summary of the licenses in the SBOM next to each exported file (implies --sbom spdx)")
+	exportCmd.BoolVar(&attestExports, "attest", false, "Write a provenance attestation next to each exported file")
+	exportCmd.StringArrayVar(&annotations, "annotation", nil, "Annotation key=value recorded in the attestation (repeatable, used with --attest)")
+	exportCmd.BoolVar(&signExports, "sign", false, "Write a detached cosign signature next to each exported file")
+	exportCmd.StringVar(&keyPath, "key", "", "Cosign private key used with --sign")
+	exportCmd.BoolVar(&rekorLog, "rekor", false, "Also upload the signatures of --sign to the public Rekor transparency log")
+	exportCmd.BoolVar(&scanSecrets, "scan-secrets", false, "Scan the layers of each image for private keys, tokens and credential files and warn before exporting it")
+	exportCmd.BoolVar(&blockOnSecrets, "block-on-secrets", false, "Scan the layers of each image for secrets and leave out the images in which any are found")
 
 	// Set up the import command
 	importCmd := pflag.NewFlagSet("import", pflag.ExitOnError)
 	importCmd.StringVarP(&source, "source", "s", "", "Specify the source .tar file path or directory containing .tar files")
 	importCmd.StringVarP(&cloudImportPath, "cloud", "c", "", "Specify the Baidu cloud file or folder path for import (mutually exclusive with -s)")
-	importCmd.StringVarP(&grepPattern, "grep", "g", "", "Filter files by pattern")
+	importCmd.BoolVar(&latestRun, "latest-run", false, "Import the latest run folder (see export --run-folder) of the source or cloud folder")
+	importCmd.StringArrayVarP(&grepPatterns, "grep", "g", nil, "Filter files by pattern (repeatable, any pattern matches)")
+	importCmd.BoolVar(&matchAll, "match-all", false, "Require all --grep patterns to match")
+	importCmd.BoolVar(&latestOnly, "latest-only", false, "Offer only the newest of the files holding the same image (e.g. several dates or digests matching --grep)")
+	importCmd.StringVar(&filterOS, "os", "", "Only include files for this operating system (e.g. linux)")
+	importCmd.StringVar(&filterArch, "arch", "", "Only include files for this architecture (e.g. arm64)")
+	importCmd.StringVar(&sortKey, "sort", "", "Sort the selection list by name, size or created")
+	importCmd.BoolVar(&sortReverse, "reverse", false, "Reverse the sort order of the selection list")
+	importCmd.BoolVar(&selectAll, "all", false, "Import all matching files without prompting")
+	importCmd.StringVar(&kindCluster, "kind", "", "Load the images into all nodes of this kind cluster instead of Docker")
+	importCmd.StringArrayVar(&alsoTags, "also-tag", nil, "Also tag the image of every imported file with this reference (repeatable)")
+	importCmd.IntVar(&transferLimit, "transfer-concurrency", 1, "Download this many files from Baidu cloud at once")
+	importCmd.IntVar(&loadConcurrency, "load-concurrency", 1, "Load this many files into Docker at once")
+	importCmd.StringVar(&nameTemplate, "name-template", "", "Go template the files were exported with, used by --os and --arch")
+	importCmd.BoolVar(&keepTempFiles, "keep-download", false, "Keep the files downloaded by a cloud import in "+tempDir)
+	importCmd.BoolVar(&noCache, "no-cache", false, "Download cloud files even if an identical copy is cached")
+	importCmd.BoolVar(&verifySignature, "verify-signature", false, "Refuse files whose cosign signature is missing or invalid")
+	importCmd.StringVar(&keyPath, "key", "", "Cosign public key used with --verify-signature")
+	importCmd.BoolVar(&skipVerify, "insecure-skip-verify", false, "Import files even if signature verification fails")
+	importCmd.BoolVar(&rekorLog, "rekor", false, "Require the signatures checked by --verify-signature to be in the Rekor transparency log")
+	importCmd.BoolVar(&detachJob, "detach", false, "Queue the import as a background job and return (see go-dkci status)")
+	importCmd.StringVar(&archivePassword, "password", "", "Password of zip and 7z containers (default $DKCI_ARCHIVE_PASSWORD)")
+	importCmd.StringVar(&outputFormat, "output", "text", "Output format: text, or json to report the loaded images on stdout")
 
 	// Set up the delete command
 	deleteCmd := pflag.NewFlagSet("delete", pflag.ExitOnError)
-	deleteCmd.StringVarP(&grepPattern, "grep", "g", "", "Filter images by pattern")
+	deleteCmd.StringArrayVarP(&grepPatterns, "grep", "g", nil, "Filter images by pattern (repeatable, any pattern matches)")
+	deleteCmd.BoolVar(&matchAll, "match-all", false, "Require all --grep patterns to match")
+	deleteCmd.StringVar(&filterOS, "os", "", "Only include images for this operating system (e.g. linux)")
+	deleteCmd.StringVar(&filterArch, "arch", "", "Only include images for this architecture (e.g. arm64)")
+	deleteCmd.StringVar(&minSize, "min-size", "", "Only include images of at least this size (e.g. 100MB)")
+	deleteCmd.StringVar(&maxSize, "max-size", "", "Only include images of at most this size (e.g. 2GB)")
+	deleteCmd.StringVar(&since, "since", "", "Only include images created on or after this date (YYYY-MM-DD)")
+	deleteCmd.StringVar(&newerThan, "newer-than", "", "Only include images created within this age (e.g. 7d)")
+	deleteCmd.StringVar(&olderThan, "older-than", "", "Only include images older than this age (e.g. 90d)")
+	deleteCmd.BoolVar(&untaggedImages, "untagged", false, "Also list untagged images, by their short ID")
+	deleteCmd.StringVar(&sortKey, "sort", "", "Sort the selection list by name, size or created")
+	deleteCmd.BoolVar(&sortReverse, "reverse", false, "Reverse the sort order of the selection list")
+	deleteCmd.BoolVar(&selectAll, "all", false, "Delete all matching images without prompting")
 
 	// Set up the clean command
 	cleanCmd := pflag.NewFlagSet("clean", pflag.ExitOnError)
+	cleanCmd.BoolVarP(&assumeYes, "yes", "y", false, "Delete the files without asking for confirmation")
+
+	// Set up the resume, status and worker commands
+	resumeCmd := pflag.NewFlagSet("resume", pflag.ExitOnError)
+	statusCmd := pflag.NewFlagSet("status", pflag.ExitOnError)
+	statusCmd.BoolVar(&allJobs, "all", false, "Also list the completed jobs")
+	workerCmd := pflag.NewFlagSet("worker", pflag.ExitOnError)
+
+	// Set up the outdated command
+	outdatedCmd := pflag.NewFlagSet("outdated", pflag.ExitOnError)
+	outdatedCmd.StringArrayVarP(&grepPatterns, "grep", "g", nil, "Filter images by pattern (repeatable, any pattern matches)")
+	outdatedCmd.BoolVar(&matchAll, "match-all", false, "Require all --grep patterns to match")
+	outdatedCmd.StringVarP(&destination, "destination", "d", "", "Only count the exports to this local directory")
+	outdatedCmd.StringVarP(&cloudPath, "cloud", "c", "", "Only count the exports to this cloud folder")
+
+	// Set up the log command
+	logCmd := pflag.NewFlagSet("log", pflag.ExitOnError)
+	logCmd.IntVar(&logLast, "last", 20, "Show this many of the most recent operations; 0 shows them all")
+	logCmd.StringVar(&outputFormat, "output", "text", "Output format: text, or json for the records of the state database")
+
+	// Set up the inspect command
+	inspectCmd := pflag.NewFlagSet("inspect", pflag.ExitOnError)
+	inspectCmd.BoolVarP(&inspectCloud, "cloud", "c", false, "Read the attestation of a Baidu cloud file instead of a local one")
+	inspectCmd.StringVar(&keyPath, "key", "", "Cosign public key used to verify the attestation signature")
+
+	// Set up the registry command
+	registryCmd := pflag.NewFlagSet("registry", pflag.ExitOnError)
+	registryCmd.StringVar(&listenAddress, "listen", ":5000", "Address the registry listens on")
+	registryCmd.StringVarP(&registryCloud, "cloud", "c", "", "Baidu cloud folder holding the exported images (default from config)")
+	registryCmd.StringVar(&nameTemplate, "name-template", "", "Go template the files were exported with")
+	registryCmd.BoolVar(&checkDocker, "check-docker", false, "Also require a reachable Docker daemon for /readyz")
+
+	// Set up the serve-files command
+	serveFilesCmd := pflag.NewFlagSet("serve-files", pflag.ExitOnError)
+	serveFilesCmd.StringVar(&serveDir, "dir", tempDir, "Directory holding the exported files")
+	serveFilesCmd.StringVar(&serveListen, "listen", "127.0.0.1:8000", "Address the file server listens on; give :8000 to serve other hosts")
+	serveFilesCmd.BoolVar(&checkDocker, "check-docker", false, "Also require a reachable Docker daemon for /readyz")
+
+	// Set up the export-container command
+	exportContainerCmd := pflag.NewFlagSet("export-container", pflag.ExitOnError)
+	exportContainerCmd.StringVarP(&destination, "destination", "d", tempDir, "Specify the export directory")
+	exportContainerCmd.StringVarP(&containerCloud, "cloud", "c", "", "Specify the Baidu cloud folder path for export (mutually exclusive with -d)")
+	exportContainerCmd.BoolVar(&keepTempFiles, "keep", false, "Keep the temporary files of a cloud export in "+tempDir)
+	exportContainerCmd.BoolVar(&noCreateFolders, "no-create-folders", false, "Fail instead of creating the cloud folder if it does not exist")
+
+	// Set up the snapshot command
+	snapshotCmd := pflag.NewFlagSet("snapshot", pflag.ExitOnError)
+	snapshotCmd.StringVarP(&snapshotTag, "tag", "t", "", "Tag of the committed image (default \"<container>:snapshot-<timestamp>\")")
+	snapshotCmd.StringVarP(&destination, "destination", "d", tempDir, "Specify the export directory")
+	snapshotCmd.StringVarP(&containerCloud, "cloud", "c", "", "Specify the Baidu cloud folder path for export (mutually exclusive with -d)")
+	snapshotCmd.BoolVar(&noPause, "no-pause", false, "Do not pause the container while committing it")
+	snapshotCmd.BoolVar(&keepTempFiles, "keep", false, "Keep the temporary files of a cloud export in "+tempDir)
+	snapshotCmd.BoolVar(&noCreateFolders, "no-create-folders", false, "Fail instead of creating the cloud folder if it does not exist")
+
+	// Set up the volume export and import commands
+	volumeExportCmd := pflag.NewFlagSet("volume export", pflag.ExitOnError)
+	volumeExportCmd.StringVarP(&destination, "destination", "d", tempDir, "Specify the export directory")
+	volumeExportCmd.StringVarP(&containerCloud, "cloud", "c", "", "Specify the Baidu cloud folder path for export (mutually exclusive with -d)")
+	volumeExportCmd.BoolVar(&keepTempFiles, "keep", false, "Keep the temporary files of a cloud export in "+tempDir)
+	volumeExportCmd.BoolVar(&noCreateFolders, "no-create-folders", false, "Fail instead of creating the cloud folder if it does not exist")
+	volumeExportCmd.StringVar(&helperImage, "helper-image", "busybox:latest", "Local image used to create the helper container the volume is mounted in")
+
+	volumeImportCmd := pflag.NewFlagSet("volume import", pflag.ExitOnError)
+	volumeImportCmd.StringVarP(&source, "source", "s", "", "Specify the .volume.tar file to import")
+	volumeImportCmd.StringVarP(&containerCloud, "cloud", "c", "", "Specify the Baidu cloud .volume.tar file to import (mutually exclusive with -s)")
+	volumeImportCmd.StringVar(&volumeName, "name", "", "Volume to restore into (default: the volume the file was exported from)")
+	volumeImportCmd.BoolVar(&keepTempFiles, "keep-download", false, "Keep the file downloaded by a cloud import in "+tempDir)
+	volumeImportCmd.StringVar(&helperImage, "helper-image", "busybox:latest", "Local image used to create the helper container the volume is mounted in")
+
+	// Set up the backup and restore commands
+	backupCmd := pflag.NewFlagSet("backup", pflag.ExitOnError)
+	backupCmd.StringVarP(&backupDir, "destination", "d", filepath.Join(tempDir, "backup"), "Specify the bundle directory")
+	backupCmd.StringVarP(&containerCloud, "cloud", "c", "", "Specify the Baidu cloud folder of the bundle (mutually exclusive with -d)")
+	backupCmd.StringArrayVarP(&grepPatterns, "grep", "g", nil, "Filter the additional images offered by pattern (repeatable)")
+	backupCmd.StringArrayVar(&composeFiles, "compose", nil, "Compose file to include besides those of running projects (repeatable)")
+	backupCmd.BoolVar(&backupAll, "all", false, "Include all images and volumes without prompting")
+	backupCmd.StringVar(&helperImage, "helper-image", "busybox:latest", "Local image used to create the helper container volumes are copied through")
+
+	restoreCmd := pflag.NewFlagSet("restore", pflag.ExitOnError)
+	restoreCmd.StringVarP(&source, "source", "s", "", "Specify the bundle directory")
+	restoreCmd.StringVarP(&containerCloud, "cloud", "c", "", "Specify the Baidu cloud folder of the bundle (mutually exclusive with -s)")
+	restoreCmd.StringVar(&composeDir, "compose-dir", "", "Write the compose files here instead of their original paths")
+	restoreCmd.StringVar(&helperImage, "helper-image", "busybox:latest", "Local image used to create the helper container volumes are copied through")
+
+	// Set up the apply command
+	applyCmd := pflag.NewFlagSet("apply", pflag.ExitOnError)
+	applyCmd.StringVarP(&bundleFile, "file", "f", "", "TOML manifest listing the images of the bundle")
+	applyCmd.BoolVar(&pruneBundle, "prune", false, "Remove the files of images no longer listed (same as prune = true)")
+	applyCmd.BoolVar(&dryRun, "dry-run", false, "Only print what would be exported and removed")
+	applyCmd.IntVar(&uploadRetries, "upload-retries", 0, "Retry a cloud upload this many times if its checksum does not match")
+
+	// Set up the repack command
+	repackCmd := pflag.NewFlagSet("repack", pflag.ExitOnError)
+	repackCmd.StringVarP(&source, "source", "s", "", "Specify the .tar file or directory to repack")
+	repackCmd.StringVarP(&cloudImportPath, "cloud", "c", "", "Specify the Baidu cloud file or folder to repack (mutually exclusive with -s)")
+	repackCmd.StringVar(&compressFormat, "to", "", "Compression to convert the files to (gzip, zstd or none)")
+	repackCmd.IntVar(&compressLevel, "level", 0, "Compression level, 1-9 for gzip and 1-19 for zstd (default: that of the format)")
+	repackCmd.StringArrayVarP(&grepPatterns, "grep", "g", nil, "Filter files by pattern (repeatable, any pattern matches)")
+	repackCmd.BoolVar(&matchAll, "match-all", false, "Require all --grep patterns to match")
+	repackCmd.StringVar(&sortKey, "sort", "", "Sort the selection list by name, size or created")
+	repackCmd.BoolVar(&sortReverse, "reverse", false, "Reverse the sort order of the selection list")
+	repackCmd.BoolVar(&selectAll, "all", false, "Repack all matching files without prompting")
+	repackCmd.IntVar(&uploadRetries, "upload-retries", 0, "Retry a cloud upload this many times if its checksum does not match")
+	repackCmd.BoolVar(&keepTempFiles, "keep", false, "Keep the repacked files of a cloud repack in "+tempDir)
+	repackCmd.StringVar(&nameTemplate, "name-template", "", "Go template the files were exported with")
+
+	// Set up the cloud prune command
+	cloudPruneCmd := pflag.NewFlagSet("cloud prune", pflag.ExitOnError)
+	cloudPruneCmd.StringArrayVarP(&grepPatterns, "grep", "g", nil, "Only delete files matching this pattern (repeatable, any pattern matches)")
+	cloudPruneCmd.BoolVar(&matchAll, "match-all", false, "Require all --grep patterns to match")
+	cloudPruneCmd.StringVar(&since, "since", "", "Only delete files uploaded on or after this date (YYYY-MM-DD)")
+	cloudPruneCmd.StringVar(&newerThan, "newer-than", "", "Only delete files uploaded within this age (e.g. 7d)")
+	cloudPruneCmd.StringVar(&olderThan, "older-than", "", "Only delete files uploaded longer ago than this age (e.g. 180d)")
+	cloudPruneCmd.StringVar(&nameTemplate, "name-template", "", "Go template the files were exported with")
+	cloudPruneCmd.BoolVarP(&assumeYes, "yes", "y", false, "Delete the files without asking for confirmation")
+	cloudPruneCmd.BoolVar(&dryRun, "dry-run", false, "Only list the files that would be deleted")
+
+	// Set up the cloud mv command
+	cloudMvCmd := pflag.NewFlagSet("cloud mv", pflag.ExitOnError)
+
+	// Set up the gc command
+	gcCmd := pflag.NewFlagSet("gc", pflag.ExitOnError)
+	gcCmd.StringVar(&nameTemplate, "name-template", "", "Go template the files were exported with")
+	gcCmd.BoolVarP(&assumeYes, "yes", "y", false, "Delete the layers without asking for confirmation")
+	gcCmd.BoolVar(&dryRun, "dry-run", false, "Only list the layers that would be deleted")
+
+	// Set up the promote command
+	promoteCmd := pflag.NewFlagSet("promote", pflag.ExitOnError)
+	promoteCmd.BoolVar(&moveFiles, "move", false, "Remove the source files once they are copied")
+	promoteCmd.BoolVar(&dryRun, "dry-run", false, "Only list the files that would be promoted")
+
+	// Set up the send command
+	sendCmd := pflag.NewFlagSet("send", pflag.ExitOnError)
+	sendCmd.StringVar(&sendListen, "listen", ":9000", "Address to wait for the receiver on")
+	sendCmd.StringArrayVarP(&grepPatterns, "grep", "g", nil, "Filter images by pattern (repeatable, any pattern matches)")
+	sendCmd.BoolVar(&matchAll, "match-all", false, "Require all --grep patterns to match")
+	sendCmd.StringVar(&filterOS, "os", "", "Only include images for this operating system (e.g. linux)")
+	sendCmd.StringVar(&filterArch, "arch", "", "Only include images for this architecture (e.g. arm64)")
+	sendCmd.BoolVar(&selectAll, "all", false, "Send all matching images without prompting")
+	sendCmd.StringVar(&tlsCert, "tls-cert", "", "Serve over TLS with this certificate")
+	sendCmd.StringVar(&tlsKey, "tls-key", "", "Private key of --tls-cert")
+	sendCmd.StringVar(&transferToken, "token", "", "Token receivers must present (default $DKCI_TRANSFER_TOKEN, or a generated one)")
+
+	// Set up the receive command
+	receiveCmd := pflag.NewFlagSet("receive", pflag.ExitOnError)
+	receiveCmd.StringVar(&receiveFrom, "from", "", "Address (host:port) of the sending go-dkci")
+	receiveCmd.StringVar(&kindCluster, "kind", "", "Load the images into all nodes of this kind cluster instead of Docker")
+	receiveCmd.BoolVar(&useTLS, "tls", false, "Connect to the sender over TLS")
+	receiveCmd.StringVar(&caCert, "ca-cert", "", "CA certificate the sender certificate is verified with (implies --tls)")
+	receiveCmd.BoolVar(&insecureTLS, "insecure", false, "Do not verify the sender certificate (implies --tls)")
+	receiveCmd.StringVar(&transferToken, "token", "", "Token printed by the sender (default $DKCI_TRANSFER_TOKEN)")
+
+	// Set up the copy command
+	copyCmd := pflag.NewFlagSet("copy", pflag.ExitOnError)
+	copyCmd.StringVar(&copyFrom, "from", "local", "Docker host to copy from (local, ssh://[user@]host[:port] or a daemon URL such as npipe://)")
+	copyCmd.StringVar(&copyTo, "to", "local", "Docker host to copy to (local, ssh://[user@]host[:port] or a daemon URL such as npipe://)")
+	copyCmd.StringArrayVarP(&grepPatterns, "grep", "g", nil, "Filter images by pattern (repeatable, any pattern matches)")
+	copyCmd.BoolVar(&matchAll, "match-all", false, "Require all --grep patterns to match")
+	copyCmd.StringVar(&filterOS, "os", "", "Only include images for this operating system (e.g. linux)")
+	copyCmd.StringVar(&filterArch, "arch", "", "Only include images for this architecture (e.g. arm64)")
+	copyCmd.BoolVar(&selectAll, "all", false, "Copy all matching images without prompting")
+
+	// Set up the migrate command
+	migrateCmd := pflag.NewFlagSet("migrate", pflag.ExitOnError)
+	migrateCmd.StringVar(&migrateOptions.From, "from", "local", "Docker host to migrate from (local, ssh://[user@]host[:port] or a daemon URL such as tcp://)")
+	migrateCmd.StringVar(&migrateOptions.To, "to", "local", "Docker host to migrate to (local, ssh://[user@]host[:port] or a daemon URL such as tcp://)")
+	migrateCmd.StringArrayVarP(&grepPatterns, "grep", "g", nil, "Filter images by pattern (repeatable, any pattern matches)")
+	migrateCmd.BoolVar(&matchAll, "match-all", false, "Require all --grep patterns to match")
+	migrateCmd.StringVar(&filterOS, "os", "", "Only include images for this operating system (e.g. linux)")
+	migrateCmd.StringVar(&filterArch, "arch", "", "Only include images for this architecture (e.g. arm64)")
+	migrateCmd.BoolVar(&selectAll, "all", false, "Migrate all matching images without prompting")
+	migrateCmd.StringVar(&migrateOptions.Via, "via", migrate.ViaDirect, "How the images travel: direct, cache (an archive in "+tempDir+") or cloud")
+	migrateCmd.StringVar(&migrateOptions.CloudDir, "cloud", "", "Cloud folder the archives are relayed through with --via cloud (default from config)")
+	migrateCmd.BoolVar(&migrateOptions.Keep, "keep", false, "Keep the relayed archives after they are loaded")
+
+	// Set up the ui command
+	uiCmd := pflag.NewFlagSet("ui", pflag.ExitOnError)
+
+	// Set up the k8s preload command
+	preloadCmd := pflag.NewFlagSet("k8s preload", pflag.ExitOnError)
+	preloadCmd.StringVar(&preloadOptions.Source, "from", "", "Directory holding the .tar files on every node")
+	preloadCmd.StringVar(&preloadOptions.Image, "image", "go-dkci:"+version, "Container image providing the go-dkci binary")
+	preloadCmd.StringVar(&preloadOptions.Name, "name", "go-dkci-preload", "Name of the DaemonSet")
+	preloadCmd.StringVarP(&preloadOptions.Namespace, "namespace", "n", "kube-system", "Namespace of the DaemonSet")
+	preloadCmd.StringVar(&preloadOptions.DockerSocket, "docker-socket", "/var/run/docker.sock", "Path of the Docker socket on the nodes")
+	preloadCmd.BoolVar(&applyManifest, "apply", false, "Apply the DaemonSet with kubectl instead of printing it")
+
+	// Set up the install-service command; the installed command follows "--", which also keeps global flags
+	// such as --no-hooks in it
+	installServiceCmd := pflag.NewFlagSet("install-service", pflag.ExitOnError)
+	installServiceCmd.SetInterspersed(false)
+	installServiceCmd.StringVar(&serviceOptions.Schedule, "schedule", "", "Cron expression or macro (e.g. \"0 2 * * *\" or @daily) to run the command on; without one it runs as a service")
+	installServiceCmd.StringVar(&serviceOptions.Name, "name", "go-dkci", "Name of the units, the crontab entry and the environment file")
+	installServiceCmd.BoolVar(&serviceOptions.User, "user", false, "Install systemd user units instead of system units")
+	installServiceCmd.BoolVar(&serviceOptions.Cron, "cron", false, "Add an entry to the crontab of the current user instead of systemd units")
+	installServiceCmd.BoolVar(&dryRun, "dry-run", false, "Only print the files that would be written")
+
+	// Set up the hidden docs command, which generates the manual pages for packaging
+	docsCmd := pflag.NewFlagSet("docs", pflag.ExitOnError)
+	docsCmd.StringVar(&docsFormat, "format", "man", "Generate man pages, or json for the command and flag reference on stdout")
+	docsCmd.StringVar(&docsDir, "dir", "man", "Directory the man pages are written to")
+
+	// The commands documented by the docs command
+	commands := []docs.Command{
+		{Name: "export", Summary: "Export Docker images to local directory or Baidu Cloud", Usage: "[flags] [images...]", Flags: exportCmd},
+		{Name: "export-container", Summary: "Export container filesystems to local directory or Baidu Cloud", Usage: "[flags] containers...", Flags: exportContainerCmd},
+		{Name: "snapshot", Summary: "Commit a container to an image and export it", Usage: "[flags] container", Flags: snapshotCmd},
+		{Name: "import", Summary: "Import Docker images from local .tar files", Usage: "[flags] [files...]", Flags: importCmd},
+		{Name: "volume export", Summary: "Export Docker volumes to local directory or Baidu Cloud", Usage: "[flags] volumes...", Flags: volumeExportCmd},
+		{Name: "volume import", Summary: "Import a volume export into a Docker volume", Flags: volumeImportCmd},
+		{Name: "backup", Summary: "Back up images, volumes and compose files of this host", Flags: backupCmd},
+		{Name: "restore", Summary: "Restore a backup made with the backup command", Flags: restoreCmd},
+		{Name: "apply", Summary: "Export the images listed in a bundle manifest and prune the others", Flags: applyCmd},
+		{Name: "repack", Summary: "Recompress exported files, locally or in Baidu cloud, in place", Usage: "[flags] [files...]", Flags: repackCmd},
+		{Name: "cloud prune", Summary: "Delete old or matching archives from a Baidu cloud folder", Usage: "[flags] [folder]", Flags: cloudPruneCmd},
+		{Name: "cloud mv", Summary: "Move or rename a file or folder of Baidu cloud", Usage: "source target", Flags: cloudMvCmd},
+		{Name: "gc", Summary: "Delete the delta export layers no longer used by any export of a cloud folder", Usage: "[flags] [folder]", Flags: gcCmd},
+		{Name: "promote", Summary: "Copy exported files and their sidecars to another cloud folder", Usage: "[flags] sources... folder", Flags: promoteCmd},
+		{Name: "delete", Summary: "Delete Docker images", Usage: "[flags] [images, IDs or digests...]", Flags: deleteCmd},
+		{Name: "resume", Summary: "Continue an interrupted export or import job", Usage: "[job-id]", Flags: resumeCmd},
+		{Name: "status", Summary: "Show the jobs in progress or to resume", Usage: "[job-id]", Flags: statusCmd},
+		{Name: "log", Summary: "Show the recent exports and imports", Flags: logCmd},
+		{Name: "outdated", Summary: "List the images changed or never exported since their last export", Flags: outdatedCmd},
+		{Name: "worker", Summary: "Run the queued background jobs", Flags: workerCmd},
+		{Name: "clean", Summary: "Clean cache directory", Flags: cleanCmd},
+		{Name: "inspect", Summary: "Show the provenance attestation of an exported file", Usage: "[flags] archive", Flags: inspectCmd},
+		{Name: "k8s preload", Summary: "Generate a DaemonSet loading exported images on every node", Flags: preloadCmd},
+		{Name: "install-service", Summary: "Run a go-dkci command on a schedule or as a service with systemd or cron", Usage: "[flags] -- command [args...]", Flags: installServiceCmd},
+		{Name: "registry", Summary: "Serve the images of a cloud folder as a read-only Docker registry", Flags: registryCmd},
+		{Name: "serve-files", Summary: "Share exported files over HTTP for download with curl", Flags: serveFilesCmd},
+		{Name: "send", Summary: "Stream Docker images directly to a receiving host", Usage: "[flags] [images...]", Flags: sendCmd},
+		{Name: "receive", Summary: "Load Docker images streamed by a sending host", Flags: receiveCmd},
+		{Name: "copy", Summary: "Copy images between Docker hosts over SSH, or between any two backends", Usage: "[flags] [images... | source target]", Flags: copyCmd},
+		{Name: "migrate", Summary: "Move images from one Docker host to another, one image at a time", Usage: "[flags] [images...]", Flags: migrateCmd},
+		{Name: "ui", Summary: "Browse local images and cloud folders interactively", Flags: uiCmd},
+		{Name: "version", Summary: "Print program version", Flags: versionCmd},
+	}
 
 	// Check if there are arguments
 	if len(os.Args) < 2 {
@@ -80,17 +741,211 @@ func main() {
 
 			exportCmd.Parse(os.Args[2:])
 
-			// Store grep pattern in environment variable for access by other modules
-			if grepPattern != "" {
-				os.Setenv("DKCI_GREP_PATTERN", grepPattern)
+			// Write the run into a subfolder named after its start time if requested. The name is set as a flag
+			// before the command line is recorded, so that a resumed or background export fills the same folder.
+			if runFolderName != "" && !runFolder {
+				i18n.Println("[x] Error: --run-folder-name requires --run-folder")
+				os.Exit(1)
+			}
+			if runFolder && runFolderName == "" {
+				exportCmd.Set("run-folder-name", docker.RunFolderName(time.Now()))
+			}
+			if err := docker.SetRunFolder(runFolderName); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Record the command line so that an interrupted export can be resumed with the unfinished images;
+			// the images of manifests and charts are resolved already, and the password is never stored
+			job.Prepare("export", exportCmd, "k8s-manifests", "helm-chart", "values", "password", "detach")
+
+			// Retry cloud uploads whose server-side checksum does not match
+			if uploadRetries < 0 {
+				i18n.Println("[x] Error: --upload-retries must not be negative")
+				os.Exit(1)
+			}
+			cloud.SetUploadRetries(uploadRetries)
+			cloud.SetKeepTempFiles(keepTempFiles)
+
+			// Flatten the images before saving them if requested; the flattened copy only carries one tag
+			if squashImages && withAllTags {
+				i18n.Println("[x] Error: --squash cannot be combined with --with-all-tags")
+				os.Exit(1)
+			}
+			docker.SetSquash(squashImages)
+			docker.SetWithAllTags(withAllTags)
+
+			// Skip the size confirmation shown before the transfer starts if requested
+			docker.SetAssumeYes(assumeYes)
+
+			// Scan the images for secrets before they leave the host if requested
+			docker.SetSecretScan(scanSecrets, blockOnSecrets)
+
+			// Write a delivery report once the export ends if requested
+			docker.SetDeliveryReport(deliveryReport, version)
+
+			// Save and upload several images at once if requested
+			if err := applyConcurrency(exportCmd); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Upload only new layers if requested; the rebuilt file is not bit-identical, so it cannot be signed
+			if deltaExport && signExports {
+				i18n.Println("[x] Error: --delta cannot be combined with --sign")
+				os.Exit(1)
+			}
+			cloud.SetDelta(deltaExport)
+
+			// Compress the exported files if requested; delta exports need the plain archive
+			if err := docker.SetCompression(compressFormat); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+			if err := applyCompressLevel(exportCmd); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+			if deltaExport && docker.Compressed() {
+				i18n.Println("[x] Error: --delta cannot be combined with --compress")
+				os.Exit(1)
+			}
+
+			// License reports are made from the SBOM, so they need one
+			if licenseReport && sbomFormat == "" {
+				sbomFormat = sbom.FormatSPDX
+			}
+			sbom.SetLicenseReport(licenseReport)
+
+			// Wrap the exported files in password-protected containers if requested; the SBOM and delta
+			// exports need to read the archive
+			if err := applyArchive(archiveFormat); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+			if docker.Sealed() && (deltaExport || sbomFormat != "") {
+				i18n.Println("[x] Error: --archive cannot be combined with --delta or --sbom")
+				os.Exit(1)
+			}
+
+			// Generate an SBOM for every exported file if requested
+			if err := sbom.SetFormat(sbomFormat); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Record the provenance of every exported file if requested
+			if attestExports {
+				annotationMap, err := attest.ParseAnnotations(annotations)
+				if err != nil {
+					i18n.Printf("[x] Error: %v\n", err)
+					os.Exit(1)
+				}
+				attest.Enable(version, annotationMap)
+			}
+
+			// Sign every exported file if requested
+			if signExports {
+				if keyPath == "" {
+					i18n.Println("[x] Error: --key is required with --sign")
+					os.Exit(1)
+				}
+				sign.SetSigningKey(keyPath)
+				sign.SetTransparencyLog(rekorLog)
+			}
+
+			// Stream the archives into the cloud without temporary files if requested; the options reading or
+			// rewriting the archive need it on disk
+			if streamExport && cloudPath == "" && !hasCFlag {
+				i18n.Println("[x] Error: --stream requires --cloud")
+				os.Exit(1)
+			}
+			if streamExport && (deltaExport || docker.Sealed() || sbomFormat != "" || attestExports || signExports) {
+				i18n.Println("[x] Error: --stream cannot be combined with --delta, --archive, --sbom, --attest or --sign")
+				os.Exit(1)
+			}
+			if streamExport && !cloud.CanStream() {
+				i18n.Println("[x] Error: --stream requires a dir:// backend, Baidu cloud and storage plugins only upload local files")
+				os.Exit(1)
+			}
+			cloud.SetStream(streamExport)
+			cloud.SetCreateFolders(!noCreateFolders)
+
+			// Apply the sort order and --all to the selection lists
+			if err := docker.SetSortOrder(sortKey, sortReverse); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+			docker.SetSelectAll(selectAll)
+
+			// Name (or parse) the archives with the configured file name template
+			if err := applyNameTemplate(); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Keep, number or timestamp the archives whose file names are taken instead of overwriting them if requested
+			if err := docker.SetOnConflict(onConflict); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Combine the grep patterns, platform, size and creation time bounds into a single filter
+			filter := docker.Filter{
+				Grep: docker.GrepFilter{Patterns: grepPatterns, MatchAll: matchAll},
+				OS:   filterOS,
+				Arch: filterArch,
+			}
+			if err := parseSizeBounds(&filter); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+			if err := parseCreatedBounds(&filter); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Collect the images to export from the command line, Kubernetes manifests and Helm charts
+			imageNames := exportCmd.Args()
+			if k8sManifests != "" {
+				manifestImages, err := k8s.ImagesFromManifests(k8sManifests)
+				if err != nil {
+					i18n.Printf("[x] Error reading Kubernetes manifests: %v\n", err)
+					os.Exit(1)
+				}
+				if len(manifestImages) == 0 {
+					i18n.Printf("[x] No images found in Kubernetes manifests %s\n", k8sManifests)
+					os.Exit(1)
+				}
+				fmt.Printf("Found %d image(s) in Kubernetes manifests\n", len(manifestImages))
+				imageNames = append(imageNames, manifestImages...)
+			}
+			if helmChart != "" {
+				chartImages, err := k8s.ImagesFromHelmChart(helmChart, helmValues)
+				if err != nil {
+					i18n.Printf("[x] Error rendering Helm chart: %v\n", err)
+					os.Exit(1)
+				}
+				if len(chartImages) == 0 {
+					i18n.Printf("[x] No images found in Helm chart %s\n", helmChart)
+					os.Exit(1)
+				}
+				fmt.Printf("Found %d image(s) in Helm chart\n", len(chartImages))
+				imageNames = append(imageNames, chartImages...)
 			}
 
 			// Check if both destination and cloud path are specified
 			if hasDFlag && cloudPath != "" {
-				fmt.Println("[x] Error: -d and -c flags are mutually exclusive")
+				i18n.Println("[x] Error: -d and -c flags are mutually exclusive")
 				os.Exit(1)
 			}
 
+			// Hand the export to the background worker if requested
+			if detachJob {
+				queueJob(imageNames)
+				return
+			}
+
 			// Check if BDFS configuration is available (to determine if we should use cloud export with default dir)
 			bdfsConfigAvailable := false
 			if os.Getenv("BDFS_CONFIG_FILE") != "" ||
@@ -99,30 +954,29 @@ func main() {
 			}
 
 			if cloudPath != "" {
-				cloud.ExportImagesToCloud(cloudPath)
+				cloud.ExportImagesToCloud(cloudPath, filter, imageNames)
 			} else if cloudPath == "" && hasCFlag {
 				// If -c flag was explicitly provided with empty value, use default cloud directory from config
-				configData, err := config.GetBDFSConfig()
+				defaultDir, err := cloud.DefaultDir()
 				if err != nil {
-					fmt.Printf("[x] Error getting BDFS configuration: %v\n", err)
+					i18n.Printf("[x] Error getting BDFS configuration: %v\n", err)
 					os.Exit(1)
 				}
 				// Use the default cloud directory from config, falling back to "/" if not set
-				defaultPath := configData.DefaultCloudDir
-				if defaultPath == "" {
-					defaultPath = "/"
+				if defaultDir == "" {
+					defaultDir = "/"
 				}
-				cloud.ExportImagesToCloud(defaultPath)
+				cloud.ExportImagesToCloud(defaultDir, filter, imageNames)
 			} else if cloudPath == "" && bdfsConfigAvailable {
 				// If cloudPath is empty and BDFS config is provided (but -c not explicitly used), use default cloud directory
-				configData, err := config.GetBDFSConfig()
+				defaultDir, err := cloud.DefaultDir()
 				if err != nil {
-					fmt.Printf("[x] Error getting BDFS configuration: %v\n", err)
+					i18n.Printf("[x] Error getting BDFS configuration: %v\n", err)
 					os.Exit(1)
 				}
-				cloud.ExportImagesToCloud(configData.DefaultCloudDir)
+				cloud.ExportImagesToCloud(defaultDir, filter, imageNames)
 			} else {
-				docker.ExportImages(destination)
+				docker.ExportImages(destination, filter, imageNames)
 			}
 		}
 	case "import":
@@ -152,41 +1006,190 @@ func main() {
 
 			importCmd.Parse(os.Args[2:])
 
-			// Store grep pattern in environment variable for access by other modules
-			if grepPattern != "" {
-				os.Setenv("DKCI_GREP_PATTERN", grepPattern)
+			// Record the command line so that an interrupted import can be resumed with the unfinished files
+			job.Prepare("import", importCmd, "password", "detach")
+
+			// Load into the kind cluster nodes instead of the local Docker daemon if requested
+			docker.SetKindCluster(kindCluster)
+
+			// Import the latest run folder of an export with --run-folder if requested
+			docker.SetLatestRun(latestRun)
+
+			// Offer only the newest archive of each image if requested
+			docker.SetLatestOnly(latestOnly)
+
+			// Tag the imported images with extra references if requested, which kind nodes cannot be given
+			if len(alsoTags) > 0 && kindCluster != "" {
+				i18n.Println("[x] Error: --also-tag cannot be combined with --kind")
+				os.Exit(1)
+			}
+			if err := docker.SetAlsoTags(alsoTags); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Download and load several files at once if requested
+			if err := applyConcurrency(importCmd); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+			cloud.SetNoCache(noCache)
+			cloud.SetKeepTempFiles(keepTempFiles)
+
+			// Verify the signature of every file before importing it if requested
+			if verifySignature {
+				if keyPath == "" {
+					i18n.Println("[x] Error: --key is required with --verify-signature")
+					os.Exit(1)
+				}
+				sign.SetVerifyKey(keyPath, skipVerify)
+				sign.SetTransparencyLog(rekorLog)
+			}
+
+			// Open files wrapped in password-protected containers
+			if err := applyArchive(""); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Apply the sort order and --all to the selection lists
+			if err := docker.SetSortOrder(sortKey, sortReverse); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+			docker.SetSelectAll(selectAll)
+
+			// Name (or parse) the archives with the configured file name template
+			if err := applyNameTemplate(); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Combine the grep patterns and platform into a single filter
+			filter := docker.Filter{
+				Grep: docker.GrepFilter{Patterns: grepPatterns, MatchAll: matchAll},
+				OS:   filterOS,
+				Arch: filterArch,
 			}
 
 			// Check if both source and cloud path are specified
 			if hasSFlag && cloudImportPath != "" {
-				fmt.Println("[x] Error: -s and -c flags are mutually exclusive")
+				i18n.Println("[x] Error: -s and -c flags are mutually exclusive")
+				os.Exit(1)
+			}
+
+			// Report the loaded images as JSON on stdout if requested; the progress messages go to stderr then
+			switch outputFormat {
+			case "text":
+			case "json":
+				if progressFormat == "jsonl" && progressFD == 1 {
+					i18n.Println("[x] Error: --output json and --progress jsonl cannot both write to stdout, set --progress-fd")
+					os.Exit(1)
+				}
+				docker.SetReport(os.Stdout)
+				os.Stdout = os.Stderr
+			default:
+				i18n.Printf("[x] Error: unsupported output format %q (expected text or json)\n", outputFormat)
 				os.Exit(1)
 			}
 
+			// Hand the import to the background worker if requested
+			if detachJob {
+				queueJob(importCmd.Args())
+				return
+			}
+
 			if source != "" {
 				// Use local source
-				docker.ImportImagesFromSource(source, grepPattern)
+				docker.ImportImagesFromSource(source, filter, importCmd.Args())
 			} else if cloudImportPath != "" {
 				// Use cloud import
-				cloud.ImportImagesFromCloud(cloudImportPath, grepPattern)
+				cloud.ImportImagesFromCloud(cloudImportPath, filter, importCmd.Args())
 			} else if cloudImportPath == "" && hasCFlag {
 				// If -c flag was explicitly provided with empty value, use default cloud directory from config
-				configData, err := config.GetBDFSConfig()
+				defaultDir, err := cloud.DefaultDir()
 				if err != nil {
-					fmt.Printf("[x] Error getting BDFS configuration: %v\n", err)
+					i18n.Printf("[x] Error getting BDFS configuration: %v\n", err)
 					os.Exit(1)
 				}
 				// Use the default cloud directory from config, falling back to "/" if not set
-				defaultPath := configData.DefaultCloudDir
-				if defaultPath == "" {
-					defaultPath = "/"
+				if defaultDir == "" {
+					defaultDir = "/"
 				}
-				cloud.ImportImagesFromCloud(defaultPath, grepPattern)
+				cloud.ImportImagesFromCloud(defaultDir, filter, importCmd.Args())
 			} else {
-				fmt.Println("[x] Error: either -s/--source or -c/--cloud flag is required for import command")
+				i18n.Println("[x] Error: either -s/--source or -c/--cloud flag is required for import command")
 				os.Exit(1)
 			}
 		}
+	case "repack":
+		repackCmd.Parse(os.Args[2:])
+
+		// Retry cloud uploads whose server-side checksum does not match
+		if uploadRetries < 0 {
+			i18n.Println("[x] Error: --upload-retries must not be negative")
+			os.Exit(1)
+		}
+		cloud.SetUploadRetries(uploadRetries)
+		cloud.SetKeepTempFiles(keepTempFiles)
+
+		// Convert to the requested compression; without --level the default of the format is used
+		if !repackCmd.Changed("to") {
+			i18n.Println("[x] Error: --to is required for repack command")
+			os.Exit(1)
+		}
+		if err := docker.SetCompression(compressFormat); err != nil {
+			i18n.Printf("[x] Error: %v\n", err)
+			os.Exit(1)
+		}
+		if repackCmd.Changed("level") && !docker.Compressed() {
+			i18n.Println("[x] Error: --level requires --to gzip or zstd")
+			os.Exit(1)
+		}
+		if err := docker.SetCompressionLevel(compressLevel); err != nil {
+			i18n.Printf("[x] Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Apply the sort order and --all to the selection lists
+		if err := docker.SetSortOrder(sortKey, sortReverse); err != nil {
+			i18n.Printf("[x] Error: %v\n", err)
+			os.Exit(1)
+		}
+		docker.SetSelectAll(selectAll)
+
+		// Find the archives of templates with subdirectories
+		if err := applyNameTemplate(); err != nil {
+			i18n.Printf("[x] Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		filter := docker.Filter{Grep: docker.GrepFilter{Patterns: grepPatterns, MatchAll: matchAll}}
+
+		if repackCmd.Changed("source") && repackCmd.Changed("cloud") {
+			i18n.Println("[x] Error: -s and -c flags are mutually exclusive")
+			os.Exit(1)
+		}
+		if source != "" {
+			docker.RepackLocal(source, filter, repackCmd.Args())
+		} else if repackCmd.Changed("cloud") {
+			// Without a path, repack the default cloud directory from config
+			if cloudImportPath == "" {
+				defaultDir, err := cloud.DefaultDir()
+				if err != nil {
+					i18n.Printf("[x] Error getting BDFS configuration: %v\n", err)
+					os.Exit(1)
+				}
+				cloudImportPath = defaultDir
+				if cloudImportPath == "" {
+					cloudImportPath = "/"
+				}
+			}
+			cloud.RepackCloud(cloudImportPath, filter, repackCmd.Args())
+		} else {
+			i18n.Println("[x] Error: either -s/--source or -c/--cloud flag is required for repack command")
+			os.Exit(1)
+		}
 	case "delete":
 		// Check for help flag before full parsing
 		showHelp := false
@@ -202,12 +1205,31 @@ func main() {
 		} else {
 			deleteCmd.Parse(os.Args[2:])
 
-			// Store grep pattern in environment variable for access by other modules
-			if grepPattern != "" {
-				os.Setenv("DKCI_GREP_PATTERN", grepPattern)
+			// Apply the sort order and --all to the selection lists
+			if err := docker.SetSortOrder(sortKey, sortReverse); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+			docker.SetSelectAll(selectAll)
+
+			// Combine the grep patterns, platform, size and creation time bounds into a single filter; untagged
+			// images are offered as well if requested
+			filter := docker.Filter{
+				Grep:     docker.GrepFilter{Patterns: grepPatterns, MatchAll: matchAll},
+				OS:       filterOS,
+				Arch:     filterArch,
+				Untagged: untaggedImages,
+			}
+			if err := parseSizeBounds(&filter); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+			if err := parseCreatedBounds(&filter); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
 			}
 
-			docker.DeleteImages(grepPattern)
+			docker.DeleteImages(filter, deleteCmd.Args())
 		}
 	case "version":
 		// Check for help flag before full parsing
@@ -223,7 +1245,10 @@ func main() {
 			versionCmd.Parse(os.Args[2:])
 		} else {
 			versionCmd.Parse(os.Args[2:])
-			fmt.Printf("go-dkci version %s\n", version)
+			if err := printVersion(outputFormat); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
 		}
 	case "clean":
 		// Check for help flag before full parsing
@@ -239,53 +1264,1560 @@ func main() {
 			cleanCmd.Parse(os.Args[2:])
 		} else {
 			cleanCmd.Parse(os.Args[2:])
-			docker.CleanCache()
+			docker.CleanCache(assumeYes)
+		}
+	case "resume":
+		// Check for help flag before full parsing
+		showHelp := false
+		for _, arg := range os.Args[2:] {
+			if arg == "-h" || arg == "--help" {
+				showHelp = true
+				break
+			}
 		}
-	case "help":
-		printUsage()
-	case "-h":
-		printUsage()
-	case "--help":
-		printUsage()
-	default:
-		fmt.Printf("Unrecognized subcommand: %s\n", os.Args[1])
-		os.Exit(1)
-	}
-}
 
-func printUsage() {
+		if showHelp {
+			resumeCmd.Parse(os.Args[2:])
+		} else {
+			resumeCmd.Parse(os.Args[2:])
+
+			// Without a job id, list the jobs that can be resumed
+			if resumeCmd.NArg() == 0 {
+				jobs, err := job.List()
+				if err != nil {
+					i18n.Printf("[x] Failed to list jobs: %v\n", err)
+					os.Exit(1)
+				}
+				resumable := 0
+				for _, j := range jobs {
+					if j.Active() || len(j.Unfinished()) == 0 {
+						continue
+					}
+					fmt.Printf("%s  %-6s  %d of %d item(s) left  started %s\n", j.ID, j.Command, len(j.Unfinished()), len(j.Items), j.Created.Format("2006-01-02 15:04"))
+					resumable++
+				}
+				if resumable == 0 {
+					fmt.Println("No unfinished jobs")
+				}
+				return
+			}
+			if resumeCmd.NArg() != 1 {
+				i18n.Println("[x] Error: resume takes a single job id")
+				os.Exit(1)
+			}
+			job.Resume(resumeCmd.Arg(0))
+		}
+	case "status":
+		// Check for help flag before full parsing
+		showHelp := false
+		for _, arg := range os.Args[2:] {
+			if arg == "-h" || arg == "--help" {
+				showHelp = true
+				break
+			}
+		}
+
+		if showHelp {
+			statusCmd.Parse(os.Args[2:])
+		} else {
+			statusCmd.Parse(os.Args[2:])
+
+			if statusCmd.NArg() > 1 {
+				i18n.Println("[x] Error: status takes at most one job id")
+				os.Exit(1)
+			}
+			job.Status(statusCmd.Arg(0), allJobs)
+		}
+	case "outdated":
+		outdatedCmd.Parse(os.Args[2:])
+
+		if outdatedCmd.NArg() > 0 {
+			i18n.Println("[x] Error: outdated takes no arguments")
+			os.Exit(1)
+		}
+		if destination != "" && cloudPath != "" {
+			i18n.Println("[x] Error: -d and -c flags are mutually exclusive")
+			os.Exit(1)
+		}
+
+		// The exports are recorded with absolute local paths and with cloud paths named after their backend
+		location := ""
+		if cloudPath != "" {
+			location = cloud.Location(cloudPath)
+		} else if destination != "" {
+			absPath, err := filepath.Abs(destination)
+			if err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+			location = absPath
+		}
+		filter := docker.Filter{
+			Grep: docker.GrepFilter{Patterns: grepPatterns, MatchAll: matchAll},
+			OS:   filterOS,
+			Arch: filterArch,
+		}
+		docker.Outdated(filter, location)
+	case "log":
+		logCmd.Parse(os.Args[2:])
+
+		if logCmd.NArg() > 0 {
+			i18n.Println("[x] Error: log takes no arguments")
+			os.Exit(1)
+		}
+		if outputFormat != "text" && outputFormat != "json" {
+			i18n.Printf("[x] Error: unsupported output format %q (expected text or json)\n", outputFormat)
+			os.Exit(1)
+		}
+		docker.PrintLog(logLast, outputFormat)
+	case "worker":
+		workerCmd.Parse(os.Args[2:])
+
+		// Run the queued background jobs; started by --detach
+		job.RunWorker()
+	case "inspect":
+		// Check for help flag before full parsing
+		showHelp := false
+		for _, arg := range os.Args[2:] {
+			if arg == "-h" || arg == "--help" {
+				showHelp = true
+				break
+			}
+		}
+
+		if showHelp {
+			inspectCmd.Parse(os.Args[2:])
+		} else {
+			inspectCmd.Parse(os.Args[2:])
+
+			if inspectCmd.NArg() != 1 {
+				i18n.Println("[x] Error: inspect requires exactly one archive path")
+				os.Exit(1)
+			}
+
+			// Locate the attestation of the archive, downloading it first for cloud files
+			attestationPath := inspectCmd.Arg(0)
+			if inspectCloud {
+				attestationPath = cloud.FetchAttestation(attestationPath)
+				defer os.Remove(attestationPath)
+				defer os.Remove(sign.SignatureFile(attestationPath))
+			} else if !strings.HasSuffix(attestationPath, attest.AttestationSuffix) {
+				attestationPath = attest.File(attestationPath)
+			}
+
+			attestation, err := attest.Read(attestationPath)
+			if err != nil {
+				i18n.Printf("[x] Failed to read attestation: %v\n", err)
+				os.Exit(1)
+			}
+			attestation.Print()
+
+			// Check the attestation signature if a key is given
+			if keyPath != "" {
+				sign.SetVerifyKey(keyPath, false)
+				if err := sign.VerifyFile(attestationPath); err != nil {
+					i18n.Printf("[x] Attestation signature verification failed: %v\n", err)
+					os.Exit(1)
+				}
+				i18n.Println("[√] Attestation signature verified")
+			}
+		}
+	case "registry":
+		// Check for help flag before full parsing
+		showHelp := false
+		for _, arg := range os.Args[2:] {
+			if arg == "-h" || arg == "--help" {
+				showHelp = true
+				break
+			}
+		}
+
+		if showHelp {
+			registryCmd.Parse(os.Args[2:])
+		} else {
+			registryCmd.Parse(os.Args[2:])
+
+			// Parse the archive names with the configured file name template
+			if err := applyNameTemplate(); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Use the default cloud directory from config if no folder is given
+			if registryCloud == "" {
+				defaultDir, err := cloud.DefaultDir()
+				if err != nil {
+					i18n.Printf("[x] Error getting BDFS configuration: %v\n", err)
+					os.Exit(1)
+				}
+				registryCloud = defaultDir
+				if registryCloud == "" {
+					registryCloud = "/"
+				}
+			}
+
+			if err := registry.Serve(listenAddress, cloud.Login(), registryCloud, readinessChecks()...); err != nil {
+				i18n.Printf("[x] Registry stopped: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	case "serve-files":
+		// Check for help flag before full parsing
+		showHelp := false
+		for _, arg := range os.Args[2:] {
+			if arg == "-h" || arg == "--help" {
+				showHelp = true
+				break
+			}
+		}
+
+		if showHelp {
+			serveFilesCmd.Parse(os.Args[2:])
+		} else {
+			serveFilesCmd.Parse(os.Args[2:])
+
+			if err := fileserver.Serve(serveListen, serveDir, readinessChecks()...); err != nil {
+				i18n.Printf("[x] File server stopped: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	case "export-container":
+		// Check for help flag before full parsing
+		showHelp := false
+		for _, arg := range os.Args[2:] {
+			if arg == "-h" || arg == "--help" {
+				showHelp = true
+				break
+			}
+		}
+
+		if showHelp {
+			exportContainerCmd.Parse(os.Args[2:])
+		} else {
+			exportContainerCmd.Parse(os.Args[2:])
+
+			if exportContainerCmd.NArg() == 0 {
+				i18n.Println("[x] Error: export-container requires at least one container name or ID")
+				os.Exit(1)
+			}
+
+			if containerCloud != "" {
+				cloud.SetKeepTempFiles(keepTempFiles)
+				cloud.SetCreateFolders(!noCreateFolders)
+				cloud.ExportContainersToCloud(containerCloud, exportContainerCmd.Args())
+			} else {
+				docker.ExportContainers(destination, exportContainerCmd.Args())
+			}
+		}
+	case "snapshot":
+		// Check for help flag before full parsing
+		showHelp := false
+		for _, arg := range os.Args[2:] {
+			if arg == "-h" || arg == "--help" {
+				showHelp = true
+				break
+			}
+		}
+
+		if showHelp {
+			snapshotCmd.Parse(os.Args[2:])
+		} else {
+			snapshotCmd.Parse(os.Args[2:])
+
+			if snapshotCmd.NArg() != 1 {
+				i18n.Println("[x] Error: snapshot requires exactly one container name or ID")
+				os.Exit(1)
+			}
+
+			// Name the exported file with the configured file name template
+			if err := applyNameTemplate(); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			imageName, err := docker.CommitContainer(snapshotCmd.Arg(0), snapshotTag, !noPause)
+			if err != nil {
+				i18n.Printf("[x] Failed to commit container %s: %v\n", snapshotCmd.Arg(0), err)
+				os.Exit(1)
+			}
+
+			if containerCloud != "" {
+				cloud.SetKeepTempFiles(keepTempFiles)
+				cloud.SetCreateFolders(!noCreateFolders)
+				cloud.ExportImagesToCloud(containerCloud, docker.Filter{}, []string{imageName})
+			} else {
+				docker.ExportImages(destination, docker.Filter{}, []string{imageName})
+			}
+		}
+	case "send":
+		// Check for help flag before full parsing
+		showHelp := false
+		for _, arg := range os.Args[2:] {
+			if arg == "-h" || arg == "--help" {
+				showHelp = true
+				break
+			}
+		}
+
+		if showHelp {
+			sendCmd.Parse(os.Args[2:])
+		} else {
+			sendCmd.Parse(os.Args[2:])
+
+			if (tlsCert == "") != (tlsKey == "") {
+				i18n.Println("[x] Error: --tls-cert and --tls-key must be used together")
+				os.Exit(1)
+			}
+			docker.SetSendTLS(tlsCert, tlsKey)
+			docker.SetTransferToken(transferToken)
+			docker.SetSelectAll(selectAll)
+
+			filter := docker.Filter{
+				Grep: docker.GrepFilter{Patterns: grepPatterns, MatchAll: matchAll},
+				OS:   filterOS,
+				Arch: filterArch,
+			}
+			docker.SendImages(sendListen, filter, sendCmd.Args())
+		}
+	case "receive":
+		// Check for help flag before full parsing
+		showHelp := false
+		for _, arg := range os.Args[2:] {
+			if arg == "-h" || arg == "--help" {
+				showHelp = true
+				break
+			}
+		}
+
+		if showHelp {
+			receiveCmd.Parse(os.Args[2:])
+		} else {
+			receiveCmd.Parse(os.Args[2:])
+
+			if receiveFrom == "" {
+				i18n.Println("[x] Error: --from is required for receive command")
+				os.Exit(1)
+			}
+			docker.SetKindCluster(kindCluster)
+			docker.SetReceiveTLS(useTLS, caCert, insecureTLS)
+			docker.SetTransferToken(transferToken)
+			docker.ReceiveImages(receiveFrom)
+		}
+	case "copy":
+		// Check for help flag before full parsing
+		showHelp := false
+		for _, arg := range os.Args[2:] {
+			if arg == "-h" || arg == "--help" {
+				showHelp = true
+				break
+			}
+		}
+
+		if showHelp {
+			copyCmd.Parse(os.Args[2:])
+		} else {
+			copyCmd.Parse(os.Args[2:])
+
+			// Two endpoint arguments such as "docker:nginx:1.26 oci-dir:/out" copy a single archive between backends
+			args := copyCmd.Args()
+			if !copyCmd.Changed("from") && !copyCmd.Changed("to") && len(args) == 2 &&
+				(backend.IsEndpoint(args[0]) || backend.IsEndpoint(args[1])) {
+				// Archives of docker: sources are named with the configured file name template
+				if err := applyNameTemplate(); err != nil {
+					i18n.Printf("[x] Error: %v\n", err)
+					os.Exit(1)
+				}
+				backend.Copy(args[0], args[1])
+				return
+			}
+
+			if copyFrom == copyTo {
+				i18n.Println("[x] Error: --from and --to must name different Docker hosts")
+				os.Exit(1)
+			}
+			docker.SetSelectAll(selectAll)
+			filter := docker.Filter{
+				Grep: docker.GrepFilter{Patterns: grepPatterns, MatchAll: matchAll},
+				OS:   filterOS,
+				Arch: filterArch,
+			}
+			docker.CopyImages(copyFrom, copyTo, filter, args)
+		}
+	case "migrate":
+		// Check for help flag before full parsing
+		showHelp := false
+		for _, arg := range os.Args[2:] {
+			if arg == "-h" || arg == "--help" {
+				showHelp = true
+				break
+			}
+		}
+
+		if showHelp {
+			migrateCmd.Parse(os.Args[2:])
+		} else {
+			migrateCmd.Parse(os.Args[2:])
+
+			if migrateOptions.From == migrateOptions.To {
+				i18n.Println("[x] Error: --from and --to must name different Docker hosts")
+				os.Exit(1)
+			}
+			if err := migrate.CheckVia(migrateOptions.Via); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+			if migrateCmd.Changed("cloud") && migrateOptions.Via != migrate.ViaCloud {
+				i18n.Println("[x] Error: --cloud requires --via cloud")
+				os.Exit(1)
+			}
+			// Without a folder, relay through the default cloud directory from config
+			if migrateOptions.Via == migrate.ViaCloud && migrateOptions.CloudDir == "" {
+				defaultDir, err := cloud.DefaultDir()
+				if err != nil {
+					i18n.Printf("[x] Error getting BDFS configuration: %v\n", err)
+					os.Exit(1)
+				}
+				migrateOptions.CloudDir = defaultDir
+				if migrateOptions.CloudDir == "" {
+					i18n.Println("[x] Error: --via cloud requires --cloud, or default_cloud_dir in the configuration file")
+					os.Exit(1)
+				}
+			}
+			// Relayed archives are named with the configured file name template
+			if err := applyNameTemplate(); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+			docker.SetSelectAll(selectAll)
+			filter := docker.Filter{
+				Grep: docker.GrepFilter{Patterns: grepPatterns, MatchAll: matchAll},
+				OS:   filterOS,
+				Arch: filterArch,
+			}
+			migrate.Migrate(migrateOptions, filter, migrateCmd.Args())
+		}
+	case "ui":
+		// Check for help flag before full parsing
+		showHelp := false
+		for _, arg := range os.Args[2:] {
+			if arg == "-h" || arg == "--help" {
+				showHelp = true
+				break
+			}
+		}
+
+		if showHelp {
+			uiCmd.Parse(os.Args[2:])
+		} else {
+			uiCmd.Parse(os.Args[2:])
+			if !docker.Interactive() {
+				i18n.Println("[x] Error: the ui command needs a terminal, use export, import or delete in scripts")
+				os.Exit(1)
+			}
+			ui.Run()
+		}
+	case "volume":
+		if len(os.Args) < 3 || (os.Args[2] != "export" && os.Args[2] != "import") {
+			i18n.Println("[x] Error: unknown volume subcommand (expected export or import)")
+			os.Exit(1)
+		}
+		volumeCmd := volumeExportCmd
+		if os.Args[2] == "import" {
+			volumeCmd = volumeImportCmd
+		}
+
+		// Check for help flag before full parsing
+		showHelp := false
+		for _, arg := range os.Args[3:] {
+			if arg == "-h" || arg == "--help" {
+				showHelp = true
+				break
+			}
+		}
+
+		if showHelp {
+			volumeCmd.Parse(os.Args[3:])
+		} else {
+			volumeCmd.Parse(os.Args[3:])
+
+			docker.SetHelperImage(helperImage)
+			cloud.SetKeepTempFiles(keepTempFiles)
+			cloud.SetCreateFolders(!noCreateFolders)
+
+			if os.Args[2] == "export" {
+				if volumeCmd.NArg() == 0 {
+					i18n.Println("[x] Error: volume export requires at least one volume name")
+					os.Exit(1)
+				}
+				if containerCloud != "" {
+					cloud.ExportVolumesToCloud(containerCloud, volumeCmd.Args())
+				} else {
+					docker.ExportVolumes(destination, volumeCmd.Args())
+				}
+			} else {
+				if source != "" && containerCloud != "" {
+					i18n.Println("[x] Error: -s/--source and -c/--cloud flags are mutually exclusive")
+					os.Exit(1)
+				}
+				if source != "" {
+					docker.ImportVolume(source, volumeName)
+				} else if containerCloud != "" {
+					cloud.ImportVolumeFromCloud(containerCloud, volumeName)
+				} else {
+					i18n.Println("[x] Error: either -s/--source or -c/--cloud flag is required for volume import command")
+					os.Exit(1)
+				}
+			}
+		}
+	case "backup":
+		// Check for help flag before full parsing
+		showHelp := false
+		for _, arg := range os.Args[2:] {
+			if arg == "-h" || arg == "--help" {
+				showHelp = true
+				break
+			}
+		}
+
+		if showHelp {
+			backupCmd.Parse(os.Args[2:])
+		} else {
+			backupCmd.Parse(os.Args[2:])
+
+			docker.SetHelperImage(helperImage)
+			filter := docker.Filter{Grep: docker.GrepFilter{Patterns: grepPatterns}}
+			backup.BackupHost(backupDir, containerCloud, filter, composeFiles, backupAll, version)
+		}
+	case "restore":
+		// Check for help flag before full parsing
+		showHelp := false
+		for _, arg := range os.Args[2:] {
+			if arg == "-h" || arg == "--help" {
+				showHelp = true
+				break
+			}
+		}
+
+		if showHelp {
+			restoreCmd.Parse(os.Args[2:])
+		} else {
+			restoreCmd.Parse(os.Args[2:])
+
+			if (source == "") == (containerCloud == "") {
+				i18n.Println("[x] Error: exactly one of -s/--source or -c/--cloud is required for restore command")
+				os.Exit(1)
+			}
+			docker.SetHelperImage(helperImage)
+			backup.RestoreHost(source, containerCloud, composeDir)
+		}
+	case "apply":
+		// Check for help flag before full parsing
+		showHelp := false
+		for _, arg := range os.Args[2:] {
+			if arg == "-h" || arg == "--help" {
+				showHelp = true
+				break
+			}
+		}
+
+		if showHelp {
+			applyCmd.Parse(os.Args[2:])
+		} else {
+			applyCmd.Parse(os.Args[2:])
+
+			if bundleFile == "" {
+				i18n.Println("[x] Error: -f/--file flag is required for apply command")
+				os.Exit(1)
+			}
+			if uploadRetries < 0 {
+				i18n.Println("[x] Error: --upload-retries must not be negative")
+				os.Exit(1)
+			}
+			cloud.SetUploadRetries(uploadRetries)
+
+			manifest, err := bundle.Load(bundleFile)
+			if err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+			nameTemplate, err := config.GetNameTemplate()
+			if err == nil {
+				err = manifest.Configure(nameTemplate, config.GetCompressLevel())
+			}
+			if err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+			bundle.Apply(manifest, pruneBundle, dryRun)
+		}
+	case "cloud":
+		// Move or rename a cloud file or folder
+		if len(os.Args) >= 3 && os.Args[2] == "mv" {
+			cloudMvCmd.Parse(os.Args[3:])
+			if cloudMvCmd.NArg() != 2 {
+				i18n.Println("[x] Error: cloud mv requires a source and a target path")
+				os.Exit(1)
+			}
+			cloud.MoveCloud(cloudMvCmd.Arg(0), cloudMvCmd.Arg(1))
+			break
+		}
+
+		if len(os.Args) < 3 || os.Args[2] != "prune" {
+			i18n.Println("[x] Error: unknown cloud subcommand (expected prune or mv)")
+			os.Exit(1)
+		}
+		cloudPruneCmd.Parse(os.Args[3:])
+
+		// Find the archives of templates with subdirectories
+		if err := applyNameTemplate(); err != nil {
+			i18n.Printf("[x] Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Refuse to empty the whole folder by accident: at least one criterion is required
+		if len(grepPatterns) == 0 && since == "" && newerThan == "" && olderThan == "" {
+			i18n.Println("[x] Error: cloud prune requires --grep, --older-than, --newer-than or --since")
+			os.Exit(1)
+		}
+		filter := docker.Filter{Grep: docker.GrepFilter{Patterns: grepPatterns, MatchAll: matchAll}}
+		if err := parseCreatedBounds(&filter); err != nil {
+			i18n.Printf("[x] Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Without a folder, prune the default cloud directory from config
+		if cloudPruneCmd.NArg() > 1 {
+			i18n.Println("[x] Error: cloud prune takes at most one folder")
+			os.Exit(1)
+		}
+		pruneFolder := cloudPruneCmd.Arg(0)
+		if pruneFolder == "" {
+			defaultDir, err := cloud.DefaultDir()
+			if err != nil {
+				i18n.Printf("[x] Error getting BDFS configuration: %v\n", err)
+				os.Exit(1)
+			}
+			pruneFolder = defaultDir
+			if pruneFolder == "" {
+				i18n.Println("[x] Error: cloud prune requires a folder, or default_cloud_dir in the configuration file")
+				os.Exit(1)
+			}
+		}
+		cloud.PruneCloud(pruneFolder, filter, assumeYes, dryRun)
+	case "gc":
+		gcCmd.Parse(os.Args[2:])
+
+		// Find the recipes of templates with subdirectories
+		if err := applyNameTemplate(); err != nil {
+			i18n.Printf("[x] Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Without a folder, collect the blob store of the default cloud directory from config
+		if gcCmd.NArg() > 1 {
+			i18n.Println("[x] Error: gc takes at most one folder")
+			os.Exit(1)
+		}
+		gcFolder := gcCmd.Arg(0)
+		if gcFolder == "" {
+			defaultDir, err := cloud.DefaultDir()
+			if err != nil {
+				i18n.Printf("[x] Error getting BDFS configuration: %v\n", err)
+				os.Exit(1)
+			}
+			gcFolder = defaultDir
+			if gcFolder == "" {
+				i18n.Println("[x] Error: gc requires a folder, or default_cloud_dir in the configuration file")
+				os.Exit(1)
+			}
+		}
+		cloud.CollectGarbage(gcFolder, assumeYes, dryRun)
+	case "promote":
+		promoteCmd.Parse(os.Args[2:])
+
+		// The last argument is the target folder, the others are the files to promote, with wildcards in their
+		// file names
+		if promoteCmd.NArg() < 2 {
+			i18n.Println("[x] Error: promote requires the files to promote and a target folder")
+			os.Exit(1)
+		}
+		args := promoteCmd.Args()
+		cloud.Promote(args[:len(args)-1], args[len(args)-1], moveFiles, dryRun)
+	case "k8s":
+		if len(os.Args) < 3 || os.Args[2] != "preload" {
+			i18n.Println("[x] Error: unknown k8s subcommand (expected preload)")
+			os.Exit(1)
+		}
+
+		// Check for help flag before full parsing
+		showHelp := false
+		for _, arg := range os.Args[3:] {
+			if arg == "-h" || arg == "--help" {
+				showHelp = true
+				break
+			}
+		}
+
+		if showHelp {
+			preloadCmd.Parse(os.Args[3:])
+		} else {
+			preloadCmd.Parse(os.Args[3:])
+
+			if preloadOptions.Source == "" {
+				i18n.Println("[x] Error: --from flag is required for k8s preload command")
+				os.Exit(1)
+			}
+
+			preloadOptions.Files = preloadCmd.Args()
+			manifest, err := k8s.PreloadDaemonSet(preloadOptions)
+			if err != nil {
+				i18n.Printf("[x] Failed to generate preload DaemonSet: %v\n", err)
+				os.Exit(1)
+			}
+
+			if !applyManifest {
+				fmt.Print(manifest)
+				return
+			}
+			if err := k8s.ApplyManifest(manifest); err != nil {
+				i18n.Printf("[x] Failed to apply preload DaemonSet: %v\n", err)
+				os.Exit(1)
+			}
+			i18n.Printf("[√] Preload DaemonSet %s/%s applied for %d file(s)\n", preloadOptions.Namespace, preloadOptions.Name, len(preloadOptions.Files))
+		}
+	case "install-service":
+		// Check for help flag before full parsing
+		showHelp := false
+		for _, arg := range os.Args[2:] {
+			if arg == "--" {
+				break
+			}
+			if arg == "-h" || arg == "--help" {
+				showHelp = true
+				break
+			}
+		}
+
+		if showHelp {
+			installServiceCmd.Parse(os.Args[2:])
+		} else {
+			installServiceCmd.Parse(os.Args[2:])
+
+			if runtime.GOOS == "windows" {
+				i18n.Println("[x] Error: install-service needs systemd or cron, use the Task Scheduler on Windows")
+				os.Exit(1)
+			}
+			serviceOptions.Args = installServiceCmd.Args()
+			if len(serviceOptions.Args) == 0 {
+				i18n.Println("[x] Error: a go-dkci command to install is required, e.g. install-service --schedule \"0 2 * * *\" -- export --all")
+				os.Exit(1)
+			}
+			if installServiceCmd.ArgsLenAtDash() != 0 {
+				// Without "--" the global flags of the command were taken out of it by parseGlobalFlags
+				i18n.Println("[x] Error: put -- before the command to install, e.g. install-service --schedule \"0 2 * * *\" -- export --all")
+				os.Exit(1)
+			}
+			if !serviceOptions.User && !serviceOptions.Cron && !dryRun && os.Geteuid() != 0 {
+				i18n.Println("[x] Error: installing systemd system units needs root, run it with sudo or add --user")
+				os.Exit(1)
+			}
+			if err := service.Install(serviceOptions, dryRun); err != nil {
+				i18n.Printf("[x] Failed to install service %s: %v\n", serviceOptions.Name, err)
+				os.Exit(1)
+			}
+			if !dryRun {
+				i18n.Printf("[√] Installed service %s\n", serviceOptions.Name)
+				if serviceOptions.User {
+					fmt.Println("Run `loginctl enable-linger` so that user units also run while you are logged out")
+				}
+			}
+		}
+	case "docs":
+		docsCmd.Parse(os.Args[2:])
+		reference := &docs.Reference{
+			Version:     currentBuild().Version,
+			Date:        docs.BuildDate(),
+			TempDir:     tempDir,
+			Global:      globalFlags,
+			Commands:    commands,
+			Environment: environment,
+		}
+		switch docsFormat {
+		case "man":
+			written, err := reference.WriteManPages(docsDir)
+			if err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Wrote %d man page(s) to %s\n", len(written), docsDir)
+		case "json":
+			if err := reference.WriteJSON(os.Stdout); err != nil {
+				i18n.Printf("[x] Error: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			i18n.Printf("[x] Error: unsupported output format %q (expected man or json)\n", docsFormat)
+			os.Exit(1)
+		}
+	case "help":
+		printUsage()
+	case "-h":
+		printUsage()
+	case "--help":
+		printUsage()
+	default:
+		fmt.Printf("Unrecognized subcommand: %s\n", os.Args[1])
+		os.Exit(1)
+	}
+}
+
+// pluginArgs returns the arguments of go-dkci run by the docker CLI as a plugin, with the environment applying the
+// --context, --host and --config flags given to docker before the plugin name. ok is false when go-dkci runs
+// otherwise, including as docker-dkci from the shell.
+func pluginArgs(osArgs []string) (args []string, env map[string]string, ok bool) {
+	if strings.TrimSuffix(filepath.Base(osArgs[0]), ".exe") != "docker-"+pluginName {
+		return osArgs, nil, false
+	}
+	env = make(map[string]string)
+	for i := 1; i < len(osArgs); i++ {
+		arg := osArgs[i]
+		if arg == pluginName {
+			return append([]string{osArgs[0]}, osArgs[i+1:]...), env, true
+		}
+		if !strings.HasPrefix(arg, "-") {
+			break
+		}
+		name, value, hasValue := strings.Cut(arg, "=")
+		variable, takesValue := dockerFlags[name]
+		if !takesValue {
+			continue
+		}
+		if !hasValue && i+1 < len(osArgs) {
+			i++
+			value = osArgs[i]
+		}
+		if variable != "" {
+			env[variable] = value
+		}
+	}
+	return osArgs, nil, false
+}
+
+// parseGlobalFlags takes the global flags out of the arguments, wherever they are given, and sets them, so that
+// the commands parse the remaining arguments. Arguments after "--" are left alone.
+func parseGlobalFlags(globalFlags *pflag.FlagSet) error {
+	args, err := extractGlobalFlags(globalFlags, os.Args)
+	if err != nil {
+		return err
+	}
+	os.Args = args
+	return nil
+}
+
+// extractGlobalFlags sets the global flags given in args and returns the other arguments. Flags without a value
+// after "=" take the next argument, except flags with an implicit value, such as bool flags, which take that.
+func extractGlobalFlags(globalFlags *pflag.FlagSet, osArgs []string) ([]string, error) {
+	args := []string{osArgs[0]}
+	for i := 1; i < len(osArgs); i++ {
+		arg := osArgs[i]
+		if arg == "--" {
+			args = append(args, osArgs[i:]...)
+			break
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		flag := globalFlags.Lookup(name)
+		if !strings.HasPrefix(arg, "--") || flag == nil {
+			args = append(args, arg)
+			continue
+		}
+		if !hasValue {
+			switch {
+			case flag.NoOptDefVal != "":
+				value = flag.NoOptDefVal
+			case i+1 >= len(osArgs):
+				return nil, fmt.Errorf("flag needs an argument: --%s", name)
+			default:
+				i++
+				value = osArgs[i]
+			}
+		}
+		if err := globalFlags.Set(name, value); err != nil {
+			return nil, fmt.Errorf("invalid argument %q for --%s: %v", value, name, err)
+		}
+	}
+	return args, nil
+}
+
+// configureProgress writes the progress events of batches as JSON lines to the file descriptor of --progress-fd
+// with --progress jsonl. Events on stdout move the other output to stderr, so that stdout can be parsed.
+func configureProgress() error {
+	switch progressFormat {
+	case "text":
+		return nil
+	case "jsonl":
+	default:
+		return fmt.Errorf("unsupported progress format %q (expected text or jsonl)", progressFormat)
+	}
+
+	if progressFD == 1 {
+		job.SetEvents(os.Stdout)
+		os.Stdout = os.Stderr
+		return nil
+	}
+	if progressFD < 0 {
+		return fmt.Errorf("--progress-fd must not be negative")
+	}
+	events := os.NewFile(uintptr(progressFD), "progress")
+	if events == nil {
+		return fmt.Errorf("--progress-fd %d is not open", progressFD)
+	}
+	if _, err := events.Stat(); err != nil {
+		return fmt.Errorf("--progress-fd %d is not open: %v", progressFD, err)
+	}
+	job.SetEvents(events)
+	return nil
+}
+
+// removeRunDirOnSignal deletes the working directory of this run and exits when go-dkci is interrupted or terminated
+func removeRunDirOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		config.RemoveRunDir()
+		os.Exit(1)
+	}()
+}
+
+// configureTimeouts sets the timeouts not given on the command line from the docker_timeout and cloud_timeout keys
+// of the configuration file
+func configureTimeouts(globalFlags *pflag.FlagSet) error {
+	timeouts, err := config.GetTimeouts()
+	if err != nil {
+		return err
+	}
+	for _, setting := range []struct {
+		flag, key, value string
+		target           *time.Duration
+	}{
+		{"docker-timeout", "docker_timeout", timeouts.Docker, &dockerTimeout},
+		{"cloud-timeout", "cloud_timeout", timeouts.Cloud, &cloudTimeout},
+	} {
+		if setting.value == "" || globalFlags.Changed(setting.flag) {
+			continue
+		}
+		timeout, err := time.ParseDuration(setting.value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q in the configuration file: %v", setting.key, setting.value, err)
+		}
+		*setting.target = timeout
+	}
+	return nil
+}
+
+// buildInfo describes this build of go-dkci, as printed by the version command
+type buildInfo struct {
+	Version   string   `json:"version"`
+	Commit    string   `json:"commit"`
+	Modified  bool     `json:"modified,omitempty"`
+	BuildDate string   `json:"build_date"`
+	GoVersion string   `json:"go_version"`
+	Platform  string   `json:"platform"`
+	Backends  []string `json:"backends"`
+	Features  []string `json:"features"`
+}
+
+// currentBuild returns the build information from the ldflags variables, falling back to the module version and
+// the version control information embedded by go build
+func currentBuild() buildInfo {
+	info := buildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Features:  features,
+	}
+	for _, prefix := range backend.Prefixes() {
+		info.Backends = append(info.Backends, strings.TrimRight(prefix, ":/"))
+	}
+
+	embedded, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	// go install module@version records the version of the module
+	if version == defaultVersion && embedded.Main.Version != "" && embedded.Main.Version != "(devel)" {
+		info.Version = embedded.Main.Version
+	}
+	for _, setting := range embedded.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildDate == "" {
+				info.BuildDate = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = commit == "" && setting.Value == "true"
+		}
+	}
+	return info
+}
+
+// printVersion prints the build information as text or JSON
+func printVersion(format string) error {
+	info := currentBuild()
+	switch format {
+	case "text":
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	default:
+		return fmt.Errorf("unsupported output format %q (expected text or json)", format)
+	}
+
+	unknown := func(value string) string {
+		if value == "" {
+			return "unknown"
+		}
+		return value
+	}
+	commitText := unknown(info.Commit)
+	if info.Modified {
+		commitText += " (modified)"
+	}
+
+	fmt.Printf("go-dkci version %s\n", info.Version)
+	fmt.Printf("  Commit:     %s\n", commitText)
+	fmt.Printf("  Built:      %s\n", unknown(info.BuildDate))
+	fmt.Printf("  Go version: %s\n", info.GoVersion)
+	fmt.Printf("  Platform:   %s\n", info.Platform)
+	fmt.Printf("  Backends:   %s\n", strings.Join(info.Backends, ", "))
+	fmt.Printf("  Features:   %s\n", strings.Join(info.Features, ", "))
+	return nil
+}
+
+// applyNameTemplate sets the file name template from the --name-template flag or the configuration,
+// adding the image digest with --with-digest
+func applyNameTemplate() error {
+	if nameTemplate == "" {
+		var err error
+		if nameTemplate, err = config.GetNameTemplate(); err != nil {
+			return err
+		}
+	}
+	if withDigest {
+		nameTemplate = docker.WithDigest(nameTemplate)
+	}
+	return docker.SetNameTemplate(nameTemplate)
+}
+
+// readinessChecks returns the checks --check-docker adds to /readyz of the serving commands
+func readinessChecks() []health.Check {
+	if !checkDocker {
+		return nil
+	}
+	return []health.Check{health.Docker("local")}
+}
+
+// queueJob queues the prepared export or import as a background job
+func queueJob(items []string) {
+	queued, err := job.Enqueue(items)
+	if err != nil {
+		i18n.Printf("[x] Failed to queue job: %v\n", err)
+		os.Exit(1)
+	}
+	i18n.Printf("[√] Queued job %s; follow it with: go-dkci status %s\n", queued.ID, queued.ID)
+}
+
+// applyArchive sets the password-protected container of exported files and the password from the --password
+// flag or the DKCI_ARCHIVE_PASSWORD environment variable, which keeps it out of the shell history
+func applyArchive(format string) error {
+	if archivePassword == "" {
+		archivePassword = os.Getenv("DKCI_ARCHIVE_PASSWORD")
+	}
+	if format != "" && format != "none" && archivePassword == "" {
+		return fmt.Errorf("--archive %s needs --password or DKCI_ARCHIVE_PASSWORD", format)
+	}
+	return docker.SetArchive(format, archivePassword)
+}
+
+// applyConcurrency sets the limits of the parallel phases from the concurrency flags of the command. Flags not
+// given on the command line take their value from the configuration file, if it sets one.
+func applyConcurrency(flagSet *pflag.FlagSet) error {
+	defaults := config.GetConcurrency()
+	limits := []struct {
+		name       string
+		value      *int
+		configured int
+		set        func(int)
+	}{
+		{"save-concurrency", &saveConcurrency, defaults.Save, docker.SetSaveConcurrency},
+		{"transfer-concurrency", &transferLimit, defaults.Transfer, cloud.SetTransferConcurrency},
+		{"load-concurrency", &loadConcurrency, defaults.Load, docker.SetLoadConcurrency},
+	}
+	for _, limit := range limits {
+		flag := flagSet.Lookup(limit.name)
+		if flag == nil {
+			continue
+		}
+		if !flag.Changed && limit.configured != 0 {
+			*limit.value = limit.configured
+		}
+		if *limit.value < 1 {
+			return fmt.Errorf("--%s must be at least 1", limit.name)
+		}
+		limit.set(*limit.value)
+	}
+	return nil
+}
+
+// applyCompressLevel sets the compression level of exports from --compress-level, or the compress_level key of the
+// configuration file when the flag is not given
+func applyCompressLevel(flagSet *pflag.FlagSet) error {
+	if flagSet.Changed("compress-level") {
+		if !docker.Compressed() {
+			return fmt.Errorf("--compress-level requires --compress")
+		}
+		return docker.SetCompressionLevel(compressLevel)
+	}
+	compressLevel = config.GetCompressLevel()
+	if err := docker.SetCompressionLevel(compressLevel); err != nil {
+		return fmt.Errorf("compress_level in the configuration file: %v", err)
+	}
+	return nil
+}
+
+// parseSizeBounds sets the size bounds of the filter from the --min-size and --max-size flags
+func parseSizeBounds(filter *docker.Filter) error {
+	var err error
+	if filter.MinSize, err = docker.ParseSize(minSize); err != nil {
+		return fmt.Errorf("--min-size: %v", err)
+	}
+	if filter.MaxSize, err = docker.ParseSize(maxSize); err != nil {
+		return fmt.Errorf("--max-size: %v", err)
+	}
+	if filter.MaxSize > 0 && filter.MinSize > filter.MaxSize {
+		return fmt.Errorf("--min-size must not be greater than --max-size")
+	}
+	return nil
+}
+
+// parseCreatedBounds sets the creation time bounds of the filter from the --since, --newer-than and --older-than flags
+func parseCreatedBounds(filter *docker.Filter) error {
+	now := time.Now()
+
+	if since != "" {
+		sinceTime, err := time.ParseInLocation("2006-01-02", since, time.Local)
+		if err != nil {
+			return fmt.Errorf("--since: invalid date %q (expected YYYY-MM-DD)", since)
+		}
+		filter.CreatedAfter = sinceTime
+	}
+
+	if newerThan != "" {
+		age, err := docker.ParseAge(newerThan)
+		if err != nil {
+			return fmt.Errorf("--newer-than: %v", err)
+		}
+		// When combined with --since, the later bound wins
+		if after := now.Add(-age); after.After(filter.CreatedAfter) {
+			filter.CreatedAfter = after
+		}
+	}
+
+	if olderThan != "" {
+		age, err := docker.ParseAge(olderThan)
+		if err != nil {
+			return fmt.Errorf("--older-than: %v", err)
+		}
+		filter.CreatedBefore = now.Add(-age)
+	}
+
+	return nil
+}
+
+func printUsage() {
+	tempDir := config.TempDir()
+
 	fmt.Println("go-dkci - A tool for managing Docker images with Baidu Cloud")
 	fmt.Println()
-	fmt.Println("Usage: go-dkci [command] [flags]")
+	fmt.Println("Usage: go-dkci [command] [flags] [image or file names...]")
 	fmt.Println()
 	fmt.Println("Available commands:")
-	fmt.Println("  export    Export Docker images to local directory or Baidu Cloud")
-	fmt.Println("  import    Import Docker images from local .tar files")
-	fmt.Println("  delete    Delete Docker images")
-	fmt.Println("  clean     Clean cache directory")
-	fmt.Println("  version   Print program version")
-	fmt.Println("  help      Display this help information")
+	fmt.Println("  export           Export Docker images to local directory or Baidu Cloud")
+	fmt.Println("  export-container Export container filesystems to local directory or Baidu Cloud")
+	fmt.Println("  snapshot         Commit a container to an image and export it")
+	fmt.Println("  import           Import Docker images from local .tar files")
+	fmt.Println("  volume           Export or import Docker volumes (volume export, volume import)")
+	fmt.Println("  backup           Back up images, volumes and compose files of this host")
+	fmt.Println("  restore          Restore a backup made with the backup command")
+	fmt.Println("  apply            Export the images listed in a bundle manifest and prune the others")
+	fmt.Println("  repack           Recompress exported files, locally or in Baidu cloud, in place")
+	fmt.Println("  cloud            Manage Baidu cloud folders (cloud prune, cloud mv)")
+	fmt.Println("  gc               Delete the delta export layers no longer used by any export of a cloud folder")
+	fmt.Println("  promote          Copy exported files and their sidecars to another cloud folder")
+	fmt.Println("  delete           Delete Docker images")
+	fmt.Println("  resume           Continue an interrupted export or import job (resume <job-id>, or list jobs)")
+	fmt.Println("  status           Show the jobs in progress or to resume (status [job-id])")
+	fmt.Println("  log              Show the recent exports and imports")
+	fmt.Println("  outdated         List the images changed or never exported since their last export")
+	fmt.Println("  worker           Run the queued background jobs (started automatically by --detach)")
+	fmt.Println("  clean            Clean cache directory")
+	fmt.Println("  inspect          Show the provenance attestation of an exported file")
+	fmt.Println("  k8s              Generate Kubernetes resources (k8s preload)")
+	fmt.Println("  install-service  Run a go-dkci command on a schedule or as a service with systemd or cron")
+	fmt.Println("  registry         Serve the images of a cloud folder as a read-only Docker registry")
+	fmt.Println("  serve-files      Share exported files over HTTP for download with curl")
+	fmt.Println("  send             Stream Docker images directly to a receiving host")
+	fmt.Println("  receive          Load Docker images streamed by a sending host")
+	fmt.Println("  copy             Copy images between Docker hosts over SSH, or between any two backends")
+	fmt.Println("  migrate          Move images from one Docker host to another, one image at a time")
+	fmt.Println("  ui               Browse local images and cloud folders interactively")
+	fmt.Println("  version          Print program version")
+	fmt.Println("  help             Display this help information")
+	fmt.Println()
+	fmt.Println("Global flags:")
+	fmt.Println("      --lang string          Language of prompts, errors and summaries: en or zh-CN (default from LC_ALL, LC_MESSAGES or LANG)")
+	fmt.Println("      --docker-api-version string Docker API version to use (e.g. 1.41) instead of negotiating it with the daemon")
+	fmt.Println("      --docker-timeout duration Fail Docker calls taking longer, and saves or loads passing no data for as long; 0 waits forever (default 2m0s)")
+	fmt.Println("      --cloud-timeout duration Fail Baidu cloud downloads passing no data for longer; 0 waits forever (default 1m0s)")
+	fmt.Println("      --cloud-qps float      Baidu cloud API requests sent per second at most, so that bulk operations are not throttled; 0 sends them unthrottled (default 5)")
+	fmt.Println("      --account-level string Declared membership level of the Baidu account, free, VIP or SVIP, which sets the per-file size limit uploads are split at (default \"free\")")
+	fmt.Println("      --backend string       Storage of the cloud commands: bdfs (Baidu cloud), dir://<path> for a local directory such as a USB drive, or plugin:<name> for a storage plugin (default \"bdfs\")")
+	fmt.Println("      --no-hooks             Do not run the pre_export, post_export, pre_import and post_import hooks of the configuration file")
+	fmt.Println("      --notify string        Email the summary of exports and imports with this [email.<profile>] of the configuration file")
+	fmt.Println("      --progress string      Progress format: text, or jsonl to also write one JSON event per started, completed or failed item and uploaded chunk (default \"text\")")
+	fmt.Println("      --progress-fd int      File descriptor the jsonl progress events are written to; with 1 (stdout) the other output goes to stderr (default 1)")
 	fmt.Println()
 	fmt.Println("Export command flags:")
-	fmt.Println("  -d, --destination string   Specify the export directory (default \"/tmp/go-dkci\")")
+	fmt.Printf("  -d, --destination string   Specify the export directory (default \"%s\")\n", tempDir)
 	fmt.Println("  -c, --cloud string         Specify the Baidu cloud folder path for export (mutually exclusive with -d)")
-	fmt.Println("  -g, --grep string          Filter images by pattern")
+	fmt.Println("  -g, --grep string          Filter images by pattern (repeatable, any pattern matches)")
+	fmt.Println("      --match-all            Require all --grep patterns to match")
+	fmt.Println("      --os string            Only include images for this operating system (e.g. linux)")
+	fmt.Println("      --arch string          Only include images for this architecture (e.g. arm64)")
+	fmt.Println("      --min-size string      Only include images of at least this size (e.g. 100MB)")
+	fmt.Println("      --max-size string      Only include images of at most this size (e.g. 2GB)")
+	fmt.Println("      --since string         Only include images created on or after this date (YYYY-MM-DD)")
+	fmt.Println("      --newer-than string    Only include images created within this age (e.g. 7d)")
+	fmt.Println("      --older-than string    Only include images older than this age (e.g. 90d)")
+	fmt.Println("      --k8s-manifests string Export the images referenced by Kubernetes manifests in this file or directory")
+	fmt.Println("      --helm-chart string    Export the images referenced by the rendered Helm chart")
+	fmt.Println("      --values string        Values file passed to helm template (repeatable, used with --helm-chart)")
+	fmt.Println("      --sort string          Sort the selection list by name, size or created")
+	fmt.Println("      --reverse              Reverse the sort order of the selection list")
+	fmt.Println("      --all                  Export all matching images without prompting")
+	fmt.Println("      --name-template string Go template for exported file names (e.g. \"{{.Name}}/{{.Tag}}/{{.Arch}}.tar\")")
+	fmt.Println("      --with-digest          Append the short image digest to exported file names and skip files that already exist")
+	fmt.Println("      --on-conflict string   What to do when the file name of an image is taken: overwrite, skip, number or timestamp (default \"overwrite\")")
+	fmt.Printf("      --keep                 Keep the temporary files of a cloud export in %s\n", tempDir)
+	fmt.Println("      --no-create-folders    Fail instead of creating the cloud folder if it does not exist")
+	fmt.Println("      --run-folder           Write the export into a subfolder named after its start time (e.g. 2024-06-01T02-00)")
+	fmt.Println("      --run-folder-name string Name the --run-folder subfolder instead of using the start time")
+	fmt.Println("      --squash               Flatten the layers of each image into one before saving it")
+	fmt.Println("      --with-all-tags        Save each image with all of its tags in one archive, restoring every tag on import")
+	fmt.Println("      --stream               Stream each image from Docker into a dir:// backend, compressing it on the way, without a temporary file; other backends reject it")
+	fmt.Println("      --delta                Upload only the layers not yet in the cloud folder, plus a recipe to rebuild the file")
+	fmt.Println("      --compress string      Compress the exported files (gzip, zstd or none)")
+	fmt.Println("      --compress-level int   Compression level of --compress (1-9 for gzip, 1-19 for zstd)")
+	fmt.Println("      --archive string       Wrap the exported files in a password-protected container (zip, 7z or none)")
+	fmt.Println("      --password string      Password of the --archive containers (default $DKCI_ARCHIVE_PASSWORD)")
+	fmt.Println("      --detach               Queue the export as a background job and return (see go-dkci status)")
+	fmt.Println("  -y, --yes                  Start the export without confirming the size of the selected images")
+	fmt.Println("      --save-concurrency int Save this many images from Docker at once (default 1)")
+	fmt.Println("      --transfer-concurrency int Upload this many files to Baidu cloud at once (default 1)")
+	fmt.Println("      --upload-retries int   Retry a cloud upload this many times if its checksum does not match")
+	fmt.Println("      --sbom string          Write an SBOM (spdx or cyclonedx) next to each exported file")
+	fmt.Println("      --delivery-report string Write a delivery report of the export to this file, as HTML if it ends in .html and as Markdown otherwise")
+	fmt.Println("      --license-report       Write a Markdown summary of the licenses in the SBOM next to each exported file (implies --sbom spdx)")
+	fmt.Println("      --attest               Write a provenance attestation next to each exported file")
+	fmt.Println("      --annotation string    Annotation key=value recorded in the attestation (repeatable)")
+	fmt.Println("      --sign                 Write a detached cosign signature next to each exported file")
+	fmt.Println("      --key string           Cosign private key used with --sign")
+	fmt.Println("      --rekor                Also upload the signatures of --sign to the public Rekor transparency log")
+	fmt.Println("      --scan-secrets         Scan the layers of each image for private keys, tokens and credential files and warn before exporting it")
+	fmt.Println("      --block-on-secrets     Scan the layers of each image for secrets and leave out the images in which any are found")
 	fmt.Println()
 	fmt.Println("Import command flags:")
 	fmt.Println("  -s, --source string        Specify the source .tar file path or directory containing .tar files")
 	fmt.Println("  -c, --cloud string         Specify the Baidu cloud file or folder path for import (mutually exclusive with -s)")
-	fmt.Println("  -g, --grep string          Filter files by pattern (optional)")
+	fmt.Println("      --latest-run           Import the latest run folder (see export --run-folder) of the source or cloud folder")
+	fmt.Println("  -g, --grep string          Filter files by pattern (repeatable, any pattern matches)")
+	fmt.Println("      --match-all            Require all --grep patterns to match")
+	fmt.Println("      --latest-only          Offer only the newest of the files holding the same image (e.g. several dates or digests matching --grep)")
+	fmt.Println("      --os string            Only include files for this operating system (e.g. linux)")
+	fmt.Println("      --arch string          Only include files for this architecture (e.g. arm64)")
+	fmt.Println("      --sort string          Sort the selection list by name, size or created")
+	fmt.Println("      --reverse              Reverse the sort order of the selection list")
+	fmt.Println("      --all                  Import all matching files without prompting")
+	fmt.Println("      --kind string          Load the images into all nodes of this kind cluster instead of Docker")
+	fmt.Println("      --also-tag stringArray Also tag the image of every imported file with this reference (repeatable)")
+	fmt.Println("      --transfer-concurrency int Download this many files from Baidu cloud at once (default 1)")
+	fmt.Println("      --load-concurrency int Load this many files into Docker at once (default 1)")
+	fmt.Println("      --name-template string Go template the files were exported with, used by --os and --arch")
+	fmt.Printf("      --keep-download        Keep the files downloaded by a cloud import in %s\n", tempDir)
+	fmt.Println("      --no-cache             Download cloud files even if an identical copy is cached")
+	fmt.Println("      --verify-signature     Refuse files whose cosign signature is missing or invalid")
+	fmt.Println("      --key string           Cosign public key used with --verify-signature")
+	fmt.Println("      --insecure-skip-verify Import files even if signature verification fails")
+	fmt.Println("      --rekor                Require the signatures checked by --verify-signature to be in the Rekor transparency log")
+	fmt.Println("      --password string      Password of zip and 7z containers (default $DKCI_ARCHIVE_PASSWORD)")
+	fmt.Println("      --detach               Queue the import as a background job and return (see go-dkci status)")
+	fmt.Println("      --output string        Output format: text, or json to report the loaded images on stdout (default \"text\")")
+	fmt.Println()
+	fmt.Println("Repack command flags:")
+	fmt.Println("  -s, --source string        Specify the .tar file or directory to repack")
+	fmt.Println("  -c, --cloud string         Specify the Baidu cloud file or folder to repack (mutually exclusive with -s)")
+	fmt.Println("      --to string            Compression to convert the files to (gzip, zstd or none)")
+	fmt.Println("      --level int            Compression level, 1-9 for gzip and 1-19 for zstd (default: that of the format)")
+	fmt.Println("  -g, --grep string          Filter files by pattern (repeatable, any pattern matches)")
+	fmt.Println("      --match-all            Require all --grep patterns to match")
+	fmt.Println("      --sort string          Sort the selection list by name, size or created")
+	fmt.Println("      --reverse              Reverse the sort order of the selection list")
+	fmt.Println("      --all                  Repack all matching files without prompting")
+	fmt.Println("      --upload-retries int   Retry a cloud upload this many times if its checksum does not match")
+	fmt.Printf("      --keep                 Keep the repacked files of a cloud repack in %s\n", tempDir)
+	fmt.Println("      --name-template string Go template the files were exported with")
 	fmt.Println()
 	fmt.Println("Delete command flags:")
-	fmt.Println("  -g, --grep string          Filter images by pattern (optional)")
+	fmt.Println("  -g, --grep string          Filter images by pattern (repeatable, any pattern matches)")
+	fmt.Println("      --match-all            Require all --grep patterns to match")
+	fmt.Println("      --os string            Only include images for this operating system (e.g. linux)")
+	fmt.Println("      --arch string          Only include images for this architecture (e.g. arm64)")
+	fmt.Println("      --min-size string      Only include images of at least this size (e.g. 100MB)")
+	fmt.Println("      --max-size string      Only include images of at most this size (e.g. 2GB)")
+	fmt.Println("      --since string         Only include images created on or after this date (YYYY-MM-DD)")
+	fmt.Println("      --newer-than string    Only include images created within this age (e.g. 7d)")
+	fmt.Println("      --older-than string    Only include images older than this age (e.g. 90d)")
+	fmt.Println("      --untagged             Also list untagged images, by their short ID")
+	fmt.Println("      --sort string          Sort the selection list by name, size or created")
+	fmt.Println("      --reverse              Reverse the sort order of the selection list")
+	fmt.Println("      --all                  Delete all matching images without prompting")
+	fmt.Println()
+	fmt.Println("Status command flags:")
+	fmt.Println("      --all                  Also list the completed jobs")
+	fmt.Println()
+	fmt.Println("Log command flags:")
+	fmt.Println("      --last int             Show this many of the most recent operations; 0 shows them all (default 20)")
+	fmt.Println("      --output string        Output format: text, or json for the records of the state database (default \"text\")")
+	fmt.Println()
+	fmt.Println("Outdated command flags:")
+	fmt.Println("  -g, --grep string          Filter images by pattern (repeatable, any pattern matches)")
+	fmt.Println("      --match-all            Require all --grep patterns to match")
+	fmt.Println("  -d, --destination string   Only count the exports to this local directory")
+	fmt.Println("  -c, --cloud string         Only count the exports to this cloud folder")
+	fmt.Println()
+	fmt.Println("Clean command flags:")
+	fmt.Println("  -y, --yes                  Delete the files without asking for confirmation")
+	fmt.Println()
+	fmt.Println("Cloud prune command flags:")
+	fmt.Println("  -g, --grep string          Only delete files matching this pattern (repeatable, any pattern matches)")
+	fmt.Println("      --match-all            Require all --grep patterns to match")
+	fmt.Println("      --since string         Only delete files uploaded on or after this date (YYYY-MM-DD)")
+	fmt.Println("      --newer-than string    Only delete files uploaded within this age (e.g. 7d)")
+	fmt.Println("      --older-than string    Only delete files uploaded longer ago than this age (e.g. 180d)")
+	fmt.Println("      --name-template string Go template the files were exported with")
+	fmt.Println("  -y, --yes                  Delete the files without asking for confirmation")
+	fmt.Println("      --dry-run              Only list the files that would be deleted")
+	fmt.Println()
+	fmt.Println("Gc command flags:")
+	fmt.Println("      --name-template string Go template the files were exported with")
+	fmt.Println("  -y, --yes                  Delete the layers without asking for confirmation")
+	fmt.Println("      --dry-run              Only list the layers that would be deleted")
+	fmt.Println()
+	fmt.Println("Promote command flags:")
+	fmt.Println("      --move                 Remove the source files once they are copied")
+	fmt.Println("      --dry-run              Only list the files that would be promoted")
+	fmt.Println()
+	fmt.Println("Inspect command flags:")
+	fmt.Println("  -c, --cloud                Read the attestation of a Baidu cloud file instead of a local one")
+	fmt.Println("      --key string           Cosign public key used to verify the attestation signature")
+	fmt.Println()
+	fmt.Println("Registry command flags:")
+	fmt.Println("      --listen string        Address the registry listens on (default \":5000\")")
+	fmt.Println("  -c, --cloud string         Baidu cloud folder holding the exported images (default from config)")
+	fmt.Println("      --name-template string Go template the files were exported with")
+	fmt.Println("      --check-docker         Also require a reachable Docker daemon for /readyz")
+	fmt.Println()
+	fmt.Println("Serve-files command flags:")
+	fmt.Printf("      --dir string           Directory holding the exported files (default \"%s\")\n", tempDir)
+	fmt.Println("      --listen string        Address the file server listens on; give :8000 to serve other hosts (default \"127.0.0.1:8000\")")
+	fmt.Println("      --check-docker         Also require a reachable Docker daemon for /readyz")
+	fmt.Println()
+	fmt.Println("Export-container command flags:")
+	fmt.Printf("  -d, --destination string   Specify the export directory (default \"%s\")\n", tempDir)
+	fmt.Println("  -c, --cloud string         Specify the Baidu cloud folder path for export (mutually exclusive with -d)")
+	fmt.Printf("      --keep                 Keep the temporary files of a cloud export in %s\n", tempDir)
+	fmt.Println("      --no-create-folders    Fail instead of creating the cloud folder if it does not exist")
+	fmt.Println()
+	fmt.Println("Snapshot command flags:")
+	fmt.Println("  -t, --tag string           Tag of the committed image (default \"<container>:snapshot-<timestamp>\")")
+	fmt.Printf("  -d, --destination string   Specify the export directory (default \"%s\")\n", tempDir)
+	fmt.Println("  -c, --cloud string         Specify the Baidu cloud folder path for export (mutually exclusive with -d)")
+	fmt.Println("      --no-pause             Do not pause the container while committing it")
+	fmt.Printf("      --keep                 Keep the temporary files of a cloud export in %s\n", tempDir)
+	fmt.Println("      --no-create-folders    Fail instead of creating the cloud folder if it does not exist")
+	fmt.Println()
+	fmt.Println("Volume export command flags:")
+	fmt.Printf("  -d, --destination string   Specify the export directory (default \"%s\")\n", tempDir)
+	fmt.Println("  -c, --cloud string         Specify the Baidu cloud folder path for export (mutually exclusive with -d)")
+	fmt.Printf("      --keep                 Keep the temporary files of a cloud export in %s\n", tempDir)
+	fmt.Println("      --no-create-folders    Fail instead of creating the cloud folder if it does not exist")
+	fmt.Println("      --helper-image string  Local image used to create the helper container (default \"busybox:latest\")")
+	fmt.Println()
+	fmt.Println("Volume import command flags:")
+	fmt.Println("  -s, --source string        Specify the .volume.tar file to import")
+	fmt.Println("  -c, --cloud string         Specify the Baidu cloud .volume.tar file to import (mutually exclusive with -s)")
+	fmt.Println("      --name string          Volume to restore into (default: the volume the file was exported from)")
+	fmt.Printf("      --keep-download        Keep the file downloaded by a cloud import in %s\n", tempDir)
+	fmt.Println("      --helper-image string  Local image used to create the helper container (default \"busybox:latest\")")
+	fmt.Println()
+	fmt.Println("Backup command flags:")
+	fmt.Printf("  -d, --destination string   Specify the bundle directory (default \"%s\")\n", filepath.Join(tempDir, "backup"))
+	fmt.Println("  -c, --cloud string         Specify the Baidu cloud folder of the bundle (mutually exclusive with -d)")
+	fmt.Println("  -g, --grep string          Filter the additional images offered by pattern (repeatable)")
+	fmt.Println("      --compose string       Compose file to include besides those of running projects (repeatable)")
+	fmt.Println("      --all                  Include all images and volumes without prompting")
+	fmt.Println("      --helper-image string  Local image used to create the helper container (default \"busybox:latest\")")
+	fmt.Println()
+	fmt.Println("Restore command flags:")
+	fmt.Println("  -s, --source string        Specify the bundle directory")
+	fmt.Println("  -c, --cloud string         Specify the Baidu cloud folder of the bundle (mutually exclusive with -s)")
+	fmt.Println("      --compose-dir string   Write the compose files here instead of their original paths")
+	fmt.Println("      --helper-image string  Local image used to create the helper container (default \"busybox:latest\")")
+	fmt.Println()
+	fmt.Println("Apply command flags:")
+	fmt.Println("  -f, --file string          TOML manifest listing the images of the bundle")
+	fmt.Println("      --prune                Remove the files of images no longer listed (same as prune = true)")
+	fmt.Println("      --dry-run              Only print what would be exported and removed")
+	fmt.Println("      --upload-retries int   Retry a cloud upload this many times if its checksum does not match")
+	fmt.Println()
+	fmt.Println("Send command flags:")
+	fmt.Println("      --listen string        Address to wait for the receiver on (default \":9000\")")
+	fmt.Println("  -g, --grep string          Filter images by pattern (repeatable, any pattern matches)")
+	fmt.Println("      --match-all            Require all --grep patterns to match")
+	fmt.Println("      --os string            Only include images for this operating system (e.g. linux)")
+	fmt.Println("      --arch string          Only include images for this architecture (e.g. arm64)")
+	fmt.Println("      --all                  Send all matching images without prompting")
+	fmt.Println("      --tls-cert string      Serve over TLS with this certificate")
+	fmt.Println("      --tls-key string       Private key of --tls-cert")
+	fmt.Println("      --token string         Token receivers must present (default $DKCI_TRANSFER_TOKEN, or a generated one)")
+	fmt.Println()
+	fmt.Println("Receive command flags:")
+	fmt.Println("      --from string          Address (host:port) of the sending go-dkci")
+	fmt.Println("      --kind string          Load the images into all nodes of this kind cluster instead of Docker")
+	fmt.Println("      --tls                  Connect to the sender over TLS")
+	fmt.Println("      --ca-cert string       CA certificate the sender certificate is verified with (implies --tls)")
+	fmt.Println("      --insecure             Do not verify the sender certificate (implies --tls)")
+	fmt.Println("      --token string         Token printed by the sender (default $DKCI_TRANSFER_TOKEN)")
+	fmt.Println()
+	fmt.Println("Copy command flags (endpoints: docker:<image>, file:<path>, bdfs:<path>, oci-dir:<dir>, s3://<bucket>/<key>):")
+	fmt.Println("      --from string          Docker host to copy from (local, ssh://[user@]host[:port] or a daemon URL such as npipe://) (default \"local\")")
+	fmt.Println("      --to string            Docker host to copy to (local, ssh://[user@]host[:port] or a daemon URL such as npipe://) (default \"local\")")
+	fmt.Println("  -g, --grep string          Filter images by pattern (repeatable, any pattern matches)")
+	fmt.Println("      --match-all            Require all --grep patterns to match")
+	fmt.Println("      --os string            Only include images for this operating system (e.g. linux)")
+	fmt.Println("      --arch string          Only include images for this architecture (e.g. arm64)")
+	fmt.Println("      --all                  Copy all matching images without prompting")
+	fmt.Println()
+	fmt.Println("Migrate command flags:")
+	fmt.Println("      --from string          Docker host to migrate from (local, ssh://[user@]host[:port] or a daemon URL such as tcp://) (default \"local\")")
+	fmt.Println("      --to string            Docker host to migrate to (local, ssh://[user@]host[:port] or a daemon URL such as tcp://) (default \"local\")")
+	fmt.Println("  -g, --grep string          Filter images by pattern (repeatable, any pattern matches)")
+	fmt.Println("      --match-all            Require all --grep patterns to match")
+	fmt.Println("      --os string            Only include images for this operating system (e.g. linux)")
+	fmt.Println("      --arch string          Only include images for this architecture (e.g. arm64)")
+	fmt.Println("      --all                  Migrate all matching images without prompting")
+	fmt.Printf("      --via string           How the images travel: direct, cache (an archive in %s) or cloud (default \"direct\")\n", tempDir)
+	fmt.Println("      --cloud string         Cloud folder the archives are relayed through with --via cloud (default from config)")
+	fmt.Println("      --keep                 Keep the relayed archives after they are loaded")
+	fmt.Println()
+	fmt.Println("Version command flags:")
+	fmt.Println("      --output string        Output format: text, or json for inventories and bug reports (default \"text\")")
+	fmt.Println()
+	fmt.Println("K8s preload command flags:")
+	fmt.Println("      --from string          Directory holding the .tar files on every node")
+	fmt.Println("      --image string         Container image providing the go-dkci binary (default \"go-dkci:<version>\")")
+	fmt.Println("      --name string          Name of the DaemonSet (default \"go-dkci-preload\")")
+	fmt.Println("  -n, --namespace string     Namespace of the DaemonSet (default \"kube-system\")")
+	fmt.Println("      --docker-socket string Path of the Docker socket on the nodes (default \"/var/run/docker.sock\")")
+	fmt.Println("      --apply                Apply the DaemonSet with kubectl instead of printing it")
+	fmt.Println()
+	fmt.Println("Install-service command flags:")
+	fmt.Println("      --schedule string      Cron expression or macro (e.g. \"0 2 * * *\" or @daily) to run the command on; without one it runs as a service")
+	fmt.Println("      --name string          Name of the units, the crontab entry and the environment file (default \"go-dkci\")")
+	fmt.Println("      --user                 Install systemd user units instead of system units")
+	fmt.Println("      --cron                 Add an entry to the crontab of the current user instead of systemd units")
+	fmt.Println("      --dry-run              Only print the files that would be written")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  go-dkci export --destination /tmp/images")
 	fmt.Println("  go-dkci export --cloud /docker-images")
+	fmt.Println("  go-dkci export nginx:1.26 redis:7 --cloud /docker-images")
+	fmt.Println("  go-dkci export --k8s-manifests ./deploy --cloud /docker-images")
+	fmt.Println("  go-dkci export --helm-chart ./chart --values prod.yaml --cloud /docker-images")
+	fmt.Println("  go-dkci export --cloud /docker-images --sign --key cosign.key")
+	fmt.Println("  go-dkci export --destination /tmp/images --sbom spdx")
+	fmt.Println("  go-dkci export --cloud /docker-images --with-digest")
+	fmt.Println("  go-dkci export --destination /tmp/images --compress gzip")
+	fmt.Println("  DKCI_ARCHIVE_PASSWORD=secret go-dkci export --cloud /docker-images --archive 7z")
+	fmt.Println("  go-dkci export myapp:1.4.2 --cloud /docker-images --delta")
+	fmt.Println("  go-dkci --backend dir:///mnt/usb export --cloud /docker-images --compress zstd --stream")
+	fmt.Println("  go-dkci export --cloud /docker-images --name-template \"{{.Name}}/{{.Tag}}/{{.Arch}}.tar\"")
+	fmt.Println("  go-dkci export --cloud /docker-images --attest --annotation ticket=OPS-42 --sign --key cosign.key")
+	fmt.Println("  go-dkci export-container my-dev-box --cloud /docker-images")
+	fmt.Println("  go-dkci snapshot my-dev-box --tag dev-box:before-upgrade --cloud /docker-images")
+	fmt.Println("  go-dkci inspect --cloud /docker-images/nginx_1.26_linux_amd64.tar --key cosign.pub")
+	fmt.Println("  go-dkci registry --listen :5000 --cloud /docker-images")
+	fmt.Println("  go-dkci serve-files --dir ~/.cache/go-dkci --listen :8000")
+	fmt.Println("  go-dkci send nginx:1.26 --listen :9000")
+	fmt.Println("  DKCI_TRANSFER_TOKEN=<token> go-dkci receive --from build-host:9000")
+	fmt.Println("  go-dkci copy --from ssh://build01 --to ssh://edge02 --grep myapp")
+	fmt.Println("  go-dkci copy bdfs:/docker-images/nginx_1.26_linux_amd64.tar s3://bucket/images/")
+	fmt.Println("  go-dkci copy docker:nginx:1.26 oci-dir:/out/nginx")
+	fmt.Println("  go-dkci migrate --from tcp://old:2376 --to tcp://new:2376 --grep app --all")
+	fmt.Println("  go-dkci migrate --from ssh://old --to ssh://new --via cloud --cloud /migrations --keep")
+	fmt.Println("  go-dkci volume export pgdata --cloud /volumes")
+	fmt.Println("  go-dkci volume import --cloud /volumes/pgdata.volume.tar --name pgdata-restored")
+	fmt.Println("  go-dkci backup --cloud /host-backups/web01 --all")
+	fmt.Println("  go-dkci restore --cloud /host-backups/web01 --compose-dir /srv/compose")
+	fmt.Println("  go-dkci apply -f bundle.toml --dry-run")
+	fmt.Println("  go-dkci apply -f bundle.toml --prune")
+	fmt.Println("  go-dkci promote \"/staging/app_1.2*.tar\" /production")
+	fmt.Println("  go-dkci cloud mv /docker-images/myapp_1.2_linux_amd64.tar /projects/myapp/")
 	fmt.Println("  go-dkci import --source /tmp/image.tar")
 	fmt.Println("  go-dkci import --source /tmp/docker-images/ --grep alpine")
+	fmt.Println("  go-dkci import --cloud /docker-images nginx_1.26_linux_amd64.tar")
+	fmt.Println("  go-dkci import --cloud /docker-images --kind my-cluster")
+	fmt.Println("  go-dkci import --source /tmp/docker-images/ --load-concurrency 4")
+	fmt.Println("  go-dkci import --cloud /docker-images nginx_1.26_linux_amd64.tar --output json")
+	fmt.Println("  go-dkci import --cloud /docker-images --transfer-concurrency 4 --load-concurrency 2")
+	fmt.Println("  go-dkci import --cloud /docker-images --verify-signature --key cosign.pub")
+	fmt.Println("  go-dkci import --source /tmp/images/nginx_1.26_linux_amd64.tar.7z --password secret")
 	fmt.Println("  go-dkci delete --grep alpine")
+	fmt.Println("  go-dkci export --grep nginx --grep redis --grep postgres")
+	fmt.Println("  go-dkci import --cloud /docker-images --os linux --arch arm64")
+	fmt.Println("  go-dkci delete --sort size --reverse")
+	fmt.Println("  go-dkci delete --min-size 1GB")
+	fmt.Println("  go-dkci delete --older-than 90d")
+	fmt.Println("  go-dkci k8s preload --from /mnt/images --apply")
+	fmt.Println("  go-dkci install-service --schedule \"0 2 * * *\" -- export --all --cloud /backup")
+	fmt.Println("  go-dkci export nginx:1.26 redis:7 --cloud /docker-images --detach")
+	fmt.Println("  go-dkci export --cloud /docker-images --save-concurrency 2 --transfer-concurrency 4")
+	fmt.Println("  go-dkci status")
+	fmt.Println("  go-dkci log --last 20")
+	fmt.Println("  go-dkci outdated --cloud /docker-images")
+	fmt.Println("  go-dkci resume")
+	fmt.Println("  go-dkci resume 20261016-153000-4f2a")
 	fmt.Println("  go-dkci clean")
+	fmt.Println("  go-dkci ui")
 	fmt.Println("  go-dkci version")
+	fmt.Println("  go-dkci version --output json")
 	fmt.Println("  go-dkci help")
 }
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/baowuhe/go-dkci/docker"
+	"github.com/spf13/pflag"
+)
+
+func TestExtractGlobalFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		noHooks bool
+		lang    string
+		wantErr bool
+	}{
+		{name: "bool before subcommand", args: []string{"--no-hooks", "export", "nginx"}, want: []string{"export", "nginx"}, noHooks: true},
+		{name: "bool after subcommand", args: []string{"export", "--no-hooks", "nginx"}, want: []string{"export", "nginx"}, noHooks: true},
+		{name: "bool with value", args: []string{"--no-hooks=false", "import"}, want: []string{"import"}},
+		{name: "string with next argument", args: []string{"--lang", "zh", "export"}, want: []string{"export"}, lang: "zh"},
+		{name: "string with value", args: []string{"export", "--lang=zh"}, want: []string{"export"}, lang: "zh"},
+		{name: "command flags kept", args: []string{"export", "--all", "--no-hooks"}, want: []string{"export", "--all"}, noHooks: true},
+		{name: "after double dash", args: []string{"export", "--", "--no-hooks"}, want: []string{"export", "--", "--no-hooks"}},
+		{name: "missing argument", args: []string{"export", "--lang"}, wantErr: true},
+		{name: "invalid bool", args: []string{"--no-hooks=maybe", "export"}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var noHooks bool
+			var lang string
+			globalFlags := pflag.NewFlagSet("go-dkci", pflag.ContinueOnError)
+			globalFlags.BoolVar(&noHooks, "no-hooks", false, "")
+			globalFlags.StringVar(&lang, "lang", "", "")
+
+			args, err := extractGlobalFlags(globalFlags, append([]string{"go-dkci"}, test.args...))
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got arguments %q", args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := append([]string{"go-dkci"}, test.want...); !slices.Equal(args, want) {
+				t.Errorf("arguments = %q, want %q", args, want)
+			}
+			if noHooks != test.noHooks {
+				t.Errorf("--no-hooks = %v, want %v", noHooks, test.noHooks)
+			}
+			if lang != test.lang {
+				t.Errorf("--lang = %q, want %q", lang, test.lang)
+			}
+		})
+	}
+}
+
+func TestPluginArgs(t *testing.T) {
+	plugin := "/home/ops/.docker/cli-plugins/docker-dkci"
+	tests := []struct {
+		name string
+		args []string
+		want []string
+		env  map[string]string
+		ok   bool
+	}{
+		{name: "plugin", args: []string{plugin, "dkci", "export", "--all"}, want: []string{plugin, "export", "--all"}, env: map[string]string{}, ok: true},
+		{name: "docker flags", args: []string{plugin, "--context", "prod", "-H=tcp://db:2376", "--debug", "--log-level", "warn", "dkci", "import"},
+			want: []string{plugin, "import"}, env: map[string]string{"DOCKER_CONTEXT": "prod", "DOCKER_HOST": "tcp://db:2376"}, ok: true},
+		{name: "windows", args: []string{"docker-dkci.exe", "dkci", "ui"}, want: []string{"docker-dkci.exe", "ui"}, env: map[string]string{}, ok: true},
+		{name: "run directly", args: []string{plugin, "export", "--grep", "dkci"}, want: []string{plugin, "export", "--grep", "dkci"}},
+		{name: "go-dkci", args: []string{"/usr/local/bin/go-dkci", "dkci"}, want: []string{"/usr/local/bin/go-dkci", "dkci"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args, env, ok := pluginArgs(test.args)
+			if ok != test.ok {
+				t.Fatalf("ok = %v, want %v", ok, test.ok)
+			}
+			if !slices.Equal(args, test.want) {
+				t.Errorf("arguments = %q, want %q", args, test.want)
+			}
+			if !maps.Equal(env, test.env) {
+				t.Errorf("environment = %v, want %v", env, test.env)
+			}
+		})
+	}
+}
+
+func TestParseSizeBounds(t *testing.T) {
+	tests := []struct {
+		min, max string
+		wantMin  int64
+		wantMax  int64
+		wantErr  bool
+	}{
+		{"", "", 0, 0, false},
+		{"100MB", "", 100 << 20, 0, false},
+		{"", "2GB", 0, 2 << 30, false},
+		{"1GB", "2GB", 1 << 30, 2 << 30, false},
+		{"2GB", "1GB", 0, 0, true},
+		{"big", "", 0, 0, true},
+	}
+	for _, test := range tests {
+		minSize, maxSize = test.min, test.max
+		var filter docker.Filter
+		err := parseSizeBounds(&filter)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("--min-size %q --max-size %q accepted", test.min, test.max)
+			}
+			continue
+		}
+		if err != nil || filter.MinSize != test.wantMin || filter.MaxSize != test.wantMax {
+			t.Errorf("--min-size %q --max-size %q = %d, %d, %v", test.min, test.max, filter.MinSize, filter.MaxSize, err)
+		}
+	}
+	minSize, maxSize = "", ""
+}
+
+func TestParseCreatedBounds(t *testing.T) {
+	t.Cleanup(func() { since, newerThan, olderThan = "", "", "" })
+	sinceDate := time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name                        string
+		since, newerThan, olderThan string
+		wantAfter                   time.Time
+		wantAfterAge, wantBeforeAge time.Duration
+		wantErr                     bool
+	}{
+		{name: "none"},
+		{name: "since", since: "2024-06-01", wantAfter: sinceDate},
+		{name: "newer than", newerThan: "7d", wantAfterAge: 7 * 24 * time.Hour},
+		{name: "older than", olderThan: "90d", wantBeforeAge: 90 * 24 * time.Hour},
+		{name: "later bound wins", since: "2024-06-01", newerThan: "1h", wantAfterAge: time.Hour},
+		{name: "since wins when later", since: "2999-01-01", newerThan: "7d", wantAfter: time.Date(2999, 1, 1, 0, 0, 0, 0, time.Local)},
+		{name: "invalid date", since: "06/01/2024", wantErr: true},
+		{name: "invalid age", olderThan: "soon", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			since, newerThan, olderThan = test.since, test.newerThan, test.olderThan
+			var filter docker.Filter
+			before := time.Now()
+			err := parseCreatedBounds(&filter)
+			if test.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Ages are relative to the time of parsing, so they are checked to the second
+			near := func(got time.Time, age time.Duration) bool {
+				want := before.Add(-age)
+				return !got.IsZero() && got.Sub(want) >= 0 && got.Sub(want) < time.Second
+			}
+			switch {
+			case test.wantAfterAge != 0:
+				if !near(filter.CreatedAfter, test.wantAfterAge) {
+					t.Errorf("CreatedAfter = %s, want %s ago", filter.CreatedAfter, test.wantAfterAge)
+				}
+			case !filter.CreatedAfter.Equal(test.wantAfter):
+				t.Errorf("CreatedAfter = %s, want %s", filter.CreatedAfter, test.wantAfter)
+			}
+			if test.wantBeforeAge != 0 {
+				if !near(filter.CreatedBefore, test.wantBeforeAge) {
+					t.Errorf("CreatedBefore = %s, want %s ago", filter.CreatedBefore, test.wantBeforeAge)
+				}
+			} else if !filter.CreatedBefore.IsZero() {
+				t.Errorf("CreatedBefore = %s, want none", filter.CreatedBefore)
+			}
+		})
+	}
+}
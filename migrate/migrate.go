@@ -0,0 +1,195 @@
+package migrate
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/baowuhe/go-dkci/cloud"
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/docker"
+	"github.com/baowuhe/go-dkci/i18n"
+)
+
+// The ways an image travels from the source daemon to the target daemon
+const (
+	// ViaDirect streams docker save on the source into docker load on the target, without intermediate files
+	ViaDirect = "direct"
+	// ViaCache writes the archive to the cache directory first, so that the source is done with an image before
+	// the target starts loading it
+	ViaCache = "cache"
+	// ViaCloud uploads the archive to the cloud storage and loads it into the target from there
+	ViaCloud = "cloud"
+)
+
+// Options describes a migration between two Docker hosts
+type Options struct {
+	// From and To are the Docker hosts, see docker.NewClient for their syntax
+	From, To string
+	// Via is ViaDirect, ViaCache or ViaCloud
+	Via string
+	// CloudDir is the cloud folder the archives are relayed through with ViaCloud
+	CloudDir string
+	// Keep keeps the relayed archives, in the cache directory or the cloud folder, after they are loaded
+	Keep bool
+}
+
+// CheckVia returns an error if via does not name a way of migrating images
+func CheckVia(via string) error {
+	switch via {
+	case ViaDirect, ViaCache, ViaCloud:
+		return nil
+	}
+	return fmt.Errorf("invalid --via %q (expected %s, %s or %s)", via, ViaDirect, ViaCache, ViaCloud)
+}
+
+// Migrate moves the selected images from the daemon of one host to the daemon of another, one image at a time, so
+// that an image that fails does not keep the others from being migrated. If imageNames is not empty, exactly those
+// images are migrated without prompting. It exits with an error if any image failed.
+func Migrate(opts Options, filter docker.Filter, imageNames []string) {
+	if err := CheckVia(opts.Via); err != nil {
+		i18n.Printf("[x] Error: %v\n", err)
+		os.Exit(1)
+	}
+	source, target := docker.OpenHosts(opts.From, opts.To)
+	defer source.Close()
+	defer target.Close()
+
+	selectedImages := docker.HostImages(source, opts.From, filter, imageNames, "Select Docker images to migrate:")
+
+	var storage cloud.Storage
+	if opts.Via == ViaCloud {
+		storage = cloud.Login()
+	}
+
+	fmt.Printf("Migrating %d image(s) from %s to %s via %s\n", len(selectedImages), docker.DisplayHost(opts.From), docker.DisplayHost(opts.To), opts.Via)
+	start := time.Now()
+
+	var failed []string
+	var total int64
+	for i, imageName := range selectedImages {
+		fmt.Printf("[%d/%d] Migrating %s...\n", i+1, len(selectedImages), imageName)
+		imageStart := time.Now()
+
+		var size int64
+		var err error
+		switch opts.Via {
+		case ViaDirect:
+			size, err = streamImage(source, target, imageName)
+		case ViaCache:
+			size, err = cacheImage(source, target, imageName, opts.Keep)
+		case ViaCloud:
+			var name string
+			if name, err = archiveName(source, imageName); err == nil {
+				size, err = cloud.RelayImage(storage, source, target, imageName, path.Join(opts.CloudDir, name), opts.Keep)
+			}
+		}
+		if err != nil {
+			i18n.Printf("[x] Failed to migrate image %s: %v\n", imageName, err)
+			failed = append(failed, imageName)
+			continue
+		}
+		total += size
+		i18n.Printf("[√] Migrated %s (%s in %s)\n", imageName, docker.FormatSize(size), time.Since(imageStart).Round(time.Second))
+	}
+
+	if len(failed) > 0 {
+		i18n.Printf("[x] Failed to migrate %d of %d image(s): %s\n", len(failed), len(selectedImages), strings.Join(failed, ", "))
+		os.Exit(1)
+	}
+	i18n.Printf("[√] Successfully migrated %d image(s) from %s to %s (%s in %s)\n", len(selectedImages), docker.DisplayHost(opts.From), docker.DisplayHost(opts.To), docker.FormatSize(total), time.Since(start).Round(time.Second))
+}
+
+// streamImage streams the archive of an image from source into target and returns its size. When both sides fail,
+// the error of the side that stopped first is returned, since it made the other one fail.
+func streamImage(source, target docker.ImageClient, imageName string) (int64, error) {
+	reader, writer := io.Pipe()
+	saved := make(chan error, 1)
+	go func() {
+		err := docker.WriteImage(source, imageName, writer)
+		writer.CloseWithError(err)
+		saved <- err
+	}()
+
+	counter := docker.NewCountingReader(reader)
+	_, loadErr := docker.LoadStream(target, counter)
+	select {
+	case saveErr := <-saved:
+		if saveErr != nil {
+			return 0, saveErr
+		}
+	default:
+		// The load ended before the save, so the save only fails on the closed pipe
+		reader.Close()
+		<-saved
+	}
+	if loadErr != nil {
+		return 0, fmt.Errorf("failed to load image %s: %v", imageName, loadErr)
+	}
+	return counter.Count(), nil
+}
+
+// cacheImage saves the archive of an image from source to the directory of this run and loads it into target from
+// there. The archive is moved to the cache directory when the load fails or keep is set, and removed otherwise.
+func cacheImage(source, target docker.ImageClient, imageName string, keep bool) (int64, error) {
+	dir, err := config.RunDir()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temp directory in %s: %v", config.TempDir(), err)
+	}
+	name, err := archiveName(source, imageName)
+	if err != nil {
+		return 0, err
+	}
+	filePath := filepath.Join(dir, filepath.Base(filepath.FromSlash(name)))
+	if err := docker.SaveImage(source, imageName, filePath); err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0, err
+	}
+
+	archive, err := docker.OpenArchive(filePath)
+	if err == nil {
+		_, err = docker.LoadStream(target, archive)
+		archive.Close()
+	}
+	if err != nil {
+		if kept, keepErr := config.KeepFile(filePath); keepErr == nil {
+			return 0, fmt.Errorf("failed to load image %s: %v (the archive is kept at %s)", imageName, err, kept)
+		}
+		return 0, fmt.Errorf("failed to load image %s: %v", imageName, err)
+	}
+
+	if keep {
+		kept, err := config.KeepFile(filePath)
+		if err != nil {
+			fmt.Printf("Warning: Failed to keep archive %s: %v\n", filePath, err)
+		} else {
+			fmt.Printf("Kept archive %s\n", kept)
+		}
+	} else {
+		os.Remove(filePath)
+	}
+	return info.Size(), nil
+}
+
+// archiveName names the relayed archive of an image with the configured file name template, as an export would, so
+// that a kept archive can be imported like an exported one
+func archiveName(cli docker.ImageClient, imageName string) (string, error) {
+	ctx, cancel := docker.CallContext()
+	imageInspect, _, err := cli.ImageInspectWithRaw(ctx, imageName)
+	cancel()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %v", imageName, err)
+	}
+	name, err := docker.ArchiveName(imageName, imageInspect.Os, imageInspect.Architecture, imageInspect.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to name the archive of image %s: %v", imageName, err)
+	}
+	return docker.CompressedName(name), nil
+}
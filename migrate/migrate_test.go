@@ -0,0 +1,105 @@
+package migrate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/baowuhe/go-dkci/cloud"
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/docker"
+)
+
+// newSource returns a daemon holding nginx:1.27, with the working directory and configuration of go-dkci in
+// temporary directories
+func newSource(t *testing.T) *docker.FakeClient {
+	t.Helper()
+	t.Setenv("DKCI_TEMP_DIR", filepath.Join(t.TempDir(), "cache"))
+	t.Setenv("BDFS_CONFIG_FILE", filepath.Join(t.TempDir(), "config.toml"))
+	t.Cleanup(config.RemoveRunDir)
+
+	return docker.NewFakeClient(docker.FakeImage{
+		RepoTags:     []string{"nginx:1.27"},
+		Os:           "linux",
+		Architecture: "amd64",
+		Created:      time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		Layers:       [][]byte{[]byte("layer")},
+	})
+}
+
+// checkMigrated fails the test unless target holds nginx:1.27 and size is the size of its archive
+func checkMigrated(t *testing.T, target *docker.FakeClient, size int64) {
+	t.Helper()
+	if _, _, err := target.ImageInspectWithRaw(context.Background(), "nginx:1.27"); err != nil {
+		t.Errorf("image not migrated: %v", err)
+	}
+	if size <= 0 {
+		t.Errorf("size = %d, want the size of the archive", size)
+	}
+}
+
+func TestStreamImage(t *testing.T) {
+	source, target := newSource(t), docker.NewFakeClient()
+	size, err := streamImage(source, target, "nginx:1.27")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMigrated(t, target, size)
+
+	if _, err := streamImage(source, target, "missing:latest"); err == nil {
+		t.Error("streamImage() of a missing image succeeded")
+	}
+}
+
+func TestCacheImage(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		source, target := newSource(t), docker.NewFakeClient()
+		size, err := cacheImage(source, target, "nginx:1.27", keep)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkMigrated(t, target, size)
+
+		kept := filepath.Join(config.TempDir(), "nginx_1.27_linux_amd64.tar")
+		if _, err := os.Stat(kept); (err == nil) != keep {
+			t.Errorf("keep %v: archive kept = %v", keep, err == nil)
+		}
+	}
+}
+
+func TestRelayImage(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		source, target := newSource(t), docker.NewFakeClient()
+		storage, err := cloud.NewDirStorage(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		name, err := archiveName(source, "nginx:1.27")
+		if err != nil {
+			t.Fatal(err)
+		}
+		remoteFilePath := "/migrations/" + name
+
+		size, err := cloud.RelayImage(storage, source, target, "nginx:1.27", remoteFilePath, keep)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkMigrated(t, target, size)
+		if _, err := storage.GetFileInfoByPath(remoteFilePath); (err == nil) != keep {
+			t.Errorf("keep %v: archive kept = %v", keep, err == nil)
+		}
+	}
+}
+
+func TestCheckVia(t *testing.T) {
+	for _, via := range []string{ViaDirect, ViaCache, ViaCloud} {
+		if err := CheckVia(via); err != nil {
+			t.Errorf("CheckVia(%q) = %v", via, err)
+		}
+	}
+	if err := CheckVia("scp"); err == nil {
+		t.Error("CheckVia(\"scp\") succeeded")
+	}
+}
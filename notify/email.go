@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baowuhe/go-dkci/config"
+)
+
+// When to email the summary of a run, as set by the on key of an email profile
+const (
+	OnAlways  = "always"
+	OnFailure = "failure"
+)
+
+var (
+	// profile is the email profile of --notify; without recipients no email is sent
+	profile config.EmailProfile
+	// profileName names the profile in errors
+	profileName string
+)
+
+// SetEmail makes runs email their summary with the SMTP settings of the named profile, usually from
+// config.GetEmailProfile, and rejects profiles missing the server, the sender or the recipients
+func SetEmail(name string, p config.EmailProfile) error {
+	if p.On == "" {
+		p.On = OnAlways
+	}
+	switch {
+	case p.SMTPHost == "":
+		return fmt.Errorf("email profile %s: smtp_host is required", name)
+	case p.From == "":
+		return fmt.Errorf("email profile %s: from is required", name)
+	case len(p.To) == 0:
+		return fmt.Errorf("email profile %s: to is required", name)
+	case p.On != OnAlways && p.On != OnFailure:
+		return fmt.Errorf("email profile %s: invalid on %q (expected always or failure)", name, p.On)
+	}
+	if p.SMTPPort == 0 {
+		p.SMTPPort = 587
+	}
+	profile, profileName = p, name
+	return nil
+}
+
+// Enabled reports whether summaries are emailed
+func Enabled() bool {
+	return len(profile.To) > 0
+}
+
+// Email sends a summary to the recipients of the profile, unless it only wants failures and failed is not set.
+// Port 465 speaks TLS from the start; other ports upgrade with STARTTLS when the server offers it, and the
+// credentials are only sent over TLS or to localhost.
+func Email(subject, body string, failed bool) error {
+	if !Enabled() || profile.On == OnFailure && !failed {
+		return nil
+	}
+
+	message := buildMessage(profile.From, profile.To, subject, body, time.Now())
+	address := net.JoinHostPort(profile.SMTPHost, strconv.Itoa(profile.SMTPPort))
+	var auth smtp.Auth
+	if profile.Username != "" {
+		auth = smtp.PlainAuth("", profile.Username, profile.Password, profile.SMTPHost)
+	}
+
+	var err error
+	if profile.SMTPPort == 465 {
+		err = sendTLS(address, auth, message)
+	} else {
+		err = smtp.SendMail(address, auth, profile.From, profile.To, message)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to email the summary with profile %s: %v", profileName, err)
+	}
+	return nil
+}
+
+// sendTLS sends a message to an SMTP server speaking TLS from the start
+func sendTLS(address string, auth smtp.Auth, message []byte) error {
+	conn, err := tls.Dial("tcp", address, &tls.Config{ServerName: profile.SMTPHost})
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, profile.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(profile.From); err != nil {
+		return err
+	}
+	for _, to := range profile.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildMessage formats a plain text email with CRLF line endings
+func buildMessage(from string, to []string, subject, body string, date time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", encodeHeader(subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// encodeHeader encodes a header value holding other than ASCII characters, such as a host name in another script
+func encodeHeader(value string) string {
+	for _, r := range value {
+		if r > 127 {
+			return mime.QEncoding.Encode("UTF-8", value)
+		}
+	}
+	return value
+}
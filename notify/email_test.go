@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/baowuhe/go-dkci/config"
+)
+
+// fakeSMTP accepts one connection on localhost and sends the message it receives to the returned channel
+func fakeSMTP(t *testing.T) (int, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		var data strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch command := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(command, "EHLO"):
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case strings.HasPrefix(command, "AUTH"):
+				reply("235 accepted")
+			case strings.HasPrefix(command, "DATA"):
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				messages <- data.String()
+				reply("250 queued")
+			case strings.HasPrefix(command, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, messages
+}
+
+func TestEmail(t *testing.T) {
+	t.Cleanup(func() { profile, profileName = config.EmailProfile{}, "" })
+
+	for _, p := range []config.EmailProfile{
+		{From: "dkci@example.com", To: []string{"ops@example.com"}},
+		{SMTPHost: "localhost", To: []string{"ops@example.com"}},
+		{SMTPHost: "localhost", From: "dkci@example.com"},
+		{SMTPHost: "localhost", From: "dkci@example.com", To: []string{"ops@example.com"}, On: "sometimes"},
+	} {
+		if err := SetEmail("nightly", p); err == nil {
+			t.Errorf("SetEmail accepted %+v", p)
+		}
+	}
+
+	port, messages := fakeSMTP(t)
+	err := SetEmail("nightly", config.EmailProfile{SMTPHost: "localhost", SMTPPort: port, Username: "dkci", Password: "secret",
+		From: "dkci@example.com", To: []string{"ops@example.com", "oncall@example.com"}, On: OnFailure})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Successful runs are not emailed with on = "failure"
+	if err := Email("go-dkci on host: 3 succeeded", "all good", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := Email("go-dkci on host: 1 failed", "export  failed  app:1\nno space left", true); err != nil {
+		t.Fatal(err)
+	}
+	message := <-messages
+	for _, want := range []string{"From: dkci@example.com\r\n", "To: ops@example.com, oncall@example.com\r\n",
+		"Subject: go-dkci on host: 1 failed\r\n", "\r\n\r\nexport  failed  app:1\r\nno space left"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("message lacks %q:\n%s", want, message)
+		}
+	}
+	if strings.Contains(message, "all good") {
+		t.Error("emailed the summary of a successful run with on = failure")
+	}
+}
@@ -0,0 +1,482 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/baowuhe/go-bdfs/pan"
+	"github.com/baowuhe/go-dkci/cloud"
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/docker"
+	"github.com/baowuhe/go-dkci/health"
+	"github.com/baowuhe/go-dkci/i18n"
+)
+
+// cacheDir holds the downloaded archives and the blobs and manifests materialized from them
+var cacheDir = filepath.Join(config.TempDir(), config.RegistryDir)
+
+// Media types of the OCI manifests served by the registry
+const (
+	mediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeConfig   = "application/vnd.oci.image.config.v1+json"
+	mediaTypeLayer    = "application/vnd.oci.image.layer.v1.tar"
+	mediaTypeGzip     = mediaTypeLayer + "+gzip"
+	mediaTypeZstd     = mediaTypeLayer + "+zstd"
+)
+
+// Magic bytes of the compressed layers `docker save` may write
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// descriptor references a blob from a manifest
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// manifest is an OCI image manifest
+type manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        descriptor   `json:"config"`
+	Layers        []descriptor `json:"layers"`
+}
+
+// saveManifest is an entry of the manifest.json written by `docker save`
+type saveManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// archive is an image archive in the cloud folder
+type archive struct {
+	file   pan.FileInfo
+	fields docker.ArchiveFields
+}
+
+// server serves a read-only Docker Registry v2 API from the archives of a cloud folder
+type server struct {
+	bdfsClient cloud.Storage
+	cloudDir   string
+
+	// locks holds a *sync.Mutex per cloud path, so that an archive is materialized once while other archives
+	// are materialized in parallel
+	locks sync.Map
+}
+
+// Serve starts a read-only Docker Registry v2 API on listen that serves the images exported to cloudDir.
+// An archive is downloaded and unpacked into blobs under ~/.cache/go-dkci/registry the first time one of its
+// manifests is requested; later pulls are served from that cache. /healthz and /readyz report whether the registry
+// runs and whether the cloud folder and the cache can be used, along with the extra checks.
+func Serve(listen string, bdfsClient cloud.Storage, cloudDir string, checks ...health.Check) error {
+	if err := os.MkdirAll(filepath.Join(cacheDir, "blobs"), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	config.Track(cacheDir)
+
+	s := &server{bdfsClient: bdfsClient, cloudDir: cloudDir}
+	fmt.Printf("Serving images from Baidu cloud folder %s on %s\n", cloudDir, listen)
+	checks = append([]health.Check{health.Cloud(bdfsClient, cloudDir), health.Writable("cache", cacheDir)}, checks...)
+	return http.ListenAndServe(listen, health.Handler(s, checks...))
+}
+
+// ServeHTTP routes the registry API requests
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "the registry is read-only")
+		return
+	}
+
+	route := strings.TrimPrefix(r.URL.Path, "/v2/")
+	switch {
+	case r.URL.Path == "/v2/" || r.URL.Path == "/v2":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	case route == "_catalog":
+		s.serveCatalog(w)
+	case strings.HasSuffix(route, "/tags/list"):
+		s.serveTags(w, strings.TrimSuffix(route, "/tags/list"))
+	case strings.Contains(route, "/manifests/"):
+		i := strings.LastIndex(route, "/manifests/")
+		s.serveManifest(w, r, route[:i], route[i+len("/manifests/"):])
+	case strings.Contains(route, "/blobs/"):
+		i := strings.LastIndex(route, "/blobs/")
+		s.serveBlob(w, r, route[i+len("/blobs/"):])
+	default:
+		writeError(w, http.StatusNotFound, "UNSUPPORTED", "unknown endpoint")
+	}
+}
+
+// serveCatalog lists the repositories of the cloud folder
+func (s *server) serveCatalog(w http.ResponseWriter) {
+	archives, err := s.listArchives()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "UNKNOWN", err.Error())
+		return
+	}
+
+	seen := make(map[string]bool)
+	repositories := []string{}
+	for _, a := range archives {
+		if !seen[a.fields.Repository] {
+			seen[a.fields.Repository] = true
+			repositories = append(repositories, a.fields.Repository)
+		}
+	}
+	sort.Strings(repositories)
+
+	writeJSON(w, "application/json", map[string]any{"repositories": repositories})
+}
+
+// serveTags lists the tags of a repository
+func (s *server) serveTags(w http.ResponseWriter, name string) {
+	archives, err := s.listArchives()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "UNKNOWN", err.Error())
+		return
+	}
+
+	seen := make(map[string]bool)
+	tags := []string{}
+	for _, a := range archives {
+		if matchRepository(a.fields.Repository, name) && !seen[a.fields.Tag] {
+			seen[a.fields.Tag] = true
+			tags = append(tags, a.fields.Tag)
+		}
+	}
+	if len(tags) == 0 {
+		writeError(w, http.StatusNotFound, "NAME_UNKNOWN", "repository not found: "+name)
+		return
+	}
+	sort.Strings(tags)
+
+	writeJSON(w, "application/json", map[string]any{"name": name, "tags": tags})
+}
+
+// serveManifest serves the manifest of name:reference, materializing the archive on first use.
+// References by digest are served from the cache.
+func (s *server) serveManifest(w http.ResponseWriter, r *http.Request, name, reference string) {
+	var digest string
+	if strings.HasPrefix(reference, "sha256:") {
+		digest = reference
+	} else {
+		a, err := s.findArchive(name, reference)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", err.Error())
+			return
+		}
+		digest, err = s.materialize(a)
+		if err != nil {
+			i18n.Printf("[x] Failed to materialize %s: %v\n", a.file.Path, err)
+			writeError(w, http.StatusBadGateway, "UNKNOWN", err.Error())
+			return
+		}
+	}
+
+	file, err := os.Open(blobPath(digest))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest not found: "+reference)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", mediaTypeManifest)
+	w.Header().Set("Docker-Content-Digest", digest)
+	http.ServeContent(w, r, "", time.Time{}, file)
+}
+
+// serveBlob serves a cached blob
+func (s *server) serveBlob(w http.ResponseWriter, r *http.Request, digest string) {
+	file, err := os.Open(blobPath(digest))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "BLOB_UNKNOWN", "blob not found: "+digest)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Docker-Content-Digest", digest)
+	http.ServeContent(w, r, "", time.Time{}, file)
+}
+
+// listArchives lists the image archives of the cloud folder whose names can be parsed by the name template
+func (s *server) listArchives() ([]archive, error) {
+	files, err := cloud.ListFilesRecursive(s.bdfsClient, s.cloudDir, docker.NameDepth())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cloud folder %s: %v", s.cloudDir, err)
+	}
+
+	var archives []archive
+	for _, file := range files {
+		if file.IsDir == 1 || docker.IsFilesystemArchive(file.Path) {
+			continue
+		}
+		if fields, ok := docker.ParseArchiveName(file.Path); ok {
+			archives = append(archives, archive{file: file, fields: fields})
+		}
+	}
+	return archives, nil
+}
+
+// findArchive returns the archive of name:tag, preferring the platform the registry runs on when the folder
+// holds archives for several platforms
+func (s *server) findArchive(name, tag string) (archive, error) {
+	archives, err := s.listArchives()
+	if err != nil {
+		return archive{}, err
+	}
+
+	var candidates []archive
+	for _, a := range archives {
+		if matchRepository(a.fields.Repository, name) && a.fields.Tag == tag {
+			candidates = append(candidates, a)
+		}
+	}
+	if len(candidates) == 0 {
+		return archive{}, fmt.Errorf("no archive found for %s:%s", name, tag)
+	}
+
+	for _, a := range candidates {
+		if a.fields.OS == runtime.GOOS && a.fields.Arch == runtime.GOARCH {
+			return a, nil
+		}
+	}
+	return candidates[0], nil
+}
+
+// materialize downloads an archive, stores its config and layers as blobs and returns the digest of the
+// generated manifest. The result is remembered per cloud file, size and modification time.
+func (s *server) materialize(a archive) (string, error) {
+	lock, _ := s.locks.LoadOrStore(a.file.Path, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	refPath := filepath.Join(cacheDir, "refs", hashString(fmt.Sprintf("%s|%d|%d", a.file.Path, a.file.Size, a.file.ServerMtime)))
+	if data, err := os.ReadFile(refPath); err == nil {
+		digest := strings.TrimSpace(string(data))
+		if _, err := os.Stat(blobPath(digest)); err == nil {
+			return digest, nil
+		}
+	}
+
+	localPath := filepath.Join(cacheDir, "archives", docker.TrimIndexSuffix(path.Base(a.file.Path)))
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return "", err
+	}
+	fmt.Printf("Downloading %s from Baidu cloud...\n", a.file.Path)
+	if err := cloud.DownloadArchive(s.bdfsClient, a.file.Path, localPath); err != nil {
+		return "", fmt.Errorf("download failed: %v", err)
+	}
+	defer os.Remove(localPath)
+
+	digest, err := unpackArchive(localPath)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(refPath), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(refPath, []byte(digest+"\n"), 0644); err != nil {
+		return "", err
+	}
+	i18n.Printf("[√] Materialized %s as %s\n", a.file.Path, digest)
+	return digest, nil
+}
+
+// unpackArchive stores every file of a `docker save` archive as a blob, then writes an OCI manifest for the
+// image described by its manifest.json and returns the manifest digest
+func unpackArchive(archivePath string) (string, error) {
+	reader, err := docker.OpenArchive(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	// Digest and size of every regular file, and the targets of symlinked (deduplicated) layers
+	type blob struct {
+		digest string
+		size   int64
+	}
+	blobs := make(map[string]blob)
+	links := make(map[string]string)
+	var saved []saveManifest
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read archive: %v", err)
+		}
+
+		name := path.Clean(header.Name)
+		switch header.Typeflag {
+		case tar.TypeSymlink:
+			links[name] = path.Join(path.Dir(name), header.Linkname)
+		case tar.TypeReg:
+			if name == "manifest.json" {
+				if err := json.NewDecoder(tarReader).Decode(&saved); err != nil {
+					return "", fmt.Errorf("invalid manifest.json: %v", err)
+				}
+				continue
+			}
+			digest, size, err := storeBlob(tarReader)
+			if err != nil {
+				return "", err
+			}
+			blobs[name] = blob{digest: digest, size: size}
+		}
+	}
+
+	if len(saved) == 0 {
+		return "", fmt.Errorf("manifest.json not found in archive")
+	}
+
+	lookup := func(name string) (blob, error) {
+		name = path.Clean(name)
+		if target, ok := links[name]; ok {
+			name = target
+		}
+		b, ok := blobs[name]
+		if !ok {
+			return blob{}, fmt.Errorf("%s not found in archive", name)
+		}
+		return b, nil
+	}
+
+	config, err := lookup(saved[0].Config)
+	if err != nil {
+		return "", err
+	}
+	m := manifest{
+		SchemaVersion: 2,
+		MediaType:     mediaTypeManifest,
+		Config:        descriptor{MediaType: mediaTypeConfig, Digest: config.digest, Size: config.size},
+		Layers:        []descriptor{},
+	}
+	for _, layer := range saved[0].Layers {
+		b, err := lookup(layer)
+		if err != nil {
+			return "", err
+		}
+		mediaType, err := layerMediaType(blobPath(b.digest))
+		if err != nil {
+			return "", err
+		}
+		m.Layers = append(m.Layers, descriptor{MediaType: mediaType, Digest: b.digest, Size: b.size})
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	digest, _, err := storeBlob(strings.NewReader(string(data)))
+	return digest, err
+}
+
+// layerMediaType returns the media type of a layer blob from its first bytes: gzip and zstd layers are served
+// as such, anything else as a plain tar
+func layerMediaType(blobPath string) (string, error) {
+	file, err := os.Open(blobPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	magic := make([]byte, len(zstdMagic))
+	n, err := io.ReadFull(file, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	switch {
+	case bytes.HasPrefix(magic[:n], gzipMagic):
+		return mediaTypeGzip, nil
+	case bytes.HasPrefix(magic[:n], zstdMagic):
+		return mediaTypeZstd, nil
+	}
+	return mediaTypeLayer, nil
+}
+
+// storeBlob writes the content of reader into the blob store under its sha256 digest
+func storeBlob(reader io.Reader) (string, int64, error) {
+	temp, err := os.CreateTemp(filepath.Join(cacheDir, "blobs"), "upload-")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(temp, hash), reader)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to store blob: %v", err)
+	}
+	if err := temp.Close(); err != nil {
+		return "", 0, err
+	}
+
+	digest := "sha256:" + hex.EncodeToString(hash.Sum(nil))
+	if err := os.Rename(temp.Name(), blobPath(digest)); err != nil {
+		return "", 0, err
+	}
+	return digest, size, nil
+}
+
+// blobPath returns the cache path of a blob; digests that are not sha256 map to a path that never exists
+func blobPath(digest string) string {
+	hexDigest, ok := strings.CutPrefix(digest, "sha256:")
+	if !ok || strings.ContainsAny(hexDigest, "/.") {
+		return filepath.Join(cacheDir, "blobs", "invalid")
+	}
+	return filepath.Join(cacheDir, "blobs", hexDigest)
+}
+
+// matchRepository reports whether an archived repository is the one requested. Docker Hub references
+// may be requested with or without the "library/" prefix.
+func matchRepository(repository, name string) bool {
+	return repository == name || "library/"+repository == name || repository == "library/"+name
+}
+
+// hashString returns the hex sha256 of a string
+func hashString(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, contentType string, value any) {
+	w.Header().Set("Content-Type", contentType)
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeError writes an error in the format of the registry API
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"errors": []map[string]string{{"code": code, "message": message}},
+	})
+}
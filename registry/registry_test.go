@@ -0,0 +1,85 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSavedArchive writes a `docker save` archive holding files and a manifest.json listing layers
+func writeSavedArchive(t *testing.T, files map[string][]byte, layers []string) string {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer := tar.NewWriter(&buffer)
+	add := func(name string, data []byte) {
+		if err := writer.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		writer.Write(data)
+	}
+	for name, data := range files {
+		add(name, data)
+	}
+	saved, _ := json.Marshal([]saveManifest{{Config: "config.json", RepoTags: []string{"app:1.0"}, Layers: layers}})
+	add("manifest.json", saved)
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archivePath := filepath.Join(t.TempDir(), "app_1.0_linux_amd64.tar")
+	if err := os.WriteFile(archivePath, buffer.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return archivePath
+}
+
+func TestUnpackArchiveLayerMediaTypes(t *testing.T) {
+	original := cacheDir
+	cacheDir = t.TempDir()
+	t.Cleanup(func() { cacheDir = original })
+	if err := os.MkdirAll(filepath.Join(cacheDir, "blobs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var gzipped bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipped)
+	gzipWriter.Write([]byte("layer"))
+	gzipWriter.Close()
+
+	files := map[string][]byte{
+		"config.json":      []byte(`{"architecture":"amd64"}`),
+		"plain/layer.tar":  []byte(strings.Repeat("\x00", 1024)),
+		"gzip/layer.tar":   gzipped.Bytes(),
+		"zstd/layer.tar":   {0x28, 0xb5, 0x2f, 0xfd, 0x00},
+		"empty/layer.tar":  {},
+		"marker/layer.tar": {0x1f},
+	}
+	layers := []string{"plain/layer.tar", "gzip/layer.tar", "zstd/layer.tar", "empty/layer.tar", "marker/layer.tar"}
+	digest, err := unpackArchive(writeSavedArchive(t, files, layers))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(blobPath(digest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{mediaTypeLayer, mediaTypeGzip, mediaTypeZstd, mediaTypeLayer, mediaTypeLayer}
+	if len(m.Layers) != len(want) {
+		t.Fatalf("manifest has %d layers, want %d", len(m.Layers), len(want))
+	}
+	for i, layer := range m.Layers {
+		if layer.MediaType != want[i] {
+			t.Errorf("%s: media type %s, want %s", layers[i], layer.MediaType, want[i])
+		}
+	}
+}
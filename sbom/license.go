@@ -0,0 +1,195 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LicenseSuffix is appended to the path of an archive to name its license report
+const LicenseSuffix = ".licenses.md"
+
+// unknownLicense groups the packages whose SBOM entry names no license
+const unknownLicense = "Unknown"
+
+// licenseReport writes a license report next to every archive an SBOM is generated for
+var licenseReport bool
+
+// SetLicenseReport makes exports write a license report from the SBOM of every archive next to it
+func SetLicenseReport(enabled bool) {
+	licenseReport = enabled
+}
+
+// LicenseReportEnabled reports whether license reports are written for exported archives
+func LicenseReportEnabled() bool {
+	return licenseReport
+}
+
+// LicenseFile returns the path of the license report belonging to an archive
+func LicenseFile(archivePath string) string {
+	return archivePath + LicenseSuffix
+}
+
+// License is a license found in an SBOM and the packages, as name@version, under it
+type License struct {
+	Name     string
+	Packages []string
+}
+
+// spdxDocument holds the fields of an SPDX JSON document naming the licenses of its packages
+type spdxDocument struct {
+	DocumentDescribes []string `json:"documentDescribes"`
+	Packages          []struct {
+		SPDXID           string `json:"SPDXID"`
+		Name             string `json:"name"`
+		VersionInfo      string `json:"versionInfo"`
+		LicenseConcluded string `json:"licenseConcluded"`
+		LicenseDeclared  string `json:"licenseDeclared"`
+	} `json:"packages"`
+	Relationships []struct {
+		Element string `json:"spdxElementId"`
+		Type    string `json:"relationshipType"`
+		Related string `json:"relatedSpdxElement"`
+	} `json:"relationships"`
+}
+
+// cycloneDXDocument holds the fields of a CycloneDX JSON document naming the licenses of its components
+type cycloneDXDocument struct {
+	Components []struct {
+		Name     string `json:"name"`
+		Version  string `json:"version"`
+		Licenses []struct {
+			License struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"license"`
+			Expression string `json:"expression"`
+		} `json:"licenses"`
+	} `json:"components"`
+}
+
+// Licenses reads an SBOM written by Generate, SPDX or CycloneDX as told by its file name, and returns its licenses
+// by name, with the packages without one under Unknown last. The package describing the image itself is left out.
+func Licenses(sbomPath string) ([]License, error) {
+	data, err := os.ReadFile(sbomPath)
+	if err != nil {
+		return nil, err
+	}
+
+	packages := make(map[string][]string)
+	if strings.HasSuffix(sbomPath, ".cdx.json") {
+		var document cycloneDXDocument
+		if err := json.Unmarshal(data, &document); err != nil {
+			return nil, fmt.Errorf("invalid CycloneDX SBOM %s: %v", sbomPath, err)
+		}
+		for _, component := range document.Components {
+			var names []string
+			for _, license := range component.Licenses {
+				switch {
+				case license.Expression != "":
+					names = append(names, license.Expression)
+				case license.License.ID != "":
+					names = append(names, license.License.ID)
+				case license.License.Name != "":
+					names = append(names, license.License.Name)
+				}
+			}
+			name := strings.Join(names, " OR ")
+			packages[name] = append(packages[name], packageName(component.Name, component.Version))
+		}
+	} else {
+		var document spdxDocument
+		if err := json.Unmarshal(data, &document); err != nil {
+			return nil, fmt.Errorf("invalid SPDX SBOM %s: %v", sbomPath, err)
+		}
+		described := make(map[string]bool)
+		for _, id := range document.DocumentDescribes {
+			described[id] = true
+		}
+		for _, relationship := range document.Relationships {
+			if relationship.Element == "SPDXRef-DOCUMENT" && relationship.Type == "DESCRIBES" {
+				described[relationship.Related] = true
+			}
+		}
+		for _, p := range document.Packages {
+			if described[p.SPDXID] {
+				continue
+			}
+			name := p.LicenseConcluded
+			if !isSPDXLicense(name) {
+				name = p.LicenseDeclared
+			}
+			if !isSPDXLicense(name) {
+				name = ""
+			}
+			packages[name] = append(packages[name], packageName(p.Name, p.VersionInfo))
+		}
+	}
+
+	licenses := make([]License, 0, len(packages))
+	for name, names := range packages {
+		sort.Strings(names)
+		if name == "" {
+			name = unknownLicense
+		}
+		licenses = append(licenses, License{Name: name, Packages: names})
+	}
+	sort.Slice(licenses, func(i, j int) bool {
+		if (licenses[i].Name == unknownLicense) != (licenses[j].Name == unknownLicense) {
+			return licenses[j].Name == unknownLicense
+		}
+		return licenses[i].Name < licenses[j].Name
+	})
+	return licenses, nil
+}
+
+// isSPDXLicense reports whether an SPDX license field names a license rather than saying it is not known
+func isSPDXLicense(value string) bool {
+	return value != "" && value != "NOASSERTION" && value != "NONE"
+}
+
+// packageName names a package with its version, if known
+func packageName(name, version string) string {
+	if version == "" {
+		return name
+	}
+	return name + "@" + version
+}
+
+// WriteLicenseReport writes the license report of an archive from its SBOM, a Markdown summary of the licenses
+// found with the packages under each, and returns its path
+func WriteLicenseReport(archivePath, imageName, sbomPath string) (string, error) {
+	licenses, err := Licenses(sbomPath)
+	if err != nil {
+		return "", err
+	}
+
+	count := 0
+	for _, license := range licenses {
+		count += len(license.Packages)
+	}
+
+	var report strings.Builder
+	fmt.Fprintf(&report, "# Licenses of %s\n\n", imageName)
+	fmt.Fprintf(&report, "Archive: `%s`  \nSBOM: `%s`  \n%d package(s) under %d license(s)\n\n",
+		filepath.Base(archivePath), filepath.Base(sbomPath), count, len(licenses))
+	report.WriteString("| License | Packages |\n| --- | ---: |\n")
+	for _, license := range licenses {
+		fmt.Fprintf(&report, "| %s | %d |\n", strings.ReplaceAll(license.Name, "|", "\\|"), len(license.Packages))
+	}
+	for _, license := range licenses {
+		fmt.Fprintf(&report, "\n## %s\n\n", license.Name)
+		for _, name := range license.Packages {
+			fmt.Fprintf(&report, "- %s\n", name)
+		}
+	}
+
+	reportPath := LicenseFile(archivePath)
+	if err := os.WriteFile(reportPath, []byte(report.String()), 0644); err != nil {
+		return "", err
+	}
+	return reportPath, nil
+}
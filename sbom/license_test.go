@@ -0,0 +1,70 @@
+package sbom
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLicenses(t *testing.T) {
+	dir := t.TempDir()
+	spdxPath := filepath.Join(dir, "app.tar.spdx.json")
+	os.WriteFile(spdxPath, []byte(`{
+		"documentDescribes": ["SPDXRef-image"],
+		"packages": [
+			{"SPDXID": "SPDXRef-image", "name": "app", "licenseConcluded": "NOASSERTION"},
+			{"SPDXID": "SPDXRef-1", "name": "musl", "versionInfo": "1.2.4", "licenseConcluded": "NOASSERTION", "licenseDeclared": "MIT"},
+			{"SPDXID": "SPDXRef-2", "name": "busybox", "versionInfo": "1.36", "licenseConcluded": "GPL-2.0-only"},
+			{"SPDXID": "SPDXRef-3", "name": "zlib", "versionInfo": "1.3", "licenseDeclared": "Zlib"},
+			{"SPDXID": "SPDXRef-4", "name": "blob", "licenseDeclared": "NONE"},
+			{"SPDXID": "SPDXRef-5", "name": "libc-utils", "versionInfo": "0.7", "licenseDeclared": "MIT"}
+		]
+	}`), 0644)
+	cdxPath := filepath.Join(dir, "app.tar.cdx.json")
+	os.WriteFile(cdxPath, []byte(`{
+		"components": [
+			{"name": "openssl", "version": "3.1", "licenses": [{"license": {"id": "Apache-2.0"}}]},
+			{"name": "tzdata", "version": "2024a", "licenses": [{"expression": "MIT OR BSD-3-Clause"}]},
+			{"name": "tool"}
+		]
+	}`), 0644)
+
+	tests := []struct {
+		path string
+		want []License
+	}{
+		{spdxPath, []License{
+			{"GPL-2.0-only", []string{"busybox@1.36"}},
+			{"MIT", []string{"libc-utils@0.7", "musl@1.2.4"}},
+			{"Zlib", []string{"zlib@1.3"}},
+			{"Unknown", []string{"blob"}},
+		}},
+		{cdxPath, []License{
+			{"Apache-2.0", []string{"openssl@3.1"}},
+			{"MIT OR BSD-3-Clause", []string{"tzdata@2024a"}},
+			{"Unknown", []string{"tool"}},
+		}},
+	}
+	for _, test := range tests {
+		got, err := Licenses(test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Licenses(%s) = %+v, want %+v", filepath.Base(test.path), got, test.want)
+		}
+	}
+
+	reportPath, err := WriteLicenseReport(filepath.Join(dir, "app.tar"), "app:1", spdxPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, _ := os.ReadFile(reportPath)
+	for _, want := range []string{"# Licenses of app:1", "5 package(s) under 4 license(s)", "| MIT | 2 |", "- musl@1.2.4"} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("license report lacks %q:\n%s", want, report)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package sbom
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// SBOM formats accepted by SetFormat
+const (
+	FormatSPDX      = "spdx"
+	FormatCycloneDX = "cyclonedx"
+)
+
+// format is the SBOM format written next to every exported archive; empty disables SBOM generation
+var format string
+
+// SetFormat makes exports write an SBOM in the given format next to every archive
+func SetFormat(name string) error {
+	switch name {
+	case "", FormatSPDX, FormatCycloneDX:
+	default:
+		return fmt.Errorf("invalid SBOM format %q (expected %s or %s)", name, FormatSPDX, FormatCycloneDX)
+	}
+
+	format = name
+	return nil
+}
+
+// Enabled reports whether SBOMs are generated for exported archives
+func Enabled() bool {
+	return format != ""
+}
+
+// File returns the path of the SBOM belonging to an archive in the configured format
+func File(archivePath string) string {
+	if format == FormatCycloneDX {
+		return archivePath + ".cdx.json"
+	}
+	return archivePath + ".spdx.json"
+}
+
+// Generate catalogs an image archive with syft and writes the SBOM next to it, returning its path
+func Generate(archivePath string) (string, error) {
+	syftPath, err := exec.LookPath("syft")
+	if err != nil {
+		return "", fmt.Errorf("syft executable not found in PATH: %v", err)
+	}
+
+	output := "spdx-json"
+	if format == FormatCycloneDX {
+		output = "cyclonedx-json"
+	}
+
+	sbomPath := File(archivePath)
+	cmd := exec.Command(syftPath, "scan", "docker-archive:"+archivePath, "--quiet", "-o", output+"="+sbomPath)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("syft failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return sbomPath, nil
+}
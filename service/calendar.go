@@ -0,0 +1,139 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// calendarMacros are the cron macros systemd has a calendar shorthand for
+var calendarMacros = map[string]string{
+	"@hourly":   "hourly",
+	"@daily":    "daily",
+	"@midnight": "daily",
+	"@weekly":   "weekly",
+	"@monthly":  "monthly",
+	"@yearly":   "yearly",
+	"@annually": "yearly",
+}
+
+// cronFields are the fields of a cron expression with their bounds. Day of week 7 is Sunday, like 0.
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// weekdays name the days of the week in calendar expressions, from Sunday
+var weekdays = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// Calendar converts a cron expression, such as "0 2 * * *", or a cron macro such as @daily into the calendar
+// expression of a systemd timer, such as "*-*-* 02:00:00". Fields take *, values, ranges, lists and steps.
+// Restricting both the day of month and the day of week is refused, as cron runs when either matches while
+// systemd wants both to.
+func Calendar(schedule string) (string, error) {
+	if calendar, ok := calendarMacros[schedule]; ok {
+		return calendar, nil
+	}
+	fields := strings.Fields(schedule)
+	if len(fields) != len(cronFields) {
+		return "", fmt.Errorf("invalid schedule %q (expected 5 cron fields, such as \"0 2 * * *\", or a macro such as @daily)", schedule)
+	}
+
+	values := make([][]int, len(fields))
+	for i, field := range fields {
+		var err error
+		if values[i], err = parseCronField(field, i); err != nil {
+			return "", fmt.Errorf("invalid schedule %q: %s: %v", schedule, cronFields[i].name, err)
+		}
+	}
+	if values[2] != nil && values[4] != nil {
+		return "", fmt.Errorf("schedule %q restricts both the day of month and the day of week, which a systemd timer cannot express; use --cron", schedule)
+	}
+
+	calendar := ""
+	if values[4] != nil {
+		days := make([]string, len(values[4]))
+		for i, day := range values[4] {
+			days[i] = weekdays[day]
+		}
+		calendar = strings.Join(days, ",") + " "
+	}
+	calendar += fmt.Sprintf("*-%s-%s %s:%s:00", calendarList(values[3]), calendarList(values[2]),
+		calendarList(values[1]), calendarList(values[0]))
+	return calendar, nil
+}
+
+// parseCronField returns the values a field of a cron expression matches in ascending order, or nil when it
+// matches every value
+func parseCronField(field string, index int) ([]int, error) {
+	bounds := cronFields[index]
+	matched := make([]bool, bounds.max+1)
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		low, high := bounds.min, bounds.max
+		if rangePart != "*" {
+			first, last, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(first); err != nil {
+				return nil, fmt.Errorf("invalid value %q", first)
+			}
+			switch {
+			case isRange:
+				if high, err = strconv.Atoi(last); err != nil {
+					return nil, fmt.Errorf("invalid value %q", last)
+				}
+			case !hasStep:
+				// A value with a step, such as 5/15, runs from the value to the end of the range
+				high = low
+			}
+		}
+		if low < bounds.min || high > bounds.max || low > high {
+			return nil, fmt.Errorf("%q is out of range %d-%d", item, bounds.min, bounds.max)
+		}
+		for value := low; value <= high; value += step {
+			matched[value] = true
+		}
+	}
+
+	last := bounds.max
+	if index == 4 {
+		matched[0] = matched[0] || matched[7]
+		last = 6
+	}
+	var values []int
+	for value := bounds.min; value <= last; value++ {
+		if matched[value] {
+			values = append(values, value)
+		}
+	}
+	if len(values) == last-bounds.min+1 {
+		return nil, nil
+	}
+	return values, nil
+}
+
+// calendarList formats the values of a field for a calendar expression, * matching every value
+func calendarList(values []int) string {
+	if values == nil {
+		return "*"
+	}
+	formatted := make([]string, len(values))
+	for i, value := range values {
+		formatted[i] = fmt.Sprintf("%02d", value)
+	}
+	return strings.Join(formatted, ",")
+}
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/baowuhe/go-dkci/config"
+)
+
+// Options describes a go-dkci command installed as a service
+type Options struct {
+	// Name names the units, the crontab entry and the environment file
+	Name string
+	// Schedule is a cron expression such as "0 2 * * *", or a macro such as @daily. Without one the command runs
+	// as a long-running service, such as the registry, restarted when it fails.
+	Schedule string
+	// Args are the arguments of go-dkci, such as export --all --cloud /backup
+	Args []string
+	// User installs systemd user units instead of system units
+	User bool
+	// Cron installs an entry in the crontab of the current user instead of systemd units
+	Cron bool
+}
+
+// validName matches the names units and crontab entries can be given
+var validName = regexp.MustCompile(`^[A-Za-z0-9_.@-]+$`)
+
+// environment are the variables go-dkci, Docker and the tools go-dkci runs read, copied into the environment
+// file so that the service runs as the command does in the shell it is installed from
+var environment = []string{
+	"PATH", "HOME", "LC_ALL", "LC_MESSAGES", "LANG",
+	"BDFS_CONFIG_FILE", "BDFS_CLIENT_ID", "BDFS_CLIENT_SECRET", "BDFS_TOKEN_PATH", "BDFS_DEFAULT_CLOUD_DIR",
+	"DKCI_NAME_TEMPLATE", "DKCI_ARCHIVE_PASSWORD", "DKCI_TEMP_DIR",
+	"DOCKER_HOST", "DOCKER_CONTEXT", "DOCKER_CONFIG", "DOCKER_CERT_PATH", "DOCKER_TLS_VERIFY", "DOCKER_API_VERSION",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy",
+}
+
+// cronMarker ends the crontab entry of a service, so that installing it again replaces the entry
+const cronMarker = " # go-dkci:"
+
+// unit holds the values of the unit templates
+type unit struct {
+	Options
+	Description string
+	ExecStart   string
+	EnvFile     string
+	Calendar    string
+}
+
+// serviceTemplate runs the command once per activation of the timer, or keeps it running without a schedule
+var serviceTemplate = template.Must(template.New("service").Parse(`[Unit]
+Description={{ .Description }}
+Wants=network-online.target
+After=network-online.target{{ if not .User }} docker.service{{ end }}
+
+[Service]
+{{- if .Schedule }}
+Type=oneshot
+{{- else }}
+Type=simple
+Restart=on-failure
+RestartSec=10
+{{- end }}
+EnvironmentFile={{ .EnvFile }}
+ExecStart={{ .ExecStart }}
+{{- if not .Schedule }}
+
+[Install]
+WantedBy={{ if .User }}default.target{{ else }}multi-user.target{{ end }}
+{{- end }}
+`))
+
+// timerTemplate starts the service on the schedule, catching up on runs missed while the host was off
+var timerTemplate = template.Must(template.New("timer").Parse(`[Unit]
+Description={{ .Description }} on schedule {{ .Schedule }}
+
+[Timer]
+OnCalendar={{ .Calendar }}
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`))
+
+// SystemdUnits renders the service unit running executable with the arguments of opts and, with a schedule, the
+// timer starting it. envFile is the environment file the service reads.
+func SystemdUnits(opts Options, executable, envFile string) (service, timer string, err error) {
+	u := unit{Options: opts, EnvFile: envFile}
+	specifiers := strings.NewReplacer("%", "%%")
+	u.Description = specifiers.Replace("go-dkci " + strings.Join(opts.Args, " "))
+	args := []string{systemdQuote(executable)}
+	for _, arg := range opts.Args {
+		args = append(args, systemdQuote(arg))
+	}
+	u.ExecStart = strings.Join(args, " ")
+	if opts.Schedule != "" {
+		if u.Calendar, err = Calendar(opts.Schedule); err != nil {
+			return "", "", err
+		}
+		u.Schedule = specifiers.Replace(opts.Schedule)
+	}
+
+	var b bytes.Buffer
+	if err := serviceTemplate.Execute(&b, u); err != nil {
+		return "", "", err
+	}
+	service = b.String()
+	if opts.Schedule == "" {
+		return service, "", nil
+	}
+	b.Reset()
+	if err := timerTemplate.Execute(&b, u); err != nil {
+		return "", "", err
+	}
+	return service, b.String(), nil
+}
+
+// CronEntry renders the crontab line running executable with the arguments of opts on its schedule, after
+// loading the environment file
+func CronEntry(opts Options, executable, envFile string) (string, error) {
+	if opts.Schedule == "" {
+		return "", fmt.Errorf("a crontab entry needs a schedule")
+	}
+	if fields := strings.Fields(opts.Schedule); len(fields) != 5 && (len(fields) != 1 || !strings.HasPrefix(opts.Schedule, "@")) {
+		return "", fmt.Errorf("invalid schedule %q (expected 5 cron fields, such as \"0 2 * * *\", or a macro such as @daily)", opts.Schedule)
+	}
+	args := []string{shellQuote(executable)}
+	for _, arg := range opts.Args {
+		args = append(args, shellQuote(arg))
+	}
+	// cron turns unescaped % into newlines
+	command := fmt.Sprintf("set -a; . %s; set +a; exec %s", shellQuote(envFile), strings.Join(args, " "))
+	return opts.Schedule + " " + strings.ReplaceAll(command, "%", `\%`) + cronMarker + opts.Name, nil
+}
+
+// EnvironmentFile renders the variables of environment that are set as an environment file systemd and sh both
+// read. Values are single-quoted, so values holding a single quote or a line break cannot be written.
+func EnvironmentFile() (string, error) {
+	var b strings.Builder
+	b.WriteString("# Environment of a go-dkci service, written by go-dkci install-service\n")
+	for _, name := range environment {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		if strings.ContainsAny(value, "'\n\r") {
+			return "", fmt.Errorf("cannot write %s to the environment file, its value holds a quote or line break", name)
+		}
+		fmt.Fprintf(&b, "%s='%s'\n", name, value)
+	}
+	return b.String(), nil
+}
+
+// systemdQuote quotes an argument of ExecStart, escaping the specifiers and variables systemd would expand
+func systemdQuote(arg string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%", "$", "$$").Replace(arg) + `"`
+}
+
+// shellQuote quotes an argument of a shell command line
+func shellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// Install writes the environment file and the systemd units or crontab entry of opts and enables them: systemd
+// timers and services are enabled and started with systemctl, and the crontab entry replaces the one installed
+// under the same name. With dryRun set the files are printed instead.
+func Install(opts Options, dryRun bool) error {
+	if !validName.MatchString(opts.Name) {
+		return fmt.Errorf("invalid service name %q", opts.Name)
+	}
+	if len(opts.Args) == 0 {
+		return fmt.Errorf("no go-dkci command to install")
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+	envFile, err := config.ServiceEnvFile(opts.Name)
+	if err != nil {
+		return err
+	}
+	env, err := EnvironmentFile()
+	if err != nil {
+		return err
+	}
+
+	files := map[string]string{envFile: env}
+	order := []string{envFile}
+	var entry string
+	if opts.Cron {
+		if entry, err = CronEntry(opts, executable, envFile); err != nil {
+			return err
+		}
+	} else {
+		unitDir := "/etc/systemd/system"
+		if opts.User {
+			configDir, err := os.UserConfigDir()
+			if err != nil {
+				return err
+			}
+			unitDir = filepath.Join(configDir, "systemd", "user")
+		}
+		service, timer, err := SystemdUnits(opts, executable, envFile)
+		if err != nil {
+			return err
+		}
+		servicePath := filepath.Join(unitDir, opts.Name+".service")
+		files[servicePath] = service
+		order = append(order, servicePath)
+		if timer != "" {
+			timerPath := filepath.Join(unitDir, opts.Name+".timer")
+			files[timerPath] = timer
+			order = append(order, timerPath)
+		}
+	}
+
+	if dryRun {
+		for _, filePath := range order {
+			fmt.Printf("# %s\n%s\n", filePath, files[filePath])
+		}
+		if entry != "" {
+			fmt.Printf("# crontab\n%s\n", entry)
+		}
+		return nil
+	}
+
+	for _, filePath := range order {
+		// The environment file may hold credentials
+		perm := os.FileMode(0644)
+		if filePath == envFile {
+			perm = 0600
+		}
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filePath, []byte(files[filePath]), perm); err != nil {
+			return err
+		}
+		if err := os.Chmod(filePath, perm); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s\n", filePath)
+	}
+	if opts.Cron {
+		return installCronEntry(opts.Name, entry)
+	}
+
+	systemctl := []string{}
+	if opts.User {
+		systemctl = append(systemctl, "--user")
+	}
+	unitName := opts.Name + ".service"
+	if opts.Schedule != "" {
+		unitName = opts.Name + ".timer"
+	}
+	if err := run("systemctl", append(systemctl, "daemon-reload")...); err != nil {
+		return err
+	}
+	return run("systemctl", append(systemctl, "enable", "--now", unitName)...)
+}
+
+// installCronEntry replaces the entry of the named service in the crontab of the current user with entry
+func installCronEntry(name, entry string) error {
+	if _, err := exec.LookPath("crontab"); err != nil {
+		return fmt.Errorf("crontab executable not found in PATH: %v", err)
+	}
+	// crontab -l fails for users without a crontab, who start with an empty one
+	current, _ := exec.Command("crontab", "-l").Output()
+
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(string(current), "\n"), "\n") {
+		if line != "" && !strings.HasSuffix(line, cronMarker+name) {
+			lines = append(lines, line)
+		}
+	}
+	lines = append(lines, entry)
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = strings.NewReader(strings.Join(lines, "\n") + "\n")
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("crontab failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// run runs a command, returning its error output with the error
+func run(name string, args ...string) error {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return fmt.Errorf("%s executable not found in PATH: %v", name, err)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(path, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s failed: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
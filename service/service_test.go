@@ -0,0 +1,119 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCalendar(t *testing.T) {
+	tests := []struct {
+		schedule string
+		want     string
+		wantErr  bool
+	}{
+		{"0 2 * * *", "*-*-* 02:00:00", false},
+		{"@daily", "daily", false},
+		{"*/15 * * * *", "*-*-* *:00,15,30,45:00", false},
+		{"30 1 1,15 * *", "*-*-01,15 01:30:00", false},
+		{"0 3 * * 1-5", "Mon,Tue,Wed,Thu,Fri *-*-* 03:00:00", false},
+		{"0 3 * * 0,7", "Sun *-*-* 03:00:00", false},
+		{"0 3 * * 0-7", "*-*-* 03:00:00", false},
+		{"5/20 * * 6 *", "*-06-* *:05,25,45:00", false},
+		{"0 2 1 * 1", "", true},
+		{"0 24 * * *", "", true},
+		{"0 2 * *", "", true},
+		{"0 2 * * mon", "", true},
+		{"*/0 * * * *", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.schedule, func(t *testing.T) {
+			got, err := Calendar(tt.schedule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Calendar(%q) error = %v, wantErr %v", tt.schedule, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Calendar(%q) = %q, want %q", tt.schedule, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSystemdUnits(t *testing.T) {
+	opts := Options{Name: "backup", Schedule: "0 2 * * *", Args: []string{"export", "--all", "--name-template", `{{.Repository}}_100%_$HOME "x"`}}
+	service, timer, err := SystemdUnits(opts, "/usr/local/bin/go-dkci", "/root/.local/app/dkci/backup.env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Type=oneshot\n",
+		"EnvironmentFile=/root/.local/app/dkci/backup.env\n",
+		`ExecStart="/usr/local/bin/go-dkci" "export" "--all" "--name-template" "{{.Repository}}_100%%_$$HOME \"x\""` + "\n",
+		"After=network-online.target docker.service\n",
+	} {
+		if !strings.Contains(service, want) {
+			t.Errorf("service lacks %q:\n%s", want, service)
+		}
+	}
+	if strings.Contains(service, "[Install]") {
+		t.Errorf("scheduled service has an [Install] section:\n%s", service)
+	}
+	if !strings.Contains(timer, "OnCalendar=*-*-* 02:00:00\n") || !strings.Contains(timer, "Persistent=true\n") {
+		t.Errorf("unexpected timer:\n%s", timer)
+	}
+
+	opts = Options{Name: "registry", Args: []string{"registry"}, User: true}
+	service, timer, err = SystemdUnits(opts, "/usr/local/bin/go-dkci", "/home/ops/.local/app/dkci/registry.env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timer != "" {
+		t.Errorf("unscheduled service has a timer:\n%s", timer)
+	}
+	for _, want := range []string{"Restart=on-failure\n", "WantedBy=default.target\n"} {
+		if !strings.Contains(service, want) {
+			t.Errorf("service lacks %q:\n%s", want, service)
+		}
+	}
+}
+
+func TestCronEntry(t *testing.T) {
+	opts := Options{Name: "backup", Schedule: "0 2 * * *", Args: []string{"export", "--grep", "it's 100%"}}
+	got, err := CronEntry(opts, "/usr/local/bin/go-dkci", "/root/.local/app/dkci/backup.env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `0 2 * * * set -a; . '/root/.local/app/dkci/backup.env'; set +a; exec '/usr/local/bin/go-dkci' 'export' '--grep' 'it'\''s 100\%' # go-dkci:backup`
+	if got != want {
+		t.Errorf("CronEntry() = %s\nwant %s", got, want)
+	}
+
+	for _, schedule := range []string{"", "0 2 * *", "daily"} {
+		opts.Schedule = schedule
+		if _, err := CronEntry(opts, "/usr/local/bin/go-dkci", "backup.env"); err == nil {
+			t.Errorf("CronEntry() with schedule %q succeeded", schedule)
+		}
+	}
+}
+
+func TestEnvironmentFile(t *testing.T) {
+	for _, name := range environment {
+		t.Setenv(name, "")
+	}
+	t.Setenv("DOCKER_HOST", "unix:///run/user/1000/docker.sock")
+	t.Setenv("DKCI_ARCHIVE_PASSWORD", "p@ss word")
+
+	got, err := EnvironmentFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"DOCKER_HOST='unix:///run/user/1000/docker.sock'\n", "DKCI_ARCHIVE_PASSWORD='p@ss word'\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("environment file lacks %q:\n%s", want, got)
+		}
+	}
+
+	t.Setenv("DKCI_ARCHIVE_PASSWORD", "it's")
+	if _, err := EnvironmentFile(); err == nil {
+		t.Error("EnvironmentFile() with a quote in a value succeeded")
+	}
+}
@@ -0,0 +1,116 @@
+package sign
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// SignatureSuffix is appended to an archive name to name its detached signature
+const SignatureSuffix = ".sig"
+
+var (
+	// signingKey is the cosign private key used to sign exported archives; empty disables signing
+	signingKey string
+	// verifyKey is the cosign public key imported archives are verified against; empty disables verification
+	verifyKey string
+	// skipVerify turns verification failures into warnings instead of refusing the import
+	skipVerify bool
+	// transparencyLog records signatures in the public Rekor log and requires them there on verification
+	transparencyLog bool
+)
+
+// SetSigningKey makes exports produce a detached cosign signature for every archive using the given private key
+func SetSigningKey(keyPath string) {
+	signingKey = keyPath
+}
+
+// Enabled reports whether exported archives are signed
+func Enabled() bool {
+	return signingKey != ""
+}
+
+// SetTransparencyLog makes signing upload every signature to the public Rekor transparency log, and verification
+// require its entry there. Both need network access; by default signatures stay offline and private.
+func SetTransparencyLog(enabled bool) {
+	transparencyLog = enabled
+}
+
+// SignatureFile returns the path of the detached signature belonging to an archive
+func SignatureFile(archivePath string) string {
+	return archivePath + SignatureSuffix
+}
+
+// SignFile writes a detached signature of the file next to it with `cosign sign-blob` and returns its path.
+// cosign may prompt for the key password unless COSIGN_PASSWORD is set. The signature is only uploaded to Rekor
+// after SetTransparencyLog(true), so that the digests of private archives are not published.
+func SignFile(filePath string) (string, error) {
+	cosignPath, err := exec.LookPath("cosign")
+	if err != nil {
+		return "", fmt.Errorf("cosign executable not found in PATH: %v", err)
+	}
+
+	signaturePath := SignatureFile(filePath)
+	cmd := exec.Command(cosignPath, "sign-blob", "--yes", "--key", signingKey,
+		fmt.Sprintf("--tlog-upload=%t", transparencyLog), "--output-signature", signaturePath, filePath)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("cosign sign-blob failed: %v", err)
+	}
+
+	return signaturePath, nil
+}
+
+// SetVerifyKey makes imports verify the detached signature of every archive against the given public key.
+// With insecureSkip, archives with a missing or invalid signature are imported anyway after a warning.
+func SetVerifyKey(keyPath string, insecureSkip bool) {
+	verifyKey = keyPath
+	skipVerify = insecureSkip
+}
+
+// VerifyEnabled reports whether imported archives are verified
+func VerifyEnabled() bool {
+	return verifyKey != ""
+}
+
+// CheckFile verifies the detached signature of an archive before it is imported. It returns an error if the
+// signature is missing or invalid, unless verification failures were explicitly allowed with SetVerifyKey.
+func CheckFile(filePath string) error {
+	if !VerifyEnabled() {
+		return nil
+	}
+
+	err := VerifyFile(filePath)
+	if err != nil && skipVerify {
+		fmt.Printf("Warning: %v (continuing because verification is skipped)\n", err)
+		return nil
+	}
+	return err
+}
+
+// VerifyFile checks the detached signature next to the file with `cosign verify-blob`. The Rekor transparency log
+// is ignored unless SetTransparencyLog(true) was called, so that offline imports work.
+func VerifyFile(filePath string) error {
+	signaturePath := SignatureFile(filePath)
+	if _, err := os.Stat(signaturePath); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("signature %s not found", signaturePath)
+	}
+
+	cosignPath, err := exec.LookPath("cosign")
+	if err != nil {
+		return fmt.Errorf("cosign executable not found in PATH: %v", err)
+	}
+
+	args := []string{"verify-blob", "--key", verifyKey, "--signature", signaturePath}
+	if !transparencyLog {
+		args = append(args, "--insecure-ignore-tlog=true")
+	}
+	output, err := exec.Command(cosignPath, append(args, filePath)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("invalid signature for %s: %s", filePath, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package sign
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeCosign puts a cosign script first in PATH that records its arguments, one per line, and returns the file
+// holding them
+func fakeCosign(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake cosign needs sh")
+	}
+	dir := t.TempDir()
+	argsPath := filepath.Join(dir, "args")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + argsPath + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "cosign"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return argsPath
+}
+
+func readArgs(t *testing.T, argsPath string) []string {
+	t.Helper()
+	data, err := os.ReadFile(argsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestSignFileArgs(t *testing.T) {
+	argsPath := fakeCosign(t)
+	archive := filepath.Join(t.TempDir(), "nginx_1.26_linux_amd64.tar")
+	SetSigningKey("cosign.key")
+	t.Cleanup(func() { SetSigningKey(""); SetTransparencyLog(false) })
+
+	tests := []struct {
+		rekor bool
+		want  string
+	}{
+		{false, "sign-blob --yes --key cosign.key --tlog-upload=false --output-signature " + archive + ".sig " + archive},
+		{true, "sign-blob --yes --key cosign.key --tlog-upload=true --output-signature " + archive + ".sig " + archive},
+	}
+	for _, test := range tests {
+		SetTransparencyLog(test.rekor)
+		signaturePath, err := SignFile(archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signaturePath != archive+SignatureSuffix {
+			t.Errorf("SignFile returned %q", signaturePath)
+		}
+		if got := strings.Join(readArgs(t, argsPath), " "); got != test.want {
+			t.Errorf("rekor %v: cosign %s, want %s", test.rekor, got, test.want)
+		}
+	}
+}
+
+func TestVerifyFileArgs(t *testing.T) {
+	argsPath := fakeCosign(t)
+	archive := filepath.Join(t.TempDir(), "nginx_1.26_linux_amd64.tar")
+	SetVerifyKey("cosign.pub", false)
+	t.Cleanup(func() { SetVerifyKey("", false); SetTransparencyLog(false) })
+
+	if err := VerifyFile(archive); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("missing signature: %v", err)
+	}
+	if err := os.WriteFile(archive+SignatureSuffix, []byte("signature"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rekor bool
+		want  string
+	}{
+		{false, "verify-blob --key cosign.pub --signature " + archive + ".sig --insecure-ignore-tlog=true " + archive},
+		{true, "verify-blob --key cosign.pub --signature " + archive + ".sig " + archive},
+	}
+	for _, test := range tests {
+		SetTransparencyLog(test.rekor)
+		if err := VerifyFile(archive); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(readArgs(t, argsPath), " "); got != test.want {
+			t.Errorf("rekor %v: cosign %s, want %s", test.rekor, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package state
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/baowuhe/go-dkci/checksum"
+	"github.com/baowuhe/go-dkci/config"
+)
+
+// The operations recorded in the state database
+const (
+	Export = "export"
+	Import = "import"
+	// Promote is the copy of an exported archive into another cloud folder, which holds the image as an export there
+	Promote = "promote"
+)
+
+// The results of the recorded operations
+const (
+	OK      = "ok"
+	Skipped = "skipped"
+	Failed  = "failed"
+)
+
+var (
+	// mu serializes the appends of the goroutines of a command to the state database, and to session
+	mu sync.Mutex
+	// session are the records added by this process, for the reports of the command
+	session []Record
+)
+
+// Record is one export or import in the state database, which is a file of records in JSON, one per line
+type Record struct {
+	// Time is when the operation started
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	// Image is the exported image, as it was named on the command line or selected
+	Image string `json:"image,omitempty"`
+	// ImageID and Digests are the image ID and the repository digests of the exported image
+	ImageID string   `json:"image_id,omitempty"`
+	Digests []string `json:"digests,omitempty"`
+	// Images are the images an import loaded
+	Images []string `json:"images,omitempty"`
+	// Location is where the archive was written or read: a local path, or a cloud path prefixed with its
+	// backend, such as bdfs:/docker-images/nginx_latest.tar
+	Location string `json:"location,omitempty"`
+	// Size and SHA256 describe the archive, once the operation got as far as having one
+	Size    int64   `json:"size,omitempty"`
+	SHA256  string  `json:"sha256,omitempty"`
+	Seconds float64 `json:"seconds"`
+	Result  string  `json:"result"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// SetArchive records the size and SHA-256 checksum of the archive of the operation. An archive that cannot be
+// read is left undescribed, as the operation itself already succeeded or failed.
+func (r *Record) SetArchive(archivePath string) {
+	sums, err := checksum.File(archivePath)
+	if err != nil {
+		return
+	}
+	r.Size, r.SHA256 = sums.Size, sums.SHA256
+}
+
+// Finish sets the result of the operation from its error
+func (r *Record) Finish(err error) {
+	if err != nil {
+		r.Result, r.Error = Failed, err.Error()
+		return
+	}
+	r.Result = OK
+}
+
+// Add appends a record to the state database, measuring its duration from its start time. The database only
+// reports on the operations, so a failure to write it is a warning rather than a failure of the operation.
+func Add(record Record) {
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	record.Seconds = time.Since(record.Time).Round(time.Millisecond).Seconds()
+
+	if err := add(record); err != nil {
+		fmt.Printf("Warning: Failed to record the %s in the state database: %v\n", record.Operation, err)
+	}
+	mu.Lock()
+	session = append(session, record)
+	mu.Unlock()
+}
+
+// Session returns the records added by this process, oldest first, whether or not they could be written to the
+// state database
+func Session() []Record {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Record(nil), session...)
+}
+
+// add writes a record as one line, which the other processes appending to the file do not interleave with
+func add(record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	stateFile, err := config.StateFile()
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(stateFile), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(stateFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Records returns the records of the state database, oldest first; there are none before the first export or
+// import. Lines that are not records, such as one cut short by a crash, are skipped.
+func Records() ([]Record, error) {
+	stateFile, err := config.StateFile()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(stateFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.Operation == "" {
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read state database %s: %v", stateFile, err)
+	}
+	return records, nil
+}
@@ -0,0 +1,340 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/terminal"
+	"github.com/baowuhe/go-bdfs/pan"
+	"github.com/baowuhe/go-dkci/cloud"
+	"github.com/baowuhe/go-dkci/config"
+	"github.com/baowuhe/go-dkci/docker"
+	"github.com/baowuhe/go-dkci/i18n"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+const (
+	menuLocal  = "Browse local images"
+	menuCloud  = "Browse cloud folder"
+	menuSearch = "Search"
+	menuQuit   = "Quit"
+
+	actionExportLocal = "Export to local directory"
+	actionExportCloud = "Export to Baidu cloud"
+	actionDelete      = "Delete"
+	actionImport      = "Import into Docker"
+	actionBack        = "Back"
+
+	entryParent = ".."
+)
+
+// session holds the state shared between the panes of the interactive browser
+type session struct {
+	cli        *client.Client
+	bdfsClient cloud.Storage
+	cloudDir   string
+	search     string
+}
+
+// Run starts the interactive browser for local Docker images and Baidu cloud folders
+func Run() {
+	// Initialize Docker client
+	cli, err := docker.NewClient("")
+	if err != nil {
+		i18n.Printf("[x] Failed to create Docker client: %v\n", err)
+		os.Exit(1)
+	}
+	defer cli.Close()
+
+	// Name exported archives with the configured file name template
+	nameTemplate, err := config.GetNameTemplate()
+	if err == nil {
+		err = docker.SetNameTemplate(nameTemplate)
+	}
+	if err != nil {
+		i18n.Printf("[x] Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	s := &session{cli: cli}
+
+	for {
+		menu := []string{menuLocal, menuCloud, menuSearch, menuQuit}
+		if s.search != "" {
+			menu[2] = i18n.Sprintf("%s (current: %s)", i18n.T(menuSearch), s.search)
+		}
+
+		choice, ok := s.choose("go-dkci:", menu)
+		if !ok {
+			return
+		}
+
+		switch choice {
+		case 0:
+			s.browseLocal()
+		case 1:
+			s.browseCloud()
+		case 2:
+			s.askSearch()
+		case 3:
+			return
+		}
+	}
+}
+
+// choose shows a single-select prompt, translating the message and options, and returns the index of the chosen
+// option. It returns false when the user aborts the prompt with Ctrl+C or Esc.
+func (s *session) choose(message string, options []string) (int, bool) {
+	labels := make([]string, len(options))
+	for i, option := range options {
+		labels[i] = i18n.T(option)
+	}
+
+	prompt := &survey.Select{
+		Message:  i18n.T(message),
+		Options:  labels,
+		PageSize: 15,
+		Filter: func(filter string, value string, index int) bool {
+			return docker.FuzzyMatch(filter, value)
+		},
+	}
+
+	var index int
+	if err := survey.AskOne(prompt, &index); err != nil {
+		if errors.Is(err, terminal.InterruptErr) {
+			return 0, false
+		}
+		i18n.Printf("[x] Failed to get user selection: %v\n", err)
+		os.Exit(1)
+	}
+
+	return index, true
+}
+
+// askSearch updates the search pattern applied to both panes
+func (s *session) askSearch() {
+	prompt := &survey.Input{
+		Message: i18n.T("Search pattern (empty to clear):"),
+		Default: s.search,
+	}
+
+	if err := survey.AskOne(prompt, &s.search); err != nil && !errors.Is(err, terminal.InterruptErr) {
+		i18n.Printf("[x] Failed to get user input: %v\n", err)
+	}
+	s.search = strings.TrimSpace(s.search)
+}
+
+// login lazily logs in to Baidu cloud the first time a cloud action is used
+func (s *session) login() {
+	if s.bdfsClient != nil {
+		return
+	}
+
+	s.bdfsClient = cloud.Login()
+
+	if s.cloudDir == "" {
+		defaultDir, err := cloud.DefaultDir()
+		if err != nil {
+			i18n.Printf("[x] Error getting BDFS configuration: %v\n", err)
+			os.Exit(1)
+		}
+		s.cloudDir = defaultDir
+	}
+}
+
+// browseLocal shows the local images pane and runs the chosen action on an image
+func (s *session) browseLocal() {
+	for {
+		ctx, cancel := docker.CallContext()
+		images, err := s.cli.ImageList(ctx, types.ImageListOptions{})
+		cancel()
+		if err != nil {
+			i18n.Printf("[x] Failed to list Docker images: %v\n", err)
+			return
+		}
+
+		type row struct {
+			name    string
+			size    int64
+			created int64
+		}
+
+		var rows []row
+		nameWidth := len("IMAGE")
+		for _, img := range images {
+			for _, tag := range img.RepoTags {
+				if tag == "<none>:<none>" {
+					continue
+				}
+				if s.search != "" && !strings.Contains(tag, s.search) {
+					continue
+				}
+				rows = append(rows, row{name: tag, size: img.Size, created: img.Created})
+				if len(tag) > nameWidth {
+					nameWidth = len(tag)
+				}
+			}
+		}
+
+		if len(rows) == 0 {
+			i18n.Println("[x] No tagged Docker images found")
+			return
+		}
+
+		sort.Slice(rows, func(i, j int) bool { return rows[i].name < rows[j].name })
+
+		options := []string{entryParent}
+		for _, r := range rows {
+			options = append(options, fmt.Sprintf("%-*s  %10s  %s", nameWidth, r.name,
+				pan.FormatBytes(r.size), time.Unix(r.created, 0).Format("2006-01-02")))
+		}
+
+		index, ok := s.choose(i18n.Sprintf("Local images (%d):", len(rows)), options)
+		if !ok || index == 0 {
+			return
+		}
+
+		s.imageActions(rows[index-1].name)
+	}
+}
+
+// imageActions runs export or delete on a single local image
+func (s *session) imageActions(imageName string) {
+	actions := []string{actionExportLocal, actionExportCloud, actionDelete, actionBack}
+	index, ok := s.choose(fmt.Sprintf("%s:", imageName), actions)
+	if !ok {
+		return
+	}
+
+	switch actions[index] {
+	case actionExportLocal:
+		destination := config.TempDir()
+		prompt := &survey.Input{
+			Message: i18n.T("Export directory:"),
+			Default: destination,
+		}
+		if err := survey.AskOne(prompt, &destination); err != nil {
+			return
+		}
+		if err := os.MkdirAll(destination, 0755); err != nil {
+			i18n.Printf("[x] Failed to create destination directory %s: %v\n", destination, err)
+			return
+		}
+		if absPath, err := filepath.Abs(destination); err == nil {
+			destination = absPath
+		}
+		if len(docker.ApplyPolicies(s.cli, []string{imageName}, destination)) == 0 {
+			return
+		}
+		docker.ExportImage(s.cli, imageName, destination)
+	case actionExportCloud:
+		s.login()
+		cloudPath := s.cloudDir
+		prompt := &survey.Input{
+			Message: i18n.T("Baidu cloud folder:"),
+			Default: cloudPath,
+		}
+		if err := survey.AskOne(prompt, &cloudPath); err != nil {
+			return
+		}
+		if len(docker.ApplyPolicies(s.cli, []string{imageName}, cloud.Location(cloudPath))) == 0 {
+			return
+		}
+		cloud.ExportImageToCloud(s.cli, imageName, cloudPath, s.bdfsClient)
+	case actionDelete:
+		confirmed := false
+		prompt := &survey.Confirm{
+			Message: i18n.Sprintf("Delete image %s?", imageName),
+		}
+		if err := survey.AskOne(prompt, &confirmed); err != nil || !confirmed {
+			return
+		}
+		docker.DeleteImage(s.cli, imageName)
+	}
+}
+
+// browseCloud shows the cloud tree pane, allowing navigation into folders and importing .tar files
+func (s *session) browseCloud() {
+	s.login()
+
+	for {
+		files, err := s.bdfsClient.ListFiles(s.cloudDir)
+		if err != nil {
+			i18n.Printf("[x] Failed to list cloud folder %s: %v\n", s.cloudDir, err)
+			if s.cloudDir == "/" {
+				return
+			}
+			s.cloudDir = path.Dir(s.cloudDir)
+			continue
+		}
+
+		var dirs, tars []pan.FileInfo
+		nameWidth := len("NAME")
+		for _, file := range files {
+			name := path.Base(file.Path)
+			if file.IsDir == 1 {
+				dirs = append(dirs, file)
+			} else if isArchive(name) && (s.search == "" || strings.Contains(name, s.search)) {
+				tars = append(tars, file)
+			} else {
+				continue
+			}
+			if len(name)+1 > nameWidth {
+				nameWidth = len(name) + 1
+			}
+		}
+
+		sort.Slice(dirs, func(i, j int) bool { return dirs[i].Path < dirs[j].Path })
+		sort.Slice(tars, func(i, j int) bool { return tars[i].Path < tars[j].Path })
+
+		options := []string{entryParent}
+		for _, dir := range dirs {
+			options = append(options, path.Base(dir.Path)+"/")
+		}
+		for _, tar := range tars {
+			options = append(options, fmt.Sprintf("%-*s  %10s  %s", nameWidth, path.Base(tar.Path),
+				pan.FormatBytes(tar.Size), pan.FormatTime(tar.ServerMtime)))
+		}
+
+		index, ok := s.choose(i18n.Sprintf("Cloud folder %s:", s.cloudDir), options)
+		if !ok {
+			return
+		}
+
+		switch {
+		case index == 0:
+			if s.cloudDir == "/" {
+				return
+			}
+			s.cloudDir = path.Dir(s.cloudDir)
+		case index <= len(dirs):
+			s.cloudDir = dirs[index-1].Path
+		default:
+			s.fileActions(tars[index-1-len(dirs)].Path)
+		}
+	}
+}
+
+// fileActions runs import on a single cloud file
+func (s *session) fileActions(cloudFilePath string) {
+	actions := []string{actionImport, actionBack}
+	index, ok := s.choose(fmt.Sprintf("%s:", path.Base(cloudFilePath)), actions)
+	if !ok || actions[index] != actionImport {
+		return
+	}
+
+	cloud.DownloadAndImportFromCloud(s.bdfsClient, cloudFilePath)
+}
+
+// isArchive reports whether the file name has one of the supported image archive extensions
+func isArchive(name string) bool {
+	return docker.IsArchiveName(name) || docker.IsIndexName(name)
+}